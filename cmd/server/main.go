@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"social_server/internal/bus"
 	"social_server/internal/config"
 	"social_server/internal/database"
 	"social_server/internal/middleware"
@@ -13,6 +14,7 @@ import (
 	"social_server/internal/repositories/postgres"
 	"social_server/internal/routes"
 	"social_server/internal/services"
+	"social_server/internal/utils"
 	"syscall"
 	"time"
 
@@ -59,6 +61,9 @@ import (
 // @tag.name Chat
 // @tag.description Real-time chat and messaging functionality
 
+// @tag.name Polls
+// @tag.description Voting in chat and post polls
+
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
@@ -93,6 +98,7 @@ func main() {
 
 	log.Println("Connected to PostgreSQL successfully")
 
+	pinnedMessageRepo := postgres.NewPinnedMessageRepository(db.DB)
 	repos := &repositories.Repositories{
 		User:             postgres.NewUserRepository(db.DB),
 		Friend:           postgres.NewFriendRepository(db.DB),
@@ -101,12 +107,25 @@ func main() {
 		Comment:          postgres.NewCommentRepository(db.DB),
 		Like:             postgres.NewLikeRepository(db.DB),
 		Share:            postgres.NewShareRepository(db.DB),
-		ChatRoom:         postgres.NewChatRoomRepository(db.DB),
+		ChatRoom:         postgres.NewChatRoomRepository(db.DB, pinnedMessageRepo),
 		Message:          postgres.NewMessageRepository(db.DB),
+		ChatRoomEmoji:    postgres.NewChatRoomEmojiRepository(db.DB),
+		ScheduledMessage: postgres.NewScheduledMessageRepository(db.DB),
+		PinnedMessage:    pinnedMessageRepo,
+		Poll:             postgres.NewPollRepository(db.DB),
+		LinkPreview:      postgres.NewLinkPreviewRepository(db.DB),
 		Participant:      postgres.NewParticipantRepository(db.DB),
 		TypingIndicator:  postgres.NewTypingIndicatorRepository(db.DB),
 		OnlineStatus:     postgres.NewOnlineStatusRepository(db.DB),
 		ChatInvite:       postgres.NewChatInviteRepository(db.DB),
+		ChatInviteLink:   postgres.NewChatInviteLinkRepository(db.DB),
+		ChatJoinRequest:  postgres.NewChatJoinRequestRepository(db.DB),
+		ChatRoomBan:      postgres.NewChatRoomBanRepository(db.DB),
+		ChatModeration:   postgres.NewChatModerationLogRepository(db.DB),
+		ChatWebhook:      postgres.NewChatWebhookRepository(db.DB),
+		WebhookDelivery:  postgres.NewWebhookDeliveryRepository(db.DB),
+		ChatExport:       postgres.NewChatExportRepository(db.DB),
+		Channel:          postgres.NewChannelRepository(db.DB),
 		ChatNotification: postgres.NewChatNotificationRepository(db.DB),
 		Auth:             postgres.NewAuthRepository(db.DB),
 		Call:             postgres.NewCallRepository(db.DB),
@@ -119,12 +138,19 @@ func main() {
 		log.Fatalf("Failed to initialize auth service: %v", err)
 	}
 
+	linkPreviewService := services.NewLinkPreviewService(
+		repos.LinkPreview,
+		utils.NewLinkPreviewFetcher(utils.DefaultLinkPreviewConfig()),
+	)
+
 	postService := services.NewPostService(
 		repos.Post,
 		repos.User,
 		repos.Comment,
 		repos.Like,
 		repos.Share,
+		repos.Poll,
+		linkPreviewService,
 	)
 
 	callService := services.NewCallService(
@@ -137,7 +163,13 @@ func main() {
 		log.Fatalf("Failed to initialize search service: %v", err)
 	}
 
-	chatService := services.NewChatService(repos)
+	chatService := services.NewChatService(
+		repos,
+		linkPreviewService,
+		utils.NewWebhookSender(utils.DefaultWebhookConfig()),
+	)
+
+	pollService := services.NewPollService(repos, chatService, postService)
 
 	userService := services.NewUserService(repos.User, &cfg.Auth)
 
@@ -162,6 +194,13 @@ func main() {
 
 	log.Println("Services initialized successfully")
 
+	// Connect to the other nodes, websocket fan-out goes through the bus
+	cluster, err := bus.NewCluster(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize realtime bus: %v", err)
+	}
+	log.Printf("Realtime node %s using %s bus", cluster.NodeID, cfg.Realtime.Bus)
+
 	// Configure middleware factory
 	middlewareConfig := &middleware.MiddlewareConfig{
 		MaxSessionsPerUser:  5,
@@ -190,10 +229,12 @@ func main() {
 		friendService,
 		postService,
 		chatService,
+		pollService,
 		searchService,
 		callService,
 		mailService,
 		onlineStatusService,
+		cluster,
 	)
 	engine := router.SetupRoutes()
 
@@ -229,6 +270,12 @@ func main() {
 	ctx, cancel = context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
+	// Tell realtime clients to reconnect elsewhere, websockets are not
+	// closed by the server shutdown
+	if err := router.Drain(ctx); err != nil {
+		log.Printf("Realtime clients forced to disconnect: %v", err)
+	}
+
 	// Shutdown server
 	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("Server forced to shutdown: %v", err)
@@ -237,5 +284,13 @@ func main() {
 	// Cleanup online status service
 	onlineStatusService.Stop()
 
+	// Stop chat background jobs
+	chatService.Stop()
+
+	// Leave the realtime bus
+	if err := cluster.Close(); err != nil {
+		log.Printf("Failed to close realtime bus: %v", err)
+	}
+
 	log.Println("Server shutdown completed")
 }
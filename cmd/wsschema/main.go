@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"social_server/internal/handlers"
+)
+
+// Writes the JSON Schema of the websocket protocol, e.g.
+// go run ./cmd/wsschema -o docs/websocket.schema.json
+func main() {
+	output := flag.String("o", "", "File to write the schema to, stdout when empty")
+	flag.Parse()
+
+	schema, err := json.MarshalIndent(handlers.ProtocolSchema(), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal schema: %v", err)
+	}
+	schema = append(schema, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*output, schema, 0644); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+	log.Printf("Wrote websocket schema to %s", *output)
+}
@@ -0,0 +1,60 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Handler receives the payloads published on a topic
+type Handler func(payload []byte)
+
+// Subscription stops the delivery of a topic to its handler
+type Subscription interface {
+	Close() error
+}
+
+// MessageBus carries messages between the nodes of the server. Every
+// subscriber of a topic gets each payload, including the node that
+// published it.
+type MessageBus interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	Subscribe(topic string, handler Handler) (Subscription, error)
+	Close() error
+}
+
+// PresenceRegistry records which nodes hold the connections of a user
+type PresenceRegistry interface {
+	// Connect counts a device of the user connected to the node
+	Connect(ctx context.Context, userID uint, nodeID string) error
+	// Disconnect uncounts a device, the node is dropped with its last one
+	Disconnect(ctx context.Context, userID uint, nodeID string) error
+	// Nodes returns the live nodes holding each of the users
+	Nodes(ctx context.Context, userIDs []uint) (map[uint][]string, error)
+	// Heartbeat keeps the node alive, its users are ignored once it stops
+	Heartbeat(ctx context.Context, nodeID string) error
+	// Reset forgets what a previous run of the node left behind
+	Reset(ctx context.Context, nodeID string) error
+}
+
+// SessionStore keeps the sessions a node handed off, a client resuming on
+// another node picks its session up there
+type SessionStore interface {
+	// Save keeps the state of a session for ttl
+	Save(ctx context.Context, token string, state []byte, ttl time.Duration) error
+	// Take returns the state of a session and forgets it, ErrSessionNotFound
+	// when there is none
+	Take(ctx context.Context, token string) ([]byte, error)
+}
+
+// NodeTopic is the topic of the messages for the users of one node
+func NodeTopic(nodeID string) string {
+	return fmt.Sprintf("ws:node:%s", nodeID)
+}
+
+// BroadcastTopic is the topic every node listens to, for rooms, channels and
+// call state that are not tied to known nodes
+const BroadcastTopic = "ws:broadcast"
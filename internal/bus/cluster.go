@@ -0,0 +1,60 @@
+package bus
+
+import (
+	"fmt"
+	"social_server/internal/config"
+	"time"
+)
+
+// Cluster is how a node reaches the other nodes of the server
+type Cluster struct {
+	NodeID            string
+	Bus               MessageBus
+	Presence          PresenceRegistry
+	Sessions          SessionStore
+	HeartbeatInterval time.Duration
+}
+
+// NewCluster sets up the bus of the configuration, the memory bus keeps a
+// single node working without Redis
+func NewCluster(cfg *config.Config) (*Cluster, error) {
+	cluster := &Cluster{
+		NodeID:            cfg.Realtime.NodeID,
+		HeartbeatInterval: cfg.Realtime.PresenceTTL / 3,
+	}
+
+	switch cfg.Realtime.Bus {
+	case "redis":
+		client, err := NewRedisClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cluster.Bus = NewRedisBus(client)
+		cluster.Presence = NewRedisPresence(client, cfg.Realtime.PresenceTTL)
+		cluster.Sessions = NewRedisSessionStore(client)
+	case "memory":
+		cluster.Bus = NewMemoryBus()
+		cluster.Presence = NewMemoryPresence()
+		cluster.Sessions = NewMemorySessionStore()
+	default:
+		return nil, fmt.Errorf("unknown realtime bus %q", cfg.Realtime.Bus)
+	}
+
+	return cluster, nil
+}
+
+// NewMemoryCluster is a node on a shared memory bus, for tests and tools that
+// run several nodes in one process
+func NewMemoryCluster(nodeID string, messageBus *MemoryBus, presence *MemoryPresence, sessions *MemorySessionStore) *Cluster {
+	return &Cluster{
+		NodeID:            nodeID,
+		Bus:               messageBus,
+		Presence:          presence,
+		Sessions:          sessions,
+		HeartbeatInterval: 10 * time.Second,
+	}
+}
+
+func (c *Cluster) Close() error {
+	return c.Bus.Close()
+}
@@ -0,0 +1,275 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrBusClosed = errors.New("message bus is closed")
+
+// MemoryBus is a message bus within one process. Several handlers sharing it
+// behave like nodes of a cluster, which is enough for a single node and tests.
+type MemoryBus struct {
+	topics map[string]map[*memorySubscription]bool
+	closed bool
+	mutex  sync.RWMutex
+}
+
+// memorySubscription queues payloads for its handler. The queue grows rather
+// than blocking the publisher, a handler may publish to its own topic.
+type memorySubscription struct {
+	bus    *MemoryBus
+	topic  string
+	queue  [][]byte
+	ready  chan struct{}
+	closed bool
+	mutex  sync.Mutex
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		topics: make(map[string]map[*memorySubscription]bool),
+	}
+}
+
+// Publish hands the payload to the subscribers in order, handlers run on
+// their own goroutine like they would with a remote bus
+func (b *MemoryBus) Publish(ctx context.Context, topic string, payload []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mutex.RLock()
+	if b.closed {
+		b.mutex.RUnlock()
+		return ErrBusClosed
+	}
+	subs := make([]*memorySubscription, 0, len(b.topics[topic]))
+	for sub := range b.topics[topic] {
+		subs = append(subs, sub)
+	}
+	b.mutex.RUnlock()
+
+	for _, sub := range subs {
+		sub.push(payload)
+	}
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(topic string, handler Handler) (Subscription, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+
+	sub := &memorySubscription{
+		bus:   b,
+		topic: topic,
+		ready: make(chan struct{}, 1),
+	}
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*memorySubscription]bool)
+	}
+	b.topics[topic][sub] = true
+
+	go sub.run(handler)
+
+	return sub, nil
+}
+
+func (b *MemoryBus) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	for _, subs := range b.topics {
+		for sub := range subs {
+			sub.stop()
+		}
+	}
+	b.topics = make(map[string]map[*memorySubscription]bool)
+	return nil
+}
+
+func (s *memorySubscription) Close() error {
+	s.bus.mutex.Lock()
+	delete(s.bus.topics[s.topic], s)
+	if len(s.bus.topics[s.topic]) == 0 {
+		delete(s.bus.topics, s.topic)
+	}
+	s.bus.mutex.Unlock()
+
+	s.stop()
+	return nil
+}
+
+func (s *memorySubscription) push(payload []byte) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.queue = append(s.queue, payload)
+	s.mutex.Unlock()
+	s.wake()
+}
+
+// stop lets the handler finish the payloads already queued
+func (s *memorySubscription) stop() {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+	s.wake()
+}
+
+func (s *memorySubscription) wake() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *memorySubscription) run(handler Handler) {
+	for {
+		s.mutex.Lock()
+		payloads, closed := s.queue, s.closed
+		s.queue = nil
+		s.mutex.Unlock()
+
+		for _, payload := range payloads {
+			handler(payload)
+		}
+		if len(payloads) > 0 {
+			continue
+		}
+		if closed {
+			return
+		}
+		<-s.ready
+	}
+}
+
+// MemoryPresence is a presence registry within one process, nodes never
+// expire since they all live as long as it does
+type MemoryPresence struct {
+	users map[uint]map[string]int // userID -> nodeID -> devices
+	mutex sync.RWMutex
+}
+
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{
+		users: make(map[uint]map[string]int),
+	}
+}
+
+func (p *MemoryPresence) Connect(ctx context.Context, userID uint, nodeID string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.users[userID] == nil {
+		p.users[userID] = make(map[string]int)
+	}
+	p.users[userID][nodeID]++
+	return nil
+}
+
+func (p *MemoryPresence) Disconnect(ctx context.Context, userID uint, nodeID string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	nodes := p.users[userID]
+	if nodes == nil {
+		return nil
+	}
+
+	nodes[nodeID]--
+	if nodes[nodeID] <= 0 {
+		delete(nodes, nodeID)
+	}
+	if len(nodes) == 0 {
+		delete(p.users, userID)
+	}
+	return nil
+}
+
+func (p *MemoryPresence) Nodes(ctx context.Context, userIDs []uint) (map[uint][]string, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	result := make(map[uint][]string, len(userIDs))
+	for _, userID := range userIDs {
+		for nodeID := range p.users[userID] {
+			result[userID] = append(result[userID], nodeID)
+		}
+	}
+	return result, nil
+}
+
+func (p *MemoryPresence) Heartbeat(ctx context.Context, nodeID string) error {
+	return nil
+}
+
+func (p *MemoryPresence) Reset(ctx context.Context, nodeID string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for userID, nodes := range p.users {
+		delete(nodes, nodeID)
+		if len(nodes) == 0 {
+			delete(p.users, userID)
+		}
+	}
+	return nil
+}
+
+// MemorySessionStore is a session store within one process
+type MemorySessionStore struct {
+	sessions map[string]memorySession
+	mutex    sync.Mutex
+}
+
+type memorySession struct {
+	state     []byte
+	expiresAt time.Time
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]memorySession),
+	}
+}
+
+func (s *MemorySessionStore) Save(ctx context.Context, token string, state []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Expired sessions are dropped as others are saved
+	now := time.Now()
+	for key, session := range s.sessions {
+		if now.After(session.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[token] = memorySession{state: state, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemorySessionStore) Take(ctx context.Context, token string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[token]
+	delete(s.sessions, token)
+	if !exists || time.Now().After(session.expiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	return session.state, nil
+}
@@ -0,0 +1,165 @@
+package bus
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryBusDeliversInOrder(t *testing.T) {
+	messageBus := NewMemoryBus()
+	defer messageBus.Close()
+
+	received := make(chan string, 3)
+	if _, err := messageBus.Subscribe("topic", func(payload []byte) {
+		received <- string(payload)
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	for _, payload := range []string{"a", "b", "c"} {
+		if err := messageBus.Publish(context.Background(), "topic", []byte(payload)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("received %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q was not delivered", want)
+		}
+	}
+}
+
+func TestMemoryBusPublishToOwnTopic(t *testing.T) {
+	messageBus := NewMemoryBus()
+	defer messageBus.Close()
+
+	// Far more than a subscriber used to buffer, published by the handler
+	// of the topic itself
+	const count = 2000
+	var handled atomic.Int32
+	done := make(chan struct{})
+	if _, err := messageBus.Subscribe("loop", func(payload []byte) {
+		if string(payload) == "start" {
+			for i := 0; i < count; i++ {
+				if err := messageBus.Publish(context.Background(), "loop", []byte("echo")); err != nil {
+					t.Errorf("Publish() error = %v", err)
+				}
+			}
+			return
+		}
+		if handled.Add(1) == count {
+			close(done)
+		}
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	if err := messageBus.Publish(context.Background(), "loop", []byte("start")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handled %d of %d payloads published to its own topic", handled.Load(), count)
+	}
+}
+
+func TestMemoryBusSlowSubscriberDoesNotBlock(t *testing.T) {
+	messageBus := NewMemoryBus()
+	defer messageBus.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := messageBus.Subscribe("slow", func(payload []byte) {
+		<-release
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 1000; i++ {
+			messageBus.Publish(context.Background(), "slow", []byte("payload"))
+		}
+		// Subscribing needs the lock Publish used to hold while blocked
+		sub, err := messageBus.Subscribe("other", func([]byte) {})
+		if err == nil {
+			sub.Close()
+		}
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish() blocked on a slow subscriber")
+	}
+}
+
+func TestMemoryBusClose(t *testing.T) {
+	messageBus := NewMemoryBus()
+
+	var handled atomic.Int32
+	sub, err := messageBus.Subscribe("topic", func([]byte) { handled.Add(1) })
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	sub.Close()
+	sub.Close()
+	if err := messageBus.Publish(context.Background(), "topic", []byte("late")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	messageBus.Close()
+	if err := messageBus.Publish(context.Background(), "topic", nil); err != ErrBusClosed {
+		t.Errorf("Publish() after Close error = %v, want %v", err, ErrBusClosed)
+	}
+	if _, err := messageBus.Subscribe("topic", func([]byte) {}); err != ErrBusClosed {
+		t.Errorf("Subscribe() after Close error = %v, want %v", err, ErrBusClosed)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if n := handled.Load(); n != 0 {
+		t.Errorf("closed subscription handled %d payloads", n)
+	}
+}
+
+func TestMemoryPresence(t *testing.T) {
+	presence := NewMemoryPresence()
+	ctx := context.Background()
+
+	presence.Connect(ctx, 1, "a")
+	presence.Connect(ctx, 1, "a")
+	presence.Connect(ctx, 1, "b")
+	presence.Connect(ctx, 2, "b")
+
+	nodes, _ := presence.Nodes(ctx, []uint{1, 2, 3})
+	sort.Strings(nodes[1])
+	if len(nodes[1]) != 2 || nodes[1][0] != "a" || nodes[1][1] != "b" {
+		t.Errorf("Nodes(1) = %v, want [a b]", nodes[1])
+	}
+	if _, exists := nodes[3]; exists {
+		t.Errorf("Nodes(3) = %v, want none", nodes[3])
+	}
+
+	// Node a still has a device of user 1 after one of two disconnects
+	presence.Disconnect(ctx, 1, "a")
+	nodes, _ = presence.Nodes(ctx, []uint{1})
+	if len(nodes[1]) != 2 {
+		t.Errorf("Nodes(1) = %v after one disconnect, want both nodes", nodes[1])
+	}
+
+	// A node starting again forgets the users it had
+	presence.Reset(ctx, "b")
+	nodes, _ = presence.Nodes(ctx, []uint{1, 2})
+	if len(nodes[1]) != 1 || nodes[1][0] != "a" || len(nodes[2]) != 0 {
+		t.Errorf("Nodes() = %v after reset of b, want user 1 on a only", nodes)
+	}
+}
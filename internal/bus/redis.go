@@ -0,0 +1,233 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"social_server/internal/config"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedisClient connects to the Redis server of the configuration
+func NewRedisClient(cfg *config.Config) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:            cfg.GetRedisAddress(),
+		Password:        cfg.Redis.Password,
+		DB:              cfg.Redis.Database,
+		MaxRetries:      cfg.Redis.MaxRetries,
+		MinRetryBackoff: cfg.Redis.RetryDelay,
+		PoolSize:        cfg.Redis.PoolSize,
+		MinIdleConns:    cfg.Redis.MinIdleConns,
+		ConnMaxLifetime: cfg.Redis.MaxConnAge,
+		PoolTimeout:     cfg.Redis.PoolTimeout,
+		ConnMaxIdleTime: cfg.Redis.IdleTimeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return client, nil
+}
+
+// RedisBus is a message bus over Redis pub/sub, payloads published while a
+// node is disconnected from Redis are lost for it
+type RedisBus struct {
+	client *redis.Client
+}
+
+type redisSubscription struct {
+	pubsub *redis.PubSub
+}
+
+func NewRedisBus(client *redis.Client) *RedisBus {
+	return &RedisBus{client: client}
+}
+
+func (b *RedisBus) Publish(ctx context.Context, topic string, payload []byte) error {
+	if err := b.client.Publish(ctx, topic, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe listens to a topic until the subscription is closed, go-redis
+// resubscribes by itself after a lost connection
+func (b *RedisBus) Subscribe(topic string, handler Handler) (Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pubsub := b.client.Subscribe(ctx, topic)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+
+	go func() {
+		for message := range pubsub.Channel() {
+			handler([]byte(message.Payload))
+		}
+	}()
+
+	return &redisSubscription{pubsub: pubsub}, nil
+}
+
+func (b *RedisBus) Close() error {
+	return b.client.Close()
+}
+
+func (s *redisSubscription) Close() error {
+	return s.pubsub.Close()
+}
+
+// RedisPresence keeps the devices of each user per node in a hash, and a key
+// per node that expires when the node stops sending heartbeats
+type RedisPresence struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisPresence(client *redis.Client, ttl time.Duration) *RedisPresence {
+	return &RedisPresence{client: client, ttl: ttl}
+}
+
+func presenceUserKey(userID uint) string {
+	return fmt.Sprintf("ws:presence:user:%d", userID)
+}
+
+func presenceNodeUsersKey(nodeID string) string {
+	return fmt.Sprintf("ws:presence:node:%s:users", nodeID)
+}
+
+func presenceNodeAliveKey(nodeID string) string {
+	return fmt.Sprintf("ws:presence:node:%s:alive", nodeID)
+}
+
+func (p *RedisPresence) Connect(ctx context.Context, userID uint, nodeID string) error {
+	pipe := p.client.TxPipeline()
+	pipe.HIncrBy(ctx, presenceUserKey(userID), nodeID, 1)
+	pipe.SAdd(ctx, presenceNodeUsersKey(nodeID), userID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to register presence of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// disconnectScript drops the node from the user once its last device is gone
+var disconnectScript = redis.NewScript(`
+local devices = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+if devices <= 0 then
+	redis.call("HDEL", KEYS[1], ARGV[1])
+	redis.call("SREM", KEYS[2], ARGV[2])
+end
+return devices
+`)
+
+func (p *RedisPresence) Disconnect(ctx context.Context, userID uint, nodeID string) error {
+	keys := []string{presenceUserKey(userID), presenceNodeUsersKey(nodeID)}
+	if err := disconnectScript.Run(ctx, p.client, keys, nodeID, userID).Err(); err != nil {
+		return fmt.Errorf("failed to remove presence of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// Nodes skips nodes whose heartbeat expired, they crashed without cleaning up
+func (p *RedisPresence) Nodes(ctx context.Context, userIDs []uint) (map[uint][]string, error) {
+	result := make(map[uint][]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	pipe := p.client.Pipeline()
+	lookups := make([]*redis.MapStringStringCmd, len(userIDs))
+	for i, userID := range userIDs {
+		lookups[i] = pipe.HGetAll(ctx, presenceUserKey(userID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get presence: %w", err)
+	}
+
+	alive := make(map[string]bool)
+	for i, lookup := range lookups {
+		for nodeID := range lookup.Val() {
+			if _, checked := alive[nodeID]; !checked {
+				exists, err := p.client.Exists(ctx, presenceNodeAliveKey(nodeID)).Result()
+				if err != nil {
+					return nil, fmt.Errorf("failed to check node %s: %w", nodeID, err)
+				}
+				alive[nodeID] = exists > 0
+			}
+			if alive[nodeID] {
+				result[userIDs[i]] = append(result[userIDs[i]], nodeID)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (p *RedisPresence) Heartbeat(ctx context.Context, nodeID string) error {
+	if err := p.client.Set(ctx, presenceNodeAliveKey(nodeID), time.Now().Unix(), p.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to send heartbeat of node %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+func (p *RedisPresence) Reset(ctx context.Context, nodeID string) error {
+	userIDs, err := p.client.SMembers(ctx, presenceNodeUsersKey(nodeID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get users of node %s: %w", nodeID, err)
+	}
+
+	pipe := p.client.TxPipeline()
+	for _, member := range userIDs {
+		userID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			log.Printf("Invalid user %q in presence of node %s", member, nodeID)
+			continue
+		}
+		pipe.HDel(ctx, presenceUserKey(uint(userID)), nodeID)
+	}
+	pipe.Del(ctx, presenceNodeUsersKey(nodeID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to reset presence of node %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+// RedisSessionStore keeps handed off sessions in Redis until they expire
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+func NewRedisSessionStore(client *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{client: client}
+}
+
+func sessionKey(token string) string {
+	return fmt.Sprintf("ws:session:%s", token)
+}
+
+func (s *RedisSessionStore) Save(ctx context.Context, token string, state []byte, ttl time.Duration) error {
+	if err := s.client.Set(ctx, sessionKey(token), state, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) Take(ctx context.Context, token string) ([]byte, error) {
+	state, err := s.client.GetDel(ctx, sessionKey(token)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take session: %w", err)
+	}
+	return state, nil
+}
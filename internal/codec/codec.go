@@ -0,0 +1,63 @@
+package codec
+
+import (
+	"encoding/json"
+	"social_server/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Encodings of websocket messages, negotiated per connection. JSON goes in
+// text frames, the others in binary frames.
+const (
+	EncodingJSON        = "json"
+	EncodingMessagePack = "msgpack"
+	EncodingProtobuf    = "protobuf"
+)
+
+// Codec encodes the envelope of websocket messages. Whatever the encoding,
+// handlers keep seeing the data of a message as JSON.
+type Codec interface {
+	Name() string
+	// FrameType is the websocket frame the messages are sent in
+	FrameType() int
+	Encode(message *models.WSMessage) ([]byte, error)
+	Decode(frame []byte, message *models.WSMessage) error
+}
+
+var codecs = map[string]Codec{
+	EncodingJSON:        JSON{},
+	EncodingMessagePack: MessagePack{},
+	EncodingProtobuf:    Protobuf{},
+}
+
+// Encodings lists the supported encodings, the default first
+var Encodings = []string{EncodingJSON, EncodingMessagePack, EncodingProtobuf}
+
+// Lookup returns the codec of an encoding, JSON when it is empty
+func Lookup(encoding string) (Codec, bool) {
+	if encoding == "" {
+		encoding = EncodingJSON
+	}
+	codec, exists := codecs[encoding]
+	return codec, exists
+}
+
+// JSON sends messages as they are described by the protocol schema
+type JSON struct{}
+
+func (JSON) Name() string {
+	return EncodingJSON
+}
+
+func (JSON) FrameType() int {
+	return websocket.TextMessage
+}
+
+func (JSON) Encode(message *models.WSMessage) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (JSON) Decode(frame []byte, message *models.WSMessage) error {
+	return json.Unmarshal(frame, message)
+}
@@ -0,0 +1,296 @@
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type sample struct {
+	name    string
+	message models.WSMessage
+}
+
+// sampleMessages are typical chat and signaling messages
+func sampleMessages(t testing.TB) []sample {
+	t.Helper()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	replyTo := uint(41)
+
+	payloads := []struct {
+		name        string
+		messageType models.MessageType
+		data        interface{}
+	}{
+		{"chat_message", models.MessageTypeChatReceiveMessage, postgres.Message{
+			ID:             42,
+			LocalID:        7,
+			ChatRoomID:     3,
+			SenderID:       12,
+			Type:           postgres.MessageTypeText,
+			Content:        "Are we still on for dinner tonight? I booked the place near the station for 8pm",
+			ReplyToID:      &replyTo,
+			DeliveryStatus: `{"13":"delivered","14":"read"}`,
+			Mentions:       "[]",
+			Tags:           "[]",
+		}},
+		{"ice_candidate", models.MessageTypeICECandidate, models.ICECandidateMessage{
+			Candidate:     "candidate:842163049 1 udp 1677729535 203.0.113.7 56143 typ srflx raddr 192.168.1.20 rport 56143 generation 0 ufrag sX2b network-cost 999",
+			SDPMLineIndex: 0,
+			SDPMid:        "0",
+			CallID:        88,
+		}},
+		{"offer", models.MessageTypeOffer, models.OfferMessage{
+			SDP:    sampleSDP,
+			CallID: 88,
+			Type:   "video",
+		}},
+		{"ack", models.MessageTypeAck, models.AckMessage{Seq: 1024}},
+	}
+
+	samples := make([]sample, len(payloads))
+	for i, payload := range payloads {
+		data, err := json.Marshal(payload.data)
+		if err != nil {
+			t.Fatalf("Failed to build sample %s: %v", payload.name, err)
+		}
+		samples[i] = sample{
+			name: payload.name,
+			message: models.WSMessage{
+				Type:      payload.messageType,
+				From:      12,
+				To:        13,
+				Data:      data,
+				Timestamp: now.Format(time.RFC3339),
+				Seq:       512,
+				RequestID: "req-5f2c",
+			},
+		}
+	}
+	return samples
+}
+
+// assertSameMessage compares envelopes, and their data as JSON values
+func assertSameMessage(t *testing.T, got, want models.WSMessage) {
+	t.Helper()
+
+	gotData, wantData := got.Data, want.Data
+	got.Data, want.Data = nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envelope = %+v, want %+v", got, want)
+	}
+
+	gotValue, err := decodeJSONValue(gotData)
+	if err != nil {
+		t.Fatalf("decoded data %s is not JSON: %v", gotData, err)
+	}
+	wantValue, err := decodeJSONValue(wantData)
+	if err != nil {
+		t.Fatalf("data %s is not JSON: %v", wantData, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("data = %s, want %s", gotData, wantData)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, sample := range sampleMessages(t) {
+		for _, encoding := range Encodings {
+			t.Run(sample.name+"/"+encoding, func(t *testing.T) {
+				messageCodec, _ := Lookup(encoding)
+
+				frame, err := messageCodec.Encode(&sample.message)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				var decoded models.WSMessage
+				if err := messageCodec.Decode(frame, &decoded); err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				assertSameMessage(t, decoded, sample.message)
+			})
+		}
+	}
+}
+
+func TestRoundTripKeepsIntegers(t *testing.T) {
+	data := `{"id":9007199254740993,"negative":-9007199254740993,"max":18446744073709551615,` +
+		`"ratio":0.25,"big":1e300,"text":"x","flag":false,"none":null,"empty":{},` +
+		`"list":[1,{"nested":[true,"2"]},[]]}`
+	message := models.WSMessage{Type: models.MessageTypeChatReceiveMessage, Data: json.RawMessage(data)}
+
+	for _, encoding := range Encodings {
+		t.Run(encoding, func(t *testing.T) {
+			messageCodec, _ := Lookup(encoding)
+
+			frame, err := messageCodec.Encode(&message)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			var decoded models.WSMessage
+			if err := messageCodec.Decode(frame, &decoded); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			assertSameMessage(t, decoded, message)
+
+			for _, integer := range []string{"9007199254740993", "-9007199254740993", "18446744073709551615"} {
+				if !strings.Contains(string(decoded.Data), integer) {
+					t.Errorf("data %s lost %s", decoded.Data, integer)
+				}
+			}
+		})
+	}
+}
+
+func TestProtobufReadsStructValues(t *testing.T) {
+	// Clients built against google.protobuf.Value send doubles
+	var data structpb.Value
+	if err := protojson.Unmarshal([]byte(`{"call_id":88,"sdp":"v=0","tracks":[1.5,null,true]}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	dataBytes, err := proto.Marshal(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := protowire.AppendTag(nil, fieldType, protowire.BytesType)
+	frame = protowire.AppendString(frame, string(models.MessageTypeOffer))
+	frame = protowire.AppendTag(frame, fieldData, protowire.BytesType)
+	frame = protowire.AppendBytes(frame, dataBytes)
+
+	var decoded models.WSMessage
+	if err := (Protobuf{}).Decode(frame, &decoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	assertSameMessage(t, decoded, models.WSMessage{
+		Type: models.MessageTypeOffer,
+		Data: json.RawMessage(`{"call_id":88,"sdp":"v=0","tracks":[1.5,null,true]}`),
+	})
+}
+
+func TestProtobufRejectsInvalidData(t *testing.T) {
+	nested := []byte{}
+	for i := 0; i <= maxValueDepth+1; i++ {
+		list := protowire.AppendTag(nil, listValues, protowire.BytesType)
+		list = protowire.AppendBytes(list, nested)
+		nested = protowire.AppendTag(nil, valueList, protowire.BytesType)
+		nested = protowire.AppendBytes(nested, list)
+	}
+
+	tests := map[string][]byte{
+		"too deep":  nested,
+		"truncated": append(protowire.AppendTag(nil, valueString, protowire.BytesType), 10, 'a'),
+		"nan": protowire.AppendFixed64(protowire.AppendTag(nil, valueNumber, protowire.Fixed64Type),
+			0x7ff8000000000001),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			frame := protowire.AppendTag(nil, fieldData, protowire.BytesType)
+			frame = protowire.AppendBytes(frame, data)
+
+			var decoded models.WSMessage
+			if err := (Protobuf{}).Decode(frame, &decoded); err == nil {
+				t.Errorf("Decode() = %s, want an error", decoded.Data)
+			}
+		})
+	}
+}
+
+// Compares the cost of the encodings, the frame sizes are reported as
+// metrics: go test ./internal/codec -bench . -benchmem
+func BenchmarkEncode(b *testing.B) {
+	for _, sample := range sampleMessages(b) {
+		for _, encoding := range Encodings {
+			messageCodec, _ := Lookup(encoding)
+			b.Run(sample.name+"/"+encoding, func(b *testing.B) {
+				frame, err := messageCodec.Encode(&sample.message)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := messageCodec.Encode(&sample.message); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(frame)), "bytes")
+				b.ReportMetric(float64(deflatedSize(frame)), "deflated")
+			})
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, sample := range sampleMessages(b) {
+		for _, encoding := range Encodings {
+			messageCodec, _ := Lookup(encoding)
+			b.Run(sample.name+"/"+encoding, func(b *testing.B) {
+				frame, err := messageCodec.Encode(&sample.message)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				var message models.WSMessage
+				for i := 0; i < b.N; i++ {
+					if err := messageCodec.Decode(frame, &message); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// deflatedSize is about what permessage-deflate puts on the wire
+func deflatedSize(frame []byte) int {
+	var buffer bytes.Buffer
+	writer, _ := flate.NewWriter(&buffer, flate.BestSpeed)
+	writer.Write(frame)
+	writer.Flush()
+	return buffer.Len()
+}
+
+const sampleSDP = `v=0
+o=- 4611731400430051336 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=msid-semantic: WMS stream
+m=audio 9 UDP/TLS/RTP/SAVPF 111 63 9 0 8 13 110 126
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:sX2b
+a=ice-pwd:Ie3rbHk9Q2X0c5mC1Yb7hLq4
+a=fingerprint:sha-256 7B:8B:F0:65:5F:78:E2:51:3B:AC:6F:F3:3F:46:1B:35:DC:B8:5F:64:1A:24:C2:43:F0:A1:58:D0:A1:2C:19:08
+a=setup:actpass
+a=mid:0
+a=sendrecv
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=fmtp:111 minptime=10;useinbandfec=1
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 102 103
+c=IN IP4 0.0.0.0
+a=mid:1
+a=sendrecv
+a=rtcp-mux
+a=rtpmap:96 VP8/90000
+a=rtpmap:102 H264/90000
+a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f
+`
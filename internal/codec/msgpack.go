@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"social_server/internal/models"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// msgpackHandle decodes maps with string keys and strings as text, so the
+// data converts back to JSON
+var msgpackHandle = func() *codec.MsgpackHandle {
+	handle := &codec.MsgpackHandle{WriteExt: true}
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	handle.RawToString = true
+	return handle
+}()
+
+// msgpackEnvelope is WSMessage with its data as a native MessagePack value
+type msgpackEnvelope struct {
+	Type      string      `codec:"type"`
+	From      uint        `codec:"from,omitempty"`
+	To        uint        `codec:"to,omitempty"`
+	RoomID    string      `codec:"room_id,omitempty"`
+	Data      interface{} `codec:"data,omitempty"`
+	Timestamp string      `codec:"timestamp"`
+	Seq       uint64      `codec:"seq,omitempty"`
+	RequestID string      `codec:"request_id,omitempty"`
+}
+
+// MessagePack sends the fields of the JSON encoding as a MessagePack map
+type MessagePack struct{}
+
+func (MessagePack) Name() string {
+	return EncodingMessagePack
+}
+
+func (MessagePack) FrameType() int {
+	return websocket.BinaryMessage
+}
+
+func (MessagePack) Encode(message *models.WSMessage) ([]byte, error) {
+	envelope := msgpackEnvelope{
+		Type:      string(message.Type),
+		From:      message.From,
+		To:        message.To,
+		RoomID:    message.RoomID,
+		Timestamp: message.Timestamp,
+		Seq:       message.Seq,
+		RequestID: message.RequestID,
+	}
+	if len(message.Data) > 0 {
+		data, err := decodeJSONValue(message.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message data: %w", err)
+		}
+		envelope.Data = data
+	}
+
+	var frame []byte
+	if err := codec.NewEncoderBytes(&frame, msgpackHandle).Encode(envelope); err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return frame, nil
+}
+
+func (MessagePack) Decode(frame []byte, message *models.WSMessage) error {
+	var envelope msgpackEnvelope
+	if err := codec.NewDecoderBytes(frame, msgpackHandle).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	*message = models.WSMessage{
+		Type:      models.MessageType(envelope.Type),
+		From:      envelope.From,
+		To:        envelope.To,
+		RoomID:    envelope.RoomID,
+		Timestamp: envelope.Timestamp,
+		Seq:       envelope.Seq,
+		RequestID: envelope.RequestID,
+	}
+	if envelope.Data != nil {
+		data, err := json.Marshal(envelope.Data)
+		if err != nil {
+			return fmt.Errorf("failed to convert message data: %w", err)
+		}
+		message.Data = data
+	}
+	return nil
+}
+
+// decodeJSONValue reads JSON into maps, slices and scalars, keeping integers
+// as integers so ids do not turn into floats
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return nativeNumbers(value), nil
+}
+
+func nativeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = nativeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = nativeNumbers(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,144 @@
+package codec
+
+import (
+	"fmt"
+	"social_server/internal/models"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the Envelope message in proto/websocket.proto
+const (
+	fieldType      protowire.Number = 1
+	fieldData      protowire.Number = 2
+	fieldFrom      protowire.Number = 3
+	fieldTo        protowire.Number = 4
+	fieldRoomID    protowire.Number = 5
+	fieldTimestamp protowire.Number = 6
+	fieldSeq       protowire.Number = 7
+	fieldRequestID protowire.Number = 8
+)
+
+// Protobuf sends the Envelope message of proto/websocket.proto, the data is a
+// Value, google.protobuf.Value with integer fields. Both are small enough to
+// be written by hand rather than generated.
+type Protobuf struct{}
+
+func (Protobuf) Name() string {
+	return EncodingProtobuf
+}
+
+func (Protobuf) FrameType() int {
+	return websocket.BinaryMessage
+}
+
+func (Protobuf) Encode(message *models.WSMessage) ([]byte, error) {
+	var frame []byte
+	frame = appendString(frame, fieldType, string(message.Type))
+	if len(message.Data) > 0 {
+		data, err := decodeJSONValue(message.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message data: %w", err)
+		}
+		dataBytes, err := appendValue(nil, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode message data: %w", err)
+		}
+		frame = protowire.AppendTag(frame, fieldData, protowire.BytesType)
+		frame = protowire.AppendBytes(frame, dataBytes)
+	}
+	frame = appendVarint(frame, fieldFrom, uint64(message.From))
+	frame = appendVarint(frame, fieldTo, uint64(message.To))
+	frame = appendString(frame, fieldRoomID, message.RoomID)
+	frame = appendString(frame, fieldTimestamp, message.Timestamp)
+	frame = appendVarint(frame, fieldSeq, message.Seq)
+	frame = appendString(frame, fieldRequestID, message.RequestID)
+	return frame, nil
+}
+
+func (Protobuf) Decode(frame []byte, message *models.WSMessage) error {
+	*message = models.WSMessage{}
+	for len(frame) > 0 {
+		number, wireType, n := protowire.ConsumeTag(frame)
+		if n < 0 {
+			return fmt.Errorf("failed to decode message: %w", protowire.ParseError(n))
+		}
+		frame = frame[n:]
+
+		switch wireType {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(frame)
+			if n < 0 {
+				return fmt.Errorf("failed to decode message: %w", protowire.ParseError(n))
+			}
+			frame = frame[n:]
+			if err := setBytesField(message, number, value); err != nil {
+				return err
+			}
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(frame)
+			if n < 0 {
+				return fmt.Errorf("failed to decode message: %w", protowire.ParseError(n))
+			}
+			frame = frame[n:]
+			setVarintField(message, number, value)
+		default:
+			// Unknown fields are skipped, like generated code does
+			n := protowire.ConsumeFieldValue(number, wireType, frame)
+			if n < 0 {
+				return fmt.Errorf("failed to decode message: %w", protowire.ParseError(n))
+			}
+			frame = frame[n:]
+		}
+	}
+	return nil
+}
+
+func setBytesField(message *models.WSMessage, number protowire.Number, value []byte) error {
+	switch number {
+	case fieldType:
+		message.Type = models.MessageType(value)
+	case fieldRoomID:
+		message.RoomID = string(value)
+	case fieldTimestamp:
+		message.Timestamp = string(value)
+	case fieldRequestID:
+		message.RequestID = string(value)
+	case fieldData:
+		dataJSON, err := valueJSON(value, 0)
+		if err != nil {
+			return fmt.Errorf("failed to convert message data: %w", err)
+		}
+		message.Data = dataJSON
+	}
+	return nil
+}
+
+func setVarintField(message *models.WSMessage, number protowire.Number, value uint64) {
+	switch number {
+	case fieldFrom:
+		message.From = uint(value)
+	case fieldTo:
+		message.To = uint(value)
+	case fieldSeq:
+		message.Seq = value
+	}
+}
+
+// Zero values are left out, as proto3 does
+func appendString(frame []byte, number protowire.Number, value string) []byte {
+	if value == "" {
+		return frame
+	}
+	frame = protowire.AppendTag(frame, number, protowire.BytesType)
+	return protowire.AppendString(frame, value)
+}
+
+func appendVarint(frame []byte, number protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return frame
+	}
+	frame = protowire.AppendTag(frame, number, protowire.VarintType)
+	return protowire.AppendVarint(frame, value)
+}
@@ -0,0 +1,230 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the Value message in proto/websocket.proto. 1 to 6 are
+// those of google.protobuf.Value, integers have their own fields so ids
+// above 2^53 do not lose precision as doubles.
+const (
+	valueNull    protowire.Number = 1
+	valueNumber  protowire.Number = 2
+	valueString  protowire.Number = 3
+	valueBool    protowire.Number = 4
+	valueStruct  protowire.Number = 5
+	valueList    protowire.Number = 6
+	valueInteger protowire.Number = 7 // sint64
+	valueUint    protowire.Number = 8 // uint64 above the int64 range
+
+	// Struct and ListValue hold their entries and items in field 1, an entry
+	// of a Struct is a key and a value
+	structFields protowire.Number = 1
+	listValues   protowire.Number = 1
+	entryKey     protowire.Number = 1
+	entryValue   protowire.Number = 2
+)
+
+// maxValueDepth bounds the nesting of the data clients send
+const maxValueDepth = 100
+
+// appendValue encodes a value read by decodeJSONValue as a Value message
+func appendValue(b []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		b = protowire.AppendTag(b, valueNull, protowire.VarintType)
+		return protowire.AppendVarint(b, 0), nil
+	case bool:
+		b = protowire.AppendTag(b, valueBool, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v)), nil
+	case string:
+		b = protowire.AppendTag(b, valueString, protowire.BytesType)
+		return protowire.AppendString(b, v), nil
+	case int64:
+		b = protowire.AppendTag(b, valueInteger, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(v)), nil
+	case uint64:
+		b = protowire.AppendTag(b, valueUint, protowire.VarintType)
+		return protowire.AppendVarint(b, v), nil
+	case float64:
+		b = protowire.AppendTag(b, valueNumber, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(v)), nil
+	case map[string]interface{}:
+		// Sorted so the same data always encodes the same
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var fields []byte
+		for _, key := range keys {
+			item, err := appendValue(nil, v[key])
+			if err != nil {
+				return nil, err
+			}
+			entry := protowire.AppendTag(nil, entryKey, protowire.BytesType)
+			entry = protowire.AppendString(entry, key)
+			entry = protowire.AppendTag(entry, entryValue, protowire.BytesType)
+			entry = protowire.AppendBytes(entry, item)
+
+			fields = protowire.AppendTag(fields, structFields, protowire.BytesType)
+			fields = protowire.AppendBytes(fields, entry)
+		}
+		b = protowire.AppendTag(b, valueStruct, protowire.BytesType)
+		return protowire.AppendBytes(b, fields), nil
+	case []interface{}:
+		var values []byte
+		for _, item := range v {
+			encoded, err := appendValue(nil, item)
+			if err != nil {
+				return nil, err
+			}
+			values = protowire.AppendTag(values, listValues, protowire.BytesType)
+			values = protowire.AppendBytes(values, encoded)
+		}
+		b = protowire.AppendTag(b, valueList, protowire.BytesType)
+		return protowire.AppendBytes(b, values), nil
+	default:
+		return nil, fmt.Errorf("unsupported value %T", value)
+	}
+}
+
+// valueJSON converts a Value message back to JSON. As in protobuf, the last
+// field of the oneof wins, a Value without one is null.
+func valueJSON(b []byte, depth int) ([]byte, error) {
+	if depth > maxValueDepth {
+		return nil, fmt.Errorf("data nested deeper than %d levels", maxValueDepth)
+	}
+
+	result := []byte("null")
+	err := consumeFields(b, func(number protowire.Number, wireType protowire.Type, value []byte, scalar uint64) error {
+		var err error
+		switch {
+		case number == valueNull && wireType == protowire.VarintType:
+			result = []byte("null")
+		case number == valueBool && wireType == protowire.VarintType:
+			result = strconv.AppendBool(nil, protowire.DecodeBool(scalar))
+		case number == valueString && wireType == protowire.BytesType:
+			result, err = json.Marshal(string(value))
+		case number == valueInteger && wireType == protowire.VarintType:
+			result = strconv.AppendInt(nil, protowire.DecodeZigZag(scalar), 10)
+		case number == valueUint && wireType == protowire.VarintType:
+			result = strconv.AppendUint(nil, scalar, 10)
+		case number == valueNumber && wireType == protowire.Fixed64Type:
+			number := math.Float64frombits(scalar)
+			if math.IsNaN(number) || math.IsInf(number, 0) {
+				return fmt.Errorf("number %v has no JSON form", number)
+			}
+			result = strconv.AppendFloat(nil, number, 'g', -1, 64)
+		case number == valueStruct && wireType == protowire.BytesType:
+			result, err = structJSON(value, depth)
+		case number == valueList && wireType == protowire.BytesType:
+			result, err = listJSON(value, depth)
+		}
+		return err
+	})
+	return result, err
+}
+
+func structJSON(b []byte, depth int) ([]byte, error) {
+	result := []byte{'{'}
+	err := consumeFields(b, func(number protowire.Number, wireType protowire.Type, entry []byte, _ uint64) error {
+		if number != structFields || wireType != protowire.BytesType {
+			return nil
+		}
+
+		var key string
+		item := []byte("null")
+		err := consumeFields(entry, func(number protowire.Number, wireType protowire.Type, value []byte, _ uint64) error {
+			var err error
+			switch {
+			case number == entryKey && wireType == protowire.BytesType:
+				key = string(value)
+			case number == entryValue && wireType == protowire.BytesType:
+				item, err = valueJSON(value, depth+1)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		if len(result) > 1 {
+			result = append(result, ',')
+		}
+		result = append(result, encodedKey...)
+		result = append(result, ':')
+		result = append(result, item...)
+		return nil
+	})
+	return append(result, '}'), err
+}
+
+func listJSON(b []byte, depth int) ([]byte, error) {
+	result := []byte{'['}
+	err := consumeFields(b, func(number protowire.Number, wireType protowire.Type, value []byte, _ uint64) error {
+		if number != listValues || wireType != protowire.BytesType {
+			return nil
+		}
+
+		item, err := valueJSON(value, depth+1)
+		if err != nil {
+			return err
+		}
+		if len(result) > 1 {
+			result = append(result, ',')
+		}
+		result = append(result, item...)
+		return nil
+	})
+	return append(result, ']'), err
+}
+
+// consumeFields calls field for each field of a message, with the content of
+// length-delimited fields or the value of the others. Groups are skipped.
+func consumeFields(b []byte, field func(number protowire.Number, wireType protowire.Type, value []byte, scalar uint64) error) error {
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("failed to decode message data: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var value []byte
+		var scalar uint64
+		switch wireType {
+		case protowire.VarintType:
+			scalar, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			scalar, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(number, wireType, b)
+			wireType = -1
+		}
+		if n < 0 {
+			return fmt.Errorf("failed to decode message data: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if wireType < 0 {
+			continue
+		}
+		if err := field(number, wireType, value, scalar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
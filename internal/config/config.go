@@ -1,6 +1,9 @@
 package config
 
 import (
+	"compress/flate"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strconv"
@@ -15,6 +18,7 @@ type Config struct {
 	Auth     AuthConfig     `json:"auth"`
 	WebRTC   WebRTCConfig   `json:"webrtc"`
 	Redis    RedisConfig    `json:"redis"`
+	Realtime RealtimeConfig `json:"realtime"`
 }
 
 type ServerConfig struct {
@@ -83,6 +87,22 @@ type RedisConfig struct {
 	IdleCheckFreq   time.Duration `json:"idle_check_freq"`
 }
 
+// RealtimeConfig sets how websocket nodes reach each other, "memory" keeps
+// fan-out in the process, "redis" routes it through Redis pub/sub.
+// Compression enables permessage-deflate for the clients that offer it.
+// The limits bound the websockets of a user or an IP and the size of the
+// frames they send.
+type RealtimeConfig struct {
+	Bus              string        `json:"bus"`
+	NodeID           string        `json:"node_id"`
+	PresenceTTL      time.Duration `json:"presence_ttl"`
+	Compression      bool          `json:"compression"`
+	CompressionLevel int           `json:"compression_level"`
+	MaxConnsPerUser  int           `json:"max_conns_per_user"`
+	MaxConnsPerIP    int           `json:"max_conns_per_ip"`
+	MaxMessageSize   int64         `json:"max_message_size"`
+}
+
 func Load() (*Config, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load()
@@ -147,6 +167,16 @@ func Load() (*Config, error) {
 			IdleTimeout:     getDurationEnv("REDIS_IDLE_TIMEOUT", 5*time.Minute),
 			IdleCheckFreq:   getDurationEnv("REDIS_IDLE_CHECK_FREQ", time.Minute),
 		},
+		Realtime: RealtimeConfig{
+			Bus:              getEnv("REALTIME_BUS", "memory"),
+			NodeID:           getEnv("NODE_ID", defaultNodeID()),
+			PresenceTTL:      getDurationEnv("REALTIME_PRESENCE_TTL", 30*time.Second),
+			Compression:      getBoolEnv("REALTIME_COMPRESSION", true),
+			CompressionLevel: getIntEnv("REALTIME_COMPRESSION_LEVEL", flate.BestSpeed),
+			MaxConnsPerUser:  getIntEnv("REALTIME_MAX_CONNS_PER_USER", 10),
+			MaxConnsPerIP:    getIntEnv("REALTIME_MAX_CONNS_PER_IP", 50),
+			MaxMessageSize:   getInt64Env("REALTIME_MAX_MESSAGE_SIZE", 64<<10), // 64KB
+		},
 	}
 
 	if err := config.validate(); err != nil {
@@ -172,6 +202,21 @@ func (c *Config) validate() error {
 	if c.Auth.PasswordMinLength < 6 {
 		return fmt.Errorf("password minimum length must be at least 6")
 	}
+	if c.Realtime.Bus != "memory" && c.Realtime.Bus != "redis" {
+		return fmt.Errorf("realtime bus must be memory or redis")
+	}
+	if c.Realtime.PresenceTTL < 3*time.Second {
+		return fmt.Errorf("realtime presence TTL must be at least 3s")
+	}
+	if c.Realtime.CompressionLevel < flate.HuffmanOnly || c.Realtime.CompressionLevel > flate.BestCompression {
+		return fmt.Errorf("realtime compression level must be between -2 and 9")
+	}
+	if c.Realtime.MaxConnsPerUser < 1 || c.Realtime.MaxConnsPerIP < 1 {
+		return fmt.Errorf("realtime connection limits must be at least 1")
+	}
+	if c.Realtime.MaxMessageSize < 1024 {
+		return fmt.Errorf("realtime max message size must be at least 1KB")
+	}
 	return nil
 }
 
@@ -206,6 +251,21 @@ func (c *Config) GetPostgresDSN() string {
 	)
 }
 
+// defaultNodeID names the node after its host, the suffix keeps replicas on
+// the same host apart
+func defaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "node"
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	return fmt.Sprintf("%s-%s", hostname, hex.EncodeToString(suffix))
+}
+
 // Helper functions for environment variables
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -88,10 +88,17 @@ func (pg *PostgresDB) AutoMigrate() error {
 		&models.Message{},
 		&models.MessageRead{},
 		&models.MessageReaction{},
+		&models.ChatRoomEmoji{},
 		&models.TypingIndicator{},
 		&models.OnlineStatus{},
 		&models.ChatInvite{},
+		&models.ChatInviteLink{},
+		&models.ChatJoinRequest{},
+		&models.ChatRoomBan{},
+		&models.ChatModerationLog{},
 		&models.ChatNotification{},
+		&models.ScheduledMessage{},
+		&models.PinnedMessage{},
 
 		// Post models
 		&models.Post{},
@@ -105,6 +112,25 @@ func (pg *PostgresDB) AutoMigrate() error {
 		&models.SavedPost{},
 		&models.PostTag{},
 
+		// Poll models
+		&models.Poll{},
+		&models.PollOption{},
+		&models.PollVote{},
+
+		// Link preview models
+		&models.LinkPreview{},
+
+		// Webhook models
+		&models.ChatWebhook{},
+		&models.WebhookDelivery{},
+
+		// Export models
+		&models.ChatExport{},
+
+		// Channel models
+		&models.ChannelSubscription{},
+		&models.MessageView{},
+
 		// Session models
 		&models.Session{},
 		&models.TokenBlacklist{},
@@ -142,6 +168,9 @@ func (pg *PostgresDB) CreateIndexes() error {
 		"CREATE INDEX IF NOT EXISTS idx_chat_rooms_last_activity ON chat_rooms(last_activity DESC)",
 		"CREATE INDEX IF NOT EXISTS idx_chat_rooms_type_archived ON chat_rooms(type) WHERE is_archived = false",
 
+		// Participant indexes, a user is in a room once until they leave it
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_participants_room_user ON participants(chat_room_id, user_id) WHERE deleted_at IS NULL",
+
 		// Message indexes
 		"CREATE INDEX IF NOT EXISTS idx_messages_room_created ON messages(chat_room_id, created_at DESC)",
 		"CREATE INDEX IF NOT EXISTS idx_messages_sender_created ON messages(sender_id, created_at DESC)",
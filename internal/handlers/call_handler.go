@@ -40,7 +40,7 @@ func NewCallHandler(callService *services.CallService, wsHandler *WebSocketHandl
 // @Security BearerAuth
 // @Router /calls [post]
 func (h *CallHandler) InitiateCall(c *gin.Context) {
-	userIDInterface, exists := c.Get("user_id")
+	callerID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "unauthorized",
@@ -49,8 +49,6 @@ func (h *CallHandler) InitiateCall(c *gin.Context) {
 		return
 	}
 
-	callerID := userIDInterface.(uint)
-
 	var req requests.InitiateCallRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -78,6 +76,9 @@ func (h *CallHandler) InitiateCall(c *gin.Context) {
 		return
 	}
 
+	// The callee rings on every transport, websocket or not
+	h.wsHandler.ringCallee(call, "")
+
 	c.JSON(http.StatusCreated, responses.CallResponse{
 		ID:       call.ID,
 		CallerID: call.CallerID,
@@ -188,7 +189,7 @@ func (h *CallHandler) GetCall(c *gin.Context) {
 // @Security BearerAuth
 // @Router /calls/{id}/accept [post]
 func (h *CallHandler) AcceptCall(c *gin.Context) {
-	userIDInterface, exists := c.Get("user_id")
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "unauthorized",
@@ -197,8 +198,6 @@ func (h *CallHandler) AcceptCall(c *gin.Context) {
 		return
 	}
 
-	userID := userIDInterface.(uint)
-
 	callIDStr := c.Param("id")
 	callID, err := strconv.ParseUint(callIDStr, 10, 32)
 	if err != nil {
@@ -236,6 +235,12 @@ func (h *CallHandler) AcceptCall(c *gin.Context) {
 		return
 	}
 
+	h.wsHandler.answerCall(userID, "", models.CallResponseMessage{
+		CallID:   uint(callID),
+		Response: "accept",
+		RoomID:   h.callRoomID(uint(callID)),
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Call accepted successfully",
 	})
@@ -254,7 +259,7 @@ func (h *CallHandler) AcceptCall(c *gin.Context) {
 // @Security BearerAuth
 // @Router /calls/{id}/decline [post]
 func (h *CallHandler) DeclineCall(c *gin.Context) {
-	userIDInterface, exists := c.Get("user_id")
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "unauthorized",
@@ -263,8 +268,6 @@ func (h *CallHandler) DeclineCall(c *gin.Context) {
 		return
 	}
 
-	userID := userIDInterface.(uint)
-
 	callIDStr := c.Param("id")
 	callID, err := strconv.ParseUint(callIDStr, 10, 32)
 	if err != nil {
@@ -302,6 +305,12 @@ func (h *CallHandler) DeclineCall(c *gin.Context) {
 		return
 	}
 
+	h.wsHandler.answerCall(userID, "", models.CallResponseMessage{
+		CallID:   uint(callID),
+		Response: "decline",
+		RoomID:   h.callRoomID(uint(callID)),
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Call declined successfully",
 	})
@@ -320,7 +329,7 @@ func (h *CallHandler) DeclineCall(c *gin.Context) {
 // @Security BearerAuth
 // @Router /calls/{id}/end [post]
 func (h *CallHandler) EndCall(c *gin.Context) {
-	userIDInterface, exists := c.Get("user_id")
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "unauthorized",
@@ -329,8 +338,6 @@ func (h *CallHandler) EndCall(c *gin.Context) {
 		return
 	}
 
-	userID := userIDInterface.(uint)
-
 	callIDStr := c.Param("id")
 	callID, err := strconv.ParseUint(callIDStr, 10, 32)
 	if err != nil {
@@ -368,11 +375,26 @@ func (h *CallHandler) EndCall(c *gin.Context) {
 		return
 	}
 
+	h.wsHandler.endCall(userID, models.CallEndMessage{
+		CallID: uint(callID),
+		RoomID: h.callRoomID(uint(callID)),
+		Reason: "hangup",
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Call ended successfully",
 	})
 }
 
+// callRoomID returns the room of a call, empty when it cannot be loaded
+func (h *CallHandler) callRoomID(callID uint) string {
+	call, err := h.callService.GetCallByID(callID)
+	if err != nil {
+		return ""
+	}
+	return call.RoomID
+}
+
 // GetCallHistory godoc
 // @Summary Get call history
 // @Description Get user's call history with pagination
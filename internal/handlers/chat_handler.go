@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
 	"social_server/internal/middleware"
 	"social_server/internal/models/constants"
@@ -8,6 +12,8 @@ import (
 	"social_server/internal/models/requests"
 	"social_server/internal/models/responses"
 	"social_server/internal/services"
+	"social_server/internal/utils"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -165,3 +171,2420 @@ func (h *ChatHandler) SyncRooms(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": response})
 }
+
+// SetDisappearingTimer sets the disappearing messages timer of a room
+// @Summary Set disappearing messages timer
+// @Description Set the room-level disappearing messages timer in seconds (0 turns it off). The countdown starts on send or on first read depending on the mode
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.SetDisappearingTimerRequest true "Timer settings"
+// @Success 200 {object} postgres.ChatRoom "Updated room"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/disappearing [put]
+func (h *ChatHandler) SetDisappearingTimer(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.SetDisappearingTimerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	room, err := h.chatService.SetDisappearingTimer(uri.ID, userID, req.Timer, req.Mode)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "set_disappearing_timer_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": room})
+}
+
+// GetScheduledMessages lists the user's pending scheduled messages in a room
+// @Summary Get scheduled messages
+// @Description List the authenticated user's pending scheduled messages in a room
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.ScheduledMessage "Scheduled messages"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/scheduled [get]
+func (h *ChatHandler) GetScheduledMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	messages, err := h.chatService.GetScheduledMessages(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_scheduled_messages_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages})
+}
+
+// UpdateScheduledMessage edits a pending scheduled message
+// @Summary Update scheduled message
+// @Description Edit the content or delivery time of a pending scheduled message
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Scheduled message ID"
+// @Param request body requests.UpdateScheduledMessageRequest true "Changes"
+// @Success 200 {object} postgres.ScheduledMessage "Updated scheduled message"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Scheduled message not found"
+// @Router /chat/scheduled/{id} [put]
+func (h *ChatHandler) UpdateScheduledMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ScheduledMessageUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scheduled_message_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.UpdateScheduledMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	message, err := h.chatService.UpdateScheduledMessage(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "update_scheduled_message_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": message})
+}
+
+// CancelScheduledMessage cancels a pending scheduled message
+// @Summary Cancel scheduled message
+// @Description Cancel a pending scheduled message before it is delivered
+// @Security BearerAuth
+// @Tags Chat
+// @Param id path int true "Scheduled message ID"
+// @Success 200 {object} map[string]interface{} "Scheduled message cancelled"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Scheduled message not found"
+// @Router /chat/scheduled/{id} [delete]
+func (h *ChatHandler) CancelScheduledMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ScheduledMessageUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scheduled_message_id", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.CancelScheduledMessage(uri.ID, userID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "cancel_scheduled_message_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled message cancelled"})
+}
+
+// UpdateRoomSettings updates the settings of a room
+// @Summary Update room settings
+// @Description Update the settings of a chat room, only admins and owners can change them
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.ChatRoomSettingsRequest true "Settings to change"
+// @Success 200 {object} postgres.ChatRoom "Updated room"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/settings [put]
+func (h *ChatHandler) UpdateRoomSettings(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.ChatRoomSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	room, err := h.chatService.UpdateRoomSettings(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "update_room_settings_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": room})
+}
+
+// CreatePoll posts a poll to a room
+// @Summary Create poll
+// @Description Post a poll message with 2 to 12 options, single or multiple choice, anonymous or public votes
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.CreatePollRequest true "Poll"
+// @Success 201 {object} postgres.Message "Poll message"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/polls [post]
+func (h *ChatHandler) CreatePoll(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.CreatePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	message, err := h.chatService.CreatePoll(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "create_poll_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": message})
+}
+
+// SendVoiceNote uploads a voice note and posts it to a room
+// @Summary Send voice note
+// @Description Upload an Opus/OGG, AAC/M4A or WebM voice note (max 10MB, 15 minutes). Duration and waveform are extracted from the file
+// @Security BearerAuth
+// @Tags Chat
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param file formData file true "Voice note"
+// @Param local_id formData int false "Client side message ID"
+// @Success 201 {object} postgres.Message "Audio message"
+// @Failure 400 {object} map[string]interface{} "Invalid file"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/voice-notes [post]
+func (h *ChatHandler) SendVoiceNote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.SendVoiceNoteRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	// Check membership before storing anything
+	if err := h.chatService.CheckUserPermission(uri.ID, userID, "send_message"); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission_denied", "message": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no_file", "message": "No voice note provided"})
+		return
+	}
+
+	result, err := utils.UploadVoiceNote(fileHeader, utils.DefaultVoiceNoteConfig())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload_failed", "message": err.Error()})
+		return
+	}
+
+	waveform, _ := json.Marshal(result.AudioInfo.Waveform)
+	media := postgres.MessageMedia{
+		Type:     "audio",
+		URL:      result.URL,
+		Filename: result.OriginalName,
+		Size:     result.FileSize,
+		MimeType: result.ContentType,
+		Duration: int(math.Ceil(result.AudioInfo.Duration)),
+		Waveform: string(waveform),
+	}
+
+	message, err := h.chatService.SendVoiceNote(userID, uri.ID, req.LocalID, media)
+	if err != nil {
+		utils.DeleteUploadedFile(result.URL)
+		c.JSON(chatErrorStatus(err), gin.H{"error": "send_voice_note_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": message})
+}
+
+// GetPinnedMessages lists the pinned messages of a room
+// @Summary Get pinned messages
+// @Description List the pinned messages of a room, newest pin first
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.PinnedMessage "Pinned messages"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/pins [get]
+func (h *ChatHandler) GetPinnedMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	pins, err := h.chatService.GetPinnedMessages(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_pinned_messages_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": pins})
+}
+
+// GetLiveLocations lists the live locations shared in a room
+// @Summary Get live locations
+// @Description List the live locations currently shared in a room with their latest position. Positions are streamed over WebSocket.
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} models.LiveLocationMessage "Live locations"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/live-locations [get]
+func (h *ChatHandler) GetLiveLocations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	locations, err := h.chatService.GetLiveLocations(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_live_locations_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": locations})
+}
+
+// PinMessage pins a message in its room
+// @Summary Pin message
+// @Description Pin a message. Admins and owners can pin, members too if the room allows it
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Message ID"
+// @Success 201 {object} postgres.PinnedMessage "Pinned message"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Router /chat/messages/{id}/pin [post]
+func (h *ChatHandler) PinMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.MessageUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_message_id", "message": err.Error()})
+		return
+	}
+
+	pin, err := h.chatService.PinMessage(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "pin_message_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": pin})
+}
+
+// UnpinMessage unpins a message
+// @Summary Unpin message
+// @Description Remove a message from the pinned messages of its room
+// @Security BearerAuth
+// @Tags Chat
+// @Param id path int true "Message ID"
+// @Success 200 {object} map[string]interface{} "Message unpinned"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Pinned message not found"
+// @Router /chat/messages/{id}/pin [delete]
+func (h *ChatHandler) UnpinMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.MessageUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_message_id", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.UnpinMessage(uri.ID, userID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "unpin_message_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message unpinned"})
+}
+
+// InviteUser invites a user to a group room
+// @Summary Invite user to room
+// @Description Invite a user to a group room. Only admins can invite when the room has only_admins_can_invite set
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.InviteUserRequest true "Invitee"
+// @Success 201 {object} postgres.ChatInvite "Created invite"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/invites [post]
+func (h *ChatHandler) InviteUser(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.InviteUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	invite, err := h.chatService.InviteUser(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "invite_user_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": invite})
+}
+
+// GetRoomInvites lists the invites sent for a room
+// @Summary Get room invites
+// @Description List the invites sent for a room, for the members allowed to invite
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param limit query int false "Number of invites to return"
+// @Param before query string false "Cursor for previous page"
+// @Param after query string false "Cursor for next page"
+// @Success 200 {object} responses.ChatInvitesResponse "Invites"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/invites [get]
+func (h *ChatHandler) GetRoomInvites(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.GetChatInvitesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	result, err := h.chatService.GetRoomInvites(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_invites_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetMyInvites lists the invites received by the current user
+// @Summary Get my invites
+// @Description List the room invites received by the current user
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param status query string false "Filter by status (pending, accepted, declined, expired)"
+// @Param limit query int false "Number of invites to return"
+// @Param before query string false "Cursor for previous page"
+// @Param after query string false "Cursor for next page"
+// @Success 200 {object} responses.ChatInvitesResponse "Invites"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /chat/invites [get]
+func (h *ChatHandler) GetMyInvites(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var req requests.GetChatInvitesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	result, err := h.chatService.GetUserInvites(userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "get_invites_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// AcceptInvite accepts a room invite
+// @Summary Accept invite
+// @Description Accept a room invite and join the room
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Invite ID"
+// @Success 200 {object} postgres.ChatInvite "Accepted invite"
+// @Failure 400 {object} map[string]interface{} "Invite expired or already answered"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Invite not found"
+// @Router /chat/invites/{id}/accept [post]
+func (h *ChatHandler) AcceptInvite(c *gin.Context) {
+	h.answerInvite(c, true)
+}
+
+// DeclineInvite declines a room invite
+// @Summary Decline invite
+// @Description Decline a room invite
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Invite ID"
+// @Success 200 {object} postgres.ChatInvite "Declined invite"
+// @Failure 400 {object} map[string]interface{} "Invite expired or already answered"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Invite not found"
+// @Router /chat/invites/{id}/decline [post]
+func (h *ChatHandler) DeclineInvite(c *gin.Context) {
+	h.answerInvite(c, false)
+}
+
+func (h *ChatHandler) answerInvite(c *gin.Context, accept bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatInviteUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_invite_id", "message": err.Error()})
+		return
+	}
+
+	var (
+		invite *postgres.ChatInvite
+		err    error
+	)
+	if accept {
+		invite, err = h.chatService.AcceptInvite(uri.ID, userID)
+	} else {
+		invite, err = h.chatService.DeclineInvite(uri.ID, userID)
+	}
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "answer_invite_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invite})
+}
+
+// CreateInviteLink creates a shareable invite link
+// @Summary Create invite link
+// @Description Create a revocable link to join a group room, with an optional expiry, max uses and approval queue
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.CreateInviteLinkRequest true "Link options"
+// @Success 201 {object} postgres.ChatInviteLink "Created link"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/invite-links [post]
+func (h *ChatHandler) CreateInviteLink(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.CreateInviteLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	link, err := h.chatService.CreateInviteLink(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "create_invite_link_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": link})
+}
+
+// GetInviteLinks lists the invite links of a room
+// @Summary Get invite links
+// @Description List the invite links of a room, including revoked and expired ones
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.ChatInviteLink "Invite links"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/invite-links [get]
+func (h *ChatHandler) GetInviteLinks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	links, err := h.chatService.GetInviteLinks(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_invite_links_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": links})
+}
+
+// RevokeInviteLink revokes an invite link
+// @Summary Revoke invite link
+// @Description Revoke an invite link, only its creator or a room admin can do it
+// @Security BearerAuth
+// @Tags Chat
+// @Param id path int true "Invite link ID"
+// @Success 200 {object} map[string]interface{} "Link revoked"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Link not found"
+// @Router /chat/invite-links/{id} [delete]
+func (h *ChatHandler) RevokeInviteLink(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatInviteLinkUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_invite_link_id", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.RevokeInviteLink(uri.ID, userID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "revoke_invite_link_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite link revoked"})
+}
+
+// GetInviteLinkPreview shows the room behind an invite link
+// @Summary Preview invite link
+// @Description Get the room an invite link leads to before joining
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param token path string true "Invite link token"
+// @Success 200 {object} postgres.ChatInviteLink "Invite link with its room"
+// @Failure 400 {object} map[string]interface{} "Link expired, revoked or used up"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Link not found"
+// @Router /chat/join/{token} [get]
+func (h *ChatHandler) GetInviteLinkPreview(c *gin.Context) {
+	if _, exists := middleware.GetUserID(c); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.InviteLinkTokenUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_token", "message": err.Error()})
+		return
+	}
+
+	link, err := h.chatService.GetInviteLinkPreview(uri.Token)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_invite_link_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": link})
+}
+
+// JoinByInviteLink joins a room through an invite link
+// @Summary Join by invite link
+// @Description Join the room of an invite link, or queue a join request when the link requires approval
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param token path string true "Invite link token"
+// @Success 200 {object} responses.JoinByInviteLinkResponse "Joined room or pending request"
+// @Failure 400 {object} map[string]interface{} "Link expired, revoked or used up"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Link not found"
+// @Router /chat/join/{token} [post]
+func (h *ChatHandler) JoinByInviteLink(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.InviteLinkTokenUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_token", "message": err.Error()})
+		return
+	}
+
+	result, err := h.chatService.JoinByInviteLink(uri.Token, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "join_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetJoinRequests lists the join requests of a room
+// @Summary Get join requests
+// @Description List the join requests made through approval invite links, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param status query string false "Filter by status (pending, approved, rejected)"
+// @Success 200 {array} postgres.ChatJoinRequest "Join requests"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/join-requests [get]
+func (h *ChatHandler) GetJoinRequests(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.GetJoinRequestsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	joinRequests, err := h.chatService.GetJoinRequests(uri.ID, userID, req.Status)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_join_requests_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": joinRequests})
+}
+
+// ApproveJoinRequest approves a join request
+// @Summary Approve join request
+// @Description Approve a pending join request and add the user to the room, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Join request ID"
+// @Success 200 {object} postgres.ChatJoinRequest "Approved request"
+// @Failure 400 {object} map[string]interface{} "Request already reviewed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Request not found"
+// @Router /chat/join-requests/{id}/approve [post]
+func (h *ChatHandler) ApproveJoinRequest(c *gin.Context) {
+	h.reviewJoinRequest(c, true)
+}
+
+// RejectJoinRequest rejects a join request
+// @Summary Reject join request
+// @Description Reject a pending join request, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Join request ID"
+// @Success 200 {object} postgres.ChatJoinRequest "Rejected request"
+// @Failure 400 {object} map[string]interface{} "Request already reviewed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Request not found"
+// @Router /chat/join-requests/{id}/reject [post]
+func (h *ChatHandler) RejectJoinRequest(c *gin.Context) {
+	h.reviewJoinRequest(c, false)
+}
+
+func (h *ChatHandler) reviewJoinRequest(c *gin.Context, approve bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatJoinRequestUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_join_request_id", "message": err.Error()})
+		return
+	}
+
+	request, err := h.chatService.ReviewJoinRequest(uri.ID, userID, approve)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "review_join_request_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": request})
+}
+
+// ForwardMessages forwards messages to other rooms
+// @Summary Forward messages
+// @Description Copy messages, including media and location, into rooms the user belongs to. Copies are attributed to the original sender unless they opted out
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param request body requests.ForwardMessagesRequest true "Messages and target rooms"
+// @Success 201 {array} postgres.Message "Forwarded copies"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not allowed to post in a target room"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Router /chat/messages/forward [post]
+func (h *ChatHandler) ForwardMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var req requests.ForwardMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	messages, err := h.chatService.ForwardMessages(userID, req.MessageIDs, req.RoomIDs)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "forward_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": messages})
+}
+
+// KickMember removes a member from a group room
+// @Summary Kick member
+// @Description Remove a member from a group room, admins only. The member can rejoin with a new invite
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param user_id path int true "User ID"
+// @Param request body requests.ModerateMemberRequest false "Reason"
+// @Success 200 {object} map[string]interface{} "Member kicked"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Member not found"
+// @Router /chat/rooms/{id}/members/{user_id}/kick [post]
+func (h *ChatHandler) KickMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomMemberUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	var req requests.ModerateMemberRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.KickMember(uri.ID, userID, uri.UserID, req.Reason); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "kick_member_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member kicked"})
+}
+
+// BanMember bans a user from a group room
+// @Summary Ban user
+// @Description Remove a user from a group room and prevent them from joining again, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param user_id path int true "User ID"
+// @Param request body requests.ModerateMemberRequest false "Reason"
+// @Success 201 {object} postgres.ChatRoomBan "Created ban"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Router /chat/rooms/{id}/bans/{user_id} [post]
+func (h *ChatHandler) BanMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomMemberUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	var req requests.ModerateMemberRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	ban, err := h.chatService.BanMember(uri.ID, userID, uri.UserID, req.Reason)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "ban_member_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": ban})
+}
+
+// UnbanMember lifts a ban
+// @Summary Unban user
+// @Description Lift the ban of a user, admins only. The user still needs an invite to rejoin
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param user_id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "User unbanned"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Ban not found"
+// @Router /chat/rooms/{id}/bans/{user_id} [delete]
+func (h *ChatHandler) UnbanMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomMemberUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.UnbanMember(uri.ID, userID, uri.UserID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "unban_member_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unbanned"})
+}
+
+// GetRoomBans lists the users banned from a room
+// @Summary Get room bans
+// @Description List the users banned from a group room, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.ChatRoomBan "Bans"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/bans [get]
+func (h *ChatHandler) GetRoomBans(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	bans, err := h.chatService.GetRoomBans(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_bans_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bans})
+}
+
+// MuteMember mutes a member of a group room
+// @Summary Mute member
+// @Description Prevent a member from posting for a duration in seconds, 0 mutes until unmuted. Admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param user_id path int true "User ID"
+// @Param request body requests.MuteMemberRequest true "Mute duration and reason"
+// @Success 200 {object} map[string]interface{} "Member muted"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Member not found"
+// @Router /chat/rooms/{id}/members/{user_id}/mute [post]
+func (h *ChatHandler) MuteMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomMemberUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	var req requests.MuteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.MuteMember(uri.ID, userID, uri.UserID, req.Duration, req.Reason); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "mute_member_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member muted"})
+}
+
+// UnmuteMember unmutes a member of a group room
+// @Summary Unmute member
+// @Description Let a muted member post again, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param user_id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Member unmuted"
+// @Failure 400 {object} map[string]interface{} "Member is not muted"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Member not found"
+// @Router /chat/rooms/{id}/members/{user_id}/mute [delete]
+func (h *ChatHandler) UnmuteMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomMemberUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.UnmuteMember(uri.ID, userID, uri.UserID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "unmute_member_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member unmuted"})
+}
+
+// SetSlowMode changes the slow mode of a group room
+// @Summary Set slow mode
+// @Description Set the minimum number of seconds between two messages of a member, 0 turns it off. Admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.SetSlowModeRequest true "Slow mode interval"
+// @Success 200 {object} postgres.ChatRoom "Updated room"
+// @Failure 400 {object} map[string]interface{} "Invalid interval"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/slow-mode [put]
+func (h *ChatHandler) SetSlowMode(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.SetSlowModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	room, err := h.chatService.SetSlowMode(uri.ID, userID, req.Seconds)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "set_slow_mode_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": room})
+}
+
+// GetModerationLog lists the moderation actions of a room
+// @Summary Get moderation log
+// @Description List the kicks, bans, mutes and slow mode changes of a group room, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param limit query int false "Number of entries to return"
+// @Param before query string false "Cursor for previous page"
+// @Param after query string false "Cursor for next page"
+// @Success 200 {object} responses.ChatModerationLogResponse "Moderation log"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/moderation-log [get]
+func (h *ChatHandler) GetModerationLog(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.GetModerationLogRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	result, err := h.chatService.GetModerationLog(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_moderation_log_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetParticipants lists the members of a room
+// @Summary Get participants
+// @Description List the members of a room with their role, bots are flagged with is_bot
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.Participant "Participants"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/participants [get]
+func (h *ChatHandler) GetParticipants(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	participants, err := h.chatService.GetParticipants(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_participants_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": participants})
+}
+
+// CreateBot creates a bot managed by the current user
+// @Summary Create bot
+// @Description Create a bot user. Bots cannot log in, they post to rooms through webhooks.
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param request body requests.CreateBotRequest true "Bot"
+// @Success 201 {object} postgres.User "Created bot"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /chat/bots [post]
+func (h *ChatHandler) CreateBot(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var req requests.CreateBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	bot, err := h.chatService.CreateBot(userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "create_bot_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": bot})
+}
+
+// GetMyBots lists the bots of the current user
+// @Summary Get my bots
+// @Description List the bots managed by the current user
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Success 200 {array} postgres.User "Bots"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /chat/bots [get]
+func (h *ChatHandler) GetMyBots(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	bots, err := h.chatService.GetMyBots(userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_bots_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bots})
+}
+
+// DeleteBot deletes a bot
+// @Summary Delete bot
+// @Description Delete a bot, removing it from its rooms along with its webhooks
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Bot user ID"
+// @Success 200 {object} map[string]interface{} "Bot deleted"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Bot not found"
+// @Router /chat/bots/{id} [delete]
+func (h *ChatHandler) DeleteBot(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.BotUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_bot_id", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.DeleteBot(userID, uri.ID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "delete_bot_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bot deleted"})
+}
+
+// CreateWebhook adds a webhook to a room
+// @Summary Create webhook
+// @Description Add an incoming webhook, posting as a bot through its token URL, or an outgoing webhook, receiving the messages that start with its trigger as HMAC-signed JSON. Admins only, the bot must be yours and joins the room.
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.CreateChatWebhookRequest true "Webhook"
+// @Success 201 {object} postgres.ChatWebhook "Created webhook"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/webhooks [post]
+func (h *ChatHandler) CreateWebhook(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.CreateChatWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	webhook, err := h.chatService.CreateWebhook(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "create_webhook_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": webhook})
+}
+
+// GetRoomWebhooks lists the webhooks of a room
+// @Summary Get room webhooks
+// @Description List the webhooks of a group room with their tokens and secrets, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.ChatWebhook "Webhooks"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/webhooks [get]
+func (h *ChatHandler) GetRoomWebhooks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	webhooks, err := h.chatService.GetRoomWebhooks(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_webhooks_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": webhooks})
+}
+
+// DeleteWebhook removes a webhook
+// @Summary Delete webhook
+// @Description Remove a webhook from its room, the bot stays a member. Admins only.
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]interface{} "Webhook deleted"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Router /chat/webhooks/{id} [delete]
+func (h *ChatHandler) DeleteWebhook(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatWebhookUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_webhook_id", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.DeleteWebhook(uri.ID, userID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "delete_webhook_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// GetWebhookDeliveries lists the deliveries of an outgoing webhook
+// @Summary Get webhook deliveries
+// @Description List the deliveries of an outgoing webhook with their status, attempts and last error, admins only
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param limit query int false "Number of deliveries to return"
+// @Param before query string false "Cursor for previous page"
+// @Param after query string false "Cursor for next page"
+// @Success 200 {object} responses.WebhookDeliveriesResponse "Deliveries"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Router /chat/webhooks/{id}/deliveries [get]
+func (h *ChatHandler) GetWebhookDeliveries(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatWebhookUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_webhook_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.GetWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	result, err := h.chatService.GetWebhookDeliveries(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_webhook_deliveries_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// ReceiveIncomingWebhook posts a message to a room through an incoming webhook
+// @Summary Post through incoming webhook
+// @Description Post a message as the bot of an incoming webhook. The token in the URL is the credential, keep it secret.
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param token path string true "Webhook token"
+// @Param request body requests.IncomingWebhookRequest true "Message"
+// @Success 201 {object} postgres.Message "Posted message"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Webhook disabled or bot cannot post"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Router /hooks/{token} [post]
+func (h *ChatHandler) ReceiveIncomingWebhook(c *gin.Context) {
+	var uri requests.WebhookTokenUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_token", "message": err.Error()})
+		return
+	}
+
+	var req requests.IncomingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	message, err := h.chatService.ReceiveIncomingWebhook(uri.Token, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "incoming_webhook_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": message})
+}
+
+// GetSlashCommands lists the slash commands for autocompletion
+// @Summary Get slash commands
+// @Description List the slash commands that can be typed in a message. With room_id, only the commands the user may run in that room are returned.
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param room_id query int false "Room ID"
+// @Success 200 {array} responses.SlashCommandResponse "Slash commands"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/commands [get]
+func (h *ChatHandler) GetSlashCommands(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var req requests.GetSlashCommandsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	commands, err := h.chatService.GetSlashCommands(userID, req.RoomID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_slash_commands_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": commands})
+}
+
+// RequestExport queues an export of the room history
+// @Summary Export room history
+// @Description Queue an export of the room messages, with sender names, replies, reactions, edits and attachments, as JSON, standalone HTML or plain text. With include_attachments the export and the uploaded files are bundled into a ZIP. Only messages sent since the user joined are exported unless the room shares its history with new members, end-to-end encrypted messages are skipped. A chat_export_updated WebSocket event is sent when the file is ready.
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param request body requests.CreateChatExportRequest true "Export options"
+// @Success 202 {object} postgres.ChatExport "Queued export"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/exports [post]
+func (h *ChatHandler) RequestExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.CreateChatExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	export, err := h.chatService.RequestExport(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "request_export_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"data": export})
+}
+
+// GetExports lists the exports of the room requested by the current user
+// @Summary Get room exports
+// @Description List the exports of a room requested by the current user, most recent first
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.ChatExport "Exports"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/exports [get]
+func (h *ChatHandler) GetExports(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	exports, err := h.chatService.GetExports(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_exports_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": exports})
+}
+
+// GetExport returns the status of an export
+// @Summary Get export
+// @Description Get the status of an export requested by the current user
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Export ID"
+// @Success 200 {object} postgres.ChatExport "Export"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Export not found"
+// @Router /chat/exports/{id} [get]
+func (h *ChatHandler) GetExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatExportUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_export_id", "message": err.Error()})
+		return
+	}
+
+	export, err := h.chatService.GetExport(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_export_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": export})
+}
+
+// DownloadExport sends the file of a completed export
+// @Summary Download export
+// @Description Download the file of a completed export until it expires
+// @Security BearerAuth
+// @Tags Chat
+// @Produce octet-stream
+// @Param id path int true "Export ID"
+// @Success 200 {file} file "Export file"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Export not found"
+// @Router /chat/exports/{id}/download [get]
+func (h *ChatHandler) DownloadExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatExportUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_export_id", "message": err.Error()})
+		return
+	}
+
+	export, err := h.chatService.GetExportFile(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "download_export_failed", "message": err.Error()})
+		return
+	}
+
+	c.FileAttachment(export.FilePath, export.FileName)
+}
+
+// CreateChannel creates a broadcast channel owned by the current user
+// @Summary Create a channel
+// @Description Create a one-to-many channel where only the owner and admins post. Public channels need a handle and can be found through search.
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param request body requests.CreateChannelRequest true "Channel"
+// @Success 201 {object} responses.ChannelResponse "Created channel"
+// @Failure 400 {object} map[string]interface{} "Invalid request or handle taken"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /chat/channels [post]
+func (h *ChatHandler) CreateChannel(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var req requests.CreateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	channel, err := h.chatService.CreateChannel(userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "create_channel_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": channel})
+}
+
+// GetSubscribedChannels lists the channels the current user follows
+// @Summary Get subscribed channels
+// @Description List the channels the current user is subscribed to
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Success 200 {array} responses.ChannelResponse "Channels"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /chat/channels [get]
+func (h *ChatHandler) GetSubscribedChannels(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	channels, err := h.chatService.GetSubscribedChannels(userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_channels_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": channels})
+}
+
+// SearchChannels finds public channels
+// @Summary Search channels
+// @Description Find public channels by name, handle or description, most followed first
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param q query string false "Search query"
+// @Param limit query int false "Max results (default 20, max 50)"
+// @Success 200 {array} responses.ChannelResponse "Channels"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /chat/channels/search [get]
+func (h *ChatHandler) SearchChannels(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var req requests.SearchChannelsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	channels, err := h.chatService.SearchChannels(userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "search_channels_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": channels})
+}
+
+// GetChannelByHandle returns a channel by its public handle
+// @Summary Get a channel by handle
+// @Description Get a channel by its public handle, with or without the @
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param handle path string true "Channel handle"
+// @Success 200 {object} responses.ChannelResponse "Channel"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Channel not found"
+// @Router /chat/channels/by-handle/{handle} [get]
+func (h *ChatHandler) GetChannelByHandle(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChannelHandleUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_handle", "message": err.Error()})
+		return
+	}
+
+	channel, err := h.chatService.GetChannelByHandle(uri.Handle, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_channel_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": channel})
+}
+
+// SubscribeChannelByHandle subscribes the current user to a public channel by its handle
+// @Summary Subscribe to a channel by handle
+// @Description Follow a public channel by its handle
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param handle path string true "Channel handle"
+// @Success 200 {object} responses.ChannelResponse "Channel"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Private channel or banned"
+// @Failure 404 {object} map[string]interface{} "Channel not found"
+// @Router /chat/channels/by-handle/{handle}/subscribe [post]
+func (h *ChatHandler) SubscribeChannelByHandle(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChannelHandleUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_handle", "message": err.Error()})
+		return
+	}
+
+	channel, err := h.chatService.SubscribeChannelByHandle(uri.Handle, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "subscribe_channel_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": channel})
+}
+
+// GetChannel returns a channel
+// @Summary Get a channel
+// @Description Get a public channel, or one the current user follows or manages
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Channel ID"
+// @Success 200 {object} responses.ChannelResponse "Channel"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Channel not found"
+// @Router /chat/channels/{id} [get]
+func (h *ChatHandler) GetChannel(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_channel_id", "message": err.Error()})
+		return
+	}
+
+	channel, err := h.chatService.GetChannel(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_channel_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": channel})
+}
+
+// SubscribeChannel subscribes the current user to a public channel
+// @Summary Subscribe to a channel
+// @Description Follow a public channel, private channels are joined with an invite
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Channel ID"
+// @Success 200 {object} responses.ChannelResponse "Channel"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Private channel or banned"
+// @Failure 404 {object} map[string]interface{} "Channel not found"
+// @Router /chat/channels/{id}/subscribe [post]
+func (h *ChatHandler) SubscribeChannel(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_channel_id", "message": err.Error()})
+		return
+	}
+
+	channel, err := h.chatService.SubscribeChannel(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "subscribe_channel_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": channel})
+}
+
+// UnsubscribeChannel stops following a channel
+// @Summary Unsubscribe from a channel
+// @Description Stop following a channel
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Channel ID"
+// @Success 200 {object} map[string]interface{} "Unsubscribed"
+// @Failure 400 {object} map[string]interface{} "Not subscribed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Channel not found"
+// @Router /chat/channels/{id}/subscribe [delete]
+func (h *ChatHandler) UnsubscribeChannel(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_channel_id", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.UnsubscribeChannel(uri.ID, userID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "unsubscribe_channel_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed from channel"})
+}
+
+// GetChannelMessages returns the posts of a channel
+// @Summary Get channel messages
+// @Description Get the posts of a channel the current user can read, newest first
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Channel ID"
+// @Param limit query int false "Page size"
+// @Param before query string false "Cursor for previous page"
+// @Param after query string false "Cursor for next page"
+// @Success 200 {object} responses.MessageResponse "Messages"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not subscribed"
+// @Failure 404 {object} map[string]interface{} "Channel not found"
+// @Router /chat/channels/{id}/messages [get]
+func (h *ChatHandler) GetChannelMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_channel_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.GetChannelMessagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	messages, err := h.chatService.GetChannelMessages(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_channel_messages_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages})
+}
+
+// RecordChannelViews counts the current user as a viewer of channel posts
+// @Summary Record channel views
+// @Description Count the current user as a viewer of the given posts, once per post. Returns the view counts by message ID.
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Channel ID"
+// @Param request body requests.RecordChannelViewsRequest true "Viewed messages"
+// @Success 200 {object} map[string]int "View counts"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not subscribed"
+// @Router /chat/channels/{id}/views [post]
+func (h *ChatHandler) RecordChannelViews(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_channel_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.RecordChannelViewsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	counts, err := h.chatService.RecordChannelViews(uri.ID, userID, req.MessageIDs)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "record_views_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": counts})
+}
+
+// AddChannelAdmin lets a user post in a channel
+// @Summary Add a channel admin
+// @Description Make a user an admin of a channel, only the owner can add admins
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Channel ID"
+// @Param user_id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Admin added"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/channels/{id}/admins/{user_id} [post]
+func (h *ChatHandler) AddChannelAdmin(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChannelMemberUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.AddChannelAdmin(uri.ID, userID, uri.UserID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "add_channel_admin_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Admin added"})
+}
+
+// RemoveChannelAdmin removes an admin from a channel
+// @Summary Remove a channel admin
+// @Description Remove an admin from a channel, only the owner can remove admins
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Channel ID"
+// @Param user_id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Admin removed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/channels/{id}/admins/{user_id} [delete]
+func (h *ChatHandler) RemoveChannelAdmin(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChannelMemberUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	if err := h.chatService.RemoveChannelAdmin(uri.ID, userID, uri.UserID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "remove_channel_admin_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Admin removed"})
+}
+
+// AddReaction reacts to a message
+// @Summary Add reaction
+// @Description React to a message with an emoji, or a custom emoji of the room as :shortcode:. The room gets a reaction_add event with the new summary.
+// @Security BearerAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Message ID"
+// @Param request body requests.MessageReactionRequest true "Reaction"
+// @Success 200 {array} postgres.ReactionCount "Reaction summary of the message"
+// @Failure 400 {object} map[string]interface{} "Invalid emoji"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied or emoji not allowed"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Router /chat/messages/{id}/reactions [post]
+func (h *ChatHandler) AddReaction(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.MessageUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_message_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.MessageReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	summary, err := h.chatService.AddReaction(uri.ID, userID, req.Emoji)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "add_reaction_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
+
+// RemoveReaction removes a reaction of the current user
+// @Summary Remove reaction
+// @Description Remove a reaction of the current user from a message. The room gets a reaction_remove event with the new summary.
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Message ID"
+// @Param emoji path string true "Emoji, URL encoded"
+// @Success 200 {array} postgres.ReactionCount "Reaction summary of the message"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Router /chat/messages/{id}/reactions/{emoji} [delete]
+func (h *ChatHandler) RemoveReaction(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.MessageReactionUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	summary, err := h.chatService.RemoveReaction(uri.ID, userID, uri.Emoji)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "remove_reaction_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
+
+// GetReactionUsers lists who reacted to a message with an emoji
+// @Summary Get reaction users
+// @Description List the users who reacted to a message with an emoji, latest first
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Message ID"
+// @Param emoji query string true "Emoji"
+// @Param limit query int false "Page size (default 20)"
+// @Param before query string false "Cursor for previous page"
+// @Param after query string false "Cursor for next page"
+// @Success 200 {object} responses.ReactionUsersResponse "Reactions with their user"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Message not found"
+// @Router /chat/messages/{id}/reactions [get]
+func (h *ChatHandler) GetReactionUsers(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.MessageUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_message_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.GetReactionUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	reactions, err := h.chatService.GetReactionUsers(uri.ID, userID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_reactions_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reactions})
+}
+
+// GetRoomEmojis lists the custom emoji of a room
+// @Summary Get room emojis
+// @Description List the custom emoji of a room, used in reactions as :shortcode:
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} postgres.ChatRoomEmoji "Custom emoji"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/emojis [get]
+func (h *ChatHandler) GetRoomEmojis(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	emojis, err := h.chatService.GetRoomEmojis(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_emojis_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": emojis})
+}
+
+// CreateRoomEmoji uploads a custom emoji for a room
+// @Summary Upload room emoji
+// @Description Upload a custom emoji for a room, admins only. The image is converted to PNG.
+// @Security BearerAuth
+// @Tags Chat
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param shortcode formData string true "Shortcode, 2 to 32 letters, digits or underscores"
+// @Param file formData file true "Emoji image, up to 256KB"
+// @Success 201 {object} postgres.ChatRoomEmoji "Created emoji"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Router /chat/rooms/{id}/emojis [post]
+func (h *ChatHandler) CreateRoomEmoji(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_room_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.CreateChatRoomEmojiRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	// Check the role before storing anything
+	if err := h.chatService.CheckUserPermission(uri.ID, userID, "update_room"); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission_denied", "message": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no_file", "message": "No emoji image provided"})
+		return
+	}
+
+	result, err := utils.UploadImageAsPNG(fileHeader, utils.DefaultCustomEmojiConfig())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload_failed", "message": err.Error()})
+		return
+	}
+
+	emoji, err := h.chatService.CreateRoomEmoji(uri.ID, userID, req.Shortcode, result.URL)
+	if err != nil {
+		utils.DeleteUploadedFile(result.URL)
+		c.JSON(chatErrorStatus(err), gin.H{"error": "create_emoji_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": emoji})
+}
+
+// DeleteRoomEmoji removes a custom emoji of a room
+// @Summary Delete room emoji
+// @Description Remove a custom emoji of a room, admins only. Reactions already made with it are kept.
+// @Security BearerAuth
+// @Tags Chat
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param emoji_id path int true "Emoji ID"
+// @Success 200 {object} map[string]interface{} "Emoji deleted"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Emoji not found"
+// @Router /chat/rooms/{id}/emojis/{emoji_id} [delete]
+func (h *ChatHandler) DeleteRoomEmoji(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.ChatRoomEmojiUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	emoji, err := h.chatService.DeleteRoomEmoji(uri.ID, uri.EmojiID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "delete_emoji_failed", "message": err.Error()})
+		return
+	}
+	utils.DeleteUploadedFile(emoji.URL)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emoji deleted"})
+}
+
+// bindOptionalJSON binds a JSON body that clients may leave out
+func bindOptionalJSON(c *gin.Context, obj interface{}) error {
+	if err := c.ShouldBindJSON(obj); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// chatErrorStatus maps chat service errors to HTTP status codes
+func chatErrorStatus(err error) int {
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, "permission denied"):
+		return http.StatusForbidden
+	case strings.Contains(message, "not found"):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
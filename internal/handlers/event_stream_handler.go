@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"social_server/internal/codec"
+	"social_server/internal/middleware"
+	"social_server/internal/models"
+	"social_server/internal/models/requests"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Transports for clients whose proxies block websockets. They open the same
+// sessions as websockets, so events are numbered, buffered and resumed the
+// same way, and actions go over REST.
+const (
+	sseHeartbeatInterval = 15 * time.Second
+	defaultPollTimeout   = 25 * time.Second
+	maxPollTimeout       = 55 * time.Second
+)
+
+// httpStream carries the events of a session over SSE or a long poll
+type httpStream struct {
+	session   *wsSession
+	remoteIP  string
+	events    chan models.WSMessage
+	done      chan struct{} // Closed when another stream took over
+	closeOnce sync.Once
+}
+
+func newHTTPStream(remoteIP string) *httpStream {
+	return &httpStream{
+		remoteIP: remoteIP,
+		events:   make(chan models.WSMessage, replayBufferSize+256), // Room for a full replay
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *httpStream) push(message models.WSMessage) bool {
+	select {
+	case s.events <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *httpStream) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// reserveHTTPStream counts a stream of the user against the same limits as
+// websockets, the request is refused when they are reached
+func (h *WebSocketHandler) reserveHTTPStream(c *gin.Context, userID uint) (string, bool) {
+	remoteIP := c.ClientIP()
+	if err := h.reserveConnection(userID, remoteIP); err != nil {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error":   "too_many_connections",
+			"message": err.Error(),
+		})
+		return "", false
+	}
+	return remoteIP, true
+}
+
+// openHTTPStream attaches a stream reserved by reserveHTTPStream to the
+// session of the resume token and returns the connected message, the first
+// event of every stream. The stream counts as a device of the user until
+// closeHTTPStream, the reservation is released when it fails to open.
+func (h *WebSocketHandler) openHTTPStream(userID uint, remoteIP, token string, lastSeq uint64) (*httpStream, models.ConnectedMessage, error) {
+	stream := newHTTPStream(remoteIP)
+	connected := models.ConnectedMessage{
+		ConnID:   h.generateConnectionID(),
+		Encoding: codec.EncodingJSON,
+	}
+	if err := h.openSession(stream, userID, token, lastSeq, connected); err != nil {
+		h.mutex.Lock()
+		h.releaseConnection(userID, remoteIP)
+		h.mutex.Unlock()
+		return nil, connected, err
+	}
+
+	first := <-stream.events
+	if err := json.Unmarshal(first.Data, &connected); err != nil {
+		h.closeHTTPStream(stream, connected)
+		return nil, connected, fmt.Errorf("failed to read connected message: %w", err)
+	}
+
+	// Polls come and go, the status debounce keeps them from flapping
+	if err := h.onlineStatusService.ConnectDevice(userID, connected.ConnID); err != nil {
+		log.Printf("Failed to set user %d online: %v", userID, err)
+	}
+	return stream, connected, nil
+}
+
+// closeHTTPStream detaches a stream and releases its reservation, its
+// session buffers the next events
+func (h *WebSocketHandler) closeHTTPStream(stream *httpStream, connected models.ConnectedMessage) {
+	stream.session.detach(stream)
+	h.mutex.Lock()
+	h.releaseConnection(stream.session.UserID, stream.remoteIP)
+	h.mutex.Unlock()
+	h.onlineStatusService.DisconnectDevice(stream.session.UserID, connected.ConnID)
+}
+
+// StreamEvents streams the events of the user as Server-Sent Events
+// @Summary Stream realtime events
+// @Description Stream the events websockets carry as Server-Sent Events, for clients that cannot open a websocket. Each event is named after its type and its data is the websocket message. The id is resume_token:seq, so EventSource resumes the session through Last-Event-ID.
+// @Tags Calls
+// @Produce text/event-stream
+// @Param resume_token query string false "Token of the session to resume"
+// @Param last_seq query int false "Last sequence received"
+// @Param Last-Event-ID header string false "resume_token:seq, takes precedence over the query"
+// @Success 200 {string} string "Event stream"
+// @Failure 401 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /events/stream [get]
+func (h *WebSocketHandler) StreamEvents(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if h.refuseWhileDraining(c) {
+		return
+	}
+
+	token := c.Query("resume_token")
+	lastSeq, _ := strconv.ParseUint(c.Query("last_seq"), 10, 64)
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		eventToken, eventSeq, _ := strings.Cut(lastEventID, ":")
+		token = eventToken
+		lastSeq, _ = strconv.ParseUint(eventSeq, 10, 64)
+	}
+
+	// The stream outlives the write timeout of the server
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear write deadline of event stream: %v", err)
+	}
+
+	remoteIP, reserved := h.reserveHTTPStream(c, userID)
+	if !reserved {
+		return
+	}
+	stream, connected, err := h.openHTTPStream(userID, remoteIP, token, lastSeq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "stream_failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	defer h.closeHTTPStream(stream, connected)
+	h.loadChannelSubscriptions(userID)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !writeSSE(c, connected.ResumeToken, models.WSMessage{
+		Type:      models.MessageTypeConnected,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      h.marshalData(connected),
+	}, connected.LastSeq) {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case message := <-stream.events:
+			if !writeSSE(c, connected.ResumeToken, message, message.Seq) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-stream.done:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE writes a message as an event, sequenced ones carry the id to
+// resume from
+func writeSSE(c *gin.Context, token string, message models.WSMessage, seq uint64) bool {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal message: %v", err)
+		return true
+	}
+
+	var event strings.Builder
+	if seq != 0 || message.Type == models.MessageTypeConnected {
+		fmt.Fprintf(&event, "id: %s:%d\n", token, seq)
+	}
+	fmt.Fprintf(&event, "event: %s\ndata: %s\n\n", message.Type, data)
+
+	if _, err := c.Writer.WriteString(event.String()); err != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// PollEvents returns the events of the user after last_seq, waiting for one
+// when there are none yet
+// @Summary Long-poll realtime events
+// @Description Return the events websockets carry that follow last_seq, waiting up to timeout seconds for one. Poll again with the returned resume_token and last_seq, which also acks the events returned.
+// @Tags Calls
+// @Produce json
+// @Param resume_token query string false "Token of the session to resume"
+// @Param last_seq query int false "Last sequence received"
+// @Param timeout query int false "Seconds to wait for an event, 25 by default, 55 at most"
+// @Success 200 {object} models.EventBatch
+// @Failure 401 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /events/poll [get]
+func (h *WebSocketHandler) PollEvents(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if h.refuseWhileDraining(c) {
+		return
+	}
+
+	timeout := defaultPollTimeout
+	if seconds, err := strconv.Atoi(c.Query("timeout")); err == nil && seconds >= 0 {
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > maxPollTimeout {
+			timeout = maxPollTimeout
+		}
+	}
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + 10*time.Second)); err != nil {
+		log.Printf("Failed to extend write deadline of poll: %v", err)
+	}
+
+	lastSeq, _ := strconv.ParseUint(c.Query("last_seq"), 10, 64)
+	remoteIP, reserved := h.reserveHTTPStream(c, userID)
+	if !reserved {
+		return
+	}
+	stream, connected, err := h.openHTTPStream(userID, remoteIP, c.Query("resume_token"), lastSeq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "poll_failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	// Events pushed after the reply stay buffered in the session for the
+	// next poll
+	defer h.closeHTTPStream(stream, connected)
+	if !connected.Resumed {
+		h.loadChannelSubscriptions(userID)
+	}
+
+	batch := models.EventBatch{
+		ResumeToken:    connected.ResumeToken,
+		Resumed:        connected.Resumed,
+		ResyncRequired: connected.ResyncRequired,
+		LastSeq:        lastSeq,
+		Events:         []models.WSMessage{},
+	}
+	if !connected.Resumed || connected.ResyncRequired {
+		batch.LastSeq = connected.LastSeq
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// The replay is already queued, otherwise wait for the next event
+	if len(stream.events) == 0 && !connected.ResyncRequired {
+		select {
+		case message := <-stream.events:
+			batch.Events = append(batch.Events, message)
+		case <-timer.C:
+		case <-stream.done:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+	for len(stream.events) > 0 {
+		batch.Events = append(batch.Events, <-stream.events)
+	}
+
+	for _, message := range batch.Events {
+		if message.Seq > batch.LastSeq {
+			batch.LastSeq = message.Seq
+		}
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// AckEvents acks the events of a session up to seq
+// @Summary Ack realtime events
+// @Description Ack the events of an SSE session up to seq, they are no longer replayed on resume
+// @Tags Calls
+// @Accept json
+// @Produce json
+// @Param request body requests.AckEventsRequest true "Session and last sequence handled"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /events/ack [post]
+func (h *WebSocketHandler) AckEvents(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req requests.AckEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_request",
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	h.mutex.RLock()
+	session, exists := h.sessions[req.ResumeToken]
+	h.mutex.RUnlock()
+	if !exists || session.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "session_not_found",
+			"message": "Session expired or unknown",
+		})
+		return
+	}
+
+	session.ack(req.Seq)
+	c.JSON(http.StatusOK, gin.H{"message": "Events acked"})
+}
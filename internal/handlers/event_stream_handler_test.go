@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHTTPStreamsShareConnectionLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := &WebSocketHandler{
+		userConnCount:   make(map[uint]int),
+		ipConnCount:     make(map[string]int),
+		maxConnsPerUser: 2,
+		maxConnsPerIP:   3,
+	}
+
+	reserve := func(userID uint) int {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, "/events/poll", nil)
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		if _, reserved := handler.reserveHTTPStream(c, userID); !reserved {
+			return recorder.Code
+		}
+		return http.StatusOK
+	}
+
+	// A websocket of the user takes one of their slots
+	if err := handler.reserveConnection(1, "192.0.2.1"); err != nil {
+		t.Fatalf("reserveConnection() error = %v", err)
+	}
+	if code := reserve(1); code != http.StatusOK {
+		t.Fatalf("second connection of the user refused with %d", code)
+	}
+	if code := reserve(1); code != http.StatusTooManyRequests {
+		t.Errorf("third connection of the user got %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// Another user from the same IP fills it
+	if code := reserve(2); code != http.StatusOK {
+		t.Fatalf("connection of another user refused with %d", code)
+	}
+	if code := reserve(3); code != http.StatusTooManyRequests {
+		t.Errorf("fourth connection from the IP got %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	handler.releaseConnection(2, "192.0.2.1")
+	if code := reserve(3); code != http.StatusOK {
+		t.Errorf("connection after a release refused with %d", code)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"social_server/internal/middleware"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dummy for swaggo
+var _ = postgres.Poll{}
+
+type PollHandler struct {
+	pollService *services.PollService
+}
+
+func NewPollHandler(pollService *services.PollService) *PollHandler {
+	return &PollHandler{
+		pollService: pollService,
+	}
+}
+
+// GetPoll returns a poll with its results
+// @Summary Get poll
+// @Description Get a chat or post poll with its tallies and the votes of the current user
+// @Security BearerAuth
+// @Tags Polls
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} postgres.Poll "Poll with results"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Poll not found"
+// @Router /polls/{id} [get]
+func (h *PollHandler) GetPoll(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.PollUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_poll_id", "message": err.Error()})
+		return
+	}
+
+	poll, err := h.pollService.GetPoll(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "get_poll_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": poll})
+}
+
+// Vote votes in a poll
+// @Summary Vote in poll
+// @Description Vote for one or more options, replacing any previous vote of the user
+// @Security BearerAuth
+// @Tags Polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Param request body requests.VotePollRequest true "Chosen options"
+// @Success 200 {object} postgres.Poll "Poll with results"
+// @Failure 400 {object} map[string]interface{} "Invalid vote or poll closed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Poll not found"
+// @Router /polls/{id}/votes [post]
+func (h *PollHandler) Vote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.PollUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_poll_id", "message": err.Error()})
+		return
+	}
+
+	var req requests.VotePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	poll, err := h.pollService.Vote(uri.ID, userID, req.OptionIDs)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "vote_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": poll})
+}
+
+// RetractVote removes the vote of the current user
+// @Summary Retract vote
+// @Description Remove the votes of the current user while the poll is open
+// @Security BearerAuth
+// @Tags Polls
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} postgres.Poll "Poll with results"
+// @Failure 400 {object} map[string]interface{} "Poll closed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Poll not found"
+// @Router /polls/{id}/votes [delete]
+func (h *PollHandler) RetractVote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.PollUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_poll_id", "message": err.Error()})
+		return
+	}
+
+	poll, err := h.pollService.RetractVote(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "retract_vote_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": poll})
+}
+
+// ClosePoll closes a poll
+// @Summary Close poll
+// @Description Close a poll and freeze its results. Only the creator, or a chat room admin, can close it
+// @Security BearerAuth
+// @Tags Polls
+// @Produce json
+// @Param id path int true "Poll ID"
+// @Success 200 {object} postgres.Poll "Closed poll with results"
+// @Failure 400 {object} map[string]interface{} "Poll already closed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission denied"
+// @Failure 404 {object} map[string]interface{} "Poll not found"
+// @Router /polls/{id}/close [post]
+func (h *PollHandler) ClosePoll(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "User not authenticated"})
+		return
+	}
+
+	var uri requests.PollUriRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_poll_id", "message": err.Error()})
+		return
+	}
+
+	poll, err := h.pollService.ClosePoll(uri.ID, userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": "close_poll_failed", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": poll})
+}
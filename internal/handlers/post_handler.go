@@ -44,12 +44,17 @@ func NewPostHandler(postService *services.PostService) *PostHandler {
 // @Accept multipart/form-data
 // @Produce json
 // @Security BearerAuth
-// @Param type formData string true "Post type (text, image, video, audio, link)"
+// @Param type formData string true "Post type (text, image, video, audio, link, poll)"
 // @Param content formData string false "Post content"
 // @Param privacy formData string false "Post privacy (public, friends, private)"
 // @Param location formData string false "Post location"
 // @Param tags formData string false "Post tags (comma separated)"
 // @Param files formData file false "Media files (images, videos, documents)"
+// @Param poll_question formData string false "Poll question, for poll posts"
+// @Param poll_options formData []string false "Poll options, 2 to 12, for poll posts" collectionFormat(multi)
+// @Param poll_multiple_choice formData bool false "Allow voting for several options"
+// @Param poll_anonymous formData bool false "Hide who voted for what"
+// @Param poll_closes_at formData string false "Poll closing time (RFC3339)"
 // @Success 201 {object} postgres.Post "Created post"
 // @Failure 400 {object} map[string]interface{} "Invalid request"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
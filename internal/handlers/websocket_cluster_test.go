@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"social_server/internal/bus"
+	"social_server/internal/models"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testCluster is the memory bus, presence and session store shared by the
+// nodes of a test
+type testCluster struct {
+	bus      *bus.MemoryBus
+	presence *bus.MemoryPresence
+	sessions *bus.MemorySessionStore
+}
+
+func newTestCluster(t *testing.T) *testCluster {
+	t.Helper()
+
+	cluster := &testCluster{
+		bus:      bus.NewMemoryBus(),
+		presence: bus.NewMemoryPresence(),
+		sessions: bus.NewMemorySessionStore(),
+	}
+	t.Cleanup(func() { cluster.bus.Close() })
+	return cluster
+}
+
+// node is a websocket handler of the cluster, without the services only
+// requests need
+func (c *testCluster) node(nodeID string) *WebSocketHandler {
+	handler := &WebSocketHandler{
+		cluster:            bus.NewMemoryCluster(nodeID, c.bus, c.presence, c.sessions),
+		connections:        make(map[string]*WebSocketConnection),
+		rooms:              make(map[string]*models.Room),
+		userConns:          make(map[uint]map[string]*WebSocketConnection),
+		calls:              make(map[uint]map[uint]string),
+		sessions:           make(map[string]*wsSession),
+		userSessions:       make(map[uint]map[string]*wsSession),
+		handoffs:           make(map[string]chan sessionState),
+		topics:             make(map[string]map[string]*WebSocketConnection),
+		channelSubscribers: make(map[uint]map[uint]bool),
+		userChannels:       make(map[uint][]uint),
+	}
+	handler.joinCluster()
+	return handler
+}
+
+// connectTestStream opens a session of the user on the node and returns its
+// stream, past the connected message
+func connectTestStream(t *testing.T, node *WebSocketHandler, userID uint) *httpStream {
+	t.Helper()
+
+	stream, _ := resumeTestStream(t, node, userID, "", 0)
+	return stream
+}
+
+// resumeTestStream opens a stream resuming the session of the token
+func resumeTestStream(t *testing.T, node *WebSocketHandler, userID uint, token string, lastSeq uint64) (*httpStream, models.ConnectedMessage) {
+	t.Helper()
+
+	stream := newHTTPStream("")
+	if err := node.openSession(stream, userID, token, lastSeq, models.ConnectedMessage{}); err != nil {
+		t.Fatalf("openSession() error = %v", err)
+	}
+	message := receive(t, stream)
+	if message.Type != models.MessageTypeConnected {
+		t.Fatalf("first message is %q, want %q", message.Type, models.MessageTypeConnected)
+	}
+	var connected models.ConnectedMessage
+	if err := json.Unmarshal(message.Data, &connected); err != nil {
+		t.Fatalf("invalid connected message: %v", err)
+	}
+	return stream, connected
+}
+
+func receive(t *testing.T, stream *httpStream) models.WSMessage {
+	t.Helper()
+
+	select {
+	case message := <-stream.events:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+		return models.WSMessage{}
+	}
+}
+
+func expectNothing(t *testing.T, stream *httpStream) {
+	t.Helper()
+
+	select {
+	case message := <-stream.events:
+		t.Fatalf("unexpected %q message", message.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRouteToUserOnAnotherNode(t *testing.T) {
+	cluster := newTestCluster(t)
+	nodeA, nodeB := cluster.node("a"), cluster.node("b")
+
+	// User 1 has a device on each node, user 2 only on b
+	streamA := connectTestStream(t, nodeA, 1)
+	streamB := connectTestStream(t, nodeB, 1)
+	other := connectTestStream(t, nodeB, 2)
+
+	nodeA.sendToUser(1, models.WSMessage{Type: models.MessageTypeChatReceiveMessage, From: 2})
+	for _, stream := range []*httpStream{streamA, streamB} {
+		if message := receive(t, stream); message.Type != models.MessageTypeChatReceiveMessage || message.Seq != 1 {
+			t.Errorf("received %q with seq %d, want %q with seq 1", message.Type, message.Seq, models.MessageTypeChatReceiveMessage)
+		}
+		expectNothing(t, stream)
+	}
+	expectNothing(t, other)
+
+	nodeA.sendToUser(2, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	receive(t, other)
+	expectNothing(t, streamA)
+}
+
+func TestRouteToChannelOnAnotherNode(t *testing.T) {
+	cluster := newTestCluster(t)
+	nodeA, nodeB := cluster.node("a"), cluster.node("b")
+
+	subscriber := connectTestStream(t, nodeB, 3)
+	nodeB.addChannelSubscription(10, 3)
+
+	nodeA.route(delivery{ChannelID: 10}, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	receive(t, subscriber)
+
+	// Leaving the channel on one node is applied by the node of the user
+	nodeA.route(delivery{UserIDs: []uint{3}, Subscription: 10}, models.WSMessage{Type: models.MessageTypeChannelUnsubscribed})
+	receive(t, subscriber)
+
+	nodeA.route(delivery{ChannelID: 10}, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	expectNothing(t, subscriber)
+}
+
+func TestClusterPresence(t *testing.T) {
+	cluster := newTestCluster(t)
+	nodeA, nodeB := cluster.node("a"), cluster.node("b")
+
+	connectTestStream(t, nodeA, 1)
+	connectTestStream(t, nodeB, 1)
+
+	nodes, _ := cluster.presence.Nodes(context.Background(), []uint{1})
+	sort.Strings(nodes[1])
+	if len(nodes[1]) != 2 || nodes[1][0] != "a" || nodes[1][1] != "b" {
+		t.Fatalf("user 1 is on nodes %v, want [a b]", nodes[1])
+	}
+	if !nodeA.connectedElsewhere(1) {
+		t.Error("connectedElsewhere() = false on a, want the device on b")
+	}
+
+	// The session on b expires, the user is only on a
+	for _, session := range nodeB.sessions {
+		nodeB.closeSession(session)
+	}
+	nodes, _ = cluster.presence.Nodes(context.Background(), []uint{1})
+	if len(nodes[1]) != 1 || nodes[1][0] != "a" {
+		t.Errorf("user 1 is on nodes %v, want [a]", nodes[1])
+	}
+	if nodeA.connectedElsewhere(1) {
+		t.Error("connectedElsewhere() = true on a, want false once b closed its session")
+	}
+
+	// A node restarting forgets the users it had
+	cluster.node("a")
+	if nodes, _ := cluster.presence.Nodes(context.Background(), []uint{1}); len(nodes[1]) != 0 {
+		t.Errorf("user 1 is on nodes %v after a restarted, want none", nodes[1])
+	}
+}
+
+func TestRouteFromBusHandler(t *testing.T) {
+	cluster := newTestCluster(t)
+	nodeA, nodeB := cluster.node("a"), cluster.node("b")
+
+	subscriber := connectTestStream(t, nodeA, 1)
+	nodeA.addChannelSubscription(10, 1)
+
+	// Node b relays what a sends to channel 20 to channels 10 and 30,
+	// publishing to the broadcast topic from a handler of that same topic
+	if _, err := cluster.bus.Subscribe(bus.BroadcastTopic, func(payload []byte) {
+		var envelope busEnvelope
+		if json.Unmarshal(payload, &envelope) == nil && envelope.Origin == "a" && envelope.Delivery.ChannelID == 20 {
+			nodeB.route(delivery{ChannelID: 10}, *envelope.Message)
+			nodeB.route(delivery{ChannelID: 30}, *envelope.Message)
+		}
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	const count = 500
+	for i := 0; i < count; i++ {
+		nodeA.route(delivery{ChannelID: 20}, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	}
+	for i := 0; i < count; i++ {
+		receive(t, subscriber)
+	}
+}
+
+func TestResumeOnAnotherNode(t *testing.T) {
+	cluster := newTestCluster(t)
+	nodeA, nodeB := cluster.node("a"), cluster.node("b")
+
+	stream, connected := resumeTestStream(t, nodeA, 1, "", 0)
+	for i := 0; i < 3; i++ {
+		nodeA.sendToUser(1, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+		receive(t, stream)
+	}
+
+	// The client loses its stream and misses two events
+	nodeA.dropStream(stream)
+	for i := 0; i < 2; i++ {
+		nodeA.sendToUser(1, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	}
+
+	resumed, reconnected := resumeTestStream(t, nodeB, 1, connected.ResumeToken, 3)
+	if !reconnected.Resumed || reconnected.ResyncRequired || reconnected.ResumeToken != connected.ResumeToken || reconnected.LastSeq != 5 {
+		t.Fatalf("connected = %+v, want the session resumed at 5", reconnected)
+	}
+	for _, want := range []uint64{4, 5} {
+		if message := receive(t, resumed); message.Seq != want {
+			t.Errorf("replayed seq %d, want %d", message.Seq, want)
+		}
+	}
+
+	// The session moved with the user, events from either node follow
+	if len(nodeA.sessions) != 0 {
+		t.Errorf("node a still holds %d sessions", len(nodeA.sessions))
+	}
+	nodes, _ := cluster.presence.Nodes(context.Background(), []uint{1})
+	if len(nodes[1]) != 1 || nodes[1][0] != "b" {
+		t.Errorf("user 1 is on nodes %v, want [b]", nodes[1])
+	}
+	nodeA.sendToUser(1, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	if message := receive(t, resumed); message.Seq != 6 {
+		t.Errorf("next event has seq %d, want 6", message.Seq)
+	}
+	expectNothing(t, stream)
+}
+
+func TestResumeAfterDrain(t *testing.T) {
+	cluster := newTestCluster(t)
+	nodeA, nodeB := cluster.node("a"), cluster.node("b")
+
+	stream, connected := resumeTestStream(t, nodeA, 1, "", 0)
+	for i := 0; i < 3; i++ {
+		nodeA.sendToUser(1, models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- nodeA.Drain(context.Background()) }()
+
+	// The client handles two events before the node goes away
+	for _, want := range []models.MessageType{
+		models.MessageTypeChatReceiveMessage,
+		models.MessageTypeChatReceiveMessage,
+		models.MessageTypeChatReceiveMessage,
+		models.MessageTypeServerGoingAway,
+	} {
+		if message := receive(t, stream); message.Type != want {
+			t.Fatalf("received %q, want %q", message.Type, want)
+		}
+	}
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatalf("Drain() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Drain() did not return")
+	}
+
+	resumed, reconnected := resumeTestStream(t, nodeB, 1, connected.ResumeToken, 2)
+	if !reconnected.Resumed || reconnected.LastSeq != 3 {
+		t.Fatalf("connected = %+v, want the session resumed at 3", reconnected)
+	}
+	if message := receive(t, resumed); message.Seq != 3 {
+		t.Errorf("replayed seq %d, want 3", message.Seq)
+	}
+
+	// The saved session was taken, it moves on from b from now on
+	if _, err := cluster.sessions.Take(context.Background(), connected.ResumeToken); !errors.Is(err, bus.ErrSessionNotFound) {
+		t.Errorf("Take() error = %v, want %v", err, bus.ErrSessionNotFound)
+	}
+	_, again := resumeTestStream(t, cluster.node("c"), 1, connected.ResumeToken, 3)
+	if !again.Resumed || again.LastSeq != 3 {
+		t.Errorf("connected = %+v, want the session resumed from b", again)
+	}
+}
+
+func TestResumeUnknownSession(t *testing.T) {
+	cluster := newTestCluster(t)
+	nodeA, nodeB := cluster.node("a"), cluster.node("b")
+
+	_, connected := resumeTestStream(t, nodeA, 1, "", 0)
+
+	// Another user cannot take the session over
+	_, other := resumeTestStream(t, nodeB, 2, connected.ResumeToken, 0)
+	if other.Resumed || !other.ResyncRequired || other.ResumeToken == connected.ResumeToken {
+		t.Errorf("connected = %+v, want a new session for user 2", other)
+	}
+	if len(nodeA.sessions) != 1 {
+		t.Errorf("node a holds %d sessions, want the session of user 1", len(nodeA.sessions))
+	}
+
+	_, unknown := resumeTestStream(t, nodeB, 1, "unknown", 0)
+	if unknown.Resumed || !unknown.ResyncRequired {
+		t.Errorf("connected = %+v, want a new session", unknown)
+	}
+}
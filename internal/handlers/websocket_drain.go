@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"social_server/internal/models"
+	"social_server/internal/models/constants"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Clients told the node is going away reconnect within reconnectSpread, so
+// the other nodes do not get them all at once
+const (
+	reconnectSpread    = 5 * time.Second
+	drainCheckInterval = 50 * time.Millisecond
+
+	// Migrating calls not resumed in time are ended by any node
+	migrationSweepInterval = 5 * time.Second
+)
+
+// Drain stops accepting connections, tells every client to reconnect
+// elsewhere and closes their streams once what was queued for them is sent.
+// Their sessions are saved for them to resume on the node they reconnect to.
+// Ongoing calls with a party here are marked migrating, not ended. It
+// returns the context error when the sends did not finish in time.
+func (h *WebSocketHandler) Drain(ctx context.Context) error {
+	if !h.draining.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	h.mutex.RLock()
+	conns := make([]*WebSocketConnection, 0, len(h.connections))
+	for _, conn := range h.connections {
+		conns = append(conns, conn)
+	}
+	sessions := make([]*wsSession, 0, len(h.sessions))
+	for _, session := range h.sessions {
+		sessions = append(sessions, session)
+	}
+	h.mutex.RUnlock()
+
+	migrating := h.migrateCalls(conns)
+	log.Printf("Draining %d websockets and %d sessions, %d calls migrating", len(conns), len(sessions), len(migrating))
+
+	for _, session := range sessions {
+		if full := session.send(models.WSMessage{
+			Type:      models.MessageTypeServerGoingAway,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Data: h.marshalData(models.ServerGoingAwayMessage{
+				Reason:         "shutdown",
+				ReconnectIn:    rand.Intn(int(reconnectSpread / time.Millisecond)),
+				MigratingCalls: migrating,
+			}),
+		}); full != nil {
+			h.dropStream(full)
+		}
+	}
+
+	err := h.waitForSends(ctx, sessions)
+
+	for _, conn := range conns {
+		closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server going away")
+		if err := conn.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second)); err != nil {
+			log.Printf("Failed to send close message to %s: %v", conn.ConnID, err)
+		}
+		h.unregisterConnection(conn.ConnID)
+	}
+	for _, session := range sessions {
+		if stream, ok := session.attachedConn().(*httpStream); ok {
+			stream.close()
+		}
+		// Kept for the client to resume on another node
+		if h.closeSession(session) {
+			h.saveSession(session)
+		}
+	}
+
+	return err
+}
+
+// migrateCalls marks the ongoing calls of the devices here as migrating and
+// tells their parties, wherever they are connected
+func (h *WebSocketHandler) migrateCalls(conns []*WebSocketConnection) []uint {
+	h.mutex.RLock()
+	inCall := make(map[uint]bool)
+	for _, conn := range conns {
+		if conn.RoomID != "" {
+			inCall[conn.UserID] = true
+		}
+		for _, parties := range h.calls {
+			if parties[conn.UserID] == conn.ConnID {
+				inCall[conn.UserID] = true
+			}
+		}
+	}
+	h.mutex.RUnlock()
+
+	var migrating []uint
+	seen := make(map[uint]bool)
+	for userID := range inCall {
+		call, err := h.callService.GetActiveCall(userID)
+		if err != nil || seen[call.ID] || call.Status != string(constants.CallStatusOngoing) {
+			continue
+		}
+		seen[call.ID] = true
+
+		if err := h.callService.MigrateCall(call.ID); err != nil {
+			log.Printf("Failed to migrate call %d: %v", call.ID, err)
+			continue
+		}
+		migrating = append(migrating, call.ID)
+		h.sendCallStatus(call.ID, call.CallerID, call.CalleeID, call.RoomID, constants.CallStatusMigrating)
+	}
+	return migrating
+}
+
+// expireMigrations ends the calls whose parties did not come back within
+// services.CallMigrationTimeout and tells them
+func (h *WebSocketHandler) expireMigrations() {
+	ticker := time.NewTicker(migrationSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		calls, err := h.callService.EndExpiredMigrations()
+		if err != nil {
+			log.Printf("Failed to end expired call migrations: %v", err)
+		}
+		for _, call := range calls {
+			log.Printf("Call %d ended, its parties did not come back after migrating", call.ID)
+			h.sendCallStatus(call.ID, call.CallerID, call.CalleeID, call.RoomID, constants.CallStatusEnded)
+			h.route(delivery{}, models.WSMessage{}, callUpdate{CallID: call.ID, Ended: true})
+		}
+	}
+}
+
+// sendCallStatus tells the parties of a call and its room about its status
+func (h *WebSocketHandler) sendCallStatus(callID, callerID uint, calleeID *uint, roomID string, status constants.CallStatus) {
+	userIDs := []uint{callerID}
+	if calleeID != nil {
+		userIDs = append(userIDs, *calleeID)
+	}
+
+	h.route(delivery{UserIDs: userIDs, RoomID: roomID}, models.WSMessage{
+		Type:      models.MessageTypeCallStatus,
+		RoomID:    roomID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data: h.marshalData(models.CallStatusMessage{
+			CallID: callID,
+			Status: string(status),
+			RoomID: roomID,
+		}),
+	})
+}
+
+// waitForSends waits until the streams of the sessions sent what was queued
+func (h *WebSocketHandler) waitForSends(ctx context.Context, sessions []*wsSession) error {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		pending := 0
+		for _, session := range sessions {
+			switch stream := session.attachedConn().(type) {
+			case *WebSocketConnection:
+				pending += len(stream.SendChan)
+			case *httpStream:
+				pending += len(stream.events)
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("failed to drain %d queued events: %w", pending, ctx.Err())
+		}
+	}
+}
+
+// refuseWhileDraining turns away new streams once the node is going away
+func (h *WebSocketHandler) refuseWhileDraining(c *gin.Context) bool {
+	if !h.draining.Load() {
+		return false
+	}
+
+	c.Header("Retry-After", "1")
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+		"error":   "server_going_away",
+		"message": "Server is shutting down, reconnect to another instance",
+	})
+	return true
+}
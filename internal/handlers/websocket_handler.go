@@ -1,14 +1,24 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"social_server/internal/bus"
+	"social_server/internal/codec"
+	"social_server/internal/config"
 	"social_server/internal/middleware"
 	"social_server/internal/models"
+	"social_server/internal/models/constants"
+	"social_server/internal/models/postgres"
 	"social_server/internal/services"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,64 +29,256 @@ type WebSocketHandler struct {
 	authService *services.AuthService
 	callService *services.CallService
 	chatService *services.ChatService
-	// onlineStatusService *services.OnlineStatusService
-	connections map[string]*WebSocketConnection
-	rooms       map[string]*models.Room
-	userConns   map[uint]string // userID -> connectionID
-	mutex       sync.RWMutex
-	upgrader    websocket.Upgrader
+	cluster     *bus.Cluster
+
+	onlineStatusService *services.OnlineStatusService
+	postService         *services.PostService
+
+	connections  map[string]*WebSocketConnection
+	rooms        map[string]*models.Room
+	userConns    map[uint]map[string]*WebSocketConnection // userID -> connID -> connection, one per device
+	calls        map[uint]map[uint]string                 // callID -> userID -> connID of the device in the call, "" while ringing, shared by all nodes
+	sessions     map[string]*wsSession                    // resume token -> session, attached or not
+	userSessions map[uint]map[string]*wsSession           // userID -> resume token -> session
+	handoffs     map[string]chan sessionState             // resume token -> resume waiting for another node
+	mutex        sync.RWMutex
+	upgrader     websocket.Upgrader
+
+	compressionLevel int // permessage-deflate level of the connections that negotiated it
+
+	// Websockets of each user and IP on this node, against their limits
+	userConnCount   map[uint]int
+	ipConnCount     map[string]int
+	maxConnsPerUser int
+	maxConnsPerIP   int
+	maxMessageSize  int64
+
+	draining atomic.Bool // Set by Drain, new streams are refused
+
+	topics map[string]map[string]*WebSocketConnection // topic -> connID -> subscribed connection
+
+	// Connected subscribers of channels, channel events are not addressed to them
+	channelSubscribers map[uint]map[uint]bool // channelID -> userIDs
+	userChannels       map[uint][]uint        // userID -> channelIDs
+	channelMutex       sync.RWMutex
 }
 
 type WebSocketConnection struct {
 	Conn      *websocket.Conn
 	UserID    uint
 	ConnID    string
-	RoomID    string
+	DeviceID  string // Optional, given by the client
+	RemoteIP  string
+	Protocol  string // Negotiated subprotocol
+	Codec     codec.Codec
+	RoomID    string          // Call room of this device
+	Topics    map[string]bool // Subscribed topics, guarded by the handler mutex
+	Session   *wsSession
 	IsActive  bool
 	LastPing  time.Time
 	SendChan  chan []byte
 	CloseChan chan bool
 }
 
-func NewWebSocketHandler(authService *services.AuthService, callService *services.CallService, chatService *services.ChatService,
+// busTimeout bounds each call to the bus and the presence registry
+const busTimeout = 5 * time.Second
+
+// delivery says which devices get a message. The node handling a request
+// resolves it against its own connections and publishes it for the others.
+type delivery struct {
+	UserIDs      []uint `json:"user_ids,omitempty"`     // Every device of these users...
+	CallID       uint   `json:"call_id,omitempty"`      // ...or only their device in this call once bound
+	RoomID       string `json:"room_id,omitempty"`      // Devices in this call room...
+	ExcludeUser  uint   `json:"exclude_user,omitempty"` // ...except those of this user
+	ChannelID    uint   `json:"channel_id,omitempty"`   // Connected subscribers of this channel
+	Subscription uint   `json:"subscription,omitempty"` // Channel the users subscribed to or left, by message type
+	Topic        string `json:"topic,omitempty"`        // Connections subscribed to this topic...
+	TopicUsers   []uint `json:"topic_users,omitempty"`  // ...only those of these users when set
+	Recheck      string `json:"recheck,omitempty"`      // Topic, or kind of topics, whose subscribers of UserIDs, all when empty, may have lost access
+}
+
+// callUpdate binds a user of a call to one device, the connID is empty while
+// ringing. Ended calls are forgotten.
+type callUpdate struct {
+	CallID uint   `json:"call_id"`
+	UserID uint   `json:"user_id,omitempty"`
+	ConnID string `json:"conn_id,omitempty"`
+	Ended  bool   `json:"ended,omitempty"`
+}
+
+// busEnvelope is what a node publishes for the others
+type busEnvelope struct {
+	Origin   string            `json:"origin"`
+	Delivery delivery          `json:"delivery"`
+	Message  *models.WSMessage `json:"message,omitempty"`
+	Calls    []callUpdate      `json:"calls,omitempty"`
+	Handoff  *sessionHandoff   `json:"handoff,omitempty"` // Asks for a session...
+	Session  *sessionState     `json:"session,omitempty"` // ...sent back to the node asking
+}
 
-// onlineStatusService *services.OnlineStatusService
+func NewWebSocketHandler(authService *services.AuthService, callService *services.CallService, chatService *services.ChatService,
+	friendService *services.FriendService, onlineStatusService *services.OnlineStatusService, postService *services.PostService, cluster *bus.Cluster,
+	realtime config.RealtimeConfig, allowedOrigins []string,
 ) *WebSocketHandler {
 	handler := &WebSocketHandler{
 		authService: authService,
 		callService: callService,
 		chatService: chatService,
-		// onlineStatusService: onlineStatusService,
-		connections: make(map[string]*WebSocketConnection),
-		rooms:       make(map[string]*models.Room),
-		userConns:   make(map[uint]string),
+		cluster:     cluster,
+
+		onlineStatusService: onlineStatusService,
+		postService:         postService,
+
+		connections:  make(map[string]*WebSocketConnection),
+		rooms:        make(map[string]*models.Room),
+		userConns:    make(map[uint]map[string]*WebSocketConnection),
+		calls:        make(map[uint]map[uint]string),
+		sessions:     make(map[string]*wsSession),
+		userSessions: make(map[uint]map[string]*wsSession),
+		handoffs:     make(map[string]chan sessionState),
+		topics:       make(map[string]map[string]*WebSocketConnection),
+
+		channelSubscribers: make(map[uint]map[uint]bool),
+		userChannels:       make(map[uint][]uint),
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// In production, implement proper origin checking
-				return true
-			},
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			CheckOrigin:       originAllowed(allowedOrigins),
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			Subprotocols:      models.WSProtocols,
+			EnableCompression: realtime.Compression,
 		},
+		compressionLevel: realtime.CompressionLevel,
+		userConnCount:    make(map[uint]int),
+		ipConnCount:      make(map[string]int),
+		maxConnsPerUser:  realtime.MaxConnsPerUser,
+		maxConnsPerIP:    realtime.MaxConnsPerIP,
+		maxMessageSize:   realtime.MaxMessageSize,
 	}
 
-	// Register callback for online status changes
-	// onlineStatusService.RegisterStatusCallback(handler.handleOnlineStatusChange)
+	// Register callback for online status changes, a user connected to
+	// several nodes is reported by the first and the last of them
+	onlineStatusService.RegisterStatusCallback(handler.handleOnlineStatusChange)
+	onlineStatusService.SetPresenceLookup(handler.connectedElsewhere)
+
+	// Register callback for chat events produced outside of websocket requests
+	chatService.RegisterEventCallback(handler.handleChatEvent)
+	postService.RegisterEventCallback(handler.handlePostEvent)
+	friendService.RegisterRelationCallback(handler.handleRelationLost)
+
+	// Receive what other nodes route to the users connected here
+	handler.joinCluster()
+
+	// Retry unacked critical events and drop sessions not resumed in time
+	go handler.maintainSessions()
+
+	// End the calls left migrating by a node that went away
+	go handler.expireMigrations()
 
 	return handler
 }
 
+// joinCluster subscribes the node to the bus and keeps its presence alive
+func (h *WebSocketHandler) joinCluster() {
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	if err := h.cluster.Presence.Reset(ctx, h.cluster.NodeID); err != nil {
+		log.Printf("Failed to reset presence of node %s: %v", h.cluster.NodeID, err)
+	}
+	if err := h.cluster.Presence.Heartbeat(ctx, h.cluster.NodeID); err != nil {
+		log.Printf("Failed to send heartbeat of node %s: %v", h.cluster.NodeID, err)
+	}
+	cancel()
+
+	for _, topic := range []string{bus.NodeTopic(h.cluster.NodeID), bus.BroadcastTopic} {
+		if _, err := h.cluster.Bus.Subscribe(topic, h.handleBusMessage); err != nil {
+			log.Printf("Failed to subscribe node %s to %s: %v", h.cluster.NodeID, topic, err)
+		}
+	}
+
+	go h.keepPresence()
+}
+
+func (h *WebSocketHandler) keepPresence() {
+	ticker := time.NewTicker(h.cluster.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+		if err := h.cluster.Presence.Heartbeat(ctx, h.cluster.NodeID); err != nil {
+			log.Printf("Failed to send heartbeat of node %s: %v", h.cluster.NodeID, err)
+		}
+		cancel()
+	}
+}
+
+// updatePresence records a device of the user connecting to or leaving this node
+func (h *WebSocketHandler) updatePresence(userID uint, connected bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+
+	var err error
+	if connected {
+		err = h.cluster.Presence.Connect(ctx, userID, h.cluster.NodeID)
+	} else {
+		err = h.cluster.Presence.Disconnect(ctx, userID, h.cluster.NodeID)
+	}
+	if err != nil {
+		log.Printf("Failed to update presence of user %d on node %s: %v", userID, h.cluster.NodeID, err)
+	}
+}
+
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
+	if h.refuseWhileDraining(c) {
+		return
+	}
+
+	// A client asking only for subprotocols we do not speak is refused, one
+	// asking for none gets the first version
+	if requested := websocket.Subprotocols(c.Request); len(requested) > 0 && !supportsProtocol(requested) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported_protocol",
+			"message": fmt.Sprintf("supported websocket protocols are %s", strings.Join(models.WSProtocols, ", ")),
+		})
+		return
+	}
+
+	messageCodec, exists := codec.Lookup(c.Query("encoding"))
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported_encoding",
+			"message": fmt.Sprintf("supported websocket encodings are %s", strings.Join(codec.Encodings, ", ")),
+		})
+		return
+	}
+
+	if !h.upgrader.CheckOrigin(c.Request) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   "origin_not_allowed",
+			"message": fmt.Sprintf("origin %s is not allowed", c.GetHeader("Origin")),
+		})
+		return
+	}
+
+	remoteIP := c.ClientIP()
+	if err := h.reserveConnection(userID, remoteIP); err != nil {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error":   "too_many_connections",
+			"message": err.Error(),
+		})
+		return
+	}
 
 	// Upgrade to WebSocket
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
+		h.mutex.Lock()
+		h.releaseConnection(userID, remoteIP)
+		h.mutex.Unlock()
 		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"error":   "cannot_upgrade_websocket",
 			"message": fmt.Sprintf("failed to upgrade to WebSocket: %v", err),
@@ -84,77 +286,231 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
+	if h.upgrader.EnableCompression {
+		conn.SetCompressionLevel(h.compressionLevel)
+	}
+
+	protocol := conn.Subprotocol()
+	if protocol == "" {
+		protocol = models.WSProtocolV1
+	}
+
 	// Create WebSocket connection
 	connID := h.generateConnectionID()
 	wsConn := &WebSocketConnection{
 		Conn:      conn,
 		UserID:    userID,
 		ConnID:    connID,
+		DeviceID:  c.Query("device_id"),
+		RemoteIP:  remoteIP,
+		Protocol:  protocol,
+		Codec:     messageCodec,
 		IsActive:  true,
 		LastPing:  time.Now(),
-		SendChan:  make(chan []byte, 256),
+		SendChan:  make(chan []byte, replayBufferSize+256), // Room for a full replay
 		CloseChan: make(chan bool),
 	}
 
-	// Register connection
-	h.registerConnection(wsConn)
+	// Register connection, within the session of the resume token if it is
+	// still there. The connected message and the missed events go out first.
+	lastSeq, _ := strconv.ParseUint(c.Query("last_seq"), 10, 64)
+	connected := models.ConnectedMessage{
+		ConnID:   connID,
+		DeviceID: wsConn.DeviceID,
+		Protocol: protocol,
+		Encoding: messageCodec.Name(),
+	}
+	if err := h.openSession(wsConn, userID, c.Query("resume_token"), lastSeq, connected); err != nil {
+		log.Printf("Failed to open websocket session for user %d: %v", userID, err)
+		conn.Close()
+		h.mutex.Lock()
+		h.releaseConnection(userID, remoteIP)
+		h.mutex.Unlock()
+		return
+	}
+	h.loadChannelSubscriptions(userID)
 
 	// Set user online in status service
-	// err = h.onlineStatusService.SetUserOnline(userID, connID)
-	// if err != nil {
-	// 	log.Printf("Failed to set user %d online: %v", userID, err)
-	// }
+	if err := h.onlineStatusService.ConnectDevice(userID, connID); err != nil {
+		log.Printf("Failed to set user %d online: %v", userID, err)
+	}
 
 	// Start connection handlers
 	go h.handleConnection(wsConn)
 	go h.handleSender(wsConn)
 
 	log.Printf("WebSocket connection established for user %d with conn ID %s", userID, connID)
-	h.sendToConnection(wsConn, models.WSMessage{
-		Type: models.MessageTypeConnected,
-	})
 }
 
-func (h *WebSocketHandler) registerConnection(conn *WebSocketConnection) {
+// openSession attaches a stream to the session of the resume token, or to a
+// new one, and registers it. The client is told to resync when the token is
+// unknown or expired.
+func (h *WebSocketHandler) openSession(stream eventStream, userID uint, token string, lastSeq uint64, connected models.ConnectedMessage) error {
+	h.mutex.RLock()
+	session, exists := h.sessions[token]
+	h.mutex.RUnlock()
+
+	// A session of another node, which may have gone away, is taken over
+	if !exists && token != "" {
+		session = h.resumeElsewhere(userID, token)
+		exists = session != nil
+	}
+
+	if exists && session.UserID == userID {
+		// A device reconnecting before its old stream timed out takes over
+		if old := session.attachedConn(); old != nil {
+			h.dropStream(old)
+		}
+		connected.Resumed = true
+	} else {
+		var err error
+		session, err = newSession(userID)
+		if err != nil {
+			return err
+		}
+		connected.ResyncRequired = token != ""
+		lastSeq = 0
+
+		h.mutex.Lock()
+		h.sessions[session.Token] = session
+		if h.userSessions[userID] == nil {
+			h.userSessions[userID] = make(map[string]*wsSession)
+		}
+		h.userSessions[userID][session.Token] = session
+		h.mutex.Unlock()
+
+		h.updatePresence(userID, true)
+	}
+
+	switch stream := stream.(type) {
+	case *WebSocketConnection:
+		stream.Session = session
+		h.registerConnection(stream)
+	case *httpStream:
+		stream.session = session
+	}
+	session.attach(stream, lastSeq, connected)
+	return nil
+}
+
+// dropStream closes a stream that was taken over or cannot keep up, its
+// session stays to be resumed
+func (h *WebSocketHandler) dropStream(stream eventStream) {
+	switch stream := stream.(type) {
+	case *WebSocketConnection:
+		h.unregisterConnection(stream.ConnID)
+	case *httpStream:
+		stream.session.detach(stream)
+		stream.close()
+	}
+}
+
+// closeSession drops a session that was not resumed in time or moved to
+// another node, the user is gone from this node with their last one. False
+// when it was already dropped.
+func (h *WebSocketHandler) closeSession(session *wsSession) bool {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
+	if h.sessions[session.Token] != session {
+		h.mutex.Unlock()
+		return false
+	}
+	delete(h.sessions, session.Token)
+	delete(h.userSessions[session.UserID], session.Token)
+	lastSession := len(h.userSessions[session.UserID]) == 0
+	if lastSession {
+		delete(h.userSessions, session.UserID)
+	}
+	h.mutex.Unlock()
+
+	h.updatePresence(session.UserID, false)
+	if lastSession {
+		h.removeChannelSubscriptions(session.UserID)
+	}
+	return true
+}
 
-	// Close existing connection if exists
-	if existingConnID, exists := h.userConns[conn.UserID]; exists {
-		if existingConn, ok := h.connections[existingConnID]; ok {
-			existingConn.CloseChan <- true
+func (h *WebSocketHandler) maintainSessions() {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		h.mutex.RLock()
+		sessions := make([]*wsSession, 0, len(h.sessions))
+		for _, session := range h.sessions {
+			sessions = append(sessions, session)
+		}
+		h.mutex.RUnlock()
+
+		for _, session := range sessions {
+			if session.expired(now) {
+				h.closeSession(session)
+				continue
+			}
+			session.retry(now)
 		}
 	}
+}
+
+// registerConnection adds a device of the user, the other devices stay connected
+func (h *WebSocketHandler) registerConnection(conn *WebSocketConnection) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
 
 	h.connections[conn.ConnID] = conn
-	h.userConns[conn.UserID] = conn.ConnID
+	if h.userConns[conn.UserID] == nil {
+		h.userConns[conn.UserID] = make(map[string]*WebSocketConnection)
+	}
+	h.userConns[conn.UserID][conn.ConnID] = conn
 }
 
 func (h *WebSocketHandler) unregisterConnection(connID string) {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
 
-	if conn, exists := h.connections[connID]; exists {
-		// Remove from room if in one
-		if conn.RoomID != "" {
-			h.removeFromRoom(conn.RoomID, conn.UserID)
-		}
+	conn, exists := h.connections[connID]
+	if !exists {
+		h.mutex.Unlock()
+		return
+	}
 
-		// Set user offline in status service
-		// err := h.onlineStatusService.SetUserOffline(conn.UserID)
-		// if err != nil {
-		// 	log.Printf("Failed to set user %d offline: %v", conn.UserID, err)
-		// }
+	// Remove from room if in one
+	roomID := conn.RoomID
+	var left models.WSMessage
+	if roomID != "" {
+		left = h.removeFromRoom(roomID, conn)
+	}
+	released := h.releaseCallDevice(conn)
+	for topic := range conn.Topics {
+		h.removeTopic(conn, topic)
+	}
 
-		delete(h.connections, connID)
+	delete(h.connections, connID)
+	h.releaseConnection(conn.UserID, conn.RemoteIP)
+	delete(h.userConns[conn.UserID], connID)
+	if len(h.userConns[conn.UserID]) == 0 {
 		delete(h.userConns, conn.UserID)
-		conn.Conn.Close()
 	}
+	h.mutex.Unlock()
+
+	// Parties of migrating calls are not told the devices of a draining
+	// node left, they rejoin from another one
+	if h.draining.Load() {
+		left = models.WSMessage{}
+	}
+
+	// The session buffers events until it is resumed or expires
+	conn.Conn.Close()
+	conn.Session.detach(conn)
+	h.onlineStatusService.DisconnectDevice(conn.UserID, conn.ConnID)
+	h.route(delivery{RoomID: roomID, ExcludeUser: conn.UserID}, left, released...)
 }
 
 func (h *WebSocketHandler) handleConnection(conn *WebSocketConnection) {
 	defer h.unregisterConnection(conn.ConnID)
 
+	// Larger frames close the connection with a message too big code
+	conn.Conn.SetReadLimit(h.maxMessageSize)
+	limiter := newWSLimiter()
+
 	conn.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	conn.Conn.SetPongHandler(func(string) error {
 		conn.LastPing = time.Now()
@@ -177,16 +533,34 @@ func (h *WebSocketHandler) handleConnection(conn *WebSocketConnection) {
 			}
 
 			var message models.WSMessage
-			if err := json.Unmarshal(messageBytes, &message); err != nil {
-				println(fmt.Sprintf("Invalid message format: %v", messageBytes))
-				h.sendError(conn, "invalid_message", "Invalid message format")
+			if err := conn.Codec.Decode(messageBytes, &message); err != nil {
+				h.sendError(conn, nil, models.WSErrorInvalidMessage, "Invalid message format")
+				if !limiter.violate() {
+					h.closeForPolicy(conn, "too many invalid messages")
+					return
+				}
+				continue
+			}
+
+			if !limiter.allow(message.Type) {
+				h.sendError(conn, &message, models.WSErrorRateLimited, fmt.Sprintf("Too many %s messages", message.Type))
+				if !limiter.violate() {
+					h.closeForPolicy(conn, "rate limit exceeded")
+					return
+				}
 				continue
 			}
 
 			message.From = conn.UserID
 			message.Timestamp = time.Now().Format(time.RFC3339)
 
-			h.handleMessage(conn, &message)
+			payload, protocolErr := decodePayload(conn.Protocol, &message)
+			if protocolErr != nil {
+				h.replyError(conn, &message, *protocolErr)
+				continue
+			}
+
+			h.handleMessage(conn, &message, payload)
 		}
 	}
 }
@@ -199,7 +573,7 @@ func (h *WebSocketHandler) handleSender(conn *WebSocketConnection) {
 		select {
 		case message := <-conn.SendChan:
 			conn.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := conn.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if err := conn.Conn.WriteMessage(conn.Codec.FrameType(), message); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -213,48 +587,59 @@ func (h *WebSocketHandler) handleSender(conn *WebSocketConnection) {
 	}
 }
 
-func (h *WebSocketHandler) handleMessage(conn *WebSocketConnection, message *models.WSMessage) {
+// handleMessage dispatches a client message, its payload was decoded and
+// validated with the one registered for its type
+func (h *WebSocketHandler) handleMessage(conn *WebSocketConnection, message *models.WSMessage, payload interface{}) {
 	switch message.Type {
 	case models.MessageTypeJoinRoom:
-		h.handleJoinRoom(conn, message)
+		h.handleJoinRoom(conn, message, payload.(*models.JoinRoomMessage))
 	case models.MessageTypeLeaveRoom:
 		h.handleLeaveRoom(conn, message)
 	case models.MessageTypeCallRequest:
-		h.handleCallRequest(conn, message)
+		h.handleCallRequest(conn, message, payload.(*models.CallRequestMessage))
 	case models.MessageTypeCallResponse:
-		h.handleCallResponse(conn, message)
+		h.handleCallResponse(conn, message, payload.(*models.CallResponseMessage))
 	case models.MessageTypeOffer:
-		h.handleOffer(conn, message)
+		h.handleOffer(conn, message, payload.(*models.OfferMessage).CallID)
 	case models.MessageTypeAnswer:
-		h.handleAnswer(conn, message)
+		h.handleAnswer(conn, message, payload.(*models.AnswerMessage).CallID)
 	case models.MessageTypeICECandidate:
-		h.handleICECandidate(conn, message)
+		h.handleICECandidate(conn, message, payload.(*models.ICECandidateMessage).CallID)
 	case models.MessageTypeCallEnd:
-		h.handleCallEnd(conn, message)
+		h.handleCallEnd(conn, message, payload.(*models.CallEndMessage))
 	case models.MessageTypeHeartbeat:
 		h.handleHeartbeat(conn, message)
+	case models.MessageTypeAck:
+		h.handleAck(conn, message, payload.(*models.AckMessage))
 	case models.MessageTypeChatSendMessage:
-		h.handleChatSendMessage(conn, message)
+		h.handleChatSendMessage(conn, message, payload.(*models.SendChatMessageMessage))
 	case models.MessageTypeChatCreateRoom:
-		h.handleChatCreateRoom(conn, message)
+		h.handleChatCreateRoom(conn, message, payload.(*models.CreateChatRoomMessage))
+	case models.MessageTypeLiveLocationStart:
+		h.handleLiveLocationStart(conn, message, payload.(*models.StartLiveLocationMessage))
+	case models.MessageTypeLiveLocationUpdate:
+		h.handleLiveLocationUpdate(conn, message, payload.(*models.UpdateLiveLocationMessage))
+	case models.MessageTypeLiveLocationStop:
+		h.handleLiveLocationStop(conn, message, payload.(*models.StopLiveLocationMessage))
+	case models.MessageTypeReactionAdd, models.MessageTypeReactionRemove:
+		h.handleReaction(conn, message, payload.(*models.ReactionMessage))
+	case models.MessageTypeSubscribe:
+		h.handleSubscribe(conn, message, payload.(*models.TopicMessage))
+	case models.MessageTypeUnsubscribe:
+		h.handleUnsubscribe(conn, message, payload.(*models.TopicMessage))
 	default:
-		h.sendError(conn, "unknown_message_type", "Unknown message type")
+		h.sendError(conn, message, models.WSErrorUnknownMessageType, "Unknown message type")
 	}
 }
 
-func (h *WebSocketHandler) handleJoinRoom(conn *WebSocketConnection, message *models.WSMessage) {
-	var joinMsg models.JoinRoomMessage
-	if err := json.Unmarshal(message.Data, &joinMsg); err != nil {
-		h.sendError(conn, "invalid_join_room_data", "Invalid join room data")
-		return
-	}
-
+func (h *WebSocketHandler) handleJoinRoom(conn *WebSocketConnection, message *models.WSMessage, joinMsg *models.JoinRoomMessage) {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
 
 	// Leave current room if in one
-	if conn.RoomID != "" {
-		h.removeFromRoom(conn.RoomID, conn.UserID)
+	previousRoomID := conn.RoomID
+	var left models.WSMessage
+	if previousRoomID != "" {
+		left = h.removeFromRoom(previousRoomID, conn)
 	}
 
 	// Join new room
@@ -271,12 +656,15 @@ func (h *WebSocketHandler) handleJoinRoom(conn *WebSocketConnection, message *mo
 		h.rooms[joinMsg.RoomID] = room
 	}
 
-	// Add user to room
+	// Add the device to the room
 	room.Participants = append(room.Participants, conn.UserID)
 	conn.RoomID = joinMsg.RoomID
+	h.mutex.Unlock()
+
+	h.route(delivery{RoomID: previousRoomID, ExcludeUser: conn.UserID}, left)
 
 	// Notify other participants
-	h.broadcastToRoom(joinMsg.RoomID, models.WSMessage{
+	h.route(delivery{RoomID: joinMsg.RoomID, ExcludeUser: conn.UserID}, models.WSMessage{
 		Type:      models.MessageTypeUserJoined,
 		From:      conn.UserID,
 		RoomID:    joinMsg.RoomID,
@@ -285,155 +673,179 @@ func (h *WebSocketHandler) handleJoinRoom(conn *WebSocketConnection, message *mo
 			UserID: conn.UserID,
 			RoomID: joinMsg.RoomID,
 		}),
-	}, conn.UserID)
+	})
+
+	// A call that migrated from a node that went away goes on
+	call, err := h.callService.ResumeMigratedCall(conn.UserID, joinMsg.RoomID)
+	if err != nil {
+		log.Printf("Failed to resume call of user %d in room %s: %v", conn.UserID, joinMsg.RoomID, err)
+	} else if call != nil {
+		h.sendCallStatus(call.ID, call.CallerID, call.CalleeID, call.RoomID, constants.CallStatusOngoing)
+	}
 
-	log.Printf("User %d joined room %s", conn.UserID, joinMsg.RoomID)
+	log.Printf("User %d joined room %s from %s", conn.UserID, joinMsg.RoomID, conn.ConnID)
 }
 
 func (h *WebSocketHandler) handleLeaveRoom(conn *WebSocketConnection, message *models.WSMessage) {
+	h.mutex.Lock()
 	if conn.RoomID == "" {
+		h.mutex.Unlock()
 		return
 	}
 
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+	roomID := conn.RoomID
+	left := h.removeFromRoom(roomID, conn)
+	h.mutex.Unlock()
 
-	h.removeFromRoom(conn.RoomID, conn.UserID)
-	conn.RoomID = ""
+	h.route(delivery{RoomID: roomID, ExcludeUser: conn.UserID}, left)
 }
 
-func (h *WebSocketHandler) handleCallRequest(conn *WebSocketConnection, message *models.WSMessage) {
-
-	var callReq models.CallRequestMessage
-	if err := json.Unmarshal(message.Data, &callReq); err != nil {
-		h.sendError(conn, "invalid_call_request", "Invalid call request data")
-		return
-	}
-
+func (h *WebSocketHandler) handleCallRequest(conn *WebSocketConnection, message *models.WSMessage, callReq *models.CallRequestMessage) {
 	// Create call in database
 	caller, err := h.callService.CreateCall(conn.UserID, callReq.CalleeID, callReq.CallType, callReq.RoomID)
 	if err != nil {
-		h.sendError(conn, "call_creation_failed", err.Error())
+		h.sendError(conn, message, models.WSErrorCallCreationFailed, err.Error())
 		return
 	}
 
-	// Send call request to callee
-	h.sendToUser(callReq.CalleeID, models.WSMessage{
+	h.ringCallee(caller, conn.ConnID)
+}
+
+// ringCallee sends a call request to every device of the callee. The caller
+// is bound to the device it called from, none for calls made over REST.
+func (h *WebSocketHandler) ringCallee(call *postgres.Call, callerConnID string) {
+	h.route(delivery{UserIDs: []uint{*call.CalleeID}}, models.WSMessage{
 		Type:      models.MessageTypeCallRequest,
-		From:      conn.UserID,
-		To:        callReq.CalleeID,
-		RoomID:    callReq.RoomID,
+		From:      call.CallerID,
+		To:        *call.CalleeID,
+		RoomID:    call.RoomID,
 		Timestamp: time.Now().Format(time.RFC3339),
 		Data: h.marshalData(models.CallRequestMessage{
-			CallID:   caller.ID,
-			CallerID: caller.CallerID,
-			CalleeID: *caller.CalleeID,
-			CallType: caller.Type,
-			RoomID:   caller.RoomID,
+			CallID:   call.ID,
+			CallerID: call.CallerID,
+			CalleeID: *call.CalleeID,
+			CallType: call.Type,
+			RoomID:   call.RoomID,
 		}),
-	})
+	},
+		callUpdate{CallID: call.ID, UserID: call.CallerID, ConnID: callerConnID},
+		callUpdate{CallID: call.ID, UserID: *call.CalleeID},
+	)
 
-	log.Printf("Call request sent from user %d to user %d", conn.UserID, callReq.CalleeID)
+	log.Printf("Call request sent from user %d to user %d", call.CallerID, *call.CalleeID)
 }
 
-func (h *WebSocketHandler) handleCallResponse(conn *WebSocketConnection, message *models.WSMessage) {
-	var callResp models.CallResponseMessage
-	if err := json.Unmarshal(message.Data, &callResp); err != nil {
-		h.sendError(conn, "invalid_call_response", "Invalid call response data")
-		return
-	}
-
+func (h *WebSocketHandler) handleCallResponse(conn *WebSocketConnection, message *models.WSMessage, callResp *models.CallResponseMessage) {
 	// Update call status
-	if callResp.Response == "accept" {
+	accepted := callResp.Response == "accept"
+	if accepted {
 		err := h.callService.AcceptCall(callResp.CallID)
 		if err != nil {
-			h.sendError(conn, "call_accept_failed", err.Error())
+			h.sendError(conn, message, models.WSErrorCallAcceptFailed, err.Error())
 			return
 		}
 	} else {
 		err := h.callService.DeclineCall(callResp.CallID)
 		if err != nil {
-			h.sendError(conn, "call_decline_failed", err.Error())
+			h.sendError(conn, message, models.WSErrorCallDeclineFailed, err.Error())
 			return
 		}
 	}
 
-	// Broadcast response to room
-	h.broadcastToRoom(callResp.RoomID, models.WSMessage{
+	h.answerCall(conn.UserID, conn.ConnID, *callResp)
+}
+
+// answerCall tells the room, the calling device and the devices of the
+// callee, which stop ringing, about the response. Signaling of the callee
+// then only goes to the device that answered, or to all their devices when
+// it was answered over REST.
+func (h *WebSocketHandler) answerCall(userID uint, connID string, response models.CallResponseMessage) {
+	update := callUpdate{CallID: response.CallID, Ended: true}
+	if response.Response == "accept" {
+		update = callUpdate{CallID: response.CallID, UserID: userID, ConnID: connID}
+	}
+	h.route(delivery{
+		UserIDs: h.callParties(response.CallID),
+		CallID:  response.CallID,
+		RoomID:  response.RoomID,
+	}, models.WSMessage{
 		Type:      models.MessageTypeCallResponse,
-		From:      conn.UserID,
-		RoomID:    callResp.RoomID,
+		From:      userID,
+		RoomID:    response.RoomID,
 		Timestamp: time.Now().Format(time.RFC3339),
-		Data:      message.Data,
-	}, 0)
+		Data:      h.marshalData(response),
+	}, update)
 }
 
-func (h *WebSocketHandler) handleOffer(conn *WebSocketConnection, message *models.WSMessage) {
+func (h *WebSocketHandler) handleOffer(conn *WebSocketConnection, message *models.WSMessage, callID uint) {
 	if message.To == 0 {
-		h.sendError(conn, "missing_recipient", "Recipient user ID is required")
+		h.sendError(conn, message, models.WSErrorMissingRecipient, "Recipient user ID is required")
 		return
 	}
 
-	// Forward offer to recipient
-	h.sendToUser(message.To, *message)
+	// Forward offer to the device of the recipient in the call
+	h.sendSignal(message, callID)
 }
 
-func (h *WebSocketHandler) handleAnswer(conn *WebSocketConnection, message *models.WSMessage) {
+func (h *WebSocketHandler) handleAnswer(conn *WebSocketConnection, message *models.WSMessage, callID uint) {
 	if message.To == 0 {
-		h.sendError(conn, "missing_recipient", "Recipient user ID is required")
+		h.sendError(conn, message, models.WSErrorMissingRecipient, "Recipient user ID is required")
 		return
 	}
 
-	// Forward answer to recipient
-	h.sendToUser(message.To, *message)
+	// Forward answer to the device of the recipient in the call
+	h.sendSignal(message, callID)
 }
 
-func (h *WebSocketHandler) handleICECandidate(conn *WebSocketConnection, message *models.WSMessage) {
+func (h *WebSocketHandler) handleICECandidate(conn *WebSocketConnection, message *models.WSMessage, callID uint) {
 	if message.To == 0 {
-		h.sendError(conn, "missing_recipient", "Recipient user ID is required")
+		h.sendError(conn, message, models.WSErrorMissingRecipient, "Recipient user ID is required")
 		return
 	}
 
-	// Forward ICE candidate to recipient
-	h.sendToUser(message.To, *message)
+	// Forward ICE candidate to the device of the recipient in the call
+	h.sendSignal(message, callID)
 }
 
-func (h *WebSocketHandler) handleCallEnd(conn *WebSocketConnection, message *models.WSMessage) {
-	var callEnd models.CallEndMessage
-	if err := json.Unmarshal(message.Data, &callEnd); err != nil {
-		h.sendError(conn, "invalid_call_end", "Invalid call end data")
-		return
-	}
-
+func (h *WebSocketHandler) handleCallEnd(conn *WebSocketConnection, message *models.WSMessage, callEnd *models.CallEndMessage) {
 	// End call in database
 	err := h.callService.EndCall(callEnd.CallID)
 	if err != nil {
-		h.sendError(conn, "call_end_failed", err.Error())
+		h.sendError(conn, message, models.WSErrorCallEndFailed, err.Error())
 		return
 	}
 
-	// Broadcast call end to room
-	h.broadcastToRoom(callEnd.RoomID, models.WSMessage{
+	h.endCall(conn.UserID, *callEnd)
+}
+
+// endCall broadcasts the end of a call to its room, and to the devices of the
+// call still ringing
+func (h *WebSocketHandler) endCall(userID uint, callEnd models.CallEndMessage) {
+	h.route(delivery{
+		UserIDs: h.callParties(callEnd.CallID),
+		CallID:  callEnd.CallID,
+		RoomID:  callEnd.RoomID,
+	}, models.WSMessage{
 		Type:      models.MessageTypeCallEnd,
-		From:      conn.UserID,
+		From:      userID,
 		RoomID:    callEnd.RoomID,
 		Timestamp: time.Now().Format(time.RFC3339),
-		Data:      message.Data,
-	}, 0)
+		Data:      h.marshalData(callEnd),
+	}, callUpdate{CallID: callEnd.CallID, Ended: true})
 }
 
 func (h *WebSocketHandler) handleHeartbeat(conn *WebSocketConnection, message *models.WSMessage) {
 	conn.LastPing = time.Now()
 
 	// Update heartbeat in online status service
-	// err := h.onlineStatusService.UpdateHeartbeat(conn.UserID)
-	// if err != nil {
-	// 	log.Printf("Failed to update heartbeat for user %d: %v", conn.UserID, err)
-	// }
+	if err := h.onlineStatusService.UpdateHeartbeat(conn.UserID); err != nil {
+		log.Printf("Failed to update heartbeat for user %d: %v", conn.UserID, err)
+	}
 
 	// Send heartbeat response
 	response := models.WSMessage{
 		Type:      models.MessageTypeHeartbeat,
+		RequestID: message.RequestID,
 		Timestamp: time.Now().Format(time.RFC3339),
 		Data: h.marshalData(models.HeartbeatMessage{
 			Status:    "ok",
@@ -445,51 +857,46 @@ func (h *WebSocketHandler) handleHeartbeat(conn *WebSocketConnection, message *m
 	h.sendToConnection(conn, response)
 }
 
-func (h *WebSocketHandler) handleChatSendMessage(conn *WebSocketConnection, message *models.WSMessage) {
-	var req models.SendChatMessageMessage
-	err := json.Unmarshal(message.Data, &req)
-	if err != nil {
-		log.Printf("Failed to unmarshal send chat message request: %v", err)
-		return
-	}
-
-	// Validate room ID
-	participants, err := h.chatService.GetParticipants(req.RoomID)
-	if err != nil {
-		log.Printf("Room %d does not exist", req.RoomID)
-		return
-	}
+// handleAck drops the events the client handled from the replay buffer
+func (h *WebSocketHandler) handleAck(conn *WebSocketConnection, message *models.WSMessage, ack *models.AckMessage) {
+	conn.Session.ack(ack.Seq)
+}
 
-	// Save chat message to database
-	createdMessage, err := h.chatService.CreateMessage(conn.UserID, req)
-	if err != nil {
-		log.Printf("Failed to create chat message: %v", err)
-		return
-	}
+func (h *WebSocketHandler) handleChatSendMessage(conn *WebSocketConnection, message *models.WSMessage, req *models.SendChatMessageMessage) {
+	// Send later, the scheduler delivers it through SendMessage
+	if req.SendAt != nil && req.SendAt.After(time.Now()) {
+		scheduled, err := h.chatService.ScheduleMessage(conn.UserID, *req)
+		if err != nil {
+			h.sendError(conn, message, models.WSErrorScheduleMessageFailed, err.Error())
+			return
+		}
 
-	// Send messages to user connected to the room
-	for _, participant := range participants {
-		h.sendToUser(participant.UserID, models.WSMessage{
-			Type:      models.MessageTypeChatReceiveMessage,
-			From:      conn.UserID,
+		h.sendToConnection(conn, models.WSMessage{
+			Type:      models.MessageTypeMessageScheduled,
+			RequestID: message.RequestID,
 			Timestamp: time.Now().UTC().Format(time.RFC3339),
-			Data:      h.marshalData(createdMessage),
+			Data:      h.marshalData(scheduled),
 		})
+		return
 	}
-}
 
-func (h *WebSocketHandler) handleChatCreateRoom(conn *WebSocketConnection, message *models.WSMessage) {
-	var req models.CreateChatRoomMessage
-	err := json.Unmarshal(message.Data, &req)
-	if err != nil {
-		log.Printf("Failed to unmarshal create chat room request: %v", err)
+	// Save chat message to database and send it to the room participants.
+	// Slash commands may run without posting a message, their replies and
+	// errors are pushed to the sender by the chat service.
+	if _, err := h.chatService.SendMessage(conn.UserID, *req); err != nil {
+		if errors.Is(err, services.ErrSlashCommandHandled) {
+			return
+		}
+		h.sendError(conn, message, models.WSErrorSendMessageFailed, err.Error())
 		return
 	}
+}
 
+func (h *WebSocketHandler) handleChatCreateRoom(conn *WebSocketConnection, message *models.WSMessage, req *models.CreateChatRoomMessage) {
 	// Create chat room
-	createdRoom, err := h.chatService.CreateRoomFromWs(conn.UserID, &req)
+	createdRoom, err := h.chatService.CreateRoomFromWs(conn.UserID, req)
 	if err != nil {
-		log.Printf("Failed to create chat room: %v", err)
+		h.sendError(conn, message, models.WSErrorCreateRoomFailed, err.Error())
 		return
 	}
 
@@ -511,101 +918,482 @@ func (h *WebSocketHandler) handleChatCreateRoom(conn *WebSocketConnection, messa
 
 }
 
-func (h *WebSocketHandler) sendToUser(userID uint, message models.WSMessage) {
-	h.mutex.RLock()
-	connID, exists := h.userConns[userID]
-	h.mutex.RUnlock()
+func (h *WebSocketHandler) handleLiveLocationStart(conn *WebSocketConnection, message *models.WSMessage, req *models.StartLiveLocationMessage) {
+	// The location message reaches the sender with the room
+	if _, err := h.chatService.StartLiveLocation(conn.UserID, *req); err != nil {
+		h.sendError(conn, message, models.WSErrorLiveLocationStartFailed, err.Error())
+	}
+}
 
-	if !exists {
+func (h *WebSocketHandler) handleLiveLocationUpdate(conn *WebSocketConnection, message *models.WSMessage, req *models.UpdateLiveLocationMessage) {
+	if err := h.chatService.UpdateLiveLocation(conn.UserID, *req); err != nil {
+		h.sendError(conn, message, models.WSErrorLiveLocationUpdateFailed, err.Error())
+	}
+}
+
+func (h *WebSocketHandler) handleLiveLocationStop(conn *WebSocketConnection, message *models.WSMessage, req *models.StopLiveLocationMessage) {
+	if err := h.chatService.StopLiveLocation(conn.UserID, req.MessageID); err != nil {
+		h.sendError(conn, message, models.WSErrorLiveLocationStopFailed, err.Error())
+	}
+}
+
+// handleReaction adds or removes a reaction, the room gets the new summary as an event
+func (h *WebSocketHandler) handleReaction(conn *WebSocketConnection, message *models.WSMessage, req *models.ReactionMessage) {
+	var err error
+	if message.Type == models.MessageTypeReactionAdd {
+		_, err = h.chatService.AddReaction(req.MessageID, conn.UserID, req.Emoji)
+	} else {
+		_, err = h.chatService.RemoveReaction(req.MessageID, conn.UserID, req.Emoji)
+	}
+	if err != nil {
+		h.sendError(conn, message, models.WSErrorReactionFailed, err.Error())
+	}
+}
+
+// handleChatEvent pushes chat service events to the recipients
+func (h *WebSocketHandler) handleChatEvent(event services.ChatEvent) {
+	if event.AccessChanged {
+		h.route(delivery{UserIDs: event.UserIDs, Recheck: topicName(topicRoom, event.RoomID)}, models.WSMessage{})
+		return
+	}
+
+	message := models.WSMessage{
+		Type:      event.Type,
+		From:      event.From,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      h.marshalData(event.Data),
+	}
+
+	target := delivery{UserIDs: event.UserIDs, ChannelID: event.ChannelID}
+	switch event.Type {
+	case models.MessageTypeChannelSubscribed, models.MessageTypeChannelUnsubscribed:
+		// Each node indexes the subscription of its own users
+		target.Subscription = event.RoomID
+	default:
+		if event.Broadcast {
+			target.Topic = topicName(topicRoom, event.RoomID)
+		}
+	}
+
+	h.route(target, message)
+}
+
+// handlePostEvent pushes a change of a post to the connections watching it
+func (h *WebSocketHandler) handlePostEvent(event services.PostEvent) {
+	if event.AccessChanged {
+		h.route(delivery{Recheck: topicName(topicPost, event.PostID)}, models.WSMessage{})
 		return
 	}
 
+	h.route(delivery{Topic: topicName(topicPost, event.PostID)}, models.WSMessage{
+		Type:      event.Type,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      h.marshalData(event.Data),
+	})
+}
+
+// handleRelationLost checks again the posts two users watch once they are
+// no longer friends or one blocked the other
+func (h *WebSocketHandler) handleRelationLost(userID, otherID uint) {
+	h.route(delivery{UserIDs: []uint{userID, otherID}, Recheck: topicPost}, models.WSMessage{})
+}
+
+// route delivers a message to the devices of this node and publishes it for
+// the other nodes. Call updates and rechecks apply once the message is
+// delivered, a message without type only carries them.
+func (h *WebSocketHandler) route(target delivery, message models.WSMessage, calls ...callUpdate) {
+	envelope := busEnvelope{
+		Origin:   h.cluster.NodeID,
+		Delivery: target,
+		Calls:    calls,
+	}
+	if message.Type != "" {
+		h.deliverLocal(target, message)
+		envelope.Message = &message
+	}
+	h.applyCallUpdates(calls)
+	h.recheckTopics(target)
+
+	h.publish(envelope)
+}
+
+// publish sends an envelope to the nodes holding its users. Rooms, channels,
+// topics and call state are not tracked per node and go to every node.
+func (h *WebSocketHandler) publish(envelope busEnvelope) {
+	if envelope.Message == nil && len(envelope.Calls) == 0 && envelope.Delivery.Recheck == "" {
+		return
+	}
+
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("Failed to marshal bus envelope: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+
+	target := envelope.Delivery
+	if target.RoomID != "" || target.ChannelID != 0 || target.Topic != "" || target.Recheck != "" || len(envelope.Calls) > 0 {
+		if err := h.cluster.Bus.Publish(ctx, bus.BroadcastTopic, payload); err != nil {
+			log.Printf("Failed to publish to all nodes: %v", err)
+		}
+		return
+	}
+	if len(target.UserIDs) == 0 {
+		return
+	}
+
+	nodes, err := h.cluster.Presence.Nodes(ctx, target.UserIDs)
+	if err != nil {
+		log.Printf("Failed to find nodes of users %v: %v", target.UserIDs, err)
+		return
+	}
+
+	published := map[string]bool{h.cluster.NodeID: true}
+	for _, nodeIDs := range nodes {
+		for _, nodeID := range nodeIDs {
+			if published[nodeID] {
+				continue
+			}
+			published[nodeID] = true
+
+			if err := h.cluster.Bus.Publish(ctx, bus.NodeTopic(nodeID), payload); err != nil {
+				log.Printf("Failed to publish to node %s: %v", nodeID, err)
+			}
+		}
+	}
+}
+
+// handleBusMessage delivers what another node routed to this one
+func (h *WebSocketHandler) handleBusMessage(payload []byte) {
+	var envelope busEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		log.Printf("Invalid bus envelope: %v", err)
+		return
+	}
+	if envelope.Origin == h.cluster.NodeID {
+		return
+	}
+
+	switch {
+	case envelope.Handoff != nil:
+		h.handOffSession(envelope.Origin, *envelope.Handoff)
+		return
+	case envelope.Session != nil:
+		h.receiveSession(*envelope.Session)
+		return
+	}
+
+	if envelope.Message != nil {
+		h.deliverLocal(envelope.Delivery, *envelope.Message)
+	}
+	h.applyCallUpdates(envelope.Calls)
+	h.recheckTopics(envelope.Delivery)
+}
+
+// deliverLocal sends a message to the devices of this node it is meant for
+func (h *WebSocketHandler) deliverLocal(target delivery, message models.WSMessage) {
+	if target.Subscription != 0 {
+		for _, userID := range target.UserIDs {
+			if message.Type == models.MessageTypeChannelSubscribed {
+				h.addChannelSubscription(target.Subscription, userID)
+			} else {
+				h.removeChannelSubscription(target.Subscription, userID)
+			}
+		}
+	}
+
+	var subscribers []uint
+	if target.ChannelID != 0 {
+		subscribers = h.channelRecipients(target.ChannelID, target.UserIDs)
+	}
+
 	h.mutex.RLock()
-	conn, exists := h.connections[connID]
+	recipients := make(map[*wsSession]bool)
+	if target.RoomID != "" {
+		for _, conn := range h.roomConnections(target.RoomID, target.ExcludeUser) {
+			recipients[conn.Session] = true
+		}
+	}
+	parties := h.calls[target.CallID]
+	for _, userID := range target.UserIDs {
+		h.addUserSessions(recipients, userID, parties[userID])
+	}
+	if target.Topic != "" {
+		h.addTopicSessions(recipients, target.Topic, target.TopicUsers)
+	}
+	for _, userID := range subscribers {
+		h.addUserSessions(recipients, userID, "")
+	}
 	h.mutex.RUnlock()
 
-	if !exists || !conn.IsActive {
+	for session := range recipients {
+		if full := session.send(message); full != nil {
+			h.dropStream(full)
+		}
+	}
+}
+
+// applyCallUpdates keeps the devices bound to calls in sync across nodes
+func (h *WebSocketHandler) applyCallUpdates(updates []callUpdate) {
+	if len(updates) == 0 {
 		return
 	}
 
-	h.sendToConnection(conn, message)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, update := range updates {
+		if update.Ended {
+			delete(h.calls, update.CallID)
+			continue
+		}
+		if h.calls[update.CallID] == nil {
+			h.calls[update.CallID] = make(map[uint]string)
+		}
+		h.calls[update.CallID][update.UserID] = update.ConnID
+	}
 }
 
-func (h *WebSocketHandler) sendToConnection(conn *WebSocketConnection, message models.WSMessage) {
-	messageBytes, err := json.Marshal(message)
+// callParties returns the users of a call
+func (h *WebSocketHandler) callParties(callID uint) []uint {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	parties := make([]uint, 0, len(h.calls[callID]))
+	for userID := range h.calls[callID] {
+		parties = append(parties, userID)
+	}
+	return parties
+}
+
+// loadChannelSubscriptions indexes the channels of a user who just connected
+func (h *WebSocketHandler) loadChannelSubscriptions(userID uint) {
+	channelIDs, err := h.chatService.GetSubscribedChannelIDs(userID)
 	if err != nil {
-		log.Printf("Failed to marshal message: %v", err)
+		log.Printf("Failed to load channel subscriptions of user %d: %v", userID, err)
 		return
 	}
 
-	select {
-	case conn.SendChan <- messageBytes:
-	default:
-		close(conn.SendChan)
-		h.unregisterConnection(conn.ConnID)
+	h.channelMutex.Lock()
+	defer h.channelMutex.Unlock()
+
+	h.userChannels[userID] = channelIDs
+	for _, channelID := range channelIDs {
+		if h.channelSubscribers[channelID] == nil {
+			h.channelSubscribers[channelID] = make(map[uint]bool)
+		}
+		h.channelSubscribers[channelID][userID] = true
 	}
 }
 
-func (h *WebSocketHandler) broadcastToRoom(roomID string, message models.WSMessage, exclude uint) {
+// addChannelSubscription indexes a new subscription if the user has a session
+func (h *WebSocketHandler) addChannelSubscription(channelID, userID uint) {
 	h.mutex.RLock()
-	room, exists := h.rooms[roomID]
+	_, connected := h.userSessions[userID]
 	h.mutex.RUnlock()
+	if !connected {
+		return
+	}
 
-	if !exists {
+	h.channelMutex.Lock()
+	defer h.channelMutex.Unlock()
+
+	if h.channelSubscribers[channelID] == nil {
+		h.channelSubscribers[channelID] = make(map[uint]bool)
+	}
+	if !h.channelSubscribers[channelID][userID] {
+		h.channelSubscribers[channelID][userID] = true
+		h.userChannels[userID] = append(h.userChannels[userID], channelID)
+	}
+}
+
+func (h *WebSocketHandler) removeChannelSubscription(channelID, userID uint) {
+	h.channelMutex.Lock()
+	defer h.channelMutex.Unlock()
+
+	delete(h.channelSubscribers[channelID], userID)
+	if len(h.channelSubscribers[channelID]) == 0 {
+		delete(h.channelSubscribers, channelID)
+	}
+
+	channelIDs := h.userChannels[userID]
+	for i, id := range channelIDs {
+		if id == channelID {
+			h.userChannels[userID] = append(channelIDs[:i], channelIDs[i+1:]...)
+			break
+		}
+	}
+}
+
+// removeChannelSubscriptions drops a disconnected user from the index
+func (h *WebSocketHandler) removeChannelSubscriptions(userID uint) {
+	h.channelMutex.Lock()
+	defer h.channelMutex.Unlock()
+
+	for _, channelID := range h.userChannels[userID] {
+		delete(h.channelSubscribers[channelID], userID)
+		if len(h.channelSubscribers[channelID]) == 0 {
+			delete(h.channelSubscribers, channelID)
+		}
+	}
+	delete(h.userChannels, userID)
+}
+
+// channelRecipients returns the connected subscribers of a channel that are
+// not already recipients of the event
+func (h *WebSocketHandler) channelRecipients(channelID uint, exclude []uint) []uint {
+	excluded := make(map[uint]bool, len(exclude))
+	for _, userID := range exclude {
+		excluded[userID] = true
+	}
+
+	h.channelMutex.RLock()
+	defer h.channelMutex.RUnlock()
+
+	recipients := make([]uint, 0, len(h.channelSubscribers[channelID]))
+	for userID := range h.channelSubscribers[channelID] {
+		if !excluded[userID] {
+			recipients = append(recipients, userID)
+		}
+	}
+	return recipients
+}
+
+// sendToUser sends a message to every connected device of a user, on any node
+func (h *WebSocketHandler) sendToUser(userID uint, message models.WSMessage) {
+	h.route(delivery{UserIDs: []uint{userID}}, message)
+}
+
+// sendSignal forwards WebRTC signaling to the device of the recipient that is
+// in the call, or to all their devices before one answered
+func (h *WebSocketHandler) sendSignal(message *models.WSMessage, callID uint) {
+	h.route(delivery{UserIDs: []uint{message.To}, CallID: callID}, *message)
+}
+
+// addUserSessions adds the session of the device connID of a user if it is
+// connected to this node, or all their sessions here, detached ones included,
+// when connID is empty. The mutex must be held.
+func (h *WebSocketHandler) addUserSessions(recipients map[*wsSession]bool, userID uint, connID string) {
+	if connID != "" {
+		if conn, exists := h.userConns[userID][connID]; exists {
+			recipients[conn.Session] = true
+		}
 		return
 	}
+	for _, session := range h.userSessions[userID] {
+		recipients[session] = true
+	}
+}
 
+// releaseCallDevice returns the updates unbinding a disconnected device from
+// its calls, signaling goes to all devices of the user again. The mutex must
+// be held.
+func (h *WebSocketHandler) releaseCallDevice(conn *WebSocketConnection) []callUpdate {
+	var released []callUpdate
+	for callID, parties := range h.calls {
+		if parties[conn.UserID] == conn.ConnID {
+			released = append(released, callUpdate{CallID: callID, UserID: conn.UserID})
+		}
+	}
+	return released
+}
+
+// sendToConnection sends a message through the session of the connection, a
+// device that cannot keep up is dropped and may resume later
+func (h *WebSocketHandler) sendToConnection(conn *WebSocketConnection, message models.WSMessage) {
+	if full := conn.Session.send(message); full != nil {
+		h.dropStream(full)
+	}
+}
+
+func (h *WebSocketHandler) broadcastToRoom(roomID string, message models.WSMessage, exclude uint) {
+	h.route(delivery{RoomID: roomID, ExcludeUser: exclude}, message)
+}
+
+// roomConnections returns the devices in a call room, except those of the
+// user exclude. The mutex must be held.
+func (h *WebSocketHandler) roomConnections(roomID string, exclude uint) []*WebSocketConnection {
+	room, exists := h.rooms[roomID]
+	if !exists {
+		return nil
+	}
+
+	var conns []*WebSocketConnection
+	seen := make(map[uint]bool, len(room.Participants))
 	for _, userID := range room.Participants {
-		if exclude != 0 && userID == exclude {
+		if seen[userID] || (exclude != 0 && userID == exclude) {
 			continue
 		}
-		h.sendToUser(userID, message)
+		seen[userID] = true
+
+		for _, conn := range h.userConns[userID] {
+			if conn.RoomID == roomID {
+				conns = append(conns, conn)
+			}
+		}
 	}
+	return conns
 }
 
-func (h *WebSocketHandler) removeFromRoom(roomID string, userID uint) {
+// removeFromRoom takes a device out of its call room. The mutex must be held,
+// the returned message is for the rest of the room once it is released.
+func (h *WebSocketHandler) removeFromRoom(roomID string, conn *WebSocketConnection) models.WSMessage {
 	room, exists := h.rooms[roomID]
 	if !exists {
-		return
+		return models.WSMessage{}
 	}
 
-	// Remove user from participants
+	// Remove the device from participants
 	for i, participantID := range room.Participants {
-		if participantID == userID {
+		if participantID == conn.UserID {
 			room.Participants = append(room.Participants[:i], room.Participants[i+1:]...)
 			break
 		}
 	}
+	conn.RoomID = ""
 
 	// Notify other participants
-	h.broadcastToRoom(roomID, models.WSMessage{
+	message := models.WSMessage{
 		Type:      models.MessageTypeUserLeft,
-		From:      userID,
+		From:      conn.UserID,
 		RoomID:    roomID,
 		Timestamp: time.Now().Format(time.RFC3339),
 		Data: h.marshalData(models.UserLeftMessage{
-			UserID: userID,
+			UserID: conn.UserID,
 			RoomID: roomID,
 		}),
-	}, userID)
+	}
 
 	// Remove room if empty
 	if len(room.Participants) == 0 {
 		delete(h.rooms, roomID)
 	}
 
-	log.Printf("User %d left room %s", userID, roomID)
+	log.Printf("User %d left room %s from %s", conn.UserID, roomID, conn.ConnID)
+	return message
+}
+
+// sendError replies to a request with an error, request is nil when the
+// message could not be read
+func (h *WebSocketHandler) sendError(conn *WebSocketConnection, request *models.WSMessage, code models.WSErrorCode, message string) {
+	h.replyError(conn, request, protocolError{Code: code, Message: message})
 }
 
-func (h *WebSocketHandler) sendError(conn *WebSocketConnection, code, message string) {
+func (h *WebSocketHandler) replyError(conn *WebSocketConnection, request *models.WSMessage, failure protocolError) {
 	errorMsg := models.WSMessage{
 		Type:      models.MessageTypeError,
 		Timestamp: time.Now().Format(time.RFC3339),
 		Data: h.marshalData(models.ErrorMessage{
-			Code:    code,
-			Message: message,
+			Code:    failure.Code,
+			Message: failure.Message,
+			Details: failure.Details,
 		}),
 	}
+	if request != nil {
+		errorMsg.RequestID = request.RequestID
+	}
 	h.sendToConnection(conn, errorMsg)
 }
 
@@ -615,7 +1403,8 @@ func (h *WebSocketHandler) marshalData(data interface{}) json.RawMessage {
 }
 
 func (h *WebSocketHandler) generateConnectionID() string {
-	return fmt.Sprintf("conn_%d_%d", time.Now().UnixNano(), time.Now().Unix())
+	// The node keeps connection IDs apart across the cluster
+	return fmt.Sprintf("conn_%s_%d", h.cluster.NodeID, time.Now().UnixNano())
 }
 
 // GetWebRTCConfig returns STUN/TURN server configuration
@@ -651,46 +1440,66 @@ func (h *WebSocketHandler) GetConnectionStats() map[string]interface{} {
 		"total_connections": len(h.connections),
 		"active_rooms":      len(h.rooms),
 		"connected_users":   len(h.userConns),
+		"sessions":          len(h.sessions),
+		"node_id":           h.cluster.NodeID,
 	}
 
 	// Add online status service stats
-	// if h.onlineStatusService != nil {
-	// 	onlineStats := h.onlineStatusService.GetConnectionStats()
-	// 	for key, value := range onlineStats {
-	// 		stats[key] = value
-	// 	}
-	// }
+	for key, value := range h.onlineStatusService.GetConnectionStats() {
+		stats[key] = value
+	}
 
 	return stats
 }
 
-// handleOnlineStatusChange broadcasts online status changes to relevant users
+// handleOnlineStatusChange broadcasts online status changes to the friends
+// allowed to see them. Routing only reaches those connected, on any node.
 func (h *WebSocketHandler) handleOnlineStatusChange(update services.OnlineStatusUpdate) {
-	// Create websocket message for online status change
-	// message := models.WSMessage{
-	// 	Type:      models.MessageTypeUserOnlineStatus,
-	// 	From:      update.UserID,
-	// 	Timestamp: time.Now().Format(time.RFC3339),
-	// 	Data: h.marshalData(models.UserOnlineStatusMessage{
-	// 		UserID:   update.UserID,
-	// 		IsOnline: update.IsOnline,
-	// 		LastSeen: update.LastSeen,
-	// 		Username: update.Username,
-	// 	}),
-	// }
-
-	// Get online friends of this user to notify them
-	// friendIDs, err := h.onlineStatusService.GetOnlineFriends(update.UserID)
-	// if err != nil {
-	// 	log.Printf("Failed to get online friends for user %d: %v", update.UserID, err)
-	// 	return
-	// }
-
-	// Send status update to online friends
-	// for _, friendID := range friendIDs {
-	// 	h.sendToUser(friendID, message)
-	// }
-
-	// log.Printf("Broadcasted online status change for user %d (online: %v) to %d friends",
-	// update.UserID, update.IsOnline, len(friendIDs))
+	friendIDs, err := h.onlineStatusService.GetStatusAudience(update.UserID)
+	if err != nil {
+		log.Printf("Failed to get status audience for user %d: %v", update.UserID, err)
+		return
+	}
+	if len(friendIDs) == 0 {
+		return
+	}
+
+	// Subscribers of the presence topic are held to the same audience, which
+	// may have changed since they subscribed
+	h.route(delivery{
+		UserIDs:    friendIDs,
+		Topic:      topicName(topicUser, update.UserID),
+		TopicUsers: append(friendIDs, update.UserID),
+	}, models.WSMessage{
+		Type:      models.MessageTypeUserOnlineStatus,
+		From:      update.UserID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data: h.marshalData(models.UserOnlineStatusMessage{
+			UserID:   update.UserID,
+			IsOnline: update.IsOnline,
+			LastSeen: update.LastSeen,
+			Username: update.Username,
+		}),
+	})
+
+	log.Printf("Broadcasted online status change for user %d (online: %v) to %d friends",
+		update.UserID, update.IsOnline, len(friendIDs))
+}
+
+// connectedElsewhere tells if another node holds a session of the user
+func (h *WebSocketHandler) connectedElsewhere(userID uint) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+
+	nodes, err := h.cluster.Presence.Nodes(ctx, []uint{userID})
+	if err != nil {
+		log.Printf("Failed to find nodes of user %d: %v", userID, err)
+		return false
+	}
+	for _, nodeID := range nodes[userID] {
+		if nodeID != h.cluster.NodeID {
+			return true
+		}
+	}
+	return false
 }
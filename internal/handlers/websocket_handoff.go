@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"social_server/internal/bus"
+	"time"
+)
+
+// A session lives on the node its client connected to. A client resuming on
+// another node has it handed off: a draining node saves its sessions to the
+// session store of the cluster, a node still holding one sends it over the
+// bus when asked.
+const sessionHandoffTimeout = time.Second
+
+// sessionHandoff asks the node holding a session to send it to the origin
+// of the envelope
+type sessionHandoff struct {
+	Token  string `json:"token"`
+	UserID uint   `json:"user_id"`
+}
+
+// resumeElsewhere takes over the session of the token from the store or the
+// node holding it, nil when none has it. The session is registered before it
+// is asked for, the events routed to the user meanwhile are kept.
+func (h *WebSocketHandler) resumeElsewhere(userID uint, token string) *wsSession {
+	session := &wsSession{Token: token, UserID: userID, detachedAt: time.Now()}
+	handoff := make(chan sessionState, 1)
+
+	h.mutex.Lock()
+	if _, exists := h.sessions[token]; exists {
+		h.mutex.Unlock()
+		return nil
+	}
+	h.sessions[token] = session
+	if h.userSessions[userID] == nil {
+		h.userSessions[userID] = make(map[string]*wsSession)
+	}
+	h.userSessions[userID][token] = session
+	h.handoffs[token] = handoff
+	h.mutex.Unlock()
+
+	h.updatePresence(userID, true)
+
+	state, found := h.takeSavedSession(token)
+	if !found {
+		state, found = h.requestSession(userID, token, handoff)
+	}
+
+	h.mutex.Lock()
+	delete(h.handoffs, token)
+	h.mutex.Unlock()
+
+	if !found || state.UserID != userID {
+		h.closeSession(session)
+		return nil
+	}
+	session.adopt(state)
+	return session
+}
+
+func (h *WebSocketHandler) takeSavedSession(token string) (sessionState, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+
+	var state sessionState
+	data, err := h.cluster.Sessions.Take(ctx, token)
+	if err != nil {
+		if !errors.Is(err, bus.ErrSessionNotFound) {
+			log.Printf("Failed to take saved session: %v", err)
+		}
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Invalid saved session: %v", err)
+		return state, false
+	}
+	return state, true
+}
+
+// requestSession asks every node for the session and waits for the one
+// holding it
+func (h *WebSocketHandler) requestSession(userID uint, token string, handoff chan sessionState) (sessionState, bool) {
+	h.publishTo(bus.BroadcastTopic, busEnvelope{
+		Origin:  h.cluster.NodeID,
+		Handoff: &sessionHandoff{Token: token, UserID: userID},
+	})
+
+	select {
+	case state := <-handoff:
+		return state, true
+	case <-time.After(sessionHandoffTimeout):
+		return sessionState{}, false
+	}
+}
+
+// handOffSession sends a session held here to the node its client resumed on
+func (h *WebSocketHandler) handOffSession(nodeID string, handoff sessionHandoff) {
+	h.mutex.RLock()
+	session := h.sessions[handoff.Token]
+	_, waiting := h.handoffs[handoff.Token]
+	h.mutex.RUnlock()
+
+	if session == nil || waiting || session.UserID != handoff.UserID {
+		return
+	}
+	if stream := session.attachedConn(); stream != nil {
+		h.dropStream(stream)
+	}
+	if !h.closeSession(session) {
+		return
+	}
+
+	state := session.state()
+	state.Token = handoff.Token
+	h.publishTo(bus.NodeTopic(nodeID), busEnvelope{Origin: h.cluster.NodeID, Session: &state})
+}
+
+// receiveSession passes a session handed off by another node to the resume
+// waiting for it
+func (h *WebSocketHandler) receiveSession(state sessionState) {
+	h.mutex.RLock()
+	handoff := h.handoffs[state.Token]
+	h.mutex.RUnlock()
+
+	if handoff != nil {
+		select {
+		case handoff <- state:
+		default:
+		}
+	}
+}
+
+// saveSession keeps a session of a draining node for its client to resume on
+// another node
+func (h *WebSocketHandler) saveSession(session *wsSession) {
+	state := session.state()
+	state.Token = session.Token
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Failed to marshal session: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+
+	if err := h.cluster.Sessions.Save(ctx, session.Token, data, resumeWindow); err != nil {
+		log.Printf("Failed to save session of user %d: %v", session.UserID, err)
+	}
+}
+
+func (h *WebSocketHandler) publishTo(topic string, envelope busEnvelope) {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("Failed to marshal bus envelope: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+
+	if err := h.cluster.Bus.Publish(ctx, topic, payload); err != nil {
+		log.Printf("Failed to publish to %s: %v", topic, err)
+	}
+}
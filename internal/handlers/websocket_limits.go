@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"social_server/internal/middleware"
+	"social_server/internal/models"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsRateLimit sizes the token bucket of a message type on a connection,
+// Burst messages at once then one per Refill
+type wsRateLimit struct {
+	Burst  int
+	Refill time.Duration
+}
+
+// wsRateLimits are the limits of the message types clients send, the others
+// share defaultWSRateLimit
+var wsRateLimits = map[models.MessageType]wsRateLimit{
+	models.MessageTypeChatSendMessage:    {Burst: 10, Refill: 500 * time.Millisecond},
+	models.MessageTypeChatCreateRoom:     {Burst: 3, Refill: 10 * time.Second},
+	models.MessageTypeCallRequest:        {Burst: 3, Refill: 10 * time.Second},
+	models.MessageTypeCallResponse:       {Burst: 5, Refill: 2 * time.Second},
+	models.MessageTypeCallEnd:            {Burst: 5, Refill: 2 * time.Second},
+	models.MessageTypeJoinRoom:           {Burst: 5, Refill: 2 * time.Second},
+	models.MessageTypeLeaveRoom:          {Burst: 5, Refill: 2 * time.Second},
+	models.MessageTypeOffer:              {Burst: 10, Refill: time.Second},
+	models.MessageTypeAnswer:             {Burst: 10, Refill: time.Second},
+	models.MessageTypeICECandidate:       {Burst: 50, Refill: 50 * time.Millisecond},
+	models.MessageTypeLiveLocationStart:  {Burst: 3, Refill: 5 * time.Second},
+	models.MessageTypeLiveLocationUpdate: {Burst: 5, Refill: time.Second},
+	models.MessageTypeLiveLocationStop:   {Burst: 3, Refill: 5 * time.Second},
+	models.MessageTypeReactionAdd:        {Burst: 20, Refill: 250 * time.Millisecond},
+	models.MessageTypeReactionRemove:     {Burst: 20, Refill: 250 * time.Millisecond},
+	models.MessageTypeHeartbeat:          {Burst: 5, Refill: 10 * time.Second},
+	models.MessageTypeAck:                {Burst: 50, Refill: 50 * time.Millisecond},
+	models.MessageTypeSubscribe:          {Burst: 20, Refill: 500 * time.Millisecond},
+	models.MessageTypeUnsubscribe:        {Burst: 20, Refill: 500 * time.Millisecond},
+}
+
+var defaultWSRateLimit = wsRateLimit{Burst: 20, Refill: 100 * time.Millisecond}
+
+// A connection that keeps sending rejected messages is closed once it spent
+// its violations, they come back one every few seconds
+const (
+	wsViolationBurst  = 20
+	wsViolationRefill = 3 * time.Second
+)
+
+// wsLimiter holds the buckets of a connection, only its reader uses it
+type wsLimiter struct {
+	buckets    map[models.MessageType]*middleware.TokenBucket
+	violations *middleware.TokenBucket
+}
+
+func newWSLimiter() *wsLimiter {
+	return &wsLimiter{
+		buckets:    make(map[models.MessageType]*middleware.TokenBucket),
+		violations: middleware.NewTokenBucket(wsViolationBurst, wsViolationRefill),
+	}
+}
+
+// allow takes a token from the bucket of the message type
+func (l *wsLimiter) allow(messageType models.MessageType) bool {
+	limit, exists := wsRateLimits[messageType]
+	if !exists {
+		// Unknown types share a bucket so clients cannot grow the map
+		messageType, limit = "", defaultWSRateLimit
+	}
+
+	bucket, exists := l.buckets[messageType]
+	if !exists {
+		bucket = middleware.NewTokenBucket(limit.Burst, limit.Refill)
+		l.buckets[messageType] = bucket
+	}
+	return bucket.Allow()
+}
+
+// violate records a rejected message, false once the connection spent its
+// violations
+func (l *wsLimiter) violate() bool {
+	return l.violations.Allow()
+}
+
+// originAllowed accepts browsers of the allowed origins. Clients outside
+// browsers send no Origin and are let through, their token authenticates them.
+func originAllowed(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// reserveConnection counts a websocket or HTTP stream of the user from the
+// IP against the limits of this node, unregisterConnection and
+// closeHTTPStream release it
+func (h *WebSocketHandler) reserveConnection(userID uint, ip string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.userConnCount[userID] >= h.maxConnsPerUser {
+		return fmt.Errorf("at most %d connections per user", h.maxConnsPerUser)
+	}
+	if h.ipConnCount[ip] >= h.maxConnsPerIP {
+		return fmt.Errorf("at most %d connections per IP", h.maxConnsPerIP)
+	}
+	h.userConnCount[userID]++
+	h.ipConnCount[ip]++
+	return nil
+}
+
+// releaseConnection must be called with the mutex held
+func (h *WebSocketHandler) releaseConnection(userID uint, ip string) {
+	if h.userConnCount[userID]--; h.userConnCount[userID] <= 0 {
+		delete(h.userConnCount, userID)
+	}
+	if h.ipConnCount[ip]--; h.ipConnCount[ip] <= 0 {
+		delete(h.ipConnCount, ip)
+	}
+}
+
+// closeForPolicy closes a connection that broke the limits, its session
+// stays to be resumed
+func (h *WebSocketHandler) closeForPolicy(conn *WebSocketConnection, reason string) {
+	log.Printf("Closing websocket %s of user %d: %s", conn.ConnID, conn.UserID, reason)
+
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	if err := conn.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second)); err != nil {
+		log.Printf("Failed to send close message to %s: %v", conn.ConnID, err)
+	}
+}
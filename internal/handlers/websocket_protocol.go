@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"social_server/internal/codec"
+	"social_server/internal/models"
+	"social_server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// protocolError is a request that cannot be handled, replied as an error message
+type protocolError struct {
+	Code    models.WSErrorCode
+	Message string
+	Details []string
+}
+
+// decodePayload checks the message type and decodes and validates its data
+// with the payload registered for it. Protocol v2 rejects unknown fields.
+func decodePayload(protocol string, message *models.WSMessage) (interface{}, *protocolError) {
+	spec, exists := models.LookupWSMessage(message.Type)
+	if !exists || !spec.AcceptedFromClient() {
+		return nil, &protocolError{
+			Code:    models.WSErrorUnknownMessageType,
+			Message: fmt.Sprintf("Unknown message type %q", message.Type),
+		}
+	}
+
+	payload := spec.NewPayload()
+	if payload == nil {
+		return nil, nil
+	}
+
+	if len(message.Data) > 0 && !bytes.Equal(message.Data, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(message.Data))
+		if protocol != models.WSProtocolV1 {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(payload); err != nil {
+			return nil, &protocolError{
+				Code:    models.WSErrorInvalidPayload,
+				Message: fmt.Sprintf("Invalid %s data: %v", message.Type, err),
+			}
+		}
+	}
+
+	if err := binding.Validator.ValidateStruct(payload); err != nil {
+		return nil, &protocolError{
+			Code:    models.WSErrorValidationFailed,
+			Message: fmt.Sprintf("Invalid %s data", message.Type),
+			Details: validationDetails(err),
+		}
+	}
+	return payload, nil
+}
+
+func supportsProtocol(requested []string) bool {
+	for _, protocol := range requested {
+		for _, supported := range models.WSProtocols {
+			if protocol == supported {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validationDetails names the field and rule of each validation failure
+func validationDetails(err error) []string {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []string{err.Error()}
+	}
+
+	details := make([]string, len(validationErrors))
+	for i, fieldError := range validationErrors {
+		details[i] = fmt.Sprintf("%s: %s", fieldError.Field(), fieldError.Tag())
+		if fieldError.Param() != "" {
+			details[i] += "=" + fieldError.Param()
+		}
+	}
+	return details
+}
+
+// ProtocolSchema describes every message of the websocket protocol as JSON
+// Schema, each message type is one variant of the envelope
+func ProtocolSchema() map[string]interface{} {
+	codes := make([]string, len(models.WSErrorCodes))
+	for i, code := range models.WSErrorCodes {
+		codes[i] = string(code)
+	}
+
+	builder := utils.NewJSONSchemaBuilder()
+	builder.Enums[reflect.TypeOf(models.WSErrorCode(""))] = codes
+	envelope := builder.Schema(reflect.TypeOf(models.WSMessage{}))
+
+	variants := make([]interface{}, 0, len(models.WSMessageSpecs))
+	for _, spec := range models.WSMessageSpecs {
+		properties := map[string]interface{}{
+			"type": map[string]interface{}{"const": spec.Type},
+		}
+		var payloads []interface{}
+		if spec.Payload != nil {
+			payloads = append(payloads, builder.Schema(reflect.TypeOf(spec.Payload)))
+		}
+		if pushed := spec.PushedPayload(); pushed != nil && reflect.TypeOf(pushed) != reflect.TypeOf(spec.Payload) {
+			payloads = append(payloads, builder.Schema(reflect.TypeOf(pushed)))
+		}
+		switch len(payloads) {
+		case 1:
+			properties["data"] = payloads[0]
+		case 2:
+			properties["data"] = map[string]interface{}{"oneOf": payloads}
+		}
+
+		variants = append(variants, map[string]interface{}{
+			"title":       string(spec.Type),
+			"description": spec.Description,
+			"x-direction": spec.Direction,
+			"properties":  properties,
+		})
+	}
+
+	return map[string]interface{}{
+		"$schema":       "https://json-schema.org/draft/2020-12/schema",
+		"$id":           "social_server/websocket",
+		"title":         "Social server websocket protocol",
+		"x-protocols":   models.WSProtocols,
+		"x-encodings":   codec.Encodings,
+		"x-error-codes": codes,
+		"allOf":         []interface{}{envelope},
+		"oneOf":         variants,
+		"$defs":         builder.Defs,
+	}
+}
+
+// GetProtocolSchema returns the JSON Schema of the websocket protocol
+// @Summary Get the websocket protocol schema
+// @Description Get a JSON Schema of every websocket message, its direction and payload, and of the error codes
+// @Tags Calls
+// @Produce json
+// @Success 200 {object} map[string]interface{} "JSON Schema of the protocol"
+// @Router /ws/schema [get]
+func (h *WebSocketHandler) GetProtocolSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, ProtocolSchema())
+}
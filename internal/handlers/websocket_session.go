@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"social_server/internal/models"
+	"sync"
+	"time"
+)
+
+// Each connection belongs to a session numbering the events it sends. The
+// last events are buffered until acked, a client that reconnects with the
+// resume token gets those it missed while the session was detached. Sessions
+// are the same whatever the transport, a websocket, an SSE stream or a long
+// poll, and whatever the node the client resumes on.
+const (
+	replayBufferSize     = 512
+	resumeWindow         = 2 * time.Minute
+	criticalRetryDelay   = 5 * time.Second
+	criticalRetryLimit   = 3
+	sessionSweepInterval = time.Second
+)
+
+// criticalMessageTypes are resent until acked, a lost call request would
+// leave the caller ringing
+var criticalMessageTypes = map[models.MessageType]bool{
+	models.MessageTypeCallRequest:  true,
+	models.MessageTypeCallResponse: true,
+	models.MessageTypeCallEnd:      true,
+}
+
+// unsequencedMessageTypes only make sense on the connection they are sent on
+var unsequencedMessageTypes = map[models.MessageType]bool{
+	models.MessageTypeConnected: true,
+	models.MessageTypeHeartbeat: true,
+	models.MessageTypeError:     true,
+
+	models.MessageTypeServerGoingAway: true,
+}
+
+// eventStream is the transport a session writes its events to
+type eventStream interface {
+	// push queues an event, false when the stream cannot keep up
+	push(message models.WSMessage) bool
+}
+
+type wsSession struct {
+	Token      string
+	UserID     uint
+	conn       eventStream // nil while detached
+	detachedAt time.Time
+	seq        uint64 // Last sequence assigned
+	trimmed    uint64 // Events up to this sequence are no longer buffered
+	buffer     []sessionEvent
+	mutex      sync.Mutex
+}
+
+type sessionEvent struct {
+	message  models.WSMessage
+	sentAt   time.Time
+	attempts int
+}
+
+func newSession(userID uint) (*wsSession, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	return &wsSession{
+		Token:      hex.EncodeToString(token),
+		UserID:     userID,
+		detachedAt: time.Now(),
+	}, nil
+}
+
+// sessionState is a session handed off to another node, with the events
+// still buffered
+type sessionState struct {
+	Token   string             `json:"token"`
+	UserID  uint               `json:"user_id"`
+	Seq     uint64             `json:"seq"`
+	Trimmed uint64             `json:"trimmed"`
+	Events  []models.WSMessage `json:"events,omitempty"`
+}
+
+// send numbers and buffers an event and writes it to the attached device.
+// Returns the stream whose send buffer is full, it has to be dropped.
+func (s *wsSession) send(message models.WSMessage) eventStream {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if unsequencedMessageTypes[message.Type] {
+		if s.conn != nil && !s.write(message) {
+			return s.conn
+		}
+		return nil
+	}
+
+	s.seq++
+	message.Seq = s.seq
+	if len(s.buffer) >= replayBufferSize {
+		s.trimmed = s.buffer[0].message.Seq
+		s.buffer = s.buffer[1:]
+	}
+	s.buffer = append(s.buffer, sessionEvent{message: message, sentAt: time.Now(), attempts: 1})
+
+	if s.conn != nil && !s.write(message) {
+		return s.conn
+	}
+	return nil
+}
+
+// ack drops the events the client handled
+func (s *wsSession) ack(seq uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ackLocked(seq)
+}
+
+func (s *wsSession) ackLocked(seq uint64) {
+	if seq > s.seq {
+		seq = s.seq
+	}
+	if seq <= s.trimmed {
+		return
+	}
+
+	i := 0
+	for i < len(s.buffer) && s.buffer[i].message.Seq <= seq {
+		i++
+	}
+	s.buffer = s.buffer[i:]
+	s.trimmed = seq
+}
+
+// attach binds a device to the session and replays the events after lastSeq,
+// after the connected message. When some of those events are no longer
+// buffered the client is told to resync and nothing is replayed.
+func (s *wsSession) attach(conn eventStream, lastSeq uint64, connected models.ConnectedMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if lastSeq < s.trimmed || lastSeq > s.seq {
+		connected.ResyncRequired = true
+		lastSeq = s.seq
+	}
+	s.ackLocked(lastSeq)
+	s.conn = conn
+
+	connected.ResumeToken = s.Token
+	connected.LastSeq = s.seq
+	data, _ := json.Marshal(connected)
+	s.write(models.WSMessage{
+		Type:      models.MessageTypeConnected,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      data,
+	})
+
+	now := time.Now()
+	for i := range s.buffer {
+		s.buffer[i].sentAt = now
+		s.buffer[i].attempts++
+		s.write(s.buffer[i].message)
+	}
+}
+
+// detach unbinds the device if it still holds the session
+func (s *wsSession) detach(conn eventStream) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn != conn {
+		return false
+	}
+	s.conn = nil
+	s.detachedAt = time.Now()
+	return true
+}
+
+func (s *wsSession) attachedConn() eventStream {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.conn
+}
+
+// retry resends the critical events that were not acked in time. HTTP
+// streams are acked by resuming, resending would only duplicate events.
+func (s *wsSession) retry(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, isWebSocket := s.conn.(*WebSocketConnection); !isWebSocket {
+		return
+	}
+
+	for i := range s.buffer {
+		event := &s.buffer[i]
+		if !criticalMessageTypes[event.message.Type] || event.attempts > criticalRetryLimit {
+			continue
+		}
+		if now.Sub(event.sentAt) < criticalRetryDelay {
+			continue
+		}
+
+		event.sentAt = now
+		event.attempts++
+		s.write(event.message)
+	}
+}
+
+// state returns what another node needs to resume the session
+func (s *wsSession) state() sessionState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := sessionState{
+		UserID:  s.UserID,
+		Seq:     s.seq,
+		Trimmed: s.trimmed,
+		Events:  make([]models.WSMessage, len(s.buffer)),
+	}
+	for i, event := range s.buffer {
+		state.Events[i] = event.message
+	}
+	return state
+}
+
+// adopt continues a session handed off by another node. The events this one
+// buffered while it was taken over come after those of the other node.
+func (s *wsSession) adopt(state sessionState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	trimmed := state.Trimmed
+	buffer := make([]sessionEvent, 0, len(state.Events)+len(s.buffer))
+	for _, message := range state.Events {
+		buffer = append(buffer, sessionEvent{message: message, sentAt: now})
+	}
+	if s.trimmed > 0 {
+		// This node alone had more events than are buffered
+		trimmed = state.Seq + s.trimmed
+		buffer = buffer[:0]
+	}
+	for _, event := range s.buffer {
+		event.message.Seq += state.Seq
+		buffer = append(buffer, event)
+	}
+	if len(buffer) > replayBufferSize {
+		trimmed = buffer[len(buffer)-replayBufferSize-1].message.Seq
+		buffer = buffer[len(buffer)-replayBufferSize:]
+	}
+
+	s.seq += state.Seq
+	s.trimmed = trimmed
+	s.buffer = buffer
+}
+
+// expired tells if the session was detached for longer than it can be resumed
+func (s *wsSession) expired(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.conn == nil && now.Sub(s.detachedAt) > resumeWindow
+}
+
+// write queues a message on the attached device. The mutex must be held.
+func (s *wsSession) write(message models.WSMessage) bool {
+	return s.conn.push(message)
+}
+
+// push encodes a message for the websocket and queues it on the sender
+func (c *WebSocketConnection) push(message models.WSMessage) bool {
+	messageBytes, err := c.Codec.Encode(&message)
+	if err != nil {
+		log.Printf("Failed to marshal message: %v", err)
+		return true
+	}
+
+	select {
+	case c.SendChan <- messageBytes:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"social_server/internal/codec"
+	"social_server/internal/models"
+	"testing"
+	"time"
+)
+
+func newTestSession(t *testing.T) *wsSession {
+	t.Helper()
+
+	session, err := newSession(1)
+	if err != nil {
+		t.Fatalf("newSession() error = %v", err)
+	}
+	return session
+}
+
+// drain returns the messages queued on a stream
+func drain(stream *httpStream) []models.WSMessage {
+	var messages []models.WSMessage
+	for {
+		select {
+		case message := <-stream.events:
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+func seqs(messages []models.WSMessage) []uint64 {
+	result := make([]uint64, len(messages))
+	for i, message := range messages {
+		result[i] = message.Seq
+	}
+	return result
+}
+
+func equalSeqs(got []uint64, want ...uint64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSessionSequencesEvents(t *testing.T) {
+	session := newTestSession(t)
+	stream := newHTTPStream("")
+	session.attach(stream, 0, models.ConnectedMessage{})
+
+	session.send(models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	session.send(models.WSMessage{Type: models.MessageTypeHeartbeat})
+	session.send(models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+
+	messages := drain(stream)
+	if len(messages) != 4 || messages[0].Type != models.MessageTypeConnected {
+		t.Fatalf("got %d messages, want connected and 3 events", len(messages))
+	}
+	// Heartbeats only make sense on their connection and are not numbered
+	if got := seqs(messages[1:]); !equalSeqs(got, 1, 0, 2) {
+		t.Errorf("sequences = %v, want [1 0 2]", got)
+	}
+	if len(session.buffer) != 2 {
+		t.Errorf("buffered %d events, want the 2 sequenced ones", len(session.buffer))
+	}
+}
+
+func TestSessionReplaysAfterLastSeq(t *testing.T) {
+	session := newTestSession(t)
+	for i := 0; i < 5; i++ {
+		session.send(models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	}
+
+	stream := newHTTPStream("")
+	session.attach(stream, 2, models.ConnectedMessage{})
+
+	messages := drain(stream)
+	connected := messages[0]
+	if connected.Type != models.MessageTypeConnected {
+		t.Fatalf("first message is %q, want %q", connected.Type, models.MessageTypeConnected)
+	}
+	if got := seqs(messages[1:]); !equalSeqs(got, 3, 4, 5) {
+		t.Errorf("replayed %v, want [3 4 5]", got)
+	}
+	// Resuming acks what the client had
+	if len(session.buffer) != 3 || session.trimmed != 2 {
+		t.Errorf("buffer holds %d events trimmed at %d, want 3 trimmed at 2", len(session.buffer), session.trimmed)
+	}
+}
+
+func TestSessionResyncWhenEventsWereDropped(t *testing.T) {
+	tests := []struct {
+		name    string
+		lastSeq uint64
+		resync  bool
+	}{
+		{"no longer buffered", 5, true},
+		{"ahead of the session", replayBufferSize + 20, true},
+		{"still buffered", replayBufferSize + 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newTestSession(t)
+			for i := 0; i < replayBufferSize+10; i++ {
+				session.send(models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+			}
+			if session.trimmed != 10 {
+				t.Fatalf("trimmed = %d, want the 10 events past the buffer", session.trimmed)
+			}
+
+			stream := newHTTPStream("")
+			session.attach(stream, tt.lastSeq, models.ConnectedMessage{})
+
+			var connected models.ConnectedMessage
+			messages := drain(stream)
+			if err := json.Unmarshal(messages[0].Data, &connected); err != nil {
+				t.Fatalf("invalid connected message: %v", err)
+			}
+			if connected.ResyncRequired != tt.resync {
+				t.Errorf("ResyncRequired = %v, want %v", connected.ResyncRequired, tt.resync)
+			}
+			if connected.LastSeq != replayBufferSize+10 {
+				t.Errorf("LastSeq = %d, want %d", connected.LastSeq, replayBufferSize+10)
+			}
+			if tt.resync && len(messages) != 1 {
+				t.Errorf("replayed %d events, want none when resyncing", len(messages)-1)
+			}
+		})
+	}
+}
+
+func TestSessionAck(t *testing.T) {
+	session := newTestSession(t)
+	for i := 0; i < 5; i++ {
+		session.send(models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	}
+
+	session.ack(3)
+	if len(session.buffer) != 2 || session.buffer[0].message.Seq != 4 {
+		t.Errorf("buffer starts at %v after ack of 3, want [4 5]", session.buffer)
+	}
+
+	// Old and future acks change nothing past what was sent
+	session.ack(2)
+	session.ack(100)
+	if len(session.buffer) != 0 || session.trimmed != 5 {
+		t.Errorf("buffer holds %d events trimmed at %d, want none trimmed at 5", len(session.buffer), session.trimmed)
+	}
+}
+
+func TestSessionRetriesCriticalEvents(t *testing.T) {
+	session := newTestSession(t)
+	conn := &WebSocketConnection{Codec: codec.JSON{}, SendChan: make(chan []byte, 64)}
+	session.attach(conn, 0, models.ConnectedMessage{})
+	<-conn.SendChan
+
+	session.send(models.WSMessage{Type: models.MessageTypeCallRequest})
+	session.send(models.WSMessage{Type: models.MessageTypeChatReceiveMessage})
+	<-conn.SendChan
+	<-conn.SendChan
+
+	start := time.Now()
+	session.retry(start.Add(criticalRetryDelay / 2))
+	if len(conn.SendChan) != 0 {
+		t.Fatalf("resent %d events before the retry delay", len(conn.SendChan))
+	}
+
+	// Only the call request is resent, up to the retry limit
+	retries := 0
+	for i := 1; i <= criticalRetryLimit+2; i++ {
+		session.retry(start.Add(time.Duration(i) * criticalRetryDelay))
+		for len(conn.SendChan) > 0 {
+			<-conn.SendChan
+			retries++
+		}
+	}
+	if retries != criticalRetryLimit {
+		t.Errorf("resent %d times, want %d", retries, criticalRetryLimit)
+	}
+
+	// Acked events are not resent
+	session = newTestSession(t)
+	session.attach(conn, 0, models.ConnectedMessage{})
+	session.send(models.WSMessage{Type: models.MessageTypeCallEnd})
+	session.ack(1)
+	drainBytes(conn.SendChan)
+	session.retry(start.Add(time.Hour))
+	if len(conn.SendChan) != 0 {
+		t.Error("acked call end was resent")
+	}
+}
+
+func TestSessionRetryIgnoresHTTPStreams(t *testing.T) {
+	session := newTestSession(t)
+	stream := newHTTPStream("")
+	session.attach(stream, 0, models.ConnectedMessage{})
+	session.send(models.WSMessage{Type: models.MessageTypeCallRequest})
+	drain(stream)
+
+	session.retry(time.Now().Add(time.Hour))
+	if messages := drain(stream); len(messages) != 0 {
+		t.Errorf("resent %d events on an HTTP stream", len(messages))
+	}
+}
+
+func drainBytes(frames chan []byte) {
+	for len(frames) > 0 {
+		<-frames
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connections subscribe to topics to get the events of resources besides
+// those addressed to their user: post:<id> for its counts,
+// user:<id>:presence and room:<id> for the rooms open on the device.
+// Subscriptions are checked with the rules of the REST endpoints and belong
+// to the connection, they are dropped with it or once the user loses access
+// to the resource.
+const (
+	topicPost = "post"
+	topicUser = "user"
+	topicRoom = "room"
+
+	maxTopicsPerConn = 100
+)
+
+// topicName returns the topic of a resource, as events are routed to it
+func topicName(kind string, id uint) string {
+	if kind == topicUser {
+		return fmt.Sprintf("%s:%d:presence", topicUser, id)
+	}
+	return fmt.Sprintf("%s:%d", kind, id)
+}
+
+// parseTopic returns the kind and ID of a topic, ok is false when it is not
+// one of the known forms
+func parseTopic(topic string) (kind string, id uint, ok bool) {
+	parts := strings.Split(topic, ":")
+	switch {
+	case len(parts) == 2 && (parts[0] == topicPost || parts[0] == topicRoom):
+	case len(parts) == 3 && parts[0] == topicUser && parts[2] == "presence":
+	default:
+		return "", 0, false
+	}
+
+	parsed, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || parsed == 0 {
+		return "", 0, false
+	}
+	return parts[0], uint(parsed), true
+}
+
+// authorizeTopic checks the user may follow the resource of a topic
+func (h *WebSocketHandler) authorizeTopic(userID uint, kind string, id uint) error {
+	switch kind {
+	case topicPost:
+		return h.postService.CheckPostAccess(id, userID)
+	case topicRoom:
+		return h.chatService.CheckRoomAccess(id, userID)
+	default:
+		allowed, err := h.onlineStatusService.CanSeeStatus(userID, id)
+		if err != nil {
+			return fmt.Errorf("failed to check status visibility: %w", err)
+		}
+		if !allowed {
+			return fmt.Errorf("access denied")
+		}
+		return nil
+	}
+}
+
+func (h *WebSocketHandler) handleSubscribe(conn *WebSocketConnection, message *models.WSMessage, req *models.TopicMessage) {
+	kind, id, ok := parseTopic(req.Topic)
+	if !ok {
+		h.sendError(conn, message, models.WSErrorInvalidTopic, "Topics are post:<id>, user:<id>:presence and room:<id>")
+		return
+	}
+	if err := h.authorizeTopic(conn.UserID, kind, id); err != nil {
+		h.sendError(conn, message, models.WSErrorSubscriptionDenied, err.Error())
+		return
+	}
+
+	// The same topic written differently is one subscription
+	topic := topicName(kind, id)
+
+	h.mutex.Lock()
+	if h.connections[conn.ConnID] != conn {
+		// Disconnected while authorizing
+		h.mutex.Unlock()
+		return
+	}
+	if len(conn.Topics) >= maxTopicsPerConn && !conn.Topics[topic] {
+		h.mutex.Unlock()
+		h.sendError(conn, message, models.WSErrorSubscriptionDenied, fmt.Sprintf("At most %d topics per connection", maxTopicsPerConn))
+		return
+	}
+	if conn.Topics == nil {
+		conn.Topics = make(map[string]bool)
+	}
+	conn.Topics[topic] = true
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[string]*WebSocketConnection)
+	}
+	h.topics[topic][conn.ConnID] = conn
+	h.mutex.Unlock()
+
+	h.replyTopic(conn, message, models.MessageTypeSubscribed, topic)
+}
+
+func (h *WebSocketHandler) handleUnsubscribe(conn *WebSocketConnection, message *models.WSMessage, req *models.TopicMessage) {
+	topic := req.Topic
+	if kind, id, ok := parseTopic(req.Topic); ok {
+		topic = topicName(kind, id)
+	}
+
+	h.mutex.Lock()
+	h.removeTopic(conn, topic)
+	h.mutex.Unlock()
+
+	h.replyTopic(conn, message, models.MessageTypeUnsubscribed, topic)
+}
+
+func (h *WebSocketHandler) replyTopic(conn *WebSocketConnection, request *models.WSMessage, messageType models.MessageType, topic string) {
+	h.sendToConnection(conn, models.WSMessage{
+		Type:      messageType,
+		RequestID: request.RequestID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      h.marshalData(models.TopicMessage{Topic: topic}),
+	})
+}
+
+// removeTopic unsubscribes a connection from a topic. The mutex must be held.
+func (h *WebSocketHandler) removeTopic(conn *WebSocketConnection, topic string) {
+	delete(conn.Topics, topic)
+	delete(h.topics[topic], conn.ConnID)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// addTopicSessions adds the subscribers of a topic to the recipients, only
+// those of users when it is not empty. The mutex must be held.
+func (h *WebSocketHandler) addTopicSessions(recipients map[*wsSession]bool, topic string, users []uint) {
+	allowed := make(map[uint]bool, len(users))
+	for _, userID := range users {
+		allowed[userID] = true
+	}
+
+	for _, conn := range h.topics[topic] {
+		if len(users) == 0 || allowed[conn.UserID] {
+			recipients[conn.Session] = true
+		}
+	}
+}
+
+// recheckTopics authorizes again the subscriptions of this node the target
+// names, to its topic or every topic of its kind, and drops those denied.
+// The connection is told with an unsubscribed message without request_id.
+func (h *WebSocketHandler) recheckTopics(target delivery) {
+	if target.Recheck == "" {
+		return
+	}
+
+	users := make(map[uint]bool, len(target.UserIDs))
+	for _, userID := range target.UserIDs {
+		users[userID] = true
+	}
+
+	type subscription struct {
+		conn  *WebSocketConnection
+		topic string
+	}
+	var subscriptions []subscription
+	h.mutex.RLock()
+	for topic, conns := range h.topics {
+		if topic != target.Recheck && !strings.HasPrefix(topic, target.Recheck+":") {
+			continue
+		}
+		for _, conn := range conns {
+			if len(users) == 0 || users[conn.UserID] {
+				subscriptions = append(subscriptions, subscription{conn: conn, topic: topic})
+			}
+		}
+	}
+	h.mutex.RUnlock()
+
+	// Devices of a user share the answer
+	denied := make(map[string]map[uint]bool)
+	for _, sub := range subscriptions {
+		if denied[sub.topic] == nil {
+			denied[sub.topic] = make(map[uint]bool)
+		}
+		isDenied, checked := denied[sub.topic][sub.conn.UserID]
+		if !checked {
+			kind, id, _ := parseTopic(sub.topic)
+			if err := h.authorizeTopic(sub.conn.UserID, kind, id); err != nil {
+				log.Printf("Dropping subscription of user %d to %s: %v", sub.conn.UserID, sub.topic, err)
+				isDenied = true
+			}
+			denied[sub.topic][sub.conn.UserID] = isDenied
+		}
+		if !isDenied {
+			continue
+		}
+
+		h.mutex.Lock()
+		subscribed := sub.conn.Topics[sub.topic]
+		h.removeTopic(sub.conn, sub.topic)
+		h.mutex.Unlock()
+
+		if subscribed {
+			h.replyTopic(sub.conn, &models.WSMessage{}, models.MessageTypeUnsubscribed, sub.topic)
+		}
+	}
+}
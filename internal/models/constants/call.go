@@ -10,10 +10,11 @@ const (
 type CallStatus string
 
 const (
-	CallStatusPending  CallStatus = "pending"
-	CallStatusRinging  CallStatus = "ringing"
-	CallStatusOngoing  CallStatus = "ongoing"
-	CallStatusEnded    CallStatus = "ended"
-	CallStatusDeclined CallStatus = "declined"
-	CallStatusMissed   CallStatus = "missed"
+	CallStatusPending   CallStatus = "pending"
+	CallStatusRinging   CallStatus = "ringing"
+	CallStatusOngoing   CallStatus = "ongoing"
+	CallStatusEnded     CallStatus = "ended"
+	CallStatusDeclined  CallStatus = "declined"
+	CallStatusMissed    CallStatus = "missed"
+	CallStatusMigrating CallStatus = "migrating" // A party is moving to another node
 )
\ No newline at end of file
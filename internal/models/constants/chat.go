@@ -5,6 +5,8 @@ type ChatRoomType string
 const (
 	ChatRoomTypePrivate ChatRoomType = "private"
 	ChatRoomTypeGroup   ChatRoomType = "group"
+	// Only owners and admins post, subscribers follow without seeing each other
+	ChatRoomTypeChannel ChatRoomType = "channel"
 )
 
 type ParticipantRole string
@@ -25,6 +27,7 @@ const (
 	MessageTypeFile     MessageType = "file"
 	MessageTypeSystem   MessageType = "system"
 	MessageTypeLocation MessageType = "location"
+	MessageTypePoll     MessageType = "poll"
 )
 
 type MessageStatus string
@@ -50,4 +53,97 @@ const (
 	WSEventParticipantJoin  WebSocketEventType = "participant_join"
 	WSEventParticipantLeave WebSocketEventType = "participant_leave"
 	WSEventMessageReaction  WebSocketEventType = "message_reaction"
-)
\ No newline at end of file
+)
+
+type DisappearingMode string
+
+const (
+	// Timer starts as soon as the message is sent
+	DisappearingModeAfterSend DisappearingMode = "after_send"
+	// Timer starts when the message is read for the first time
+	DisappearingModeAfterRead DisappearingMode = "after_read"
+)
+
+type ScheduledMessageStatus string
+
+const (
+	ScheduledMessageStatusPending   ScheduledMessageStatus = "pending"
+	ScheduledMessageStatusSending   ScheduledMessageStatus = "sending"
+	ScheduledMessageStatusSent      ScheduledMessageStatus = "sent"
+	ScheduledMessageStatusCancelled ScheduledMessageStatus = "cancelled"
+	ScheduledMessageStatusFailed    ScheduledMessageStatus = "failed"
+)
+
+type ChatInviteStatus string
+
+const (
+	ChatInviteStatusPending  ChatInviteStatus = "pending"
+	ChatInviteStatusAccepted ChatInviteStatus = "accepted"
+	ChatInviteStatusDeclined ChatInviteStatus = "declined"
+	ChatInviteStatusExpired  ChatInviteStatus = "expired"
+)
+
+type ChatJoinRequestStatus string
+
+const (
+	ChatJoinRequestStatusPending  ChatJoinRequestStatus = "pending"
+	ChatJoinRequestStatusApproved ChatJoinRequestStatus = "approved"
+	ChatJoinRequestStatusRejected ChatJoinRequestStatus = "rejected"
+)
+
+type ChatModerationAction string
+
+const (
+	ChatModerationActionKick     ChatModerationAction = "kick"
+	ChatModerationActionBan      ChatModerationAction = "ban"
+	ChatModerationActionUnban    ChatModerationAction = "unban"
+	ChatModerationActionMute     ChatModerationAction = "mute"
+	ChatModerationActionUnmute   ChatModerationAction = "unmute"
+	ChatModerationActionSlowMode ChatModerationAction = "slow_mode"
+)
+
+type ChatWebhookType string
+
+const (
+	// Posts messages to the room as the bot
+	ChatWebhookTypeIncoming ChatWebhookType = "incoming"
+	// Sends matching room messages to an external URL
+	ChatWebhookTypeOutgoing ChatWebhookType = "outgoing"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+type SlashCommandOutput string
+
+const (
+	// The command posts a message visible to the room
+	SlashCommandOutputMessage SlashCommandOutput = "message"
+	// The command only answers the sender
+	SlashCommandOutputEphemeral SlashCommandOutput = "ephemeral"
+	// The command acts on the room, e.g. kicks a member
+	SlashCommandOutputAction SlashCommandOutput = "action"
+)
+
+type ChatExportFormat string
+
+const (
+	ChatExportFormatJSON ChatExportFormat = "json"
+	ChatExportFormatHTML ChatExportFormat = "html"
+	ChatExportFormatText ChatExportFormat = "text"
+)
+
+type ChatExportStatus string
+
+const (
+	ChatExportStatusPending    ChatExportStatus = "pending"
+	ChatExportStatusProcessing ChatExportStatus = "processing"
+	ChatExportStatusCompleted  ChatExportStatus = "completed"
+	ChatExportStatusFailed     ChatExportStatus = "failed"
+	ChatExportStatusExpired    ChatExportStatus = "expired"
+)
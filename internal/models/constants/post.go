@@ -8,6 +8,7 @@ const (
 	PostTypeVideo PostType = "video"
 	PostTypeLink  PostType = "link"
 	PostTypeAudio PostType = "audio"
+	PostTypePoll  PostType = "poll"
 )
 
 type PostPrivacy string
@@ -11,10 +11,11 @@ type Call struct {
 	CallerID    uint           `gorm:"not null;index" json:"caller_id"`
 	CalleeID    *uint          `gorm:"index" json:"callee_id,omitempty"`
 	Type        string         `gorm:"size:20;not null;default:video" json:"type"` // video, audio
-	Status      string         `gorm:"size:20;not null;default:pending" json:"status"` // pending, ringing, ongoing, ended, declined, missed
+	Status      string         `gorm:"size:20;not null;default:pending" json:"status"` // pending, ringing, ongoing, migrating, ended, declined, missed
 	Duration    int            `gorm:"default:0" json:"duration"` // in seconds
 	StartedAt   *time.Time     `json:"started_at,omitempty"`
 	EndedAt     *time.Time     `json:"ended_at,omitempty"`
+	MigratedAt  *time.Time     `json:"migrated_at,omitempty"` // When it started migrating, ended if not resumed in time
 	IsGroupCall bool           `gorm:"default:false" json:"is_group_call"`
 	RoomID      string         `gorm:"size:100" json:"room_id,omitempty"`
 	
@@ -59,12 +60,13 @@ type CallType = constants.CallType
 type CallStatus = constants.CallStatus
 
 const (
-	CallStatusPending  = constants.CallStatusPending
-	CallStatusRinging  = constants.CallStatusRinging
-	CallStatusOngoing  = constants.CallStatusOngoing
-	CallStatusEnded    = constants.CallStatusEnded
-	CallStatusDeclined = constants.CallStatusDeclined
-	CallStatusMissed   = constants.CallStatusMissed
+	CallStatusPending   = constants.CallStatusPending
+	CallStatusRinging   = constants.CallStatusRinging
+	CallStatusOngoing   = constants.CallStatusOngoing
+	CallStatusEnded     = constants.CallStatusEnded
+	CallStatusDeclined  = constants.CallStatusDeclined
+	CallStatusMissed    = constants.CallStatusMissed
+	CallStatusMigrating = constants.CallStatusMigrating
 )
 
 const (
@@ -0,0 +1,32 @@
+package postgres
+
+import "time"
+
+// ChannelSubscription is a user following a channel. Subscribers are not
+// participants: they read the channel but cannot post or see each other.
+type ChannelSubscription struct {
+	ID         uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID uint `gorm:"not null;uniqueIndex:idx_channel_subscription_room_user" json:"chat_room_id"`
+	UserID     uint `gorm:"not null;uniqueIndex:idx_channel_subscription_room_user;index" json:"user_id"`
+
+	// Relationships
+	ChatRoom *ChatRoom `gorm:"foreignKey:ChatRoomID" json:"chat_room,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageView records that a user saw a channel message, once per user
+type MessageView struct {
+	MessageID uint      `gorm:"primaryKey" json:"message_id"`
+	UserID    uint      `gorm:"primaryKey" json:"user_id"`
+	ViewedAt  time.Time `json:"viewed_at"`
+}
+
+func (ChannelSubscription) TableName() string {
+	return "channel_subscriptions"
+}
+
+func (MessageView) TableName() string {
+	return "message_views"
+}
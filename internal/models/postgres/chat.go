@@ -11,10 +11,16 @@ type ChatRoomType = constants.ChatRoomType
 type ParticipantRole = constants.ParticipantRole
 type MessageType = constants.MessageType
 type MessageStatus = constants.MessageStatus
+type DisappearingMode = constants.DisappearingMode
+type ChatInviteStatus = constants.ChatInviteStatus
+type ChatJoinRequestStatus = constants.ChatJoinRequestStatus
+type ChatModerationAction = constants.ChatModerationAction
+type ScheduledMessageStatus = constants.ScheduledMessageStatus
 
 const (
 	ChatRoomTypePrivate = constants.ChatRoomTypePrivate
 	ChatRoomTypeGroup   = constants.ChatRoomTypeGroup
+	ChatRoomTypeChannel = constants.ChatRoomTypeChannel
 )
 
 const (
@@ -31,6 +37,7 @@ const (
 	MessageTypeFile     = constants.MessageTypeFile
 	MessageTypeSystem   = constants.MessageTypeSystem
 	MessageTypeLocation = constants.MessageTypeLocation
+	MessageTypePoll     = constants.MessageTypePoll
 )
 
 const (
@@ -40,6 +47,41 @@ const (
 	MessageStatusFailed    = constants.MessageStatusFailed
 )
 
+const (
+	DisappearingModeAfterSend = constants.DisappearingModeAfterSend
+	DisappearingModeAfterRead = constants.DisappearingModeAfterRead
+)
+
+const (
+	ChatInviteStatusPending  = constants.ChatInviteStatusPending
+	ChatInviteStatusAccepted = constants.ChatInviteStatusAccepted
+	ChatInviteStatusDeclined = constants.ChatInviteStatusDeclined
+	ChatInviteStatusExpired  = constants.ChatInviteStatusExpired
+)
+
+const (
+	ChatJoinRequestStatusPending  = constants.ChatJoinRequestStatusPending
+	ChatJoinRequestStatusApproved = constants.ChatJoinRequestStatusApproved
+	ChatJoinRequestStatusRejected = constants.ChatJoinRequestStatusRejected
+)
+
+const (
+	ChatModerationActionKick     = constants.ChatModerationActionKick
+	ChatModerationActionBan      = constants.ChatModerationActionBan
+	ChatModerationActionUnban    = constants.ChatModerationActionUnban
+	ChatModerationActionMute     = constants.ChatModerationActionMute
+	ChatModerationActionUnmute   = constants.ChatModerationActionUnmute
+	ChatModerationActionSlowMode = constants.ChatModerationActionSlowMode
+)
+
+const (
+	ScheduledMessageStatusPending   = constants.ScheduledMessageStatusPending
+	ScheduledMessageStatusSending   = constants.ScheduledMessageStatusSending
+	ScheduledMessageStatusSent      = constants.ScheduledMessageStatusSent
+	ScheduledMessageStatusCancelled = constants.ScheduledMessageStatusCancelled
+	ScheduledMessageStatusFailed    = constants.ScheduledMessageStatusFailed
+)
+
 type ChatRoom struct {
 	ID           uint         `gorm:"primaryKey;autoIncrement" json:"id"`
 	LocalID      uint         `gorm:"size:100" json:"local_id"`
@@ -51,6 +93,12 @@ type ChatRoom struct {
 	IsArchived   bool         `gorm:"default:false" json:"is_archived"`
 	LastActivity *time.Time   `gorm:"index" json:"last_activity"`
 
+	// Channels, the participants of a channel are its staff and its
+	// subscribers are kept in ChannelSubscription
+	Handle          *string `gorm:"size:32;uniqueIndex" json:"handle,omitempty"` // Public name, e.g. @news
+	IsPublic        bool    `gorm:"default:false" json:"is_public"`              // Listed in search, anyone can subscribe
+	SubscriberCount int     `gorm:"default:0" json:"subscriber_count"`
+
 	// Embedded settings
 	Settings ChatRoomSettings `gorm:"embedded;embeddedPrefix:settings_" json:"settings"`
 
@@ -72,6 +120,23 @@ type ChatRoomSettings struct {
 	OnlyAdminsCanPost   bool `gorm:"default:false" json:"only_admins_can_post"`
 	OnlyAdminsCanInvite bool `gorm:"default:false" json:"only_admins_can_invite"`
 	MessageEncryption   bool `gorm:"default:false" json:"message_encryption"`
+
+	// Disappearing messages, timer in seconds (0 = off)
+	DisappearingTimer int              `gorm:"default:0" json:"disappearing_timer"`
+	DisappearingMode  DisappearingMode `gorm:"size:20;default:after_send" json:"disappearing_mode"`
+
+	// Let every member pin messages, not only admins
+	AllowMembersToPin bool `gorm:"default:false" json:"allow_members_to_pin"`
+
+	// Slow mode, minimum seconds between two messages of a member (0 = off)
+	SlowModeSeconds int `gorm:"default:0" json:"slow_mode_seconds"`
+
+	// Let members export the messages sent before they joined
+	ShareHistoryWithNewMembers bool `gorm:"default:false" json:"share_history_with_new_members"`
+
+	// Emoji members can react with, empty allows any emoji. Custom emoji of
+	// the room are listed as :shortcode:
+	AllowedReactions []string `gorm:"serializer:json;type:text" json:"allowed_reactions"`
 }
 
 type Participant struct {
@@ -82,9 +147,11 @@ type Participant struct {
 	JoinedAt    time.Time       `json:"joined_at"`
 	LastReadAt  time.Time       `json:"last_read_at"`
 	IsMuted     bool            `gorm:"default:false" json:"is_muted"`
+	MutedUntil  *time.Time      `json:"muted_until,omitempty"` // nil with IsMuted = until unmuted
 	IsBlocked   bool            `gorm:"default:false" json:"is_blocked"`
 	Nickname    string          `gorm:"size:100" json:"nickname"`
 	Permissions string          `gorm:"type:text" json:"permissions"` // JSON array as string
+	IsBot       bool            `gorm:"-" json:"is_bot"`
 
 	// Relationships
 	ChatRoom ChatRoom `gorm:"foreignKey:ChatRoomID" json:"chat_room"`
@@ -106,22 +173,34 @@ type Message struct {
 	EncryptedContent string      `gorm:"type:text" json:"encrypted_content,omitempty"`
 	ReplyToID        *uint       `gorm:"index" json:"reply_to_id,omitempty"`
 	ForwardedFromID  *uint       `gorm:"index" json:"forwarded_from_id,omitempty"`
+	IsForwarded      bool        `gorm:"default:false" json:"is_forwarded"`
+	OriginalSenderID *uint       `json:"original_sender_id,omitempty"` // Set on forwarded copies, nil if the sender opted out
+	LinkPreviewID    *uint       `gorm:"index" json:"link_preview_id,omitempty"`
 	EditedAt         *time.Time  `json:"edited_at,omitempty"`
 	DeliveryStatus   string      `gorm:"type:text" json:"delivery_status"` // JSON as string
 	Mentions         string      `gorm:"type:text" json:"mentions"`        // JSON array as string
 	Tags             string      `gorm:"type:text" json:"tags"`            // JSON array as string
+	ExpiresIn        int         `json:"expires_in,omitempty"`             // Seconds, countdown starts on first read
+	ExpiresAt        *time.Time  `gorm:"index" json:"expires_at,omitempty"`
+	ViewCount        int         `gorm:"default:0" json:"view_count"` // Channels only, unique viewers
 
 	// Embedded media and location
 	Media    *MessageMedia    `gorm:"embedded;embeddedPrefix:media_" json:"media,omitempty"`
 	Location *MessageLocation `gorm:"embedded;embeddedPrefix:location_" json:"location,omitempty"`
 
 	// Relationships
-	ChatRoom      *ChatRoom         `gorm:"foreignKey:ChatRoomID" json:"chat_room"`
-	Sender        *User             `gorm:"foreignKey:SenderID" json:"sender"`
-	ReplyTo       *Message          `gorm:"foreignKey:ReplyToID" json:"reply_to,omitempty"`
-	ForwardedFrom *Message          `gorm:"foreignKey:ForwardedFromID" json:"forwarded_from,omitempty"`
-	ReadBy        []MessageRead     `gorm:"foreignKey:MessageID" json:"read_by"`
-	Reactions     []MessageReaction `gorm:"foreignKey:MessageID" json:"reactions"`
+	ChatRoom       *ChatRoom         `gorm:"foreignKey:ChatRoomID" json:"chat_room"`
+	Sender         *User             `gorm:"foreignKey:SenderID" json:"sender"`
+	ReplyTo        *Message          `gorm:"foreignKey:ReplyToID" json:"reply_to,omitempty"`
+	ForwardedFrom  *Message          `gorm:"foreignKey:ForwardedFromID" json:"forwarded_from,omitempty"`
+	OriginalSender *User             `gorm:"foreignKey:OriginalSenderID" json:"original_sender,omitempty"`
+	LinkPreview    *LinkPreview      `gorm:"foreignKey:LinkPreviewID" json:"link_preview,omitempty"`
+	ReadBy         []MessageRead     `gorm:"foreignKey:MessageID" json:"read_by"`
+	Reactions      []MessageReaction `gorm:"foreignKey:MessageID" json:"reactions"`
+	Poll           *Poll             `gorm:"foreignKey:MessageID" json:"poll,omitempty"`
+
+	// Reactions grouped by emoji, computed for the user reading the message
+	ReactionSummary []ReactionCount `gorm:"-" json:"reaction_summary"`
 
 	// Timestamps
 	CreatedAt time.Time      `gorm:"index" json:"created_at"`
@@ -139,6 +218,7 @@ type MessageMedia struct {
 	Duration  int    `json:"duration,omitempty"`
 	Width     int    `json:"width,omitempty"`
 	Height    int    `json:"height,omitempty"`
+	Waveform  string `gorm:"type:text" json:"waveform,omitempty"` // JSON array as string, 0-100 per bar
 }
 
 type MessageLocation struct {
@@ -146,6 +226,10 @@ type MessageLocation struct {
 	Longitude float64 `json:"longitude"`
 	Address   string  `gorm:"size:500" json:"address,omitempty"`
 	PlaceName string  `gorm:"size:255" json:"place_name,omitempty"`
+	Accuracy  float64 `json:"accuracy,omitempty"` // In meters
+
+	// Set on live locations, when sharing ends or ended
+	LiveUntil *time.Time `json:"live_until,omitempty"`
 }
 
 type MessageRead struct {
@@ -164,9 +248,9 @@ type MessageRead struct {
 
 type MessageReaction struct {
 	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	MessageID uint      `gorm:"not null;index" json:"message_id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	Emoji     string    `gorm:"size:50;not null" json:"emoji"`
+	MessageID uint      `gorm:"not null;index;uniqueIndex:idx_message_reaction_user_emoji" json:"message_id"`
+	UserID    uint      `gorm:"not null;index;uniqueIndex:idx_message_reaction_user_emoji" json:"user_id"`
+	Emoji     string    `gorm:"size:50;not null;uniqueIndex:idx_message_reaction_user_emoji" json:"emoji"`
 	ReactedAt time.Time `json:"reacted_at"`
 
 	// Relationships
@@ -229,6 +313,76 @@ type ChatInvite struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+type ChatInviteLink struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID       uint       `gorm:"not null;index" json:"chat_room_id"`
+	CreatorID        uint       `gorm:"not null;index" json:"creator_id"`
+	Token            string     `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	Name             string     `gorm:"size:100" json:"name,omitempty"`
+	ExpiresAt        *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	MaxUses          int        `gorm:"default:0" json:"max_uses"` // 0 = unlimited
+	UseCount         int        `gorm:"default:0" json:"use_count"`
+	RequiresApproval bool       `gorm:"default:false" json:"requires_approval"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+
+	// Relationships
+	ChatRoom *ChatRoom `gorm:"foreignKey:ChatRoomID" json:"chat_room,omitempty"`
+	Creator  *User     `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ChatJoinRequest struct {
+	ID           uint                  `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID   uint                  `gorm:"not null;index" json:"chat_room_id"`
+	UserID       uint                  `gorm:"not null;index" json:"user_id"`
+	InviteLinkID uint                  `gorm:"not null;index" json:"invite_link_id"`
+	Status       ChatJoinRequestStatus `gorm:"size:20;default:pending;index" json:"status"`
+	ReviewedBy   *uint                 `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time            `json:"reviewed_at,omitempty"`
+
+	// Relationships
+	ChatRoom *ChatRoom `gorm:"foreignKey:ChatRoomID" json:"chat_room,omitempty"`
+	User     *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ChatRoomBan struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID uint   `gorm:"not null;uniqueIndex:idx_chat_room_ban_room_user" json:"chat_room_id"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_chat_room_ban_room_user" json:"user_id"`
+	BannedBy   uint   `gorm:"not null" json:"banned_by"`
+	Reason     string `gorm:"type:text" json:"reason,omitempty"`
+
+	// Relationships
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChatModerationLog struct {
+	ID           uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID   uint                 `gorm:"not null;index" json:"chat_room_id"`
+	ActorID      uint                 `gorm:"not null;index" json:"actor_id"`
+	TargetUserID *uint                `gorm:"index" json:"target_user_id,omitempty"`
+	Action       ChatModerationAction `gorm:"size:20;not null" json:"action"`
+	Reason       string               `gorm:"type:text" json:"reason,omitempty"`
+	Duration     int                  `gorm:"default:0" json:"duration,omitempty"` // Mute length or slow mode interval in seconds
+
+	// Relationships
+	Actor      *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	TargetUser *User `gorm:"foreignKey:TargetUserID" json:"target_user,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type ChatNotification struct {
 	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID     uint       `gorm:"not null;index" json:"user_id"`
@@ -252,6 +406,45 @@ type ChatNotification struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// ScheduledMessage is a message waiting to be delivered at SendAt.
+// It is only visible to its sender until the scheduler creates the real message.
+type ScheduledMessage struct {
+	ID         uint                   `gorm:"primaryKey;autoIncrement" json:"id"`
+	LocalID    uint                   `gorm:"index" json:"local_id"`
+	ChatRoomID uint                   `gorm:"not null;index" json:"chat_room_id"`
+	SenderID   uint                   `gorm:"not null;index" json:"sender_id"`
+	Content    string                 `gorm:"type:text" json:"content"`
+	SendAt     time.Time              `gorm:"not null;index" json:"send_at"`
+	Status     ScheduledMessageStatus `gorm:"size:20;default:pending;index" json:"status"`
+	MessageID  *uint                  `gorm:"index" json:"message_id,omitempty"` // Delivered message
+	SentAt     *time.Time             `json:"sent_at,omitempty"`
+	Error      string                 `gorm:"type:text" json:"error,omitempty"`
+	IsReminder bool                   `gorm:"default:false" json:"is_reminder"` // Only pushed back to the sender, see /remind
+
+	// Relationships
+	ChatRoom *ChatRoom `gorm:"foreignKey:ChatRoomID" json:"chat_room,omitempty"`
+	Sender   *User     `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PinnedMessage struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID uint      `gorm:"not null;index" json:"chat_room_id"`
+	MessageID  uint      `gorm:"not null;uniqueIndex" json:"message_id"`
+	PinnedBy   uint      `gorm:"not null;index" json:"pinned_by"`
+	PinnedAt   time.Time `json:"pinned_at"`
+
+	// Relationships
+	Message *Message `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+	Pinner  *User    `gorm:"foreignKey:PinnedBy" json:"pinner,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // Table names
 func (ChatRoom) TableName() string {
 	return "chat_rooms"
@@ -285,6 +478,30 @@ func (ChatInvite) TableName() string {
 	return "chat_invites"
 }
 
+func (ChatInviteLink) TableName() string {
+	return "chat_invite_links"
+}
+
+func (ChatJoinRequest) TableName() string {
+	return "chat_join_requests"
+}
+
+func (ChatRoomBan) TableName() string {
+	return "chat_room_bans"
+}
+
+func (ChatModerationLog) TableName() string {
+	return "chat_moderation_logs"
+}
+
 func (ChatNotification) TableName() string {
 	return "chat_notifications"
 }
+
+func (ScheduledMessage) TableName() string {
+	return "scheduled_messages"
+}
+
+func (PinnedMessage) TableName() string {
+	return "pinned_messages"
+}
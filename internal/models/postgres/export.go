@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"social_server/internal/models/constants"
+	"time"
+)
+
+type ChatExportFormat = constants.ChatExportFormat
+type ChatExportStatus = constants.ChatExportStatus
+
+const (
+	ChatExportFormatJSON = constants.ChatExportFormatJSON
+	ChatExportFormatHTML = constants.ChatExportFormatHTML
+	ChatExportFormatText = constants.ChatExportFormatText
+)
+
+const (
+	ChatExportStatusPending    = constants.ChatExportStatusPending
+	ChatExportStatusProcessing = constants.ChatExportStatusProcessing
+	ChatExportStatusCompleted  = constants.ChatExportStatusCompleted
+	ChatExportStatusFailed     = constants.ChatExportStatusFailed
+	ChatExportStatusExpired    = constants.ChatExportStatusExpired
+)
+
+// ChatExport is a request to archive the history of a room. A background job
+// writes the file, the requester downloads it until ExpiresAt.
+type ChatExport struct {
+	ID                 uint             `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID         uint             `gorm:"not null;index" json:"chat_room_id"`
+	RequestedBy        uint             `gorm:"not null;index" json:"requested_by"`
+	Format             ChatExportFormat `gorm:"size:10;not null" json:"format"`
+	IncludeAttachments bool             `gorm:"default:false" json:"include_attachments"`
+	Status             ChatExportStatus `gorm:"size:20;not null;default:pending;index" json:"status"`
+
+	// Messages sent from Since to Until are exported, Since is nil for the whole history
+	Since *time.Time `json:"since,omitempty"`
+	Until time.Time  `json:"until"`
+
+	// Result
+	FilePath        string     `gorm:"size:500" json:"-"`
+	FileName        string     `gorm:"size:255" json:"file_name,omitempty"`
+	FileSize        int64      `json:"file_size,omitempty"`
+	MessageCount    int        `json:"message_count"`
+	AttachmentCount int        `json:"attachment_count"`
+	SkippedCount    int        `json:"skipped_count"` // End-to-end encrypted messages
+	Error           string     `gorm:"type:text" json:"error,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt       *time.Time `gorm:"index" json:"expires_at,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ChatExport) TableName() string {
+	return "chat_exports"
+}
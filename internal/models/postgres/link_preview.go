@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"time"
+)
+
+// LinkPreview caches the unfurled metadata of a URL, shared by posts and messages
+type LinkPreview struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	URL          string    `gorm:"size:2048;not null;uniqueIndex" json:"url"`
+	CanonicalURL string    `gorm:"size:2048" json:"canonical_url,omitempty"`
+	Title        string    `gorm:"size:300" json:"title,omitempty"`
+	Description  string    `gorm:"type:text" json:"description,omitempty"`
+	SiteName     string    `gorm:"size:300" json:"site_name,omitempty"`
+	ImageURL     string    `gorm:"size:2048" json:"image_url,omitempty"`
+	Type         string    `gorm:"size:50" json:"type,omitempty"`
+	Failed       bool      `gorm:"default:false" json:"-"` // The fetch failed, cached so it is not retried right away
+	FetchedAt    time.Time `gorm:"index" json:"fetched_at"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (LinkPreview) TableName() string {
+	return "link_previews"
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"time"
+)
+
+// Poll is attached to either a chat message or a post
+type Poll struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatorID      uint       `gorm:"not null;index" json:"creator_id"`
+	ChatRoomID     *uint      `gorm:"index" json:"chat_room_id,omitempty"`
+	MessageID      *uint      `gorm:"uniqueIndex" json:"message_id,omitempty"`
+	PostID         *uint      `gorm:"uniqueIndex" json:"post_id,omitempty"`
+	Question       string     `gorm:"size:300;not null" json:"question"`
+	MultipleChoice bool       `gorm:"default:false" json:"multiple_choice"`
+	Anonymous      bool       `gorm:"default:false" json:"anonymous"`
+	ClosesAt       *time.Time `gorm:"index" json:"closes_at,omitempty"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+
+	// Results, filled in by the service
+	TotalVoters int    `gorm:"-" json:"total_voters"`
+	MyVotes     []uint `gorm:"-" json:"my_votes,omitempty"` // Option IDs voted by the current user
+	IsClosed    bool   `gorm:"-" json:"is_closed"`
+
+	// Relationships
+	Creator *User        `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+	Options []PollOption `gorm:"foreignKey:PollID" json:"options"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PollOption struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	PollID   uint   `gorm:"not null;index" json:"poll_id"`
+	Text     string `gorm:"size:100;not null" json:"text"`
+	Position int    `gorm:"default:0" json:"position"`
+
+	// Results, filled in by the service
+	VoteCount int    `gorm:"-" json:"vote_count"`
+	VoterIDs  []uint `gorm:"-" json:"voter_ids,omitempty"` // Empty for anonymous polls
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PollVote struct {
+	ID       uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	PollID   uint `gorm:"not null;index" json:"poll_id"`
+	OptionID uint `gorm:"not null;uniqueIndex:idx_poll_vote_option_user" json:"option_id"`
+	UserID   uint `gorm:"not null;uniqueIndex:idx_poll_vote_option_user;index" json:"user_id"`
+
+	// Relationships
+	Option *PollOption `gorm:"foreignKey:OptionID" json:"option,omitempty"`
+	User   *User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Closed reports whether the poll no longer accepts votes
+func (p *Poll) Closed(now time.Time) bool {
+	return p.ClosedAt != nil || (p.ClosesAt != nil && !p.ClosesAt.After(now))
+}
+
+// Table names
+func (Poll) TableName() string {
+	return "polls"
+}
+
+func (PollOption) TableName() string {
+	return "poll_options"
+}
+
+func (PollVote) TableName() string {
+	return "poll_votes"
+}
@@ -16,6 +16,7 @@ const (
 	PostTypeVideo = constants.PostTypeVideo
 	PostTypeLink  = constants.PostTypeLink
 	PostTypeAudio = constants.PostTypeAudio
+	PostTypePoll  = constants.PostTypePoll
 )
 
 const (
@@ -40,14 +41,17 @@ type Post struct {
 	CommentCount   int         `gorm:"default:0" json:"comment_count"`
 	ShareCount     int         `gorm:"default:0" json:"share_count"`
 	ViewCount      int         `gorm:"default:0" json:"view_count"`
+	LinkPreviewID  *uint       `gorm:"index" json:"link_preview_id,omitempty"`
 
 	// Relationships
-	Author   User        `gorm:"foreignKey:AuthorID" json:"author"`
-	Media    []PostMedia `gorm:"foreignKey:PostID" json:"media"`
-	Likes    []Like      `gorm:"foreignKey:PostID" json:"likes"`
-	Comments []Comment   `gorm:"foreignKey:PostID" json:"comments"`
-	Shares   []Share     `gorm:"foreignKey:PostID" json:"shares"`
-	Views    []PostView  `gorm:"foreignKey:PostID" json:"views"`
+	Author      User         `gorm:"foreignKey:AuthorID" json:"author"`
+	Media       []PostMedia  `gorm:"foreignKey:PostID" json:"media"`
+	Likes       []Like       `gorm:"foreignKey:PostID" json:"likes"`
+	Comments    []Comment    `gorm:"foreignKey:PostID" json:"comments"`
+	Shares      []Share      `gorm:"foreignKey:PostID" json:"shares"`
+	Views       []PostView   `gorm:"foreignKey:PostID" json:"views"`
+	Poll        *Poll        `gorm:"foreignKey:PostID" json:"poll,omitempty"`
+	LinkPreview *LinkPreview `gorm:"foreignKey:LinkPreviewID" json:"link_preview,omitempty"`
 
 	// Timestamps
 	CreatedAt time.Time      `gorm:"index" json:"created_at"`
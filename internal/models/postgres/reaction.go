@@ -0,0 +1,31 @@
+package postgres
+
+import "time"
+
+// ChatRoomEmoji is a custom emoji uploaded by a room admin, used in reactions
+// as :shortcode:
+type ChatRoomEmoji struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID uint   `gorm:"not null;uniqueIndex:idx_chat_room_emoji_shortcode" json:"chat_room_id"`
+	Shortcode  string `gorm:"size:32;not null;uniqueIndex:idx_chat_room_emoji_shortcode" json:"shortcode"` // Without the colons
+	URL        string `gorm:"size:500;not null" json:"url"`
+	CreatedBy  uint   `gorm:"not null" json:"created_by"`
+
+	// Relationships
+	Creator *User `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReactionCount is the number of reactions with one emoji on a message, not stored
+type ReactionCount struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+	URL         string `json:"url,omitempty"` // Image of custom emoji
+}
+
+func (ChatRoomEmoji) TableName() string {
+	return "chat_room_emojis"
+}
@@ -35,6 +35,8 @@ type User struct {
 	IsBanned     bool       `gorm:"default:false" json:"is_banned"`
 	BannedUntil  *time.Time `json:"banned_until,omitempty"`
 	BanReason    string     `gorm:"type:text" json:"ban_reason,omitempty"`
+	IsBot        bool       `gorm:"default:false;index" json:"is_bot"`
+	BotOwnerID   *uint      `gorm:"index" json:"bot_owner_id,omitempty"` // The user managing the bot
 
 	// Relationships
 	UserFriends            []UserFriend    `gorm:"foreignKey:UserID" json:"user_friends"`
@@ -58,6 +60,7 @@ type UserSettings struct {
 	PrivacyProfileVisibility   string `gorm:"size:20;default:public" json:"privacy_profile_visibility"`
 	PrivacyShowOnlineStatus    bool   `gorm:"default:true" json:"privacy_show_online_status"`
 	PrivacyAllowFriendRequests bool   `gorm:"default:true" json:"privacy_allow_friend_requests"`
+	PrivacyForwardAttribution  *bool  `gorm:"default:true" json:"privacy_forward_attribution,omitempty"` // Link forwarded copies back to the user, unchanged when omitted
 
 	// Notification settings
 	NotificationsEmail          bool `gorm:"default:true" json:"notifications_email"`
@@ -67,6 +70,12 @@ type UserSettings struct {
 	NotificationsPosts          bool `gorm:"default:true" json:"notifications_posts"`
 }
 
+// ForwardAttribution tells whether forwarded copies link back to the user,
+// they do unless the user opted out
+func (s UserSettings) ForwardAttribution() bool {
+	return s.PrivacyForwardAttribution == nil || *s.PrivacyForwardAttribution
+}
+
 // Table names
 func (User) TableName() string {
 	return "users"
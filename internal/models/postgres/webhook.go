@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"social_server/internal/models/constants"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ChatWebhookType = constants.ChatWebhookType
+type WebhookDeliveryStatus = constants.WebhookDeliveryStatus
+
+const (
+	ChatWebhookTypeIncoming = constants.ChatWebhookTypeIncoming
+	ChatWebhookTypeOutgoing = constants.ChatWebhookTypeOutgoing
+)
+
+const (
+	WebhookDeliveryStatusPending   = constants.WebhookDeliveryStatusPending
+	WebhookDeliveryStatusSucceeded = constants.WebhookDeliveryStatusSucceeded
+	WebhookDeliveryStatusFailed    = constants.WebhookDeliveryStatusFailed
+)
+
+// ChatWebhook connects a bot to a room. Incoming webhooks post messages as the
+// bot through their token, outgoing webhooks send the messages starting with
+// their trigger to an external URL.
+type ChatWebhook struct {
+	ID         uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatRoomID uint            `gorm:"not null;index" json:"chat_room_id"`
+	BotID      uint            `gorm:"not null;index" json:"bot_id"`
+	CreatedBy  uint            `gorm:"not null" json:"created_by"`
+	Type       ChatWebhookType `gorm:"size:20;not null" json:"type"`
+	Name       string          `gorm:"size:100" json:"name,omitempty"`
+	IsActive   bool            `gorm:"default:true" json:"is_active"`
+
+	// Incoming webhooks
+	Token string `gorm:"size:64;uniqueIndex:idx_chat_webhook_token,where:token <> ''" json:"token,omitempty"`
+
+	// Outgoing webhooks, Trigger is a prefix such as "!deploy" or a slash command such as "/deploy"
+	URL     string `gorm:"size:2048" json:"url,omitempty"`
+	Secret  string `gorm:"size:64" json:"secret,omitempty"` // HMAC-SHA256 key of the deliveries
+	Trigger string `gorm:"size:100" json:"trigger,omitempty"`
+
+	// Relationships
+	Bot *User `gorm:"foreignKey:BotID" json:"bot,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// WebhookDelivery is one message sent to an outgoing webhook, with its attempts
+type WebhookDelivery struct {
+	ID             uint                  `gorm:"primaryKey;autoIncrement" json:"id"`
+	WebhookID      uint                  `gorm:"not null;index" json:"webhook_id"`
+	MessageID      uint                  `gorm:"not null;index" json:"message_id"`
+	Status         WebhookDeliveryStatus `gorm:"size:20;not null;default:pending;index" json:"status"`
+	Payload        string                `gorm:"type:text;not null" json:"payload"`
+	Attempts       int                   `gorm:"default:0" json:"attempts"`
+	ResponseStatus int                   `json:"response_status,omitempty"`
+	Error          string                `gorm:"type:text" json:"error,omitempty"`
+	NextAttemptAt  *time.Time            `gorm:"index" json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ChatWebhook) TableName() string {
+	return "chat_webhooks"
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
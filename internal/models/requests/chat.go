@@ -1,13 +1,19 @@
 package requests
 
-import "social_server/internal/models/constants"
+import (
+	"social_server/internal/models/constants"
+	"time"
+)
 
 type ChatRoomType = constants.ChatRoomType
 type MessageType = constants.MessageType
+type DisappearingMode = constants.DisappearingMode
+type ChatExportFormat = constants.ChatExportFormat
 
 const (
 	ChatRoomTypePrivate = constants.ChatRoomTypePrivate
 	ChatRoomTypeGroup   = constants.ChatRoomTypeGroup
+	ChatRoomTypeChannel = constants.ChatRoomTypeChannel
 )
 
 const (
@@ -39,6 +45,14 @@ type DeleteChatRoomRequest struct {
 	ID uint `uri:"id" binding:"required"`
 }
 
+type ChatRoomUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type MessageUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
 type UpdateChatRoomRequest struct {
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
@@ -111,8 +125,201 @@ type ChatRoomSettingsRequest struct {
 	OnlyAdminsCanPost   *bool `json:"only_admins_can_post,omitempty"`
 	OnlyAdminsCanInvite *bool `json:"only_admins_can_invite,omitempty"`
 	MessageEncryption   *bool `json:"message_encryption,omitempty"`
+	AllowMembersToPin   *bool `json:"allow_members_to_pin,omitempty"`
+
+	ShareHistoryWithNewMembers *bool `json:"share_history_with_new_members,omitempty"`
+
+	AllowedReactions *[]string `json:"allowed_reactions,omitempty"` // Empty list allows any emoji
 }
 
 type SyncChatRoomsRequest struct {
 	LastID *uint `form:"last_id"`
 }
+
+type SetDisappearingTimerRequest struct {
+	Timer int              `json:"timer" binding:"min=0"` // Seconds, 0 turns the timer off
+	Mode  DisappearingMode `json:"mode,omitempty" binding:"omitempty,oneof=after_send after_read"`
+}
+
+type ScheduledMessageUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type UpdateScheduledMessageRequest struct {
+	Content *string    `json:"content,omitempty"`
+	SendAt  *time.Time `json:"send_at,omitempty"`
+}
+
+type SendVoiceNoteRequest struct {
+	LocalID uint `form:"local_id"`
+}
+
+type InviteUserRequest struct {
+	UserID  uint   `json:"user_id" binding:"required"`
+	Message string `json:"message,omitempty" binding:"max=500"`
+}
+
+type GetChatInvitesRequest struct {
+	Status string `form:"status,omitempty"`
+	Limit  int    `form:"limit,omitempty"`
+	Before string `form:"before,omitempty"`
+	After  string `form:"after,omitempty"`
+}
+
+type CreateInviteLinkRequest struct {
+	Name             string     `json:"name,omitempty" binding:"max=100"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	MaxUses          int        `json:"max_uses,omitempty" binding:"min=0"`
+	RequiresApproval bool       `json:"requires_approval"`
+}
+
+type GetJoinRequestsRequest struct {
+	Status constants.ChatJoinRequestStatus `form:"status,omitempty"`
+}
+
+type ChatInviteUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type ChatInviteLinkUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type InviteLinkTokenUriRequest struct {
+	Token string `uri:"token" binding:"required"`
+}
+
+type ChatJoinRequestUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type ForwardMessagesRequest struct {
+	MessageIDs []uint `json:"message_ids" binding:"required,min=1,dive,required"`
+	RoomIDs    []uint `json:"room_ids" binding:"required,min=1,dive,required"`
+}
+
+type ChatRoomMemberUriRequest struct {
+	ID     uint `uri:"id" binding:"required"`
+	UserID uint `uri:"user_id" binding:"required"`
+}
+
+type ModerateMemberRequest struct {
+	Reason string `json:"reason,omitempty" binding:"max=500"`
+}
+
+type MuteMemberRequest struct {
+	Duration int    `json:"duration" binding:"min=0"` // Seconds, 0 mutes until unmuted
+	Reason   string `json:"reason,omitempty" binding:"max=500"`
+}
+
+type SetSlowModeRequest struct {
+	Seconds int `json:"seconds" binding:"min=0"` // 0 turns slow mode off
+}
+
+type GetModerationLogRequest struct {
+	Limit  int    `form:"limit,omitempty"`
+	Before string `form:"before,omitempty"`
+	After  string `form:"after,omitempty"`
+}
+
+type CreateBotRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description,omitempty" binding:"max=500"`
+	Avatar      string `json:"avatar,omitempty" binding:"max=500"`
+}
+
+type BotUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type CreateChatWebhookRequest struct {
+	Type    constants.ChatWebhookType `json:"type" binding:"required,oneof=incoming outgoing"`
+	BotID   uint                      `json:"bot_id" binding:"required"`
+	Name    string                    `json:"name,omitempty" binding:"max=100"`
+	URL     string                    `json:"url,omitempty" binding:"max=2048"`    // Outgoing webhooks only
+	Trigger string                    `json:"trigger,omitempty" binding:"max=100"` // Outgoing webhooks only
+}
+
+type ChatWebhookUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type WebhookTokenUriRequest struct {
+	Token string `uri:"token" binding:"required"`
+}
+
+type IncomingWebhookRequest struct {
+	Content string `json:"content" binding:"required,max=4000"`
+}
+
+type GetWebhookDeliveriesRequest struct {
+	Limit  int    `form:"limit,omitempty"`
+	Before string `form:"before,omitempty"`
+	After  string `form:"after,omitempty"`
+}
+
+type GetSlashCommandsRequest struct {
+	RoomID uint `form:"room_id,omitempty"`
+}
+
+type CreateChatExportRequest struct {
+	Format             ChatExportFormat `json:"format" binding:"required,oneof=json html text"`
+	IncludeAttachments bool             `json:"include_attachments"` // Bundles the export and the attachments into a ZIP
+}
+
+type ChatExportUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type CreateChannelRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+	Handle      string `json:"handle,omitempty"` // Required for public channels
+	IsPublic    bool   `json:"is_public"`
+}
+
+type ChannelHandleUriRequest struct {
+	Handle string `uri:"handle" binding:"required"`
+}
+
+type ChannelMemberUriRequest struct {
+	ID     uint `uri:"id" binding:"required"`
+	UserID uint `uri:"user_id" binding:"required"`
+}
+
+type SearchChannelsRequest struct {
+	Query string `form:"q,omitempty"`
+	Limit int    `form:"limit,omitempty"`
+}
+
+type GetChannelMessagesRequest struct {
+	Limit  int    `form:"limit,omitempty"`
+	Before string `form:"before,omitempty"`
+	After  string `form:"after,omitempty"`
+}
+
+type RecordChannelViewsRequest struct {
+	MessageIDs []uint `json:"message_ids" binding:"required,min=1,max=100"`
+}
+
+type MessageReactionUriRequest struct {
+	ID    uint   `uri:"id" binding:"required"`
+	Emoji string `uri:"emoji" binding:"required"`
+}
+
+type GetReactionUsersRequest struct {
+	Emoji  string `form:"emoji" binding:"required"`
+	Limit  int    `form:"limit,omitempty"`
+	Before string `form:"before,omitempty"`
+	After  string `form:"after,omitempty"`
+}
+
+type CreateChatRoomEmojiRequest struct {
+	Shortcode string `form:"shortcode" binding:"required"`
+}
+
+type ChatRoomEmojiUriRequest struct {
+	ID      uint `uri:"id" binding:"required"`
+	EmojiID uint `uri:"emoji_id" binding:"required"`
+}
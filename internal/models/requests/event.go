@@ -0,0 +1,7 @@
+package requests
+
+// AckEventsRequest acks the events of a session streamed over SSE
+type AckEventsRequest struct {
+	ResumeToken string `json:"resume_token" binding:"required"`
+	Seq         uint64 `json:"seq" binding:"required"`
+}
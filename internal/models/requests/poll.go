@@ -0,0 +1,20 @@
+package requests
+
+import "time"
+
+type CreatePollRequest struct {
+	LocalID        uint       `json:"local_id"`
+	Question       string     `json:"question" binding:"required,max=300"`
+	Options        []string   `json:"options" binding:"required,min=2,max=12,dive,required,max=100"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	Anonymous      bool       `json:"anonymous"`
+	ClosesAt       *time.Time `json:"closes_at,omitempty"`
+}
+
+type VotePollRequest struct {
+	OptionIDs []uint `json:"option_ids" binding:"required,min=1"`
+}
+
+type PollUriRequest struct {
+	ID uint `uri:"id" binding:"required"`
+}
@@ -1,6 +1,9 @@
 package requests
 
-import "social_server/internal/models/constants"
+import (
+	"social_server/internal/models/constants"
+	"time"
+)
 
 type PostType = constants.PostType
 type PostPrivacy = constants.PostPrivacy
@@ -11,6 +14,7 @@ const (
 	PostTypeVideo = constants.PostTypeVideo
 	PostTypeLink  = constants.PostTypeLink
 	PostTypeAudio = constants.PostTypeAudio
+	PostTypePoll  = constants.PostTypePoll
 )
 
 const (
@@ -34,6 +38,13 @@ type CreatePostRequest struct {
 	Location string             `form:"location,omitempty"`
 	Tags     []string           `form:"tags,omitempty"`
 	Media    []PostMediaRequest `form:"media,omitempty"`
+
+	// Poll posts
+	PollQuestion       string     `form:"poll_question,omitempty"`
+	PollOptions        []string   `form:"poll_options,omitempty"`
+	PollMultipleChoice bool       `form:"poll_multiple_choice,omitempty"`
+	PollAnonymous      bool       `form:"poll_anonymous,omitempty"`
+	PollClosesAt       *time.Time `form:"poll_closes_at,omitempty" time_format:"2006-01-02T15:04:05Z07:00"`
 }
 
 type UpdatePostRequest struct {
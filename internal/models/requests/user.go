@@ -20,6 +20,7 @@ type UpdateUserSettingsRequest struct {
 	PrivacyProfileVisibility    *string `json:"privacy_profile_visibility,omitempty"`
 	PrivacyShowOnlineStatus     *bool   `json:"privacy_show_online_status,omitempty"`
 	PrivacyAllowFriendRequests  *bool   `json:"privacy_allow_friend_requests,omitempty"`
+	PrivacyForwardAttribution   *bool   `json:"privacy_forward_attribution,omitempty"`
 	NotificationsEmail          *bool   `json:"notifications_email,omitempty"`
 	NotificationsPush           *bool   `json:"notifications_push,omitempty"`
 	NotificationsFriendRequests *bool   `json:"notifications_friend_requests,omitempty"`
@@ -1,6 +1,7 @@
 package responses
 
 import (
+	"social_server/internal/models/constants"
 	"social_server/internal/models/postgres"
 	"time"
 
@@ -19,18 +20,19 @@ import (
 // }
 
 type ChatRoomSummary struct {
-	ID               uint                  `json:"id"`
-	LocalID          uint                  `json:"local_id"`
-	Name             string                `json:"name"`
-	Type             postgres.ChatRoomType `json:"type"`
-	Avatar           string                `json:"avatar"`
-	ParticipantCount int                   `json:"participant_count"`
-	LastMessage      *postgres.Message     `json:"last_message"`
-	LastActivity     *time.Time            `json:"last_activity"`
-	UnreadCount      int                   `json:"unread_count"`
-	IsMuted          bool                  `json:"is_muted"`
-	CreatedAt        time.Time             `json:"created_at"`
-	UpdatedAt        time.Time             `json:"updated_at"`
+	ID               uint                     `json:"id"`
+	LocalID          uint                     `json:"local_id"`
+	Name             string                   `json:"name"`
+	Type             postgres.ChatRoomType    `json:"type"`
+	Avatar           string                   `json:"avatar"`
+	ParticipantCount int                      `json:"participant_count"`
+	LastMessage      *postgres.Message        `json:"last_message"`
+	LastActivity     *time.Time               `json:"last_activity"`
+	UnreadCount      int                      `json:"unread_count"`
+	IsMuted          bool                     `json:"is_muted"`
+	PinnedMessages   []postgres.PinnedMessage `json:"pinned_messages"`
+	CreatedAt        time.Time                `json:"created_at"`
+	UpdatedAt        time.Time                `json:"updated_at"`
 }
 type ChatRoomsResponse struct {
 	Conversations []ChatRoomSummary `json:"rooms"`
@@ -58,6 +60,27 @@ type MessageResponse struct {
 	Total int `json:"total"`
 }
 
+type ChatInvitesResponse struct {
+	Invites    []postgres.ChatInvite `json:"invites"`
+	NextCursor *paginator.Cursor     `json:"next_cursor,omitempty"`
+}
+
+type ChatModerationLogResponse struct {
+	Entries    []postgres.ChatModerationLog `json:"entries"`
+	NextCursor *paginator.Cursor            `json:"next_cursor,omitempty"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []postgres.WebhookDelivery `json:"deliveries"`
+	NextCursor *paginator.Cursor          `json:"next_cursor,omitempty"`
+}
+
+type JoinByInviteLinkResponse struct {
+	Joined  bool                      `json:"joined"`
+	Room    *postgres.ChatRoom        `json:"room,omitempty"`
+	Request *postgres.ChatJoinRequest `json:"request,omitempty"` // Set when the link requires approval
+}
+
 type NotificationResponse struct {
 	Notifications []postgres.ChatNotification `json:"notifications"`
 	NextCursor    *paginator.Cursor           `json:"next_cursor,omitempty"`
@@ -139,3 +162,33 @@ type MessageDeliveryResponse struct {
 	ReadBy      []uint    `json:"read_by"`
 	DeliveredAt time.Time `json:"delivered_at"`
 }
+
+type SlashCommandResponse struct {
+	Name        string                       `json:"name"`
+	Description string                       `json:"description"`
+	Usage       string                       `json:"usage"`
+	Output      constants.SlashCommandOutput `json:"output"`
+	Permission  string                       `json:"permission,omitempty"`
+}
+
+// ChannelResponse is a channel as seen by a user, staff and subscribers are not listed
+type ChannelResponse struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	Avatar          string     `json:"avatar"`
+	Handle          *string    `json:"handle,omitempty"`
+	IsPublic        bool       `json:"is_public"`
+	SubscriberCount int        `json:"subscriber_count"`
+	LastActivity    *time.Time `json:"last_activity"`
+	CreatedAt       time.Time  `json:"created_at"`
+
+	IsSubscribed bool                     `json:"is_subscribed"`
+	Role         postgres.ParticipantRole `json:"role,omitempty"` // Set for the staff of the channel
+}
+
+// ReactionUsersResponse is a page of the users who reacted with an emoji
+type ReactionUsersResponse struct {
+	Reactions  []postgres.MessageReaction `json:"reactions"`
+	NextCursor *paginator.Cursor          `json:"next_cursor,omitempty"`
+}
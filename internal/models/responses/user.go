@@ -60,6 +60,7 @@ type UserSettingsResponse struct {
 	PrivacyProfileVisibility    string `json:"privacy_profile_visibility"`
 	PrivacyShowOnlineStatus     bool   `json:"privacy_show_online_status"`
 	PrivacyAllowFriendRequests  bool   `json:"privacy_allow_friend_requests"`
+	PrivacyForwardAttribution   bool   `json:"privacy_forward_attribution"`
 	NotificationsEmail          bool   `json:"notifications_email"`
 	NotificationsPush           bool   `json:"notifications_push"`
 	NotificationsFriendRequests bool   `json:"notifications_friend_requests"`
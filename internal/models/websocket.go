@@ -30,6 +30,66 @@ const (
 	MessageTypeChatCreateRoom     MessageType = "create_room"
 	MessageTypeChatSendMessage    MessageType = "send_message"
 	MessageTypeChatReceiveMessage MessageType = "receive_message"
+	MessageTypeMessageExpired     MessageType = "message_expired"
+
+	// Scheduled messages, only sent to the sender
+	MessageTypeMessageScheduled       MessageType = "message_scheduled"
+	MessageTypeScheduledMessageFailed MessageType = "scheduled_message_failed"
+
+	// Pinned messages
+	MessageTypeMessagePinned   MessageType = "message_pinned"
+	MessageTypeMessageUnpinned MessageType = "message_unpinned"
+
+	// Live poll tallies
+	MessageTypePollUpdated MessageType = "poll_updated"
+
+	// Invites and join requests, only sent to the users concerned
+	MessageTypeChatInvite             MessageType = "chat_invite"
+	MessageTypeChatInviteUpdated      MessageType = "chat_invite_updated"
+	MessageTypeChatJoinRequest        MessageType = "chat_join_request"
+	MessageTypeChatJoinRequestUpdated MessageType = "chat_join_request_updated"
+
+	// Moderation actions, sent to the room and the moderated user
+	MessageTypeChatModeration MessageType = "chat_moderation"
+
+	// A message changed after it was sent, e.g. its link preview resolved
+	MessageTypeMessageUpdated MessageType = "message_updated"
+
+	// Live location sharing, positions are only sent to the room participants
+	MessageTypeLiveLocationStart   MessageType = "live_location_start"
+	MessageTypeLiveLocationUpdate  MessageType = "live_location_update"
+	MessageTypeLiveLocationStop    MessageType = "live_location_stop"
+	MessageTypeLiveLocationUpdated MessageType = "live_location_updated"
+	MessageTypeLiveLocationStopped MessageType = "live_location_stopped"
+
+	// Slash command replies and reminders, only sent to the user concerned
+	MessageTypeCommandReply MessageType = "command_reply"
+	MessageTypeChatReminder MessageType = "chat_reminder"
+
+	// Reactions, sent by clients and pushed to the room with the new summary
+	MessageTypeReactionAdd    MessageType = "reaction_add"
+	MessageTypeReactionRemove MessageType = "reaction_remove"
+
+	// Channel subscriptions, sent to the subscriber's own connections
+	MessageTypeChannelSubscribed   MessageType = "channel_subscribed"
+	MessageTypeChannelUnsubscribed MessageType = "channel_unsubscribed"
+
+	// Sent to the requester when a room export is ready or failed
+	MessageTypeChatExportUpdated MessageType = "chat_export_updated"
+
+	// Sent by clients with the last sequence they handled
+	MessageTypeAck MessageType = "ack"
+
+	// Sent before the node shuts down, clients reconnect to another one
+	MessageTypeServerGoingAway MessageType = "server_going_away"
+
+	// Topic subscriptions of a connection, post:<id>, user:<id>:presence and
+	// room:<id>
+	MessageTypeSubscribe         MessageType = "subscribe"
+	MessageTypeUnsubscribe       MessageType = "unsubscribe"
+	MessageTypeSubscribed        MessageType = "subscribed"
+	MessageTypeUnsubscribed      MessageType = "unsubscribed"
+	MessageTypePostCountsUpdated MessageType = "post_counts_updated"
 )
 
 // Main WebSocket message structure
@@ -40,24 +100,56 @@ type WSMessage struct {
 	RoomID    string          `json:"room_id,omitempty"`
 	Data      json.RawMessage `json:"data,omitempty"`
 	Timestamp string          `json:"timestamp"`
+	Seq       uint64          `json:"seq,omitempty"`        // Set by the server on events of a session
+	RequestID string          `json:"request_id,omitempty"` // Set by clients, echoed on the replies
+}
+
+// Sent once a connection is open, ConnID identifies the device. Clients
+// reconnect with the resume token and the last sequence they acked to get the
+// events they missed. ResyncRequired means those are gone and the state must
+// be reloaded over HTTP.
+type ConnectedMessage struct {
+	ConnID         string `json:"conn_id"`
+	DeviceID       string `json:"device_id,omitempty"`
+	Protocol       string `json:"protocol"`
+	Encoding       string `json:"encoding"`
+	ResumeToken    string `json:"resume_token"`
+	Resumed        bool   `json:"resumed"`
+	ResyncRequired bool   `json:"resync_required,omitempty"`
+	LastSeq        uint64 `json:"last_seq"`
+}
+
+// EventBatch is the reply of a long poll: the events after the last_seq of
+// the request, LastSeq is the one to poll with next
+type EventBatch struct {
+	ResumeToken    string      `json:"resume_token"`
+	Resumed        bool        `json:"resumed"`
+	ResyncRequired bool        `json:"resync_required,omitempty"`
+	LastSeq        uint64      `json:"last_seq"`
+	Events         []WSMessage `json:"events"`
+}
+
+// Acks every event of the session up to Seq
+type AckMessage struct {
+	Seq uint64 `json:"seq"`
 }
 
 // WebRTC Offer message
 type OfferMessage struct {
-	SDP    string `json:"sdp"`
+	SDP    string `json:"sdp" binding:"required"`
 	CallID uint   `json:"call_id"`
 	Type   string `json:"type"` // video, audio
 }
 
 // WebRTC Answer message
 type AnswerMessage struct {
-	SDP    string `json:"sdp"`
+	SDP    string `json:"sdp" binding:"required"`
 	CallID uint   `json:"call_id"`
 }
 
 // ICE Candidate message
 type ICECandidateMessage struct {
-	Candidate     string `json:"candidate"`
+	Candidate     string `json:"candidate" binding:"required"`
 	SDPMLineIndex int    `json:"sdp_m_line_index"`
 	SDPMid        string `json:"sdp_mid"`
 	CallID        uint   `json:"call_id"`
@@ -65,7 +157,7 @@ type ICECandidateMessage struct {
 
 // Join room message
 type JoinRoomMessage struct {
-	RoomID   string `json:"room_id"`
+	RoomID   string `json:"room_id" binding:"required"`
 	UserID   uint   `json:"user_id"`
 	CallType string `json:"call_type"` // video, audio
 }
@@ -80,29 +172,78 @@ type LeaveRoomMessage struct {
 type CallRequestMessage struct {
 	CallID   uint   `json:"call_id,omitempty"`
 	CallerID uint   `json:"caller_id,omitempty"`
-	CalleeID uint   `json:"callee_id"`
-	CallType string `json:"call_type"` // video, audio
-	RoomID   string `json:"room_id"`
+	CalleeID uint   `json:"callee_id" binding:"required"`
+	CallType string `json:"call_type" binding:"required,oneof=video audio"`
+	RoomID   string `json:"room_id" binding:"required"`
 }
 
 // Call response message
 type CallResponseMessage struct {
-	CallID   uint   `json:"call_id"`
-	Response string `json:"response"` // accept, decline
+	CallID   uint   `json:"call_id" binding:"required"`
+	Response string `json:"response" binding:"required,oneof=accept decline"`
 	RoomID   string `json:"room_id"`
 }
 
 // Call end message
 type CallEndMessage struct {
-	CallID uint   `json:"call_id"`
+	CallID uint   `json:"call_id" binding:"required"`
 	RoomID string `json:"room_id"`
 	Reason string `json:"reason,omitempty"` // hangup, timeout, error
 }
 
-// Error message
+// Error message, the reply carries the request ID of the message that failed
 type ErrorMessage struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    WSErrorCode `json:"code"`
+	Message string      `json:"message"`
+	Details []string    `json:"details,omitempty"` // Failed validation rules
+}
+
+// Stable codes of error messages, clients may rely on them
+type WSErrorCode string
+
+const (
+	WSErrorInvalidMessage           WSErrorCode = "invalid_message"
+	WSErrorUnknownMessageType       WSErrorCode = "unknown_message_type"
+	WSErrorInvalidPayload           WSErrorCode = "invalid_payload"
+	WSErrorValidationFailed         WSErrorCode = "validation_failed"
+	WSErrorMissingRecipient         WSErrorCode = "missing_recipient"
+	WSErrorCallCreationFailed       WSErrorCode = "call_creation_failed"
+	WSErrorCallAcceptFailed         WSErrorCode = "call_accept_failed"
+	WSErrorCallDeclineFailed        WSErrorCode = "call_decline_failed"
+	WSErrorCallEndFailed            WSErrorCode = "call_end_failed"
+	WSErrorSendMessageFailed        WSErrorCode = "send_message_failed"
+	WSErrorScheduleMessageFailed    WSErrorCode = "schedule_message_failed"
+	WSErrorCreateRoomFailed         WSErrorCode = "create_room_failed"
+	WSErrorLiveLocationStartFailed  WSErrorCode = "live_location_start_failed"
+	WSErrorLiveLocationUpdateFailed WSErrorCode = "live_location_update_failed"
+	WSErrorLiveLocationStopFailed   WSErrorCode = "live_location_stop_failed"
+	WSErrorReactionFailed           WSErrorCode = "reaction_failed"
+	WSErrorRateLimited              WSErrorCode = "rate_limited"
+	WSErrorInvalidTopic             WSErrorCode = "invalid_topic"
+	WSErrorSubscriptionDenied       WSErrorCode = "subscription_denied"
+)
+
+// WSErrorCodes lists every error code, for the protocol schema
+var WSErrorCodes = []WSErrorCode{
+	WSErrorInvalidMessage,
+	WSErrorUnknownMessageType,
+	WSErrorInvalidPayload,
+	WSErrorValidationFailed,
+	WSErrorMissingRecipient,
+	WSErrorCallCreationFailed,
+	WSErrorCallAcceptFailed,
+	WSErrorCallDeclineFailed,
+	WSErrorCallEndFailed,
+	WSErrorSendMessageFailed,
+	WSErrorScheduleMessageFailed,
+	WSErrorCreateRoomFailed,
+	WSErrorLiveLocationStartFailed,
+	WSErrorLiveLocationUpdateFailed,
+	WSErrorLiveLocationStopFailed,
+	WSErrorReactionFailed,
+	WSErrorRateLimited,
+	WSErrorInvalidTopic,
+	WSErrorSubscriptionDenied,
 }
 
 // Heartbeat message
@@ -129,10 +270,31 @@ type UserLeftMessage struct {
 // Call status message
 type CallStatusMessage struct {
 	CallID uint   `json:"call_id"`
-	Status string `json:"status"` // ringing, ongoing, ended
+	Status string `json:"status"` // ringing, ongoing, migrating, ended
 	RoomID string `json:"room_id"`
 }
 
+// Topic message, to subscribe to a topic or leave it and in the replies
+type TopicMessage struct {
+	Topic string `json:"topic" binding:"required,max=64"`
+}
+
+// Post counts message, pushed to the subscribers of post:<id>
+type PostCountsMessage struct {
+	PostID       uint `json:"post_id"`
+	LikeCount    int  `json:"like_count"`
+	CommentCount int  `json:"comment_count"`
+	ShareCount   int  `json:"share_count"`
+}
+
+// Server going away message, clients reconnect after reconnect_in and rejoin
+// the rooms of the migrating calls
+type ServerGoingAwayMessage struct {
+	Reason         string `json:"reason"`
+	ReconnectIn    int    `json:"reconnect_in"` // Milliseconds, spread so clients do not reconnect at once
+	MigratingCalls []uint `json:"migrating_calls,omitempty"`
+}
+
 // WebSocket connection info
 type WSConnection struct {
 	UserID      uint      `json:"user_id"`
@@ -176,17 +338,94 @@ type UserOnlineStatusMessage struct {
 
 // Send chat message
 type SendChatMessageMessage struct {
-	RoomID    uint      `json:"room_id"`
-	LocalID   uint      `json:"local_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	RoomID    uint       `json:"room_id" binding:"required"`
+	LocalID   uint       `json:"local_id"`
+	Content   string     `json:"content" binding:"required"`
+	CreatedAt time.Time  `json:"created_at"`
+	SendAt    *time.Time `json:"send_at,omitempty"` // Deliver later when set in the future
 }
 
 type CreateChatRoomMessage struct {
 	LocalID        uint                  `json:"local_id"`
-	Type           postgres.ChatRoomType `json:"type"`
+	Type           postgres.ChatRoomType `json:"type" binding:"required"`
 	Name           string                `json:"name"`
 	Description    *string               `json:"description"`
 	ParticipantIDs []uint                `json:"participant_ids"`
 	CreatedAt      time.Time             `json:"created_at"`
 }
+
+// Disappearing messages removed by the expiry job
+type MessageExpiredMessage struct {
+	RoomID     uint   `json:"room_id"`
+	MessageIDs []uint `json:"message_ids"`
+}
+
+// Pin change, carries the current pins of the room
+type MessagePinMessage struct {
+	RoomID         uint                     `json:"room_id"`
+	MessageID      uint                     `json:"message_id"`
+	UserID         uint                     `json:"user_id"`
+	PinnedMessages []postgres.PinnedMessage `json:"pinned_messages"`
+}
+
+type PollUpdatedMessage struct {
+	RoomID    uint           `json:"room_id"`
+	MessageID uint           `json:"message_id"`
+	Poll      *postgres.Poll `json:"poll"`
+}
+
+// Start sharing a live location, Duration is in seconds
+type StartLiveLocationMessage struct {
+	RoomID    uint    `json:"room_id" binding:"required"`
+	LocalID   uint    `json:"local_id"`
+	Latitude  float64 `json:"latitude" binding:"min=-90,max=90"`
+	Longitude float64 `json:"longitude" binding:"min=-180,max=180"`
+	Accuracy  float64 `json:"accuracy" binding:"min=0"`
+	Duration  int     `json:"duration" binding:"min=0"`
+}
+
+// New position of a live location
+type UpdateLiveLocationMessage struct {
+	MessageID uint     `json:"message_id" binding:"required"`
+	Latitude  float64  `json:"latitude" binding:"min=-90,max=90"`
+	Longitude float64  `json:"longitude" binding:"min=-180,max=180"`
+	Accuracy  float64  `json:"accuracy" binding:"min=0"`
+	Heading   *float64 `json:"heading,omitempty"`
+}
+
+type StopLiveLocationMessage struct {
+	MessageID uint `json:"message_id" binding:"required"`
+}
+
+// Current position of a live location, pushed to the room
+type LiveLocationMessage struct {
+	MessageID uint      `json:"message_id"`
+	RoomID    uint      `json:"room_id"`
+	UserID    uint      `json:"user_id"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Accuracy  float64   `json:"accuracy,omitempty"`
+	Heading   *float64  `json:"heading,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Answer of a slash command, Error is set when the command failed
+type CommandReplyMessage struct {
+	RoomID  uint   `json:"room_id"`
+	LocalID uint   `json:"local_id"`
+	Command string `json:"command"`
+	Content string `json:"content,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Add or remove a reaction, also pushed to the room with the new counts of
+// the message. Pushed summaries have no reacted_by_me, and no UserID in
+// channels where subscribers do not see each other.
+type ReactionMessage struct {
+	MessageID uint                     `json:"message_id" binding:"required"`
+	Emoji     string                   `json:"emoji" binding:"required,max=64"`
+	RoomID    uint                     `json:"room_id,omitempty"`
+	UserID    uint                     `json:"user_id,omitempty"`
+	Summary   []postgres.ReactionCount `json:"reaction_summary,omitempty"`
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"reflect"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/responses"
+)
+
+// Subprotocols of the websocket API, the newest first. Clients without a
+// subprotocol get WSProtocolV1.
+const (
+	WSProtocolV1 = "social.v1"
+	WSProtocolV2 = "social.v2" // Rejects unknown fields in payloads
+)
+
+var WSProtocols = []string{WSProtocolV2, WSProtocolV1}
+
+// WSDirection tells who sends a message type
+type WSDirection string
+
+const (
+	WSFromClient WSDirection = "client"
+	WSFromServer WSDirection = "server"
+	WSBothWays   WSDirection = "both"
+)
+
+// WSMessageSpec describes a message type. Payload is a zero value of its
+// data, nil when the data is free-form or absent.
+type WSMessageSpec struct {
+	Type        MessageType
+	Direction   WSDirection
+	Description string
+	Payload     interface{}
+}
+
+// AcceptedFromClient tells if clients may send the message type
+func (s WSMessageSpec) AcceptedFromClient() bool {
+	return s.Direction == WSFromClient || s.Direction == WSBothWays
+}
+
+// PushedPayload returns a zero value of the data the server pushes
+func (s WSMessageSpec) PushedPayload() interface{} {
+	if payload, exists := wsPushedPayloads[s.Type]; exists {
+		return payload
+	}
+	return s.Payload
+}
+
+// NewPayload returns a pointer to an empty payload of the message type
+func (s WSMessageSpec) NewPayload() interface{} {
+	if s.Payload == nil {
+		return nil
+	}
+	return reflect.New(reflect.TypeOf(s.Payload)).Interface()
+}
+
+// WSMessageSpecs lists every message type of the protocol
+var WSMessageSpecs = []WSMessageSpec{
+	{MessageTypeConnected, WSFromServer, "Connection opened, carries the session to resume", ConnectedMessage{}},
+	{MessageTypeError, WSFromServer, "A request failed, echoes its request_id", ErrorMessage{}},
+	{MessageTypeHeartbeat, WSBothWays, "Keeps the connection alive, the data of clients is ignored", nil},
+	{MessageTypeAck, WSFromClient, "Acks every event of the session up to seq", AckMessage{}},
+	{MessageTypeServerGoingAway, WSFromServer, "The node is shutting down, reconnect after reconnect_in", ServerGoingAwayMessage{}},
+
+	// Topics, dropped with the connection
+	{MessageTypeSubscribe, WSFromClient, "Subscribe the connection to post:<id>, user:<id>:presence or room:<id>", TopicMessage{}},
+	{MessageTypeUnsubscribe, WSFromClient, "Unsubscribe the connection from a topic", TopicMessage{}},
+	{MessageTypeSubscribed, WSFromServer, "The connection subscribed to the topic, echoes the request_id", TopicMessage{}},
+	{MessageTypeUnsubscribed, WSFromServer, "The connection left the topic, echoes the request_id, or lost access to it without one", TopicMessage{}},
+	{MessageTypePostCountsUpdated, WSFromServer, "New like, comment and share counts of a post", PostCountsMessage{}},
+
+	// Calls
+	{MessageTypeJoinRoom, WSFromClient, "Join a call room", JoinRoomMessage{}},
+	{MessageTypeLeaveRoom, WSFromClient, "Leave the current call room", LeaveRoomMessage{}},
+	{MessageTypeUserJoined, WSFromServer, "A user joined the call room", UserJoinedMessage{}},
+	{MessageTypeUserLeft, WSFromServer, "A user left the call room", UserLeftMessage{}},
+	{MessageTypeCallRequest, WSBothWays, "Call a user, every device of the callee rings", CallRequestMessage{}},
+	{MessageTypeCallResponse, WSBothWays, "Accept or decline a call", CallResponseMessage{}},
+	{MessageTypeCallEnd, WSBothWays, "End a call", CallEndMessage{}},
+	{MessageTypeCallStatus, WSFromServer, "Status of a call changed", CallStatusMessage{}},
+	{MessageTypeOffer, WSBothWays, "WebRTC offer, forwarded to the user in to", OfferMessage{}},
+	{MessageTypeAnswer, WSBothWays, "WebRTC answer, forwarded to the user in to", AnswerMessage{}},
+	{MessageTypeICECandidate, WSBothWays, "ICE candidate, forwarded to the user in to", ICECandidateMessage{}},
+	{MessageTypeUserOnlineStatus, WSFromServer, "A friend went online or offline", UserOnlineStatusMessage{}},
+
+	// Chat
+	{MessageTypeChatSendMessage, WSFromClient, "Send a chat message, later when send_at is set", SendChatMessageMessage{}},
+	{MessageTypeChatCreateRoom, WSBothWays, "Create a chat room, the participants get its summary", CreateChatRoomMessage{}},
+	{MessageTypeChatReceiveMessage, WSFromServer, "New message in a room", postgres.Message{}},
+	{MessageTypeMessageUpdated, WSFromServer, "A message changed after it was sent", postgres.Message{}},
+	{MessageTypeMessageExpired, WSFromServer, "Disappearing messages were removed", MessageExpiredMessage{}},
+	{MessageTypeMessageScheduled, WSFromServer, "A message was scheduled", postgres.ScheduledMessage{}},
+	{MessageTypeScheduledMessageFailed, WSFromServer, "A scheduled message could not be sent", postgres.ScheduledMessage{}},
+	{MessageTypeMessagePinned, WSFromServer, "A message was pinned", MessagePinMessage{}},
+	{MessageTypeMessageUnpinned, WSFromServer, "A message was unpinned", MessagePinMessage{}},
+	{MessageTypePollUpdated, WSFromServer, "New tally of a poll", PollUpdatedMessage{}},
+	{MessageTypeChatInvite, WSFromServer, "The user was invited to a room", postgres.ChatInvite{}},
+	{MessageTypeChatInviteUpdated, WSFromServer, "An invite was accepted, declined or revoked", postgres.ChatInvite{}},
+	{MessageTypeChatJoinRequest, WSFromServer, "A user asked to join a room the user administers", postgres.ChatJoinRequest{}},
+	{MessageTypeChatJoinRequestUpdated, WSFromServer, "A join request was approved or rejected", postgres.ChatJoinRequest{}},
+	{MessageTypeChatModeration, WSFromServer, "A moderation action in a room", postgres.ChatModerationLog{}},
+	{MessageTypeLiveLocationStart, WSFromClient, "Start sharing a live location", StartLiveLocationMessage{}},
+	{MessageTypeLiveLocationUpdate, WSFromClient, "New position of a shared location", UpdateLiveLocationMessage{}},
+	{MessageTypeLiveLocationStop, WSFromClient, "Stop sharing a live location", StopLiveLocationMessage{}},
+	{MessageTypeLiveLocationUpdated, WSFromServer, "Current position of a live location", LiveLocationMessage{}},
+	{MessageTypeLiveLocationStopped, WSFromServer, "A live location stopped", LiveLocationMessage{}},
+	{MessageTypeCommandReply, WSFromServer, "Answer of a slash command", CommandReplyMessage{}},
+	{MessageTypeChatReminder, WSFromServer, "A reminder set with a slash command is due", postgres.ScheduledMessage{}},
+	{MessageTypeReactionAdd, WSBothWays, "Add a reaction, pushed with the new summary", ReactionMessage{}},
+	{MessageTypeReactionRemove, WSBothWays, "Remove a reaction, pushed with the new summary", ReactionMessage{}},
+	{MessageTypeChannelSubscribed, WSFromServer, "The user subscribed to a channel", nil},
+	{MessageTypeChannelUnsubscribed, WSFromServer, "The user left a channel", nil},
+	{MessageTypeChatExportUpdated, WSFromServer, "An export of a room is ready or failed", postgres.ChatExport{}},
+}
+
+// wsPushedPayloads are the data pushed by the server where it differs from
+// what clients send
+var wsPushedPayloads = map[MessageType]interface{}{
+	MessageTypeHeartbeat:      HeartbeatMessage{},
+	MessageTypeChatCreateRoom: responses.ChatRoomSummary{},
+}
+
+var wsMessageSpecIndex = func() map[MessageType]WSMessageSpec {
+	index := make(map[MessageType]WSMessageSpec, len(WSMessageSpecs))
+	for _, spec := range WSMessageSpecs {
+		index[spec.Type] = spec
+	}
+	return index
+}()
+
+// LookupWSMessage returns the spec of a message type
+func LookupWSMessage(messageType MessageType) (WSMessageSpec, bool) {
+	spec, exists := wsMessageSpecIndex[messageType]
+	return spec, exists
+}
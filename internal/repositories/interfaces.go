@@ -28,6 +28,8 @@ type UserRepository interface {
 	IsFriend(userID, targetID uint) (bool, error)
 	AreFriends(userID, targetID uint) (bool, error)
 	IsBlocked(userID, targetID uint) (bool, error)
+
+	GetBotsByOwner(ownerID uint) ([]postgres.User, error)
 }
 
 type FriendRepository interface {
@@ -119,17 +121,30 @@ type ChatRoomRepository interface {
 	Delete(userID uint, roomID uint) error
 	GetUserRooms(userID uint, archive bool, cursor paginator.Cursor, limit int) ([]responses.ChatRoomSummary, paginator.Cursor, error)
 	GetPrivateRoom(userID1, userID2 uint) (*postgres.ChatRoom, error)
-	AddParticipant(participant *postgres.Participant) error
+	AddParticipant(participant *postgres.Participant) (bool, error)
 	RemoveParticipant(roomID, userID uint) error
 	GetParticipants(roomID uint) ([]postgres.Participant, error)
 	UpdateLastActivity(roomID uint, lastActivity time.Time) error
 	ArchiveRoom(roomID uint) error
 	SearchRooms(userID uint, query string, limit int) ([]responses.ChatRoomSummary, error)
 	GetUserChatRoomsByUserIDAndLastRoomID(userID uint, lastID *uint) ([]postgres.ChatRoom, int64, error)
+	GetRoomType(roomID uint) (postgres.ChatRoomType, error)
+}
+
+type ChannelRepository interface {
+	Create(channel *postgres.ChatRoom) error
+	GetByHandle(handle string) (*postgres.ChatRoom, error)
+	SearchPublic(query string, limit int) ([]postgres.ChatRoom, error)
+	Subscribe(roomID, userID uint) (bool, error)
+	Unsubscribe(roomID, userID uint) (bool, error)
+	IsSubscribed(roomID, userID uint) (bool, error)
+	GetUserSubscriptions(userID uint) ([]postgres.ChannelSubscription, error)
+	GetUserChannelIDs(userID uint) ([]uint, error)
+	RecordViews(roomID, userID uint, messageIDs []uint) (map[uint]int, error)
 }
 
 type MessageRepository interface {
-	Create(content string, localID uint, senderID uint, roomID uint, createdAt time.Time) (*postgres.Message, error)
+	Create(message *postgres.Message) error
 	GetByID(id uint) (*postgres.Message, error)
 	Update(id uint, updates map[string]interface{}) error
 	Delete(id uint) error
@@ -140,8 +155,56 @@ type MessageRepository interface {
 	SearchMessages(roomID uint, query string, limit int) ([]responses.MessageSearchResult, error)
 	GetMessagesByType(roomID uint, messageType postgres.MessageType, cursor paginator.Cursor, limit int) ([]postgres.Message, paginator.Cursor, error)
 	GetRecentMedia(roomID uint, mediaType postgres.MessageType, limit int) ([]postgres.Message, error)
-	AddReaction(messageID, userID uint, emoji string) error
-	RemoveReaction(messageID, userID uint, emoji string) error
+	AddReaction(messageID, userID uint, emoji string) (bool, error)
+	RemoveReaction(messageID, userID uint, emoji string) (bool, error)
+	GetReactionSummaries(messageIDs []uint, userID uint) (map[uint][]postgres.ReactionCount, error)
+	GetReactionUsers(messageID uint, emoji string, cursor paginator.Cursor, limit int) ([]postgres.MessageReaction, paginator.Cursor, error)
+	GetExpiredMessages(before time.Time, limit int) ([]postgres.Message, error)
+	HardDelete(ids []uint) error
+	IsMediaReferenced(url string) (bool, error)
+	GetLastSentAt(roomID, senderID uint) (*time.Time, error)
+	GetByIDs(ids []uint) ([]postgres.Message, error)
+	GetForExport(roomID, afterID uint, since *time.Time, until time.Time, limit int) ([]postgres.Message, error)
+}
+
+type ChatRoomEmojiRepository interface {
+	Create(emoji *postgres.ChatRoomEmoji) error
+	GetByID(id uint) (*postgres.ChatRoomEmoji, error)
+	GetRoomEmojis(roomID uint) ([]postgres.ChatRoomEmoji, error)
+	CountRoomEmojis(roomID uint) (int64, error)
+	Delete(id uint) error
+}
+
+type PollRepository interface {
+	Create(poll *postgres.Poll) error
+	CreateWithMessage(poll *postgres.Poll, message *postgres.Message) error
+	GetByID(id uint) (*postgres.Poll, error)
+	Update(id uint, updates map[string]interface{}) error
+	ReplaceVotes(pollID, userID uint, optionIDs []uint, now time.Time) (bool, error)
+	GetOptionVoteCounts(pollIDs []uint) (map[uint]int, error)
+	GetVoterCounts(pollIDs []uint) (map[uint]int, error)
+	GetOptionVoters(pollIDs []uint) (map[uint][]uint, error)
+	GetUserVotes(pollIDs []uint, userID uint) (map[uint][]uint, error)
+}
+
+type ScheduledMessageRepository interface {
+	Create(message *postgres.ScheduledMessage) error
+	GetByID(id uint) (*postgres.ScheduledMessage, error)
+	Update(id uint, updates map[string]interface{}) error
+	GetUserRoomMessages(userID, roomID uint) ([]postgres.ScheduledMessage, error)
+	GetDueMessages(before time.Time, limit int) ([]postgres.ScheduledMessage, error)
+	UpdateIfStatus(id uint, status postgres.ScheduledMessageStatus, updates map[string]interface{}) (bool, error)
+	Claim(id uint) (bool, error)
+	ReleaseStale(olderThan time.Time) error
+}
+
+type PinnedMessageRepository interface {
+	Create(pin *postgres.PinnedMessage) error
+	CreateWithinLimit(pin *postgres.PinnedMessage, limit int64) (bool, error)
+	Delete(messageID uint) error
+	GetByMessageID(messageID uint) (*postgres.PinnedMessage, error)
+	GetRoomPins(roomID uint) ([]postgres.PinnedMessage, error)
+	GetRoomsPins(roomIDs []uint) (map[uint][]postgres.PinnedMessage, error)
 }
 
 type ParticipantRepository interface {
@@ -154,7 +217,7 @@ type ParticipantRepository interface {
 	GetUserParticipations(userID uint) ([]postgres.Participant, error)
 	UpdateRole(roomID, userID uint, role postgres.ParticipantRole) error
 	UpdateLastRead(roomID, userID uint) error
-	MuteParticipant(roomID, userID uint, isMuted bool) error
+	MuteParticipant(roomID, userID uint, isMuted bool, mutedUntil *time.Time) error
 	BlockParticipant(roomID, userID uint, isBlocked bool) error
 }
 
@@ -180,11 +243,78 @@ type ChatInviteRepository interface {
 	Delete(id uint) error
 	GetUserInvites(userID uint, status string, cursor paginator.Cursor, limit int) ([]postgres.ChatInvite, paginator.Cursor, error)
 	GetRoomInvites(roomID uint, cursor paginator.Cursor, limit int) ([]postgres.ChatInvite, paginator.Cursor, error)
-	AcceptInvite(inviteID uint) error
-	DeclineInvite(inviteID uint) error
+	GetPendingInvite(roomID, inviteeID uint) (*postgres.ChatInvite, error)
+	AcceptInvite(inviteID uint) (bool, error)
+	DeclineInvite(inviteID uint) (bool, error)
 	ExpireOldInvites(ctx context.Context) error
 }
 
+type ChatInviteLinkRepository interface {
+	Create(link *postgres.ChatInviteLink) error
+	GetByID(id uint) (*postgres.ChatInviteLink, error)
+	GetByToken(token string) (*postgres.ChatInviteLink, error)
+	Update(id uint, updates map[string]interface{}) error
+	GetRoomLinks(roomID uint) ([]postgres.ChatInviteLink, error)
+	UseToJoin(id uint, now time.Time, participant *postgres.Participant) (bool, error)
+	UseToSubscribe(id uint, now time.Time, roomID, userID uint) (bool, error)
+	UseToRequest(id uint, now time.Time, request *postgres.ChatJoinRequest) (bool, error)
+}
+
+type ChatJoinRequestRepository interface {
+	Create(request *postgres.ChatJoinRequest) error
+	GetByID(id uint) (*postgres.ChatJoinRequest, error)
+	Update(id uint, updates map[string]interface{}) error
+	GetPending(roomID, userID uint) (*postgres.ChatJoinRequest, error)
+	GetRoomRequests(roomID uint, status postgres.ChatJoinRequestStatus) ([]postgres.ChatJoinRequest, error)
+}
+
+type LinkPreviewRepository interface {
+	GetByURL(url string) (*postgres.LinkPreview, error)
+	Save(preview *postgres.LinkPreview) error
+}
+
+type ChatRoomBanRepository interface {
+	Create(ban *postgres.ChatRoomBan) error
+	Delete(roomID, userID uint) error
+	IsBanned(roomID, userID uint) (bool, error)
+	GetRoomBans(roomID uint) ([]postgres.ChatRoomBan, error)
+}
+
+type ChatModerationLogRepository interface {
+	Create(entry *postgres.ChatModerationLog) error
+	GetRoomLogs(roomID uint, cursor paginator.Cursor, limit int) ([]postgres.ChatModerationLog, paginator.Cursor, error)
+}
+
+type ChatWebhookRepository interface {
+	Create(webhook *postgres.ChatWebhook) error
+	GetByID(id uint) (*postgres.ChatWebhook, error)
+	GetByToken(token string) (*postgres.ChatWebhook, error)
+	GetRoomWebhooks(roomID uint) ([]postgres.ChatWebhook, error)
+	GetActiveOutgoing(roomID uint) ([]postgres.ChatWebhook, error)
+	Delete(id uint) error
+	DeleteBotWebhooks(botID uint) error
+}
+
+type WebhookDeliveryRepository interface {
+	Create(delivery *postgres.WebhookDelivery) error
+	GetByID(id uint) (*postgres.WebhookDelivery, error)
+	Update(id uint, updates map[string]interface{}) error
+	GetDueDeliveries(before time.Time, limit int) ([]postgres.WebhookDelivery, error)
+	GetWebhookDeliveries(webhookID uint, cursor paginator.Cursor, limit int) ([]postgres.WebhookDelivery, paginator.Cursor, error)
+}
+
+type ChatExportRepository interface {
+	Create(export *postgres.ChatExport) error
+	GetByID(id uint) (*postgres.ChatExport, error)
+	Update(id uint, updates map[string]interface{}) error
+	GetUserRoomExports(roomID, userID uint) ([]postgres.ChatExport, error)
+	CountActive(userID uint) (int64, error)
+	GetPending(limit int) ([]postgres.ChatExport, error)
+	Claim(id uint) (bool, error)
+	ReleaseStale(olderThan time.Time) error
+	GetExpired(before time.Time, limit int) ([]postgres.ChatExport, error)
+}
+
 type ChatNotificationRepository interface {
 	Create(notification *postgres.ChatNotification) error
 	GetByID(id uint) (*postgres.ChatNotification, error)
@@ -219,10 +349,12 @@ type CallRepository interface {
 	Create(call *postgres.Call) error
 	GetByID(id uint) (*postgres.Call, error)
 	Update(id uint, updates map[string]interface{}) error
+	UpdateIfStatus(id uint, status string, updates map[string]interface{}) (bool, error)
 	Delete(id uint) error
 	GetUserCalls(userID uint, cursor paginator.Cursor, limit int) ([]postgres.Call, paginator.Cursor, error)
 	GetUserCallHistory(userID uint, cursor paginator.Cursor, limit int) ([]postgres.Call, paginator.Cursor, error)
 	GetActiveCall(userID uint) (*postgres.Call, error)
+	GetMigratingCalls(before time.Time) ([]postgres.Call, error)
 	EndCall(callID uint) error
 	JoinCall(callID, userID uint) error
 	LeaveCall(callID, userID uint) error
@@ -266,10 +398,23 @@ type Repositories struct {
 	Share            ShareRepository
 	ChatRoom         ChatRoomRepository
 	Message          MessageRepository
+	ChatRoomEmoji    ChatRoomEmojiRepository
+	ScheduledMessage ScheduledMessageRepository
+	PinnedMessage    PinnedMessageRepository
+	Poll             PollRepository
+	LinkPreview      LinkPreviewRepository
 	Participant      ParticipantRepository
 	TypingIndicator  TypingIndicatorRepository
 	OnlineStatus     OnlineStatusRepository
 	ChatInvite       ChatInviteRepository
+	ChatInviteLink   ChatInviteLinkRepository
+	ChatJoinRequest  ChatJoinRequestRepository
+	ChatRoomBan      ChatRoomBanRepository
+	ChatModeration   ChatModerationLogRepository
+	ChatWebhook      ChatWebhookRepository
+	WebhookDelivery  WebhookDeliveryRepository
+	ChatExport       ChatExportRepository
+	Channel          ChannelRepository
 	ChatNotification ChatNotificationRepository
 	Auth             AuthRepository
 	Call             CallRepository
@@ -45,6 +45,17 @@ func (r *callRepository) Update(id uint, updates map[string]interface{}) error {
 		Updates(updates).Error
 }
 
+// UpdateIfStatus updates a call that is still in the status, false when it
+// was not anymore
+func (r *callRepository) UpdateIfStatus(id uint, status string, updates map[string]interface{}) (bool, error) {
+	updates["updated_at"] = time.Now()
+	result := r.db.
+		Model(&postgres.Call{}).
+		Where("id = ? AND status = ?", id, status).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
 func (r *callRepository) Delete(id uint) error {
 	return r.db.Delete(&postgres.Call{}, id).Error
 }
@@ -81,7 +92,7 @@ func (r *callRepository) GetActiveCall(userID uint) (*postgres.Call, error) {
 	var call postgres.Call
 	err := r.db.
 		Where("(caller_id = ? OR callee_id = ?) AND status IN (?)",
-			userID, userID, []string{"ongoing", "ringing"}).
+			userID, userID, []string{"ongoing", "ringing", "migrating"}).
 		Preload("Caller").
 		Preload("Callee").
 		Preload("Participants").
@@ -93,6 +104,15 @@ func (r *callRepository) GetActiveCall(userID uint) (*postgres.Call, error) {
 	return &call, nil
 }
 
+// GetMigratingCalls returns the calls migrating since before
+func (r *callRepository) GetMigratingCalls(before time.Time) ([]postgres.Call, error) {
+	var calls []postgres.Call
+	err := r.db.
+		Where("status = ? AND COALESCE(migrated_at, updated_at) <= ?", "migrating", before).
+		Find(&calls).Error
+	return calls, err
+}
+
 func (r *callRepository) EndCall(callID uint) error {
 	now := time.Now()
 	return r.db.
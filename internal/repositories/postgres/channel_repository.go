@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"fmt"
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Channel Repository Implementation
+type channelRepository struct {
+	db *gorm.DB
+}
+
+func NewChannelRepository(db *gorm.DB) repositories.ChannelRepository {
+	return &channelRepository{db: db}
+}
+
+// Create creates a channel and makes its creator the owner in one
+// transaction
+func (r *channelRepository) Create(channel *postgres.ChatRoom) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		channel.Type = postgres.ChatRoomTypeChannel
+		channel.CreatedAt = now
+		channel.UpdatedAt = now
+		if err := tx.Create(channel).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&postgres.Participant{
+			ChatRoomID: channel.ID,
+			UserID:     channel.CreatedBy,
+			Role:       postgres.ParticipantRoleOwner,
+			JoinedAt:   now,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}).Error
+	})
+}
+
+func (r *channelRepository) GetByHandle(handle string) (*postgres.ChatRoom, error) {
+	var room postgres.ChatRoom
+	err := r.db.
+		Where("handle = ? AND type = ?", handle, postgres.ChatRoomTypeChannel).
+		First(&room).Error
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// SearchPublic finds public channels by name, handle or description, the
+// most followed first
+func (r *channelRepository) SearchPublic(query string, limit int) ([]postgres.ChatRoom, error) {
+	var rooms []postgres.ChatRoom
+	db := r.db.
+		Where("type = ? AND is_public = ? AND is_archived = ?", postgres.ChatRoomTypeChannel, true, false)
+
+	if query != "" {
+		searchQuery := fmt.Sprintf("%%%s%%", strings.ToLower(query))
+		db = db.Where("LOWER(name) LIKE ? OR LOWER(handle) LIKE ? OR LOWER(description) LIKE ?", searchQuery, searchQuery, searchQuery)
+	}
+
+	err := db.
+		Order("subscriber_count DESC, id DESC").
+		Limit(limit).
+		Find(&rooms).Error
+	return rooms, err
+}
+
+// Subscribe adds a subscription and counts it, returns false if the user
+// was already subscribed
+func (r *channelRepository) Subscribe(roomID, userID uint) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = subscribeChannel(tx, roomID, userID)
+		return err
+	})
+	return created, err
+}
+
+// subscribeChannel adds a subscription and counts it within a transaction
+func subscribeChannel(tx *gorm.DB, roomID, userID uint) (bool, error) {
+	result := tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&postgres.ChannelSubscription{
+			ChatRoomID: roomID,
+			UserID:     userID,
+			CreatedAt:  time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	err := tx.Model(&postgres.ChatRoom{}).
+		Where("id = ?", roomID).
+		UpdateColumn("subscriber_count", gorm.Expr("subscriber_count + 1")).Error
+	return err == nil, err
+}
+
+// Unsubscribe removes a subscription, returns false if there was none
+func (r *channelRepository) Unsubscribe(roomID, userID uint) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("chat_room_id = ? AND user_id = ?", roomID, userID).
+			Delete(&postgres.ChannelSubscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		deleted = true
+		return tx.Model(&postgres.ChatRoom{}).
+			Where("id = ? AND subscriber_count > 0", roomID).
+			UpdateColumn("subscriber_count", gorm.Expr("subscriber_count - 1")).Error
+	})
+	return deleted, err
+}
+
+func (r *channelRepository) IsSubscribed(roomID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.
+		Model(&postgres.ChannelSubscription{}).
+		Where("chat_room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *channelRepository) GetUserSubscriptions(userID uint) ([]postgres.ChannelSubscription, error) {
+	var subscriptions []postgres.ChannelSubscription
+	err := r.db.
+		Where("user_id = ?", userID).
+		Preload("ChatRoom").
+		Order("created_at DESC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *channelRepository) GetUserChannelIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.
+		Model(&postgres.ChannelSubscription{}).
+		Where("user_id = ?", userID).
+		Pluck("chat_room_id", &ids).Error
+	return ids, err
+}
+
+// RecordViews counts a view of each message of the channel the user had not
+// seen yet. Returns the view counts of the messages.
+func (r *channelRepository) RecordViews(roomID, userID uint, messageIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Model(&postgres.Message{}).
+			Where("chat_room_id = ? AND id IN ?", roomID, messageIDs).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, id := range ids {
+			result := tx.
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&postgres.MessageView{MessageID: id, UserID: userID, ViewedAt: now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			err := tx.Model(&postgres.Message{}).
+				Where("id = ?", id).
+				UpdateColumn("view_count", gorm.Expr("view_count + 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var messages []postgres.Message
+		if err := tx.Select("id", "view_count").Where("id IN ?", ids).Find(&messages).Error; err != nil {
+			return err
+		}
+		for _, message := range messages {
+			counts[message.ID] = message.ViewCount
+		}
+		return nil
+	})
+	return counts, err
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+
+	"gorm.io/gorm"
+)
+
+// Chat Room Emoji Repository Implementation
+type chatRoomEmojiRepository struct {
+	db *gorm.DB
+}
+
+func NewChatRoomEmojiRepository(db *gorm.DB) repositories.ChatRoomEmojiRepository {
+	return &chatRoomEmojiRepository{db: db}
+}
+
+func (r *chatRoomEmojiRepository) Create(emoji *postgres.ChatRoomEmoji) error {
+	return r.db.Create(emoji).Error
+}
+
+func (r *chatRoomEmojiRepository) GetByID(id uint) (*postgres.ChatRoomEmoji, error) {
+	var emoji postgres.ChatRoomEmoji
+	if err := r.db.First(&emoji, id).Error; err != nil {
+		return nil, err
+	}
+	return &emoji, nil
+}
+
+func (r *chatRoomEmojiRepository) GetRoomEmojis(roomID uint) ([]postgres.ChatRoomEmoji, error) {
+	var emojis []postgres.ChatRoomEmoji
+	err := r.db.
+		Where("chat_room_id = ?", roomID).
+		Order("shortcode ASC").
+		Find(&emojis).Error
+	return emojis, err
+}
+
+func (r *chatRoomEmojiRepository) CountRoomEmojis(roomID uint) (int64, error) {
+	var count int64
+	err := r.db.
+		Model(&postgres.ChatRoomEmoji{}).
+		Where("chat_room_id = ?", roomID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *chatRoomEmojiRepository) Delete(id uint) error {
+	return r.db.Delete(&postgres.ChatRoomEmoji{}, id).Error
+}
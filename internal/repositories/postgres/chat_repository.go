@@ -12,15 +12,18 @@ import (
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type chatRoomRepository struct {
-	db *gorm.DB
+	db   *gorm.DB
+	pins repositories.PinnedMessageRepository // Pins of the rooms listed
 }
 
-func NewChatRoomRepository(db *gorm.DB) repositories.ChatRoomRepository {
+func NewChatRoomRepository(db *gorm.DB, pins repositories.PinnedMessageRepository) repositories.ChatRoomRepository {
 	return &chatRoomRepository{
-		db: db,
+		db:   db,
+		pins: pins,
 	}
 }
 
@@ -89,8 +92,22 @@ func (r *chatRoomRepository) Create(name string, creatorID uint, chatRoomType po
 	return &createdRoom, nil
 }
 
-func (r *chatRoomRepository) AddParticipant(participant *postgres.Participant) error {
-	return r.db.Create(participant).Error
+// AddParticipant adds a user to a room, false when they are already in it
+func (r *chatRoomRepository) AddParticipant(participant *postgres.Participant) (bool, error) {
+	return addParticipant(r.db, participant)
+}
+
+// addParticipant inserts a participant unless the user is already in the
+// room, which the unique index on the participants who did not leave catches
+// even when joins race
+func addParticipant(tx *gorm.DB, participant *postgres.Participant) (bool, error) {
+	result := tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(participant)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
 }
 
 func (r *chatRoomRepository) GetByID(id uint) (*postgres.ChatRoom, error) {
@@ -241,6 +258,12 @@ func (r *chatRoomRepository) GetUserRooms(userID uint, archive bool, cursor pagi
 		unreadCountMap[uc.ChatRoomID] = uc.Count
 	}
 
+	// Batch query for pinned messages
+	pinnedMessageMap, err := r.pins.GetRoomsPins(roomIDs)
+	if err != nil {
+		return nil, paginator.Cursor{}, err
+	}
+
 	// Build summaries
 	summaries := make([]responses.ChatRoomSummary, 0, len(rooms))
 	for _, room := range rooms {
@@ -270,6 +293,7 @@ func (r *chatRoomRepository) GetUserRooms(userID uint, archive bool, cursor pagi
 			LastActivity:     room.LastActivity,
 			UnreadCount:      int(unreadCountMap[room.ID]),
 			IsMuted:          false,
+			PinnedMessages:   pinnedMessageMap[room.ID],
 			CreatedAt:        room.CreatedAt,
 		}
 
@@ -407,3 +431,12 @@ func (r *chatRoomRepository) GetUserChatRoomsByUserIDAndLastRoomID(userID uint,
 	})
 	return chatRooms, count, err
 }
+
+// GetRoomType returns the type of a room without loading its participants
+func (r *chatRoomRepository) GetRoomType(roomID uint) (postgres.ChatRoomType, error) {
+	var room postgres.ChatRoom
+	err := r.db.
+		Select("type").
+		First(&room, roomID).Error
+	return room.Type, err
+}
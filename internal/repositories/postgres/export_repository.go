@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChatExport Repository Implementation
+type chatExportRepository struct {
+	db *gorm.DB
+}
+
+func NewChatExportRepository(db *gorm.DB) repositories.ChatExportRepository {
+	return &chatExportRepository{db: db}
+}
+
+func (r *chatExportRepository) Create(export *postgres.ChatExport) error {
+	return r.db.Create(export).Error
+}
+
+func (r *chatExportRepository) GetByID(id uint) (*postgres.ChatExport, error) {
+	var export postgres.ChatExport
+	if err := r.db.First(&export, id).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *chatExportRepository) Update(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.db.
+		Model(&postgres.ChatExport{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+func (r *chatExportRepository) GetUserRoomExports(roomID, userID uint) ([]postgres.ChatExport, error) {
+	var exports []postgres.ChatExport
+	err := r.db.
+		Where("chat_room_id = ? AND requested_by = ?", roomID, userID).
+		Order("created_at DESC").
+		Find(&exports).Error
+	return exports, err
+}
+
+// CountActive counts the exports of a user that are waiting or being written
+func (r *chatExportRepository) CountActive(userID uint) (int64, error) {
+	var count int64
+	err := r.db.
+		Model(&postgres.ChatExport{}).
+		Where("requested_by = ? AND status IN ?", userID, []postgres.ChatExportStatus{
+			postgres.ChatExportStatusPending,
+			postgres.ChatExportStatusProcessing,
+		}).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *chatExportRepository) GetPending(limit int) ([]postgres.ChatExport, error) {
+	var exports []postgres.ChatExport
+	err := r.db.
+		Where("status = ?", postgres.ChatExportStatusPending).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+// Claim marks a pending export as processing, returns false if another worker got it first
+func (r *chatExportRepository) Claim(id uint) (bool, error) {
+	result := r.db.
+		Model(&postgres.ChatExport{}).
+		Where("id = ? AND status = ?", id, postgres.ChatExportStatusPending).
+		Updates(map[string]interface{}{
+			"status":     postgres.ChatExportStatusProcessing,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseStale puts exports stuck in processing (e.g. after a crash) back to pending
+func (r *chatExportRepository) ReleaseStale(olderThan time.Time) error {
+	return r.db.
+		Model(&postgres.ChatExport{}).
+		Where("status = ? AND updated_at < ?", postgres.ChatExportStatusProcessing, olderThan).
+		Updates(map[string]interface{}{
+			"status":     postgres.ChatExportStatusPending,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// GetExpired returns the completed exports whose file can be removed
+func (r *chatExportRepository) GetExpired(before time.Time, limit int) ([]postgres.ChatExport, error) {
+	var exports []postgres.ChatExport
+	err := r.db.
+		Where("status = ? AND expires_at <= ?", postgres.ChatExportStatusCompleted, before).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"errors"
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChatInviteLink Repository Implementation
+type chatInviteLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewChatInviteLinkRepository(db *gorm.DB) repositories.ChatInviteLinkRepository {
+	return &chatInviteLinkRepository{db: db}
+}
+
+func (r *chatInviteLinkRepository) Create(link *postgres.ChatInviteLink) error {
+	return r.db.Create(link).Error
+}
+
+func (r *chatInviteLinkRepository) GetByID(id uint) (*postgres.ChatInviteLink, error) {
+	var link postgres.ChatInviteLink
+	err := r.db.First(&link, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *chatInviteLinkRepository) GetByToken(token string) (*postgres.ChatInviteLink, error) {
+	var link postgres.ChatInviteLink
+	err := r.db.
+		Preload("ChatRoom").
+		Where("token = ?", token).
+		First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *chatInviteLinkRepository) Update(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.db.
+		Model(&postgres.ChatInviteLink{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+func (r *chatInviteLinkRepository) GetRoomLinks(roomID uint) ([]postgres.ChatInviteLink, error) {
+	var links []postgres.ChatInviteLink
+	err := r.db.
+		Where("chat_room_id = ?", roomID).
+		Preload("Creator").
+		Order("created_at DESC").
+		Find(&links).Error
+	return links, err
+}
+
+// errInviteLinkUnusable rolls back a join through a link that is revoked,
+// expired or used up, or by a user already in the room
+var errInviteLinkUnusable = errors.New("invite link is no longer valid")
+
+// UseToJoin counts a use of a link and adds the participant in one
+// transaction, returns false and adds nobody if the link cannot be used or
+// the user is already in the room
+func (r *chatInviteLinkRepository) UseToJoin(id uint, now time.Time, participant *postgres.Participant) (bool, error) {
+	return r.useWith(id, now, func(tx *gorm.DB) error {
+		added, err := addParticipant(tx, participant)
+		if err != nil {
+			return err
+		}
+		if !added {
+			return errInviteLinkUnusable
+		}
+		return nil
+	})
+}
+
+// UseToSubscribe counts a use of a link and subscribes the user to its
+// channel in one transaction, returns false if the link cannot be used
+func (r *chatInviteLinkRepository) UseToSubscribe(id uint, now time.Time, roomID, userID uint) (bool, error) {
+	return r.useWith(id, now, func(tx *gorm.DB) error {
+		_, err := subscribeChannel(tx, roomID, userID)
+		return err
+	})
+}
+
+// UseToRequest counts a use of a link and queues the join request in one
+// transaction, returns false if the link cannot be used
+func (r *chatInviteLinkRepository) UseToRequest(id uint, now time.Time, request *postgres.ChatJoinRequest) (bool, error) {
+	return r.useWith(id, now, func(tx *gorm.DB) error {
+		return tx.Create(request).Error
+	})
+}
+
+// useWith counts a use of a link, then runs join in the same transaction
+func (r *chatInviteLinkRepository) useWith(id uint, now time.Time, join func(tx *gorm.DB) error) (bool, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Model(&postgres.ChatInviteLink{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Where("expires_at IS NULL OR expires_at > ?", now).
+			Where("max_uses = 0 OR use_count < max_uses").
+			Updates(map[string]interface{}{
+				"use_count":  gorm.Expr("use_count + 1"),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errInviteLinkUnusable
+		}
+		return join(tx)
+	})
+	if errors.Is(err, errInviteLinkUnusable) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ChatJoinRequest Repository Implementation
+type chatJoinRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewChatJoinRequestRepository(db *gorm.DB) repositories.ChatJoinRequestRepository {
+	return &chatJoinRequestRepository{db: db}
+}
+
+func (r *chatJoinRequestRepository) Create(request *postgres.ChatJoinRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *chatJoinRequestRepository) GetByID(id uint) (*postgres.ChatJoinRequest, error) {
+	var request postgres.ChatJoinRequest
+	err := r.db.First(&request, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *chatJoinRequestRepository) Update(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.db.
+		Model(&postgres.ChatJoinRequest{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+func (r *chatJoinRequestRepository) GetPending(roomID, userID uint) (*postgres.ChatJoinRequest, error) {
+	var request postgres.ChatJoinRequest
+	err := r.db.
+		Where("chat_room_id = ? AND user_id = ? AND status = ?", roomID, userID, postgres.ChatJoinRequestStatusPending).
+		First(&request).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *chatJoinRequestRepository) GetRoomRequests(roomID uint, status postgres.ChatJoinRequestStatus) ([]postgres.ChatJoinRequest, error) {
+	var requests []postgres.ChatJoinRequest
+	query := r.db.
+		Where("chat_room_id = ?", roomID).
+		Preload("User").
+		Preload("User.Profile")
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.Order("created_at ASC").Find(&requests).Error
+	return requests, err
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LinkPreview Repository Implementation
+type linkPreviewRepository struct {
+	db *gorm.DB
+}
+
+func NewLinkPreviewRepository(db *gorm.DB) repositories.LinkPreviewRepository {
+	return &linkPreviewRepository{db: db}
+}
+
+func (r *linkPreviewRepository) GetByURL(url string) (*postgres.LinkPreview, error) {
+	var preview postgres.LinkPreview
+	err := r.db.
+		Where("url = ?", url).
+		First(&preview).Error
+	if err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// Save inserts a preview or refreshes the cached one of the same URL
+func (r *linkPreviewRepository) Save(preview *postgres.LinkPreview) error {
+	return r.db.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "url"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"canonical_url", "title", "description", "site_name",
+				"image_url", "type", "failed", "fetched_at", "updated_at",
+			}),
+		}).
+		Create(preview).Error
+}
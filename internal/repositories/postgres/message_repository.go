@@ -12,6 +12,7 @@ import (
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Message Repository Implementation
@@ -23,28 +24,19 @@ func NewMessageRepository(db *gorm.DB) repositories.MessageRepository {
 	return &messageRepository{db: db}
 }
 
-func (r *messageRepository) Create(content string, localID uint, senderID uint, roomID uint, createdAt time.Time) (*postgres.Message, error) {
-	message := &postgres.Message{
-		Content:    content,
-		LocalID:    localID,
-		Type:       postgres.MessageTypeText,
-		SenderID:   senderID,
-		ChatRoomID: roomID,
-		CreatedAt:  createdAt,
-	}
-
-	err := r.db.Create(message).Error
-	if err != nil {
-		return nil, err
-	}
-	return message, nil
+func (r *messageRepository) Create(message *postgres.Message) error {
+	return r.db.Create(message).Error
 }
+
 func (r *messageRepository) GetByID(id uint) (*postgres.Message, error) {
 	var message postgres.Message
 	err := r.db.
 		Preload("Sender").
 		Preload("ReadBy").
 		Preload("Reactions").
+		Preload("Poll.Options").
+		Preload("OriginalSender").
+		Preload("LinkPreview").
 		First(&message, id).Error
 	if err != nil {
 		return nil, err
@@ -52,6 +44,17 @@ func (r *messageRepository) GetByID(id uint) (*postgres.Message, error) {
 	return &message, nil
 }
 
+func (r *messageRepository) GetByIDs(ids []uint) ([]postgres.Message, error) {
+	var messages []postgres.Message
+	err := r.db.
+		Where("id IN ?", ids).
+		Preload("Sender").
+		Preload("Poll").
+		Order("created_at ASC, id ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
 func (r *messageRepository) Update(id uint, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
 	return r.db.
@@ -70,7 +73,10 @@ func (r *messageRepository) GetRoomMessages(roomID uint, cursor paginator.Cursor
 		Where("chat_room_id = ?", roomID).
 		Preload("Sender").
 		Preload("Reactions").
-		Preload("Reactions.User")
+		Preload("Reactions.User").
+		Preload("Poll.Options").
+		Preload("OriginalSender").
+		Preload("LinkPreview")
 
 	order := paginator.DESC
 	p := paginators.CreateMessagesPaginator(
@@ -202,7 +208,8 @@ func (r *messageRepository) GetRecentMedia(roomID uint, mediaType postgres.Messa
 	return messages, err
 }
 
-func (r *messageRepository) AddReaction(messageID, userID uint, emoji string) error {
+// AddReaction returns false if the user already reacted with the emoji
+func (r *messageRepository) AddReaction(messageID, userID uint, emoji string) (bool, error) {
 	reaction := &postgres.MessageReaction{
 		MessageID: messageID,
 		UserID:    userID,
@@ -210,13 +217,188 @@ func (r *messageRepository) AddReaction(messageID, userID uint, emoji string) er
 		ReactedAt: time.Now(),
 		CreatedAt: time.Now(),
 	}
-	return r.db.
-		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
-		FirstOrCreate(reaction).Error
+	result := r.db.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(reaction)
+	return result.RowsAffected > 0, result.Error
 }
 
-func (r *messageRepository) RemoveReaction(messageID, userID uint, emoji string) error {
-	return r.db.
+// RemoveReaction returns false if the user had not reacted with the emoji
+func (r *messageRepository) RemoveReaction(messageID, userID uint, emoji string) (bool, error) {
+	result := r.db.
 		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
-		Delete(&postgres.MessageReaction{}).Error
+		Delete(&postgres.MessageReaction{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetReactionSummaries counts the reactions of messages by emoji, the most used
+// first, and flags the emoji userID reacted with
+func (r *messageRepository) GetReactionSummaries(messageIDs []uint, userID uint) (map[uint][]postgres.ReactionCount, error) {
+	summaries := make(map[uint][]postgres.ReactionCount)
+	if len(messageIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []struct {
+		MessageID   uint
+		Emoji       string
+		Count       int
+		ReactedByMe bool
+	}
+	err := r.db.
+		Model(&postgres.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted_by_me", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("message_id, count DESC, MIN(created_at) ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		summaries[row.MessageID] = append(summaries[row.MessageID], postgres.ReactionCount{
+			Emoji:       row.Emoji,
+			Count:       row.Count,
+			ReactedByMe: row.ReactedByMe,
+		})
+	}
+	return summaries, nil
+}
+
+// GetReactionUsers lists the users who reacted to a message with an emoji, latest first
+func (r *messageRepository) GetReactionUsers(messageID uint, emoji string, cursor paginator.Cursor, limit int) ([]postgres.MessageReaction, paginator.Cursor, error) {
+	var reactions []postgres.MessageReaction
+	db := r.db.
+		Where("message_id = ? AND emoji = ?", messageID, emoji).
+		Preload("User").
+		Preload("User.Profile")
+
+	order := paginator.DESC
+	p := paginators.CreateReactionPaginator(
+		cursor,
+		&order,
+		&limit,
+	)
+
+	result, nextCursor, err := p.Paginate(db, &reactions)
+	if err != nil {
+		return nil, paginator.Cursor{}, err
+	}
+	if result.Error != nil {
+		return nil, paginator.Cursor{}, result.Error
+	}
+	return reactions, nextCursor, nil
+}
+
+func (r *messageRepository) GetExpiredMessages(before time.Time, limit int) ([]postgres.Message, error) {
+	var messages []postgres.Message
+	err := r.db.
+		Unscoped().
+		Where("expires_at IS NOT NULL AND expires_at <= ?", before).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// IsMediaReferenced tells whether a message still uses the file, forwards
+// share the media of the message they copy
+func (r *messageRepository) IsMediaReferenced(url string) (bool, error) {
+	var count int64
+	err := r.db.Model(&postgres.Message{}).
+		Unscoped().
+		Where("media_url = ? OR media_thumbnail = ?", url, url).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *messageRepository) HardDelete(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Detach replies and forwards pointing at the expired messages
+		if err := tx.Model(&postgres.Message{}).Unscoped().
+			Where("reply_to_id IN ?", ids).
+			Update("reply_to_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&postgres.Message{}).Unscoped().
+			Where("forwarded_from_id IN ?", ids).
+			Update("forwarded_from_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&postgres.ChatNotification{}).Unscoped().
+			Where("message_id IN ?", ids).
+			Update("message_id", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("message_id IN ?", ids).Delete(&postgres.PinnedMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id IN ?", ids).Delete(&postgres.MessageReaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id IN ?", ids).Delete(&postgres.MessageRead{}).Error; err != nil {
+			return err
+		}
+
+		// Polls of the expired messages go with them
+		pollIDs := tx.Model(&postgres.Poll{}).Select("id").Where("message_id IN ?", ids)
+		if err := tx.Where("poll_id IN (?)", pollIDs).Delete(&postgres.PollVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("poll_id IN (?)", pollIDs).Delete(&postgres.PollOption{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id IN ?", ids).Delete(&postgres.Poll{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&postgres.Message{}).Error
+	})
+}
+
+// GetLastSentAt returns when a user last posted in a room, nil if never
+func (r *messageRepository) GetLastSentAt(roomID, senderID uint) (*time.Time, error) {
+	var message postgres.Message
+	err := r.db.
+		Select("created_at").
+		Where("chat_room_id = ? AND sender_id = ? AND type <> ?", roomID, senderID, postgres.MessageTypeSystem).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	if message.CreatedAt.IsZero() {
+		return nil, nil
+	}
+	return &message.CreatedAt, nil
+}
+
+// GetForExport returns the next batch of room messages after afterID in sending
+// order, with what an export shows. Expired disappearing messages are left out.
+func (r *messageRepository) GetForExport(roomID, afterID uint, since *time.Time, until time.Time, limit int) ([]postgres.Message, error) {
+	var messages []postgres.Message
+	db := r.db.
+		Where("chat_room_id = ? AND id > ? AND created_at <= ?", roomID, afterID, until).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	if since != nil {
+		db = db.Where("created_at >= ?", *since)
+	}
+
+	err := db.
+		Preload("Reactions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("reacted_at ASC")
+		}).
+		Preload("ReplyTo").
+		Preload("Poll.Options").
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
 }
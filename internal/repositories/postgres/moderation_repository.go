@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"social_server/internal/models/paginators"
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChatRoomBan Repository Implementation
+type chatRoomBanRepository struct {
+	db *gorm.DB
+}
+
+func NewChatRoomBanRepository(db *gorm.DB) repositories.ChatRoomBanRepository {
+	return &chatRoomBanRepository{db: db}
+}
+
+// Create bans a user, banning an already banned user updates the reason
+func (r *chatRoomBanRepository) Create(ban *postgres.ChatRoomBan) error {
+	return r.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chat_room_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"banned_by", "reason"}),
+		}).
+		Create(ban).Error
+}
+
+func (r *chatRoomBanRepository) Delete(roomID, userID uint) error {
+	return r.db.
+		Where("chat_room_id = ? AND user_id = ?", roomID, userID).
+		Delete(&postgres.ChatRoomBan{}).Error
+}
+
+func (r *chatRoomBanRepository) IsBanned(roomID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.
+		Model(&postgres.ChatRoomBan{}).
+		Where("chat_room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *chatRoomBanRepository) GetRoomBans(roomID uint) ([]postgres.ChatRoomBan, error) {
+	var bans []postgres.ChatRoomBan
+	err := r.db.
+		Where("chat_room_id = ?", roomID).
+		Preload("User").
+		Order("created_at DESC").
+		Find(&bans).Error
+	return bans, err
+}
+
+// ChatModerationLog Repository Implementation
+type chatModerationLogRepository struct {
+	db *gorm.DB
+}
+
+func NewChatModerationLogRepository(db *gorm.DB) repositories.ChatModerationLogRepository {
+	return &chatModerationLogRepository{db: db}
+}
+
+func (r *chatModerationLogRepository) Create(entry *postgres.ChatModerationLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *chatModerationLogRepository) GetRoomLogs(roomID uint, cursor paginator.Cursor, limit int) ([]postgres.ChatModerationLog, paginator.Cursor, error) {
+	var entries []postgres.ChatModerationLog
+	dbQuery := r.db.
+		Where("chat_room_id = ?", roomID).
+		Preload("Actor").
+		Preload("TargetUser")
+
+	order := paginator.DESC
+	p := paginators.CreateNotificationPaginator(cursor, &order, &limit)
+	result, nextCursor, err := p.Paginate(dbQuery, &entries)
+	if err != nil {
+		return nil, paginator.Cursor{}, err
+	}
+
+	if result.Error != nil {
+		return nil, paginator.Cursor{}, result.Error
+	}
+
+	return entries, nextCursor, nil
+}
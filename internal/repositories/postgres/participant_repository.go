@@ -96,13 +96,14 @@ func (r *participantRepository) UpdateLastRead(roomID, userID uint) error {
 		}).Error
 }
 
-func (r *participantRepository) MuteParticipant(roomID, userID uint, isMuted bool) error {
+func (r *participantRepository) MuteParticipant(roomID, userID uint, isMuted bool, mutedUntil *time.Time) error {
 	return r.db.
 		Model(&postgres.Participant{}).
 		Where("chat_room_id = ? AND user_id = ?", roomID, userID).
 		Updates(map[string]interface{}{
-			"is_muted":   isMuted,
-			"updated_at": time.Now(),
+			"is_muted":    isMuted,
+			"muted_until": mutedUntil,
+			"updated_at":  time.Now(),
 		}).Error
 }
 
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"errors"
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PinnedMessage Repository Implementation
+type pinnedMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewPinnedMessageRepository(db *gorm.DB) repositories.PinnedMessageRepository {
+	return &pinnedMessageRepository{db: db}
+}
+
+func (r *pinnedMessageRepository) Create(pin *postgres.PinnedMessage) error {
+	return r.db.Create(pin).Error
+}
+
+// errPinLimitReached rolls back a pin over the limit of its room
+var errPinLimitReached = errors.New("pin limit reached")
+
+// CreateWithinLimit pins a message unless its room already has limit pins,
+// returns false then. The room is locked so concurrent pins count each other.
+func (r *pinnedMessageRepository) CreateWithinLimit(pin *postgres.PinnedMessage, limit int64) (bool, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var room postgres.ChatRoom
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&room, pin.ChatRoomID).Error
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		err = tx.
+			Model(&postgres.PinnedMessage{}).
+			Where("chat_room_id = ?", pin.ChatRoomID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count >= limit {
+			return errPinLimitReached
+		}
+		return tx.Create(pin).Error
+	})
+	if errors.Is(err, errPinLimitReached) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *pinnedMessageRepository) Delete(messageID uint) error {
+	return r.db.
+		Where("message_id = ?", messageID).
+		Delete(&postgres.PinnedMessage{}).Error
+}
+
+func (r *pinnedMessageRepository) GetByMessageID(messageID uint) (*postgres.PinnedMessage, error) {
+	var pin postgres.PinnedMessage
+	err := r.db.
+		Where("message_id = ?", messageID).
+		First(&pin).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+func (r *pinnedMessageRepository) GetRoomPins(roomID uint) ([]postgres.PinnedMessage, error) {
+	var pins []postgres.PinnedMessage
+	err := r.db.
+		Where("chat_room_id = ?", roomID).
+		Preload("Message").
+		Preload("Message.Sender").
+		Order("pinned_at DESC").
+		Find(&pins).Error
+	return pins, err
+}
+
+func (r *pinnedMessageRepository) GetRoomsPins(roomIDs []uint) (map[uint][]postgres.PinnedMessage, error) {
+	pinMap := make(map[uint][]postgres.PinnedMessage)
+	if len(roomIDs) == 0 {
+		return pinMap, nil
+	}
+
+	var pins []postgres.PinnedMessage
+	err := r.db.
+		Where("chat_room_id IN ?", roomIDs).
+		Preload("Message").
+		Order("pinned_at DESC").
+		Find(&pins).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pin := range pins {
+		pinMap[pin.ChatRoomID] = append(pinMap[pin.ChatRoomID], pin)
+	}
+	return pinMap, nil
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Poll Repository Implementation
+type pollRepository struct {
+	db *gorm.DB
+}
+
+func NewPollRepository(db *gorm.DB) repositories.PollRepository {
+	return &pollRepository{db: db}
+}
+
+func (r *pollRepository) Create(poll *postgres.Poll) error {
+	return r.db.Create(poll).Error
+}
+
+// CreateWithMessage creates a chat poll and the message carrying it in one
+// transaction, there is never one without the other
+func (r *pollRepository) CreateWithMessage(poll *postgres.Poll, message *postgres.Message) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		poll.ChatRoomID = &message.ChatRoomID
+		poll.MessageID = &message.ID
+		return tx.Create(poll).Error
+	})
+}
+
+func (r *pollRepository) GetByID(id uint) (*postgres.Poll, error) {
+	var poll postgres.Poll
+	err := r.db.
+		Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		First(&poll, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (r *pollRepository) Update(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.db.
+		Model(&postgres.Poll{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+// ReplaceVotes replaces the votes of a user in a poll, an empty optionIDs retracts them.
+// Returns false without changing anything if the poll is closed.
+func (r *pollRepository) ReplaceVotes(pollID, userID uint, optionIDs []uint, now time.Time) (bool, error) {
+	accepted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Touching the poll row locks it, so votes cannot race with closing
+		result := tx.
+			Model(&postgres.Poll{}).
+			Where("id = ? AND closed_at IS NULL AND (closes_at IS NULL OR closes_at > ?)", pollID, now).
+			Update("updated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		accepted = true
+
+		if err := tx.Where("poll_id = ? AND user_id = ?", pollID, userID).Delete(&postgres.PollVote{}).Error; err != nil {
+			return err
+		}
+		if len(optionIDs) == 0 {
+			return nil
+		}
+
+		votes := make([]postgres.PollVote, len(optionIDs))
+		for i, optionID := range optionIDs {
+			votes[i] = postgres.PollVote{
+				PollID:    pollID,
+				OptionID:  optionID,
+				UserID:    userID,
+				CreatedAt: now,
+			}
+		}
+		return tx.Create(&votes).Error
+	})
+	return accepted, err
+}
+
+// GetOptionVoteCounts returns the number of votes per option ID
+func (r *pollRepository) GetOptionVoteCounts(pollIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		OptionID uint
+		Count    int
+	}
+	err := r.db.
+		Model(&postgres.PollVote{}).
+		Select("option_id, COUNT(*) AS count").
+		Where("poll_id IN ?", pollIDs).
+		Group("option_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.OptionID] = row.Count
+	}
+	return counts, nil
+}
+
+// GetVoterCounts returns the number of distinct voters per poll ID
+func (r *pollRepository) GetVoterCounts(pollIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		PollID uint
+		Count  int
+	}
+	err := r.db.
+		Model(&postgres.PollVote{}).
+		Select("poll_id, COUNT(DISTINCT user_id) AS count").
+		Where("poll_id IN ?", pollIDs).
+		Group("poll_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.PollID] = row.Count
+	}
+	return counts, nil
+}
+
+// GetOptionVoters returns the voter IDs per option ID
+func (r *pollRepository) GetOptionVoters(pollIDs []uint) (map[uint][]uint, error) {
+	var votes []postgres.PollVote
+	err := r.db.
+		Select("option_id, user_id").
+		Where("poll_id IN ?", pollIDs).
+		Order("created_at ASC").
+		Find(&votes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	voters := make(map[uint][]uint)
+	for _, vote := range votes {
+		voters[vote.OptionID] = append(voters[vote.OptionID], vote.UserID)
+	}
+	return voters, nil
+}
+
+// GetUserVotes returns the option IDs voted by a user per poll ID
+func (r *pollRepository) GetUserVotes(pollIDs []uint, userID uint) (map[uint][]uint, error) {
+	var votes []postgres.PollVote
+	err := r.db.
+		Select("poll_id, option_id").
+		Where("poll_id IN ? AND user_id = ?", pollIDs, userID).
+		Find(&votes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	userVotes := make(map[uint][]uint)
+	for _, vote := range votes {
+		userVotes[vote.PollID] = append(userVotes[vote.PollID], vote.OptionID)
+	}
+	return userVotes, nil
+}
@@ -32,6 +32,8 @@ func (r *postRepository) GetByID(id uint) (*postgres.Post, error) {
 		Preload("Author").
 		Preload("Author.Profile").
 		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview").
 		Preload("Likes").
 		Preload("Comments").
 		Preload("Shares").
@@ -68,7 +70,9 @@ func (r *postRepository) GetUserPosts(currUserID uint, targetUserID *uint, priva
 	query = query.Where("privacy = ?", privacy).
 		Preload("Author").
 		Preload("Author.Profile").
-		Preload("Media")
+		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview")
 	order := paginator.DESC
 	p := paginators.CreatePostPaginator(cursor, &order, &limit)
 	result, nextCursor, err := p.Paginate(query, &posts)
@@ -88,7 +92,9 @@ func (r *postRepository) GetFeed(userID uint, cursor paginator.Cursor, limit int
 		Where("(friendships.user_id = ? AND friendships.status = ?) OR posts.privacy = ? OR posts.author_id = ?",
 			userID, "accepted", "public", userID).
 		Preload("Author").
-		Preload("Media")
+		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview")
 
 	order := paginator.DESC
 	p := paginators.CreatePostPaginator(cursor, &order, &limit)
@@ -109,7 +115,9 @@ func (r *postRepository) GetPublicFeed(cursor paginator.Cursor, limit int) ([]po
 	query := r.db.
 		Where("privacy = ?", "public").
 		Preload("Author").
-		Preload("Media")
+		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview")
 	order := paginator.DESC
 	p := paginators.CreatePostPaginator(cursor, &order, &limit)
 	result, nextCursor, err := p.Paginate(query, &posts)
@@ -162,7 +170,9 @@ func (r *postRepository) SearchPosts(query string, cursor paginator.Cursor, limi
 		Where("LOWER(content) LIKE ? OR LOWER(tags) LIKE ?", searchQuery, searchQuery).
 		Where("privacy = ?", "public").
 		Preload("Author").
-		Preload("Media")
+		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview")
 
 	order := paginator.DESC
 	p := paginators.CreatePostPaginator(cursor, &order, &limit)
@@ -186,7 +196,9 @@ func (r *postRepository) GetPostsByTag(tag string, cursor paginator.Cursor, limi
 		Where("LOWER(tags) LIKE ?", tagQuery).
 		Where("privacy = ?", "public").
 		Preload("Author").
-		Preload("Media")
+		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview")
 
 	order := paginator.DESC
 	p := paginators.CreatePostPaginator(cursor, &order, &limit)
@@ -231,6 +243,8 @@ func (r *postRepository) GetSavedPosts(userID uint) ([]postgres.Post, error) {
 		Where("saved_posts.user_id = ?", userID).
 		Preload("Author").
 		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview").
 		Order("saved_posts.created_at DESC").
 		Find(&posts).Error
 	return posts, err
@@ -320,6 +334,8 @@ func (r *postRepository) Search(query string, userID *uint, limit int) ([]postgr
 		Where("LOWER(content) LIKE ? OR LOWER(tags) LIKE ?", searchQuery, searchQuery).
 		Preload("Author").
 		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview").
 		Order("created_at DESC").
 		Limit(limit)
 
@@ -340,6 +356,8 @@ func (r *postRepository) GetTrending(limit int) ([]postgres.Post, error) {
 		Where("created_at > ?", time.Now().AddDate(0, 0, -7)). // Last 7 days
 		Preload("Author").
 		Preload("Media").
+		Preload("Poll.Options").
+		Preload("LinkPreview").
 		Order("(likes_count + comments_count + shares_count + views_count) DESC").
 		Limit(limit).
 		Find(&posts).Error
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduledMessage Repository Implementation
+type scheduledMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledMessageRepository(db *gorm.DB) repositories.ScheduledMessageRepository {
+	return &scheduledMessageRepository{db: db}
+}
+
+func (r *scheduledMessageRepository) Create(message *postgres.ScheduledMessage) error {
+	return r.db.Create(message).Error
+}
+
+func (r *scheduledMessageRepository) GetByID(id uint) (*postgres.ScheduledMessage, error) {
+	var message postgres.ScheduledMessage
+	err := r.db.First(&message, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *scheduledMessageRepository) Update(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.db.
+		Model(&postgres.ScheduledMessage{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+func (r *scheduledMessageRepository) GetUserRoomMessages(userID, roomID uint) ([]postgres.ScheduledMessage, error) {
+	var messages []postgres.ScheduledMessage
+	err := r.db.
+		Where("sender_id = ? AND chat_room_id = ? AND status = ?", userID, roomID, postgres.ScheduledMessageStatusPending).
+		Order("send_at ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *scheduledMessageRepository) GetDueMessages(before time.Time, limit int) ([]postgres.ScheduledMessage, error) {
+	var messages []postgres.ScheduledMessage
+	err := r.db.
+		Where("status = ? AND send_at <= ?", postgres.ScheduledMessageStatusPending, before).
+		Order("send_at ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// UpdateIfStatus updates a message still in status, returns false if it moved on
+func (r *scheduledMessageRepository) UpdateIfStatus(id uint, status postgres.ScheduledMessageStatus, updates map[string]interface{}) (bool, error) {
+	updates["updated_at"] = time.Now()
+	result := r.db.
+		Model(&postgres.ScheduledMessage{}).
+		Where("id = ? AND status = ?", id, status).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Claim marks a pending message as sending, returns false if another worker got it first
+func (r *scheduledMessageRepository) Claim(id uint) (bool, error) {
+	return r.UpdateIfStatus(id, postgres.ScheduledMessageStatusPending, map[string]interface{}{
+		"status": postgres.ScheduledMessageStatusSending,
+	})
+}
+
+// ReleaseStale puts messages stuck in sending (e.g. after a crash) back to pending
+func (r *scheduledMessageRepository) ReleaseStale(olderThan time.Time) error {
+	return r.db.
+		Model(&postgres.ScheduledMessage{}).
+		Where("status = ? AND updated_at < ?", postgres.ScheduledMessageStatusSending, olderThan).
+		Updates(map[string]interface{}{
+			"status":     postgres.ScheduledMessageStatusPending,
+			"updated_at": time.Now(),
+		}).Error
+}
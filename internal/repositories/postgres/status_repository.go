@@ -240,24 +240,39 @@ func (r *chatInviteRepository) GetRoomInvites(roomID uint, cursor paginator.Curs
 	return invites, nextCursor, nil
 }
 
-func (r *chatInviteRepository) AcceptInvite(inviteID uint) error {
-	return r.db.
-		Model(&postgres.ChatInvite{}).
-		Where("id = ?", inviteID).
-		Updates(map[string]interface{}{
-			"status":     "accepted",
-			"updated_at": time.Now(),
-		}).Error
+func (r *chatInviteRepository) GetPendingInvite(roomID, inviteeID uint) (*postgres.ChatInvite, error) {
+	var invite postgres.ChatInvite
+	err := r.db.
+		Where("chat_room_id = ? AND invitee_id = ? AND status = ? AND expires_at > ?", roomID, inviteeID, "pending", time.Now()).
+		First(&invite).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
 }
 
-func (r *chatInviteRepository) DeclineInvite(inviteID uint) error {
-	return r.db.
+// AcceptInvite accepts a pending invite, false when it was already answered
+func (r *chatInviteRepository) AcceptInvite(inviteID uint) (bool, error) {
+	return r.answerInvite(inviteID, "accepted")
+}
+
+// DeclineInvite declines a pending invite, false when it was already answered
+func (r *chatInviteRepository) DeclineInvite(inviteID uint) (bool, error) {
+	return r.answerInvite(inviteID, "declined")
+}
+
+func (r *chatInviteRepository) answerInvite(inviteID uint, status string) (bool, error) {
+	result := r.db.
 		Model(&postgres.ChatInvite{}).
-		Where("id = ?", inviteID).
+		Where("id = ? AND status = ?", inviteID, "pending").
 		Updates(map[string]interface{}{
-			"status":     "declined",
+			"status":     status,
 			"updated_at": time.Now(),
-		}).Error
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
 }
 
 func (r *chatInviteRepository) ExpireOldInvites(ctx context.Context) error {
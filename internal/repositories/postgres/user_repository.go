@@ -364,3 +364,14 @@ func (r *userRepository) IsBlocked(userID, targetID uint) (bool, error) {
 
 	return count > 0, err
 }
+
+// GetBotsByOwner returns the bot users managed by a user
+func (r *userRepository) GetBotsByOwner(ownerID uint) ([]postgres.User, error) {
+	var bots []postgres.User
+	err := r.db.
+		Where("is_bot = ? AND bot_owner_id = ?", true, ownerID).
+		Preload("Profile").
+		Order("created_at ASC").
+		Find(&bots).Error
+	return bots, err
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"social_server/internal/models/paginators"
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+	"time"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+)
+
+// ChatWebhook Repository Implementation
+type chatWebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewChatWebhookRepository(db *gorm.DB) repositories.ChatWebhookRepository {
+	return &chatWebhookRepository{db: db}
+}
+
+func (r *chatWebhookRepository) Create(webhook *postgres.ChatWebhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *chatWebhookRepository) GetByID(id uint) (*postgres.ChatWebhook, error) {
+	var webhook postgres.ChatWebhook
+	err := r.db.
+		Preload("Bot.Profile").
+		First(&webhook, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *chatWebhookRepository) GetByToken(token string) (*postgres.ChatWebhook, error) {
+	var webhook postgres.ChatWebhook
+	err := r.db.
+		Where("token = ? AND type = ?", token, postgres.ChatWebhookTypeIncoming).
+		First(&webhook).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *chatWebhookRepository) GetRoomWebhooks(roomID uint) ([]postgres.ChatWebhook, error) {
+	var webhooks []postgres.ChatWebhook
+	err := r.db.
+		Where("chat_room_id = ?", roomID).
+		Preload("Bot.Profile").
+		Order("created_at ASC").
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *chatWebhookRepository) GetActiveOutgoing(roomID uint) ([]postgres.ChatWebhook, error) {
+	var webhooks []postgres.ChatWebhook
+	err := r.db.
+		Where("chat_room_id = ? AND type = ? AND is_active = ?", roomID, postgres.ChatWebhookTypeOutgoing, true).
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *chatWebhookRepository) Delete(id uint) error {
+	return r.db.Delete(&postgres.ChatWebhook{}, id).Error
+}
+
+func (r *chatWebhookRepository) DeleteBotWebhooks(botID uint) error {
+	return r.db.
+		Where("bot_id = ?", botID).
+		Delete(&postgres.ChatWebhook{}).Error
+}
+
+// WebhookDelivery Repository Implementation
+type webhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookDeliveryRepository(db *gorm.DB) repositories.WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{db: db}
+}
+
+func (r *webhookDeliveryRepository) Create(delivery *postgres.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *webhookDeliveryRepository) GetByID(id uint) (*postgres.WebhookDelivery, error) {
+	var delivery postgres.WebhookDelivery
+	if err := r.db.First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *webhookDeliveryRepository) Update(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.db.
+		Model(&postgres.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+// GetDueDeliveries returns the pending deliveries whose next attempt is due
+func (r *webhookDeliveryRepository) GetDueDeliveries(before time.Time, limit int) ([]postgres.WebhookDelivery, error) {
+	var deliveries []postgres.WebhookDelivery
+	err := r.db.
+		Where("status = ? AND next_attempt_at <= ?", postgres.WebhookDeliveryStatusPending, before).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *webhookDeliveryRepository) GetWebhookDeliveries(webhookID uint, cursor paginator.Cursor, limit int) ([]postgres.WebhookDelivery, paginator.Cursor, error) {
+	var deliveries []postgres.WebhookDelivery
+	dbQuery := r.db.Where("webhook_id = ?", webhookID)
+
+	order := paginator.DESC
+	p := paginators.CreateNotificationPaginator(cursor, &order, &limit)
+	result, nextCursor, err := p.Paginate(dbQuery, &deliveries)
+	if err != nil {
+		return nil, paginator.Cursor{}, err
+	}
+
+	if result.Error != nil {
+		return nil, paginator.Cursor{}, result.Error
+	}
+
+	return deliveries, nextCursor, nil
+}
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"context"
+	"social_server/internal/bus"
 	"social_server/internal/config"
 	"social_server/internal/handlers"
 	"social_server/internal/middleware"
@@ -19,6 +21,7 @@ type Router struct {
 	friendHandler       *handlers.FriendHandler
 	postHandler         *handlers.PostHandler
 	chatHandler         *handlers.ChatHandler
+	pollHandler         *handlers.PollHandler
 	uploadHandler       *handlers.UploadHandler
 	searchHandler       *handlers.SearchHandler
 	callHandler         *handlers.CallHandler
@@ -34,12 +37,14 @@ func NewRouter(
 	friendService *services.FriendService,
 	postService *services.PostService,
 	chatService *services.ChatService,
+	pollService *services.PollService,
 	searchService *services.SearchService,
 	callService *services.CallService,
 	mailService *services.MailService,
 	onlineStatusService *services.OnlineStatusService,
+	cluster *bus.Cluster,
 ) *Router {
-	wsHandler := handlers.NewWebSocketHandler(authService, callService, chatService)
+	wsHandler := handlers.NewWebSocketHandler(authService, callService, chatService, friendService, onlineStatusService, postService, cluster, cfg.Realtime, cfg.Server.AllowedOrigins)
 
 	onlineStatusHandler := handlers.NewOnlineStatusHandler(onlineStatusService, authService)
 
@@ -51,6 +56,7 @@ func NewRouter(
 		friendHandler:       handlers.NewFriendHandler(friendService),
 		postHandler:         handlers.NewPostHandler(postService),
 		chatHandler:         handlers.NewChatHandler(chatService, userService),
+		pollHandler:         handlers.NewPollHandler(pollService),
 		uploadHandler:       handlers.NewUploadHandler("./uploads"),
 		searchHandler:       handlers.NewSearchHandler(searchService),
 		callHandler:         handlers.NewCallHandler(callService, wsHandler),
@@ -59,6 +65,11 @@ func NewRouter(
 	}
 }
 
+// Drain moves the realtime clients to other instances before shutdown
+func (r *Router) Drain(ctx context.Context) error {
+	return r.wsHandler.Drain(ctx)
+}
+
 func (r *Router) SetupRoutes() *gin.Engine {
 	// Set Gin mode
 	if r.config.IsProduction() {
@@ -111,6 +122,8 @@ func (r *Router) SetupRoutes() *gin.Engine {
 		r.setupFriendRoutes(v1)
 		r.setupPostRoutes(v1)
 		r.setupChatRoutes(v1)
+		r.setupHookRoutes(v1)
+		r.setupPollRoutes(v1)
 		r.setupSearchRoutes(v1)
 		r.setupCallRoutes(v1)
 		r.setupOnlineStatusRoutes(v1)
@@ -213,6 +226,17 @@ func (r *Router) setupPostRoutes(v1 *gin.RouterGroup) {
 
 }
 
+func (r *Router) setupPollRoutes(v1 *gin.RouterGroup) {
+	polls := v1.Group("/polls")
+	polls.Use(middleware.Auth())
+	{
+		polls.GET("/:id", r.pollHandler.GetPoll)
+		polls.POST("/:id/votes", r.pollHandler.Vote)
+		polls.DELETE("/:id/votes", r.pollHandler.RetractVote)
+		polls.POST("/:id/close", r.pollHandler.ClosePoll)
+	}
+}
+
 func (r *Router) setupChatRoutes(v1 *gin.RouterGroup) {
 	chat := v1.Group("/chat")
 	chat.Use(middleware.Auth())
@@ -225,6 +249,98 @@ func (r *Router) setupChatRoutes(v1 *gin.RouterGroup) {
 		// // chat.PUT("/rooms/:room_id", r.chatHandler.UpdateRoom)
 		chat.DELETE("/rooms/:id", r.chatHandler.DeleteRoom)
 		chat.GET("/rooms/sync", r.chatHandler.SyncRooms)
+		chat.PUT("/rooms/:id/settings", r.chatHandler.UpdateRoomSettings)
+		chat.PUT("/rooms/:id/disappearing", r.chatHandler.SetDisappearingTimer)
+
+		// Scheduled messages
+		chat.GET("/rooms/:id/scheduled", r.chatHandler.GetScheduledMessages)
+		chat.PUT("/scheduled/:id", r.chatHandler.UpdateScheduledMessage)
+		chat.DELETE("/scheduled/:id", r.chatHandler.CancelScheduledMessage)
+
+		// Polls
+		chat.POST("/rooms/:id/polls", r.chatHandler.CreatePoll)
+
+		// Voice notes
+		chat.POST("/rooms/:id/voice-notes", r.chatHandler.SendVoiceNote)
+
+		// Invites
+		chat.POST("/rooms/:id/invites", r.chatHandler.InviteUser)
+		chat.GET("/rooms/:id/invites", r.chatHandler.GetRoomInvites)
+		chat.GET("/invites", r.chatHandler.GetMyInvites)
+		chat.POST("/invites/:id/accept", r.chatHandler.AcceptInvite)
+		chat.POST("/invites/:id/decline", r.chatHandler.DeclineInvite)
+
+		// Invite links and join requests
+		chat.POST("/rooms/:id/invite-links", r.chatHandler.CreateInviteLink)
+		chat.GET("/rooms/:id/invite-links", r.chatHandler.GetInviteLinks)
+		chat.DELETE("/invite-links/:id", r.chatHandler.RevokeInviteLink)
+		chat.GET("/join/:token", r.chatHandler.GetInviteLinkPreview)
+		chat.POST("/join/:token", r.chatHandler.JoinByInviteLink)
+		chat.GET("/rooms/:id/join-requests", r.chatHandler.GetJoinRequests)
+		chat.POST("/join-requests/:id/approve", r.chatHandler.ApproveJoinRequest)
+		chat.POST("/join-requests/:id/reject", r.chatHandler.RejectJoinRequest)
+
+		// Moderation
+		chat.POST("/rooms/:id/members/:user_id/kick", r.chatHandler.KickMember)
+		chat.POST("/rooms/:id/members/:user_id/mute", r.chatHandler.MuteMember)
+		chat.DELETE("/rooms/:id/members/:user_id/mute", r.chatHandler.UnmuteMember)
+		chat.GET("/rooms/:id/bans", r.chatHandler.GetRoomBans)
+		chat.POST("/rooms/:id/bans/:user_id", r.chatHandler.BanMember)
+		chat.DELETE("/rooms/:id/bans/:user_id", r.chatHandler.UnbanMember)
+		chat.PUT("/rooms/:id/slow-mode", r.chatHandler.SetSlowMode)
+		chat.GET("/rooms/:id/moderation-log", r.chatHandler.GetModerationLog)
+
+		// Forwarding
+		chat.POST("/messages/forward", r.chatHandler.ForwardMessages)
+
+		// Live locations, positions are streamed over WebSocket
+		chat.GET("/rooms/:id/live-locations", r.chatHandler.GetLiveLocations)
+
+		// Bots and webhooks
+		chat.GET("/rooms/:id/participants", r.chatHandler.GetParticipants)
+		chat.POST("/bots", r.chatHandler.CreateBot)
+		chat.GET("/bots", r.chatHandler.GetMyBots)
+		chat.DELETE("/bots/:id", r.chatHandler.DeleteBot)
+		chat.POST("/rooms/:id/webhooks", r.chatHandler.CreateWebhook)
+		chat.GET("/rooms/:id/webhooks", r.chatHandler.GetRoomWebhooks)
+		chat.DELETE("/webhooks/:id", r.chatHandler.DeleteWebhook)
+		chat.GET("/webhooks/:id/deliveries", r.chatHandler.GetWebhookDeliveries)
+
+		// History exports
+		chat.POST("/rooms/:id/exports", r.chatHandler.RequestExport)
+		chat.GET("/rooms/:id/exports", r.chatHandler.GetExports)
+		chat.GET("/exports/:id", r.chatHandler.GetExport)
+		chat.GET("/exports/:id/download", r.chatHandler.DownloadExport)
+
+		// Reactions and custom emoji
+		chat.POST("/messages/:id/reactions", r.chatHandler.AddReaction)
+		chat.GET("/messages/:id/reactions", r.chatHandler.GetReactionUsers)
+		chat.DELETE("/messages/:id/reactions/:emoji", r.chatHandler.RemoveReaction)
+		chat.GET("/rooms/:id/emojis", r.chatHandler.GetRoomEmojis)
+		chat.POST("/rooms/:id/emojis", r.chatHandler.CreateRoomEmoji)
+		chat.DELETE("/rooms/:id/emojis/:emoji_id", r.chatHandler.DeleteRoomEmoji)
+
+		// Channels, subscribers are not participants
+		chat.POST("/channels", r.chatHandler.CreateChannel)
+		chat.GET("/channels", r.chatHandler.GetSubscribedChannels)
+		chat.GET("/channels/search", r.chatHandler.SearchChannels)
+		chat.GET("/channels/by-handle/:handle", r.chatHandler.GetChannelByHandle)
+		chat.POST("/channels/by-handle/:handle/subscribe", r.chatHandler.SubscribeChannelByHandle)
+		chat.GET("/channels/:id", r.chatHandler.GetChannel)
+		chat.POST("/channels/:id/subscribe", r.chatHandler.SubscribeChannel)
+		chat.DELETE("/channels/:id/subscribe", r.chatHandler.UnsubscribeChannel)
+		chat.GET("/channels/:id/messages", r.chatHandler.GetChannelMessages)
+		chat.POST("/channels/:id/views", r.chatHandler.RecordChannelViews)
+		chat.POST("/channels/:id/admins/:user_id", r.chatHandler.AddChannelAdmin)
+		chat.DELETE("/channels/:id/admins/:user_id", r.chatHandler.RemoveChannelAdmin)
+
+		// Slash commands, run when a message starts with one
+		chat.GET("/commands", r.chatHandler.GetSlashCommands)
+
+		// Pinned messages
+		chat.GET("/rooms/:id/pins", r.chatHandler.GetPinnedMessages)
+		chat.POST("/messages/:id/pin", r.chatHandler.PinMessage)
+		chat.DELETE("/messages/:id/pin", r.chatHandler.UnpinMessage)
 
 		// // Message management
 		// chat.GET("/rooms/:room_id/messages", r.chatHandler.GetMessages)
@@ -234,18 +350,25 @@ func (r *Router) setupChatRoutes(v1 *gin.RouterGroup) {
 		// chat.POST("/messages/:message_id/read", r.chatHandler.MarkMessageRead)
 
 		// // Participant management
-		// chat.GET("/rooms/:room_id/participants", r.chatHandler.GetParticipants)
 		// chat.POST("/rooms/:room_id/participants", r.chatHandler.AddParticipant)
 		// chat.DELETE("/rooms/:room_id/participants/:user_id", r.chatHandler.RemoveParticipant)
 		// // chat.PUT("/rooms/:room_id/participants/:user_id/role", r.chatHandler.UpdateParticipantRole)
 
-		// // Reactions and typing
-		// chat.POST("/messages/:message_id/reactions", r.chatHandler.AddReaction)
-		// chat.DELETE("/messages/:message_id/reactions/:emoji", r.chatHandler.RemoveReaction)
+		// // Typing
 		// chat.POST("/rooms/:room_id/typing", r.chatHandler.SetTyping)
 	}
 }
 
+// setupHookRoutes registers the incoming webhook endpoint, authenticated by the token in the URL
+func (r *Router) setupHookRoutes(v1 *gin.RouterGroup) {
+	hooks := v1.Group("/hooks")
+	{
+		hooks.Use(middleware.RateLimitByIP(60, 10)) // 60 requests per minute, burst of 10
+
+		hooks.POST("/:token", r.chatHandler.ReceiveIncomingWebhook)
+	}
+}
+
 func (r *Router) setupSearchRoutes(v1 *gin.RouterGroup) {
 	search := v1.Group("/search")
 	{
@@ -283,6 +406,19 @@ func (r *Router) setupCallRoutes(v1 *gin.RouterGroup) {
 		callGroup.GET("/calls", r.wsHandler.HandleWebSocket)
 	}
 
+	// Schema of the websocket protocol, public so clients can generate code
+	v1.GET("/ws/schema", r.wsHandler.GetProtocolSchema)
+
+	// The same events for clients whose proxies block websockets, actions
+	// go over the REST routes
+	events := v1.Group("/events")
+	events.Use(middleware.Auth())
+	{
+		events.GET("/stream", r.wsHandler.StreamEvents)
+		events.GET("/poll", r.wsHandler.PollEvents)
+		events.POST("/ack", r.wsHandler.AckEvents)
+	}
+
 	// v1.GET("/ws/chat", middleware.Auth(), r.chatHandler.HandleWebSocket)
 }
 
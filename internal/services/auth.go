@@ -287,6 +287,12 @@ func (s *AuthService) Login(req *requests.LoginRequest, ipAddress, userAgent str
 		return nil, fmt.Errorf("account is inactive")
 	}
 
+	// Bots have no password, they post through webhooks
+	if user.IsBot {
+		s.logLoginAttempt(req.EmailOrUsername, ipAddress, userAgent, false, "bot_account")
+		return nil, fmt.Errorf("invalid credentials")
+	}
+
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
@@ -12,6 +12,10 @@ import (
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 )
 
+// CallMigrationTimeout is how long a call waits for its parties to come back
+// after their node went away, it is ended afterwards
+const CallMigrationTimeout = 30 * time.Second
+
 type CallService struct {
 	callRepo repositories.CallRepository
 	userRepo repositories.UserRepository
@@ -46,7 +50,7 @@ func (s *CallService) CreateCall(callerID, calleeID uint, callType, roomID strin
 	}
 
 	// Check if caller has active call
-	activeCall, err := s.callRepo.GetActiveCall(callerID)
+	activeCall, err := s.GetActiveCall(callerID)
 	if err == nil && activeCall != nil {
 		// TODO: Implement logic to handle active call for caller
 		return activeCall, nil
@@ -54,7 +58,7 @@ func (s *CallService) CreateCall(callerID, calleeID uint, callType, roomID strin
 	}
 
 	// Check if callee has active call
-	activeCall, err = s.callRepo.GetActiveCall(calleeID)
+	activeCall, err = s.GetActiveCall(calleeID)
 	if err == nil && activeCall != nil {
 		return nil, fmt.Errorf("callee already in active call")
 	}
@@ -154,25 +158,33 @@ func (s *CallService) EndCall(callID uint) error {
 		return nil // Already ended
 	}
 
+	err = s.callRepo.Update(callID, endedCallUpdates(call))
+	if err != nil {
+		return fmt.Errorf("failed to update call status: %w", err)
+	}
+
+	return nil
+}
+
+// endedCallUpdates ends a call with its duration, a migrating call stopped
+// when it started migrating
+func endedCallUpdates(call *postgres.Call) map[string]interface{} {
+	endedAt := time.Now()
+	if call.Status == string(constants.CallStatusMigrating) {
+		endedAt = migrationStart(call)
+	}
+
 	// Calculate duration if call was ongoing
 	var duration int
 	if call.StartedAt != nil {
-		duration = int(time.Since(*call.StartedAt).Seconds())
+		duration = int(endedAt.Sub(*call.StartedAt).Seconds())
 	}
 
-	// Update call status
-	updates := map[string]interface{}{
+	return map[string]interface{}{
 		"status":   string(constants.CallStatusEnded),
-		"ended_at": time.Now(),
+		"ended_at": endedAt,
 		"duration": duration,
 	}
-
-	err = s.callRepo.Update(callID, updates)
-	if err != nil {
-		return fmt.Errorf("failed to update call status: %w", err)
-	}
-
-	return nil
 }
 
 func (s *CallService) GetCallByID(callID uint) (*postgres.Call, error) {
@@ -183,8 +195,20 @@ func (s *CallService) GetUserCalls(userID uint, cursor paginator.Cursor, limit i
 	return s.callRepo.GetUserCalls(userID, cursor, limit)
 }
 
+// GetActiveCall returns the call the user is in. A call that stayed
+// migrating past CallMigrationTimeout is ended instead.
 func (s *CallService) GetActiveCall(userID uint) (*postgres.Call, error) {
-	return s.callRepo.GetActiveCall(userID)
+	call, err := s.callRepo.GetActiveCall(userID)
+	if err != nil {
+		return nil, err
+	}
+	if migrationExpired(call, time.Now()) {
+		if _, err := s.endMigratedCall(call); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no active call")
+	}
+	return call, nil
 }
 
 func (s *CallService) GetCallParticipants(callID uint) ([]postgres.CallParticipant, error) {
@@ -197,13 +221,110 @@ func (s *CallService) JoinCall(callID, userID uint) error {
 		return fmt.Errorf("call not found: %w", err)
 	}
 
-	if call.Status != string(constants.CallStatusOngoing) && call.Status != string(constants.CallStatusRinging) {
+	if call.Status != string(constants.CallStatusOngoing) && call.Status != string(constants.CallStatusRinging) &&
+		call.Status != string(constants.CallStatusMigrating) {
 		return fmt.Errorf("call is not active")
 	}
 
 	return s.callRepo.JoinCall(callID, userID)
 }
 
+// MigrateCall marks an ongoing call as migrating while a party reconnects to
+// another node, it is not ended nor its duration reset
+func (s *CallService) MigrateCall(callID uint) error {
+	call, err := s.callRepo.GetByID(callID)
+	if err != nil {
+		return fmt.Errorf("call not found: %w", err)
+	}
+
+	if call.Status != string(constants.CallStatusOngoing) {
+		return fmt.Errorf("call is not ongoing")
+	}
+
+	migrated, err := s.callRepo.UpdateIfStatus(callID, string(constants.CallStatusOngoing), map[string]interface{}{
+		"status":      string(constants.CallStatusMigrating),
+		"migrated_at": time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update call status: %w", err)
+	}
+	if !migrated {
+		return fmt.Errorf("call is not ongoing")
+	}
+
+	return nil
+}
+
+// ResumeMigratedCall puts back the migrating call of the user once they
+// rejoin its room, it returns nil when there is none
+func (s *CallService) ResumeMigratedCall(userID uint, roomID string) (*postgres.Call, error) {
+	call, err := s.GetActiveCall(userID)
+	if err != nil || call.RoomID != roomID || call.Status != string(constants.CallStatusMigrating) {
+		return nil, nil
+	}
+
+	resumed, err := s.callRepo.UpdateIfStatus(call.ID, string(constants.CallStatusMigrating), map[string]interface{}{
+		"status":      string(constants.CallStatusOngoing),
+		"migrated_at": nil,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update call status: %w", err)
+	}
+	if !resumed {
+		// Ended by its deadline meanwhile
+		return nil, nil
+	}
+
+	call.Status = string(constants.CallStatusOngoing)
+	call.MigratedAt = nil
+	return call, nil
+}
+
+// EndExpiredMigrations ends the calls that stayed migrating past
+// CallMigrationTimeout and returns them, each once across the nodes
+func (s *CallService) EndExpiredMigrations() ([]postgres.Call, error) {
+	calls, err := s.callRepo.GetMigratingCalls(time.Now().Add(-CallMigrationTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migrating calls: %w", err)
+	}
+
+	var ended []postgres.Call
+	for i := range calls {
+		endedHere, err := s.endMigratedCall(&calls[i])
+		if err != nil {
+			return ended, err
+		}
+		if endedHere {
+			ended = append(ended, calls[i])
+		}
+	}
+	return ended, nil
+}
+
+// endMigratedCall ends a migrating call, false when it was resumed or ended
+// elsewhere first
+func (s *CallService) endMigratedCall(call *postgres.Call) (bool, error) {
+	ended, err := s.callRepo.UpdateIfStatus(call.ID, string(constants.CallStatusMigrating), endedCallUpdates(call))
+	if err != nil {
+		return false, fmt.Errorf("failed to end migrating call: %w", err)
+	}
+	return ended, nil
+}
+
+// migrationExpired tells whether a migrating call is past its deadline
+func migrationExpired(call *postgres.Call, now time.Time) bool {
+	return call.Status == string(constants.CallStatusMigrating) && now.Sub(migrationStart(call)) >= CallMigrationTimeout
+}
+
+// migrationStart is when a migrating call started migrating, calls migrated
+// before it was recorded fall back to their last update
+func migrationStart(call *postgres.Call) time.Time {
+	if call.MigratedAt != nil {
+		return *call.MigratedAt
+	}
+	return call.UpdatedAt
+}
+
 func (s *CallService) LeaveCall(callID, userID uint) error {
 	_, err := s.callRepo.GetByID(callID)
 	if err != nil {
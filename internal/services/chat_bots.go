@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"strings"
+	"time"
+)
+
+const MaxBotsPerUser = 10
+
+// CreateBot creates a bot user managed by ownerID. Bots cannot log in, they
+// post through the webhooks of the rooms they are added to.
+func (s *ChatService) CreateBot(ownerID uint, req requests.CreateBotRequest) (*postgres.User, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("bot name is required")
+	}
+
+	owner, err := s.repos.User.GetByID(ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if owner.IsBot {
+		return nil, fmt.Errorf("permission denied: bots cannot create bots")
+	}
+
+	bots, err := s.repos.User.GetBotsByOwner(ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bots: %w", err)
+	}
+	if len(bots) >= MaxBotsPerUser {
+		return nil, fmt.Errorf("a user can have at most %d bots", MaxBotsPerUser)
+	}
+
+	// Bots need a unique email, they never receive mail
+	handle, err := randomHex(8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+
+	bot := &postgres.User{
+		Email:      fmt.Sprintf("bot-%s@bots.invalid", handle),
+		IsActive:   true,
+		IsVerified: true,
+		IsBot:      true,
+		BotOwnerID: &ownerID,
+		Settings: postgres.UserSettings{
+			PrivacyProfileVisibility:   "public",
+			PrivacyShowOnlineStatus:    false,
+			PrivacyAllowFriendRequests: false,
+		},
+	}
+	if _, err := s.repos.User.Create(bot); err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+
+	profile, err := s.repos.Profile.Create(&postgres.Profile{
+		UserID:      bot.ID,
+		DisplayName: name,
+		Avatar:      req.Avatar,
+		Bio:         req.Description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot profile: %w", err)
+	}
+	bot.Profile = profile
+
+	return bot, nil
+}
+
+// GetMyBots returns the bots managed by a user
+func (s *ChatService) GetMyBots(ownerID uint) ([]postgres.User, error) {
+	bots, err := s.repos.User.GetBotsByOwner(ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bots: %w", err)
+	}
+	return bots, nil
+}
+
+// DeleteBot removes a bot from its rooms, deletes its webhooks and the bot
+func (s *ChatService) DeleteBot(ownerID, botID uint) error {
+	if _, err := s.getOwnedBot(ownerID, botID); err != nil {
+		return err
+	}
+
+	participations, err := s.repos.Participant.GetUserParticipations(botID)
+	if err != nil {
+		return fmt.Errorf("failed to get bot rooms: %w", err)
+	}
+	for _, participation := range participations {
+		if err := s.repos.ChatRoom.RemoveParticipant(participation.ChatRoomID, botID); err != nil {
+			return fmt.Errorf("failed to remove bot from room %d: %w", participation.ChatRoomID, err)
+		}
+	}
+
+	if err := s.repos.ChatWebhook.DeleteBotWebhooks(botID); err != nil {
+		return fmt.Errorf("failed to delete bot webhooks: %w", err)
+	}
+
+	if err := s.repos.User.Delete(botID); err != nil {
+		return fmt.Errorf("failed to delete bot: %w", err)
+	}
+	return nil
+}
+
+// getOwnedBot loads a bot managed by ownerID
+func (s *ChatService) getOwnedBot(ownerID, botID uint) (*postgres.User, error) {
+	bot, err := s.repos.User.GetByID(botID)
+	if err != nil || !bot.IsBot {
+		return nil, fmt.Errorf("bot not found")
+	}
+	if bot.BotOwnerID == nil || *bot.BotOwnerID != ownerID {
+		return nil, fmt.Errorf("permission denied: you do not manage this bot")
+	}
+	return bot, nil
+}
+
+// addBotToRoom makes a bot a member of a room, nothing happens if it already is
+func (s *ChatService) addBotToRoom(roomID, botID, addedBy uint) error {
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, botID); err == nil {
+		return nil
+	}
+	if err := s.checkNotBanned(roomID, botID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	participant := &postgres.Participant{
+		ChatRoomID: roomID,
+		UserID:     botID,
+		Role:       postgres.ParticipantRoleMember,
+		JoinedAt:   now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	added, err := s.repos.ChatRoom.AddParticipant(participant)
+	if err != nil {
+		return fmt.Errorf("failed to add bot: %w", err)
+	}
+	if !added {
+		return nil
+	}
+
+	if _, err := s.createSystemMessage(roomID, addedBy, "Added a bot"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// postBotMessage posts a message as a bot. Bot messages never trigger
+// outgoing webhooks, so bots cannot loop on each other.
+func (s *ChatService) postBotMessage(botID, roomID uint, content string) (*postgres.Message, error) {
+	message, err := s.CreateMessage(botID, models.SendChatMessageMessage{
+		RoomID:    roomID,
+		Content:   content,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.deliverMessage(message)
+	return message, nil
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/models/responses"
+	"strings"
+	"time"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+)
+
+const MaxChannelSearchResults = 50
+
+var channelHandlePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{3,31}$`)
+
+// CreateChannel creates a channel owned by ownerID. Public channels need a
+// handle, they can be found through search and anyone can subscribe.
+func (s *ChatService) CreateChannel(ownerID uint, req requests.CreateChannelRequest) (*responses.ChannelResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("channel name is required")
+	}
+
+	var handle *string
+	if req.Handle != "" {
+		normalized, err := normalizeChannelHandle(req.Handle)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.repos.Channel.GetByHandle(normalized); err == nil {
+			return nil, fmt.Errorf("handle @%s is already taken", normalized)
+		}
+		handle = &normalized
+	}
+	if req.IsPublic && handle == nil {
+		return nil, fmt.Errorf("public channels need a handle")
+	}
+
+	room := &postgres.ChatRoom{
+		Name:        name,
+		CreatedBy:   ownerID,
+		Description: req.Description,
+		Avatar:      req.Avatar,
+		Handle:      handle,
+		IsPublic:    req.IsPublic,
+	}
+	if err := s.repos.Channel.Create(room); err != nil {
+		return nil, fmt.Errorf("failed to create channel: %w", err)
+	}
+
+	return s.GetChannel(room.ID, ownerID)
+}
+
+// GetChannel returns a channel the user can read: a public channel, or one
+// they follow or manage
+func (s *ChatService) GetChannel(roomID, userID uint) (*responses.ChannelResponse, error) {
+	room, err := s.getChannel(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return s.channelResponse(room, userID, true)
+}
+
+// GetChannelByHandle returns the channel with a public handle
+func (s *ChatService) GetChannelByHandle(handle string, userID uint) (*responses.ChannelResponse, error) {
+	room, err := s.getChannelByHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	return s.channelResponse(room, userID, true)
+}
+
+// SubscribeChannel makes a user follow a public channel. Private channels are
+// joined through their invites and invite links.
+func (s *ChatService) SubscribeChannel(roomID, userID uint) (*responses.ChannelResponse, error) {
+	room, err := s.getChannel(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscribePublicChannel(room, userID)
+}
+
+// SubscribeChannelByHandle makes a user follow a public channel by its handle
+func (s *ChatService) SubscribeChannelByHandle(handle string, userID uint) (*responses.ChannelResponse, error) {
+	room, err := s.getChannelByHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscribePublicChannel(room, userID)
+}
+
+// UnsubscribeChannel stops following a channel
+func (s *ChatService) UnsubscribeChannel(roomID, userID uint) error {
+	if _, err := s.getChannel(roomID); err != nil {
+		return err
+	}
+
+	removed, err := s.repos.Channel.Unsubscribe(roomID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+	if !removed {
+		return fmt.Errorf("not subscribed to this channel")
+	}
+
+	s.emitSubscriptionChange(roomID, userID, models.MessageTypeChannelUnsubscribed)
+	s.emitAccessLost(roomID, userID)
+	return nil
+}
+
+// GetSubscribedChannels lists the channels a user follows
+func (s *ChatService) GetSubscribedChannels(userID uint) ([]responses.ChannelResponse, error) {
+	subscriptions, err := s.repos.Channel.GetUserSubscriptions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	channels := make([]responses.ChannelResponse, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		if subscription.ChatRoom == nil {
+			continue
+		}
+		channel := newChannelResponse(subscription.ChatRoom)
+		channel.IsSubscribed = true
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+// GetSubscribedChannelIDs returns the IDs of the channels a user follows, the
+// realtime transports use it to route channel events
+func (s *ChatService) GetSubscribedChannelIDs(userID uint) ([]uint, error) {
+	ids, err := s.repos.Channel.GetUserChannelIDs(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	return ids, nil
+}
+
+// SearchChannels finds public channels by name, handle or description
+func (s *ChatService) SearchChannels(userID uint, req requests.SearchChannelsRequest) ([]responses.ChannelResponse, error) {
+	if req.Limit < 1 || req.Limit > MaxChannelSearchResults {
+		req.Limit = 20
+	}
+
+	query := strings.TrimPrefix(strings.TrimSpace(req.Query), "@")
+	rooms, err := s.repos.Channel.SearchPublic(query, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search channels: %w", err)
+	}
+
+	channels := make([]responses.ChannelResponse, 0, len(rooms))
+	for i := range rooms {
+		channel, err := s.channelResponse(&rooms[i], userID, false)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, *channel)
+	}
+	return channels, nil
+}
+
+// GetChannelMessages returns the messages of a channel the user can read
+func (s *ChatService) GetChannelMessages(roomID, userID uint, req requests.GetChannelMessagesRequest) (*responses.MessageResponse, error) {
+	room, err := s.getChannel(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCanReadChannel(room, userID); err != nil {
+		return nil, err
+	}
+
+	cursor := paginator.Cursor{
+		Before: &req.Before,
+		After:  &req.After,
+	}
+	messages, next, err := s.repos.Message.GetRoomMessages(roomID, cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel messages: %w", err)
+	}
+	s.attachMessagePolls(messages, userID)
+	s.attachMessageReactions(messages, userID)
+
+	// Subscribers do not see each other, they get the counts of reactions
+	// and votes but not who reacted or voted
+	if !s.isRoomAdmin(roomID, userID) {
+		for i := range messages {
+			messages[i].Reactions = []postgres.MessageReaction{}
+			if messages[i].Poll != nil {
+				hidePollVoters(messages[i].Poll)
+			}
+		}
+	}
+
+	return &responses.MessageResponse{
+		Messages:   messages,
+		NextCursor: &next,
+	}, nil
+}
+
+// RecordChannelViews counts the user as a viewer of channel messages, each
+// user is counted once per message. Returns the view counts.
+func (s *ChatService) RecordChannelViews(roomID, userID uint, messageIDs []uint) (map[uint]int, error) {
+	room, err := s.getChannel(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCanReadChannel(room, userID); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repos.Channel.RecordViews(roomID, userID, messageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record views: %w", err)
+	}
+	return counts, nil
+}
+
+// AddChannelAdmin makes a user an admin of a channel, owner only
+func (s *ChatService) AddChannelAdmin(roomID, ownerID, userID uint) error {
+	room, err := s.getChannel(roomID)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.repos.Participant.GetByRoomAndUser(roomID, ownerID)
+	if err != nil || owner.Role != postgres.ParticipantRoleOwner {
+		return fmt.Errorf("permission denied: only the owner can add admins")
+	}
+	if _, err := s.repos.User.GetByID(userID); err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if err := s.checkNotBanned(roomID, userID); err != nil {
+		return err
+	}
+
+	if participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err == nil {
+		if participant.Role != postgres.ParticipantRoleMember {
+			return fmt.Errorf("user is already an admin")
+		}
+		return s.repos.Participant.UpdateRole(roomID, userID, postgres.ParticipantRoleAdmin)
+	}
+
+	now := time.Now()
+	added, err := s.repos.ChatRoom.AddParticipant(&postgres.Participant{
+		ChatRoomID: room.ID,
+		UserID:     userID,
+		Role:       postgres.ParticipantRoleAdmin,
+		JoinedAt:   now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add admin: %w", err)
+	}
+	if !added {
+		return fmt.Errorf("user is already an admin")
+	}
+	return nil
+}
+
+// RemoveChannelAdmin removes an admin from the staff of a channel, owner only.
+// The admin keeps following the channel if they subscribed.
+func (s *ChatService) RemoveChannelAdmin(roomID, ownerID, userID uint) error {
+	if _, err := s.getChannel(roomID); err != nil {
+		return err
+	}
+
+	owner, err := s.repos.Participant.GetByRoomAndUser(roomID, ownerID)
+	if err != nil || owner.Role != postgres.ParticipantRoleOwner {
+		return fmt.Errorf("permission denied: only the owner can remove admins")
+	}
+	if ownerID == userID {
+		return fmt.Errorf("the owner cannot be removed")
+	}
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+		return fmt.Errorf("user is not an admin of this channel")
+	}
+
+	if err := s.repos.ChatRoom.RemoveParticipant(roomID, userID); err != nil {
+		return fmt.Errorf("failed to remove admin: %w", err)
+	}
+	s.stopUserLiveLocations(roomID, userID)
+	s.emitAccessLost(roomID, userID)
+	return nil
+}
+
+func (s *ChatService) subscribePublicChannel(room *postgres.ChatRoom, userID uint) (*responses.ChannelResponse, error) {
+	if !room.IsPublic {
+		return nil, fmt.Errorf("permission denied: this channel can only be joined with an invite")
+	}
+	if err := s.subscribeChannel(room.ID, userID); err != nil {
+		return nil, err
+	}
+	return s.channelResponse(room, userID, false)
+}
+
+// subscribeChannel adds a subscriber, also used when an invite to a channel is accepted
+func (s *ChatService) subscribeChannel(roomID, userID uint) error {
+	if err := s.checkNotBanned(roomID, userID); err != nil {
+		return err
+	}
+
+	added, err := s.repos.Channel.Subscribe(roomID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	if added {
+		s.emitSubscriptionChange(roomID, userID, models.MessageTypeChannelSubscribed)
+	}
+	return nil
+}
+
+// removeChannelSubscriber drops the subscription of a banned user
+func (s *ChatService) removeChannelSubscriber(roomID, userID uint) {
+	removed, err := s.repos.Channel.Unsubscribe(roomID, userID)
+	if err != nil {
+		log.Printf("Failed to unsubscribe user %d from channel %d: %v", userID, roomID, err)
+		return
+	}
+	if removed {
+		s.emitSubscriptionChange(roomID, userID, models.MessageTypeChannelUnsubscribed)
+	}
+}
+
+// emitSubscriptionChange tells the transports of the user to start or stop
+// routing the events of the channel to them
+func (s *ChatService) emitSubscriptionChange(roomID, userID uint, eventType models.MessageType) {
+	s.emit(ChatEvent{
+		Type:    eventType,
+		RoomID:  roomID,
+		UserIDs: []uint{userID},
+		Data:    map[string]uint{"channel_id": roomID},
+	})
+}
+
+// checkCanReadChannel allows the staff and the subscribers of a channel, and
+// anyone for public channels
+func (s *ChatService) checkCanReadChannel(room *postgres.ChatRoom, userID uint) error {
+	if room.IsPublic {
+		return nil
+	}
+	if _, err := s.repos.Participant.GetByRoomAndUser(room.ID, userID); err == nil {
+		return nil
+	}
+
+	subscribed, err := s.repos.Channel.IsSubscribed(room.ID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if !subscribed {
+		return fmt.Errorf("permission denied: not subscribed to this channel")
+	}
+	return nil
+}
+
+func (s *ChatService) channelResponse(room *postgres.ChatRoom, userID uint, checkAccess bool) (*responses.ChannelResponse, error) {
+	channel := newChannelResponse(room)
+
+	if participant, err := s.repos.Participant.GetByRoomAndUser(room.ID, userID); err == nil {
+		channel.Role = participant.Role
+	}
+	subscribed, err := s.repos.Channel.IsSubscribed(room.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subscription: %w", err)
+	}
+	channel.IsSubscribed = subscribed
+
+	if checkAccess && !room.IsPublic && channel.Role == "" && !subscribed {
+		return nil, fmt.Errorf("channel not found")
+	}
+	return &channel, nil
+}
+
+// getChannel loads a room that must be a channel
+func (s *ChatService) getChannel(roomID uint) (*postgres.ChatRoom, error) {
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil || room.Type != postgres.ChatRoomTypeChannel {
+		return nil, fmt.Errorf("channel not found")
+	}
+	return room, nil
+}
+
+func (s *ChatService) getChannelByHandle(handle string) (*postgres.ChatRoom, error) {
+	normalized, err := normalizeChannelHandle(handle)
+	if err != nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+
+	room, err := s.repos.Channel.GetByHandle(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+	return room, nil
+}
+
+// roomType returns the type of a room, cached since it never changes
+func (s *ChatService) roomType(roomID uint) postgres.ChatRoomType {
+	if roomType, ok := s.roomTypes.Load(roomID); ok {
+		return roomType.(postgres.ChatRoomType)
+	}
+
+	roomType, err := s.repos.ChatRoom.GetRoomType(roomID)
+	if err != nil {
+		return ""
+	}
+	s.roomTypes.Store(roomID, roomType)
+	return roomType
+}
+
+func newChannelResponse(room *postgres.ChatRoom) responses.ChannelResponse {
+	return responses.ChannelResponse{
+		ID:              room.ID,
+		Name:            room.Name,
+		Description:     room.Description,
+		Avatar:          room.Avatar,
+		Handle:          room.Handle,
+		IsPublic:        room.IsPublic,
+		SubscriberCount: room.SubscriberCount,
+		LastActivity:    room.LastActivity,
+		CreatedAt:       room.CreatedAt,
+	}
+}
+
+// normalizeChannelHandle lower-cases a handle and drops its @
+func normalizeChannelHandle(handle string) (string, error) {
+	normalized := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	if !channelHandlePattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid handle, use 4 to 32 letters, digits or underscores starting with a letter")
+	}
+	return normalized, nil
+}
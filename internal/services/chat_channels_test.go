@@ -0,0 +1,168 @@
+package services
+
+import (
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/repositories"
+	"testing"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+)
+
+// Stubs of the repositories a channel is read through, methods they do not
+// override panic
+
+type channelRoomStub struct {
+	repositories.ChatRoomRepository
+	room *postgres.ChatRoom
+}
+
+func (r *channelRoomStub) GetByID(id uint) (*postgres.ChatRoom, error) {
+	if id != r.room.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.room, nil
+}
+
+type channelParticipantStub struct {
+	repositories.ParticipantRepository
+	roles map[uint]postgres.ParticipantRole
+}
+
+func (r *channelParticipantStub) GetByRoomAndUser(roomID, userID uint) (*postgres.Participant, error) {
+	role, exists := r.roles[userID]
+	if !exists {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &postgres.Participant{ChatRoomID: roomID, UserID: userID, Role: role}, nil
+}
+
+type channelSubscriptionStub struct {
+	repositories.ChannelRepository
+	subscribers map[uint]bool
+}
+
+func (r *channelSubscriptionStub) IsSubscribed(roomID, userID uint) (bool, error) {
+	return r.subscribers[userID], nil
+}
+
+// channelMessageStub returns the same history on every read, as loaded with
+// its preloads
+type channelMessageStub struct {
+	repositories.MessageRepository
+	history func() []postgres.Message
+}
+
+func (r *channelMessageStub) GetRoomMessages(roomID uint, cursor paginator.Cursor, limit int) ([]postgres.Message, paginator.Cursor, error) {
+	return r.history(), paginator.Cursor{}, nil
+}
+
+func (r *channelMessageStub) GetReactionSummaries(messageIDs []uint, userID uint) (map[uint][]postgres.ReactionCount, error) {
+	summaries := make(map[uint][]postgres.ReactionCount, len(messageIDs))
+	for _, id := range messageIDs {
+		summaries[id] = []postgres.ReactionCount{{Emoji: "👍", Count: 1, ReactedByMe: userID == 3}}
+	}
+	return summaries, nil
+}
+
+type channelPollStub struct {
+	repositories.PollRepository
+}
+
+func (r *channelPollStub) GetOptionVoteCounts(pollIDs []uint) (map[uint]int, error) {
+	return map[uint]int{51: 1}, nil
+}
+
+func (r *channelPollStub) GetVoterCounts(pollIDs []uint) (map[uint]int, error) {
+	return map[uint]int{50: 1}, nil
+}
+
+func (r *channelPollStub) GetOptionVoters(pollIDs []uint) (map[uint][]uint, error) {
+	return map[uint][]uint{51: {3}}, nil
+}
+
+func (r *channelPollStub) GetUserVotes(pollIDs []uint, userID uint) (map[uint][]uint, error) {
+	if userID != 3 {
+		return map[uint][]uint{}, nil
+	}
+	return map[uint][]uint{50: {51}}, nil
+}
+
+// newTestChannelService returns a service reading a public channel owned by
+// user 1, where user 2 is an admin and users 3 and 4 are subscribers. User 3
+// reacted to its only message, a public poll, and voted on it.
+func newTestChannelService() *ChatService {
+	return &ChatService{repos: &repositories.Repositories{
+		ChatRoom: &channelRoomStub{room: &postgres.ChatRoom{
+			ID:       10,
+			Type:     postgres.ChatRoomTypeChannel,
+			IsPublic: true,
+		}},
+		Participant: &channelParticipantStub{roles: map[uint]postgres.ParticipantRole{
+			1: postgres.ParticipantRoleOwner,
+			2: postgres.ParticipantRoleAdmin,
+		}},
+		Channel: &channelSubscriptionStub{subscribers: map[uint]bool{3: true, 4: true}},
+		Message: &channelMessageStub{history: func() []postgres.Message {
+			return []postgres.Message{{
+				ID:         100,
+				ChatRoomID: 10,
+				SenderID:   1,
+				Reactions: []postgres.MessageReaction{{
+					MessageID: 100,
+					UserID:    3,
+					Emoji:     "👍",
+					User:      postgres.User{ID: 3, Email: "subscriber@example.com"},
+				}},
+				Poll: &postgres.Poll{
+					ID:      50,
+					Options: []postgres.PollOption{{ID: 51, PollID: 50}, {ID: 52, PollID: 50}},
+				},
+			}}
+		}},
+		Poll: &channelPollStub{},
+	}}
+}
+
+func TestGetChannelMessagesHidesIdentitiesFromSubscribers(t *testing.T) {
+	service := newTestChannelService()
+
+	response, err := service.GetChannelMessages(10, 4, requests.GetChannelMessagesRequest{})
+	if err != nil {
+		t.Fatalf("GetChannelMessages() error = %v", err)
+	}
+	message := response.Messages[0]
+	if len(message.Reactions) != 0 {
+		t.Errorf("subscriber got reactions %+v, want none", message.Reactions)
+	}
+	if len(message.ReactionSummary) != 1 || message.ReactionSummary[0].Count != 1 {
+		t.Errorf("ReactionSummary = %+v, want the count of the reaction", message.ReactionSummary)
+	}
+	for _, option := range message.Poll.Options {
+		if len(option.VoterIDs) != 0 {
+			t.Errorf("subscriber got voters %v of option %d, want none", option.VoterIDs, option.ID)
+		}
+	}
+	if message.Poll.Options[0].VoteCount != 1 || message.Poll.TotalVoters != 1 {
+		t.Errorf("poll results = %+v, want the count of the vote", message.Poll)
+	}
+}
+
+func TestGetChannelMessagesShowsIdentitiesToStaff(t *testing.T) {
+	service := newTestChannelService()
+
+	for _, userID := range []uint{1, 2} {
+		response, err := service.GetChannelMessages(10, userID, requests.GetChannelMessagesRequest{})
+		if err != nil {
+			t.Fatalf("GetChannelMessages() error = %v", err)
+		}
+		reactions := response.Messages[0].Reactions
+		if len(reactions) != 1 || reactions[0].User.ID != 3 {
+			t.Errorf("user %d got reactions %+v, want the reaction of user 3", userID, reactions)
+		}
+		if voters := response.Messages[0].Poll.Options[0].VoterIDs; len(voters) != 1 || voters[0] != 3 {
+			t.Errorf("user %d got voters %v, want user 3", userID, voters)
+		}
+	}
+}
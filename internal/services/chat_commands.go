@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"social_server/internal/models"
+	"social_server/internal/models/constants"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/responses"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrSlashCommandHandled is returned by SendMessage for slash commands that ran
+// without posting a message, e.g. an ephemeral reply or a room action
+var ErrSlashCommandHandled = errors.New("slash command handled")
+
+var slashCommandNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// SlashCommandContext is what a slash command handler receives
+type SlashCommandContext struct {
+	UserID  uint
+	RoomID  uint
+	LocalID uint
+	Command string
+	Args    []string // Split on spaces, quoted arguments are kept together
+	Text    string   // Everything after the command name
+}
+
+// SlashCommandResult is what a command produced, both fields are optional
+type SlashCommandResult struct {
+	Message *postgres.Message // Message posted to the room
+	Reply   string            // Shown to the sender only
+}
+
+type SlashCommandHandler func(ctx *SlashCommandContext) (*SlashCommandResult, error)
+
+// SlashCommand describes a command typed as "/name args" in a chat message
+type SlashCommand struct {
+	Name        string
+	Description string
+	Usage       string
+	Output      constants.SlashCommandOutput
+	Permission  string // CheckUserPermission action the sender needs, "" for any member
+	MinArgs     int
+	Handler     SlashCommandHandler
+}
+
+// SlashCommandRegistry holds the slash commands known to the chat service
+type SlashCommandRegistry struct {
+	commands map[string]*SlashCommand
+	mutex    sync.RWMutex
+}
+
+func NewSlashCommandRegistry() *SlashCommandRegistry {
+	return &SlashCommandRegistry{
+		commands: make(map[string]*SlashCommand),
+	}
+}
+
+// Register adds a command, names are unique
+func (r *SlashCommandRegistry) Register(command *SlashCommand) error {
+	if !slashCommandNamePattern.MatchString(command.Name) {
+		return fmt.Errorf("invalid slash command name %q", command.Name)
+	}
+	if command.Handler == nil {
+		return fmt.Errorf("slash command /%s has no handler", command.Name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.commands[command.Name]; exists {
+		return fmt.Errorf("slash command /%s is already registered", command.Name)
+	}
+	r.commands[command.Name] = command
+	return nil
+}
+
+func (r *SlashCommandRegistry) Get(name string) (*SlashCommand, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	command, exists := r.commands[name]
+	return command, exists
+}
+
+// List returns the commands sorted by name
+func (r *SlashCommandRegistry) List() []*SlashCommand {
+	r.mutex.RLock()
+	commands := make([]*SlashCommand, 0, len(r.commands))
+	for _, command := range r.commands {
+		commands = append(commands, command)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// RegisterSlashCommand adds a command to the chat, e.g. from an integration
+func (s *ChatService) RegisterSlashCommand(command *SlashCommand) error {
+	return s.commands.Register(command)
+}
+
+// GetSlashCommands lists the commands for autocompletion. With a room, only the
+// commands the user is allowed to run there are returned.
+func (s *ChatService) GetSlashCommands(userID, roomID uint) ([]responses.SlashCommandResponse, error) {
+	if roomID != 0 {
+		if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+			return nil, fmt.Errorf("permission denied: user not in room")
+		}
+	}
+
+	commands := []responses.SlashCommandResponse{}
+	for _, command := range s.commands.List() {
+		if roomID != 0 && s.CheckUserPermission(roomID, userID, command.Permission) != nil {
+			continue
+		}
+		commands = append(commands, responses.SlashCommandResponse{
+			Name:        command.Name,
+			Description: command.Description,
+			Usage:       command.Usage,
+			Output:      command.Output,
+			Permission:  command.Permission,
+		})
+	}
+	return commands, nil
+}
+
+// findSlashCommand returns the registered command a message starts with.
+// Unknown commands are sent as plain messages, outgoing webhooks may handle them.
+func (s *ChatService) findSlashCommand(content string) (*SlashCommand, string, bool) {
+	if len(content) < 2 || content[0] != '/' {
+		return nil, "", false
+	}
+
+	name, text := content[1:], ""
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, text = name[:i], name[i:]
+	}
+
+	command, exists := s.commands.Get(strings.ToLower(name))
+	if !exists {
+		return nil, "", false
+	}
+	return command, strings.TrimSpace(text), true
+}
+
+// runSlashCommand checks the arguments and permission of a command then runs it.
+// Errors are also pushed to the sender as an ephemeral reply.
+func (s *ChatService) runSlashCommand(userID uint, req models.SendChatMessageMessage, command *SlashCommand, text string) (*postgres.Message, error) {
+	result, err := s.executeSlashCommand(userID, req, command, text)
+	if err != nil {
+		s.sendCommandReply(userID, req, command.Name, models.CommandReplyMessage{Error: err.Error()})
+		return nil, err
+	}
+
+	if result != nil && result.Reply != "" {
+		s.sendCommandReply(userID, req, command.Name, models.CommandReplyMessage{Content: result.Reply})
+	}
+	if result != nil && result.Message != nil {
+		return result.Message, nil
+	}
+	return nil, ErrSlashCommandHandled
+}
+
+func (s *ChatService) executeSlashCommand(userID uint, req models.SendChatMessageMessage, command *SlashCommand, text string) (*SlashCommandResult, error) {
+	args, err := splitCommandArgs(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < command.MinArgs {
+		return nil, fmt.Errorf("usage: %s", command.Usage)
+	}
+
+	if err := s.CheckUserPermission(req.RoomID, userID, command.Permission); err != nil {
+		return nil, err
+	}
+
+	return command.Handler(&SlashCommandContext{
+		UserID:  userID,
+		RoomID:  req.RoomID,
+		LocalID: req.LocalID,
+		Command: command.Name,
+		Args:    args,
+		Text:    text,
+	})
+}
+
+func (s *ChatService) sendCommandReply(userID uint, req models.SendChatMessageMessage, command string, reply models.CommandReplyMessage) {
+	reply.RoomID = req.RoomID
+	reply.LocalID = req.LocalID
+	reply.Command = command
+
+	s.emit(ChatEvent{
+		Type:    models.MessageTypeCommandReply,
+		RoomID:  req.RoomID,
+		UserIDs: []uint{userID},
+		Data:    reply,
+	})
+}
+
+// splitCommandArgs splits command arguments on spaces, double quotes group words
+func splitCommandArgs(text string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg := false, false
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case unicode.IsSpace(r) && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/constants"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	shrug             = `¯\_(ツ)_/¯`
+	maxTopicLength    = 500
+	maxPollQuestion   = 300
+	maxPollOptionText = 100
+)
+
+// registerBuiltinCommands registers the slash commands available in every room
+func (s *ChatService) registerBuiltinCommands() {
+	commands := []*SlashCommand{
+		{
+			Name:        "me",
+			Description: "Describe what you are doing",
+			Usage:       "/me <action>",
+			Output:      constants.SlashCommandOutputMessage,
+			Permission:  "send_message",
+			MinArgs:     1,
+			Handler:     s.commandMe,
+		},
+		{
+			Name:        "shrug",
+			Description: `Append ¯\_(ツ)_/¯ to your message`,
+			Usage:       "/shrug [message]",
+			Output:      constants.SlashCommandOutputMessage,
+			Permission:  "send_message",
+			Handler:     s.commandShrug,
+		},
+		{
+			Name:        "poll",
+			Description: "Create a poll, quote the question and options with several words",
+			Usage:       `/poll "Question" "Option 1" "Option 2" ...`,
+			Output:      constants.SlashCommandOutputMessage,
+			Permission:  "send_message",
+			MinArgs:     1 + MinPollOptions,
+			Handler:     s.commandPoll,
+		},
+		{
+			Name:        "remind",
+			Description: "Get a reminder in this room, only you will see it",
+			Usage:       "/remind <in, e.g. 30m, 2h or 1d> <text>",
+			Output:      constants.SlashCommandOutputEphemeral,
+			MinArgs:     2,
+			Handler:     s.commandRemind,
+		},
+		{
+			Name:        "mute",
+			Description: "Mute a member, for a duration or until unmuted",
+			Usage:       "/mute <@user_id> [duration, e.g. 10m] [reason]",
+			Output:      constants.SlashCommandOutputAction,
+			Permission:  "moderate_members",
+			MinArgs:     1,
+			Handler:     s.commandMute,
+		},
+		{
+			Name:        "kick",
+			Description: "Remove a member from the room",
+			Usage:       "/kick <@user_id> [reason]",
+			Output:      constants.SlashCommandOutputAction,
+			Permission:  "moderate_members",
+			MinArgs:     1,
+			Handler:     s.commandKick,
+		},
+		{
+			Name:        "topic",
+			Description: "Change the topic of the room",
+			Usage:       "/topic <topic>",
+			Output:      constants.SlashCommandOutputAction,
+			Permission:  "update_room",
+			MinArgs:     1,
+			Handler:     s.commandTopic,
+		},
+	}
+
+	for _, command := range commands {
+		if err := s.commands.Register(command); err != nil {
+			log.Printf("Failed to register slash command /%s: %v", command.Name, err)
+		}
+	}
+}
+
+func (s *ChatService) commandMe(ctx *SlashCommandContext) (*SlashCommandResult, error) {
+	content := fmt.Sprintf("* %s %s", s.displayName(ctx.UserID), ctx.Text)
+	return s.postCommandMessage(ctx, content)
+}
+
+func (s *ChatService) commandShrug(ctx *SlashCommandContext) (*SlashCommandResult, error) {
+	content := strings.TrimSpace(ctx.Text + " " + shrug)
+	return s.postCommandMessage(ctx, content)
+}
+
+func (s *ChatService) commandPoll(ctx *SlashCommandContext) (*SlashCommandResult, error) {
+	question, options := ctx.Args[0], ctx.Args[1:]
+	if utf8.RuneCountInString(question) > maxPollQuestion {
+		return nil, fmt.Errorf("poll question cannot be longer than %d characters", maxPollQuestion)
+	}
+	for _, option := range options {
+		if utf8.RuneCountInString(option) > maxPollOptionText {
+			return nil, fmt.Errorf("poll options cannot be longer than %d characters", maxPollOptionText)
+		}
+	}
+
+	message, err := s.CreatePoll(ctx.RoomID, ctx.UserID, requests.CreatePollRequest{
+		LocalID:  ctx.LocalID,
+		Question: question,
+		Options:  options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SlashCommandResult{Message: message}, nil
+}
+
+func (s *ChatService) commandRemind(ctx *SlashCommandContext) (*SlashCommandResult, error) {
+	delay, err := parseCommandDuration(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	sendAt := time.Now().Add(delay)
+	if err := validateSendAt(sendAt); err != nil {
+		return nil, err
+	}
+
+	reminder := &postgres.ScheduledMessage{
+		LocalID:    ctx.LocalID,
+		ChatRoomID: ctx.RoomID,
+		SenderID:   ctx.UserID,
+		Content:    commandTextAfterArg(ctx.Text),
+		SendAt:     sendAt.UTC(),
+		Status:     postgres.ScheduledMessageStatusPending,
+		IsReminder: true,
+	}
+	if err := s.repos.ScheduledMessage.Create(reminder); err != nil {
+		return nil, fmt.Errorf("failed to create reminder: %w", err)
+	}
+
+	return &SlashCommandResult{
+		Reply: fmt.Sprintf("I will remind you in %s: %s", formatTimer(int(delay.Seconds())), reminder.Content),
+	}, nil
+}
+
+func (s *ChatService) commandMute(ctx *SlashCommandContext) (*SlashCommandResult, error) {
+	targetID, err := parseUserArg(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// The duration is optional, without it the rest is the reason
+	duration := 0
+	reason := commandTextAfterArg(ctx.Text)
+	if len(ctx.Args) > 1 {
+		if d, err := parseCommandDuration(ctx.Args[1]); err == nil {
+			duration = int(d.Seconds())
+			reason = commandTextAfterArg(reason)
+		}
+	}
+
+	if err := s.MuteMember(ctx.RoomID, ctx.UserID, targetID, duration, reason); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (s *ChatService) commandKick(ctx *SlashCommandContext) (*SlashCommandResult, error) {
+	targetID, err := parseUserArg(ctx.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.KickMember(ctx.RoomID, ctx.UserID, targetID, commandTextAfterArg(ctx.Text)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (s *ChatService) commandTopic(ctx *SlashCommandContext) (*SlashCommandResult, error) {
+	topic := ctx.Text
+	if utf8.RuneCountInString(topic) > maxTopicLength {
+		return nil, fmt.Errorf("topic cannot be longer than %d characters", maxTopicLength)
+	}
+
+	if err := s.repos.ChatRoom.Update(ctx.RoomID, map[string]interface{}{"description": topic}); err != nil {
+		return nil, fmt.Errorf("failed to update room: %w", err)
+	}
+
+	if _, err := s.createSystemMessage(ctx.RoomID, ctx.UserID, "Changed the topic to: "+topic); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// postCommandMessage posts the text produced by a command as a regular message
+func (s *ChatService) postCommandMessage(ctx *SlashCommandContext, content string) (*SlashCommandResult, error) {
+	message, err := s.sendMessage(ctx.UserID, models.SendChatMessageMessage{
+		RoomID:    ctx.RoomID,
+		LocalID:   ctx.LocalID,
+		Content:   content,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SlashCommandResult{Message: message}, nil
+}
+
+// displayName returns the name shown for a user in messages
+func (s *ChatService) displayName(userID uint) string {
+	profile, err := s.repos.Profile.GetByUserID(userID)
+	if err != nil {
+		return "Someone"
+	}
+	if profile.DisplayName != "" {
+		return profile.DisplayName
+	}
+	if name := strings.TrimSpace(profile.FirstName + " " + profile.LastName); name != "" {
+		return name
+	}
+	return "Someone"
+}
+
+// parseUserArg reads a user mention, @42 or 42
+func parseUserArg(arg string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(arg, "@"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid user %q, mention them by ID such as @42", arg)
+	}
+	return uint(id), nil
+}
+
+// parseCommandDuration reads durations such as 90s, 30m, 2h, 1d or 1w
+func parseCommandDuration(arg string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration %q, use e.g. 30m, 2h or 1d", arg)
+	if arg == "" {
+		return 0, invalid
+	}
+
+	var duration time.Duration
+	switch unit := arg[len(arg)-1]; unit {
+	case 'd', 'w':
+		n, err := strconv.Atoi(arg[:len(arg)-1])
+		if err != nil {
+			return 0, invalid
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+		if unit == 'w' {
+			duration *= 7
+		}
+	default:
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return 0, invalid
+		}
+		duration = d
+	}
+
+	if duration <= 0 {
+		return 0, invalid
+	}
+	return duration, nil
+}
+
+// commandTextAfterArg drops the first word of a command text
+func commandTextAfterArg(text string) string {
+	_, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	return strings.TrimSpace(rest)
+}
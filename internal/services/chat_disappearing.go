@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/utils"
+	"time"
+)
+
+const (
+	MinDisappearingTimer     = 60                   // 1 minute
+	MaxDisappearingTimer     = 4 * 7 * 24 * 60 * 60 // 4 weeks
+	MessageExpiryInterval    = 30 * time.Second
+	expiredMessagesBatchSize = 500
+)
+
+// SetDisappearingTimer changes the disappearing messages timer of a room.
+// A timer of 0 turns disappearing messages off.
+func (s *ChatService) SetDisappearingTimer(roomID, userID uint, timer int, mode postgres.DisappearingMode) (*postgres.ChatRoom, error) {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	// Anyone can change the timer of a private room, only admins in groups
+	if room.Type != postgres.ChatRoomTypePrivate &&
+		participant.Role != postgres.ParticipantRoleAdmin && participant.Role != postgres.ParticipantRoleOwner {
+		return nil, fmt.Errorf("permission denied: only admins can change disappearing messages")
+	}
+
+	if timer != 0 && (timer < MinDisappearingTimer || timer > MaxDisappearingTimer) {
+		return nil, fmt.Errorf("timer must be between %d and %d seconds", MinDisappearingTimer, MaxDisappearingTimer)
+	}
+
+	if mode == "" {
+		mode = postgres.DisappearingModeAfterSend
+	}
+
+	err = s.repos.ChatRoom.Update(roomID, map[string]interface{}{
+		"settings_disappearing_timer": timer,
+		"settings_disappearing_mode":  mode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update disappearing timer: %w", err)
+	}
+
+	room.Settings.DisappearingTimer = timer
+	room.Settings.DisappearingMode = mode
+
+	content := "Disappearing messages turned off"
+	if timer > 0 {
+		content = fmt.Sprintf("Disappearing messages set to %s", formatTimer(timer))
+		if mode == postgres.DisappearingModeAfterRead {
+			content += " after read"
+		}
+	}
+	if _, err := s.createSystemMessage(roomID, userID, content); err != nil {
+		log.Printf("Failed to post disappearing timer change in room %d: %v", roomID, err)
+	}
+
+	return room, nil
+}
+
+// applyDisappearingTimer sets the expiry of a new message from the room settings
+func applyDisappearingTimer(room *postgres.ChatRoom, message *postgres.Message) {
+	timer := room.Settings.DisappearingTimer
+	if timer <= 0 {
+		return
+	}
+
+	if room.Settings.DisappearingMode == postgres.DisappearingModeAfterRead {
+		message.ExpiresIn = timer
+		return
+	}
+
+	sentAt := message.CreatedAt
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+	expiresAt := sentAt.Add(time.Duration(timer) * time.Second)
+	message.ExpiresAt = &expiresAt
+}
+
+// startReadCountdown starts the expiry countdown of an after-read message
+// when it is read by someone other than the sender
+func (s *ChatService) startReadCountdown(messageID, userID uint) {
+	message, err := s.repos.Message.GetByID(messageID)
+	if err != nil {
+		log.Printf("Failed to get message %d for read countdown: %v", messageID, err)
+		return
+	}
+
+	if message.ExpiresIn <= 0 || message.ExpiresAt != nil || message.SenderID == userID {
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(message.ExpiresIn) * time.Second)
+	if err := s.repos.Message.Update(messageID, map[string]interface{}{"expires_at": expiresAt}); err != nil {
+		log.Printf("Failed to start read countdown for message %d: %v", messageID, err)
+	}
+}
+
+// PurgeExpiredMessages hard-deletes expired messages with their reactions,
+// read receipts and media files, then notifies the rooms
+func (s *ChatService) PurgeExpiredMessages() {
+	for {
+		messages, err := s.repos.Message.GetExpiredMessages(time.Now(), expiredMessagesBatchSize)
+		if err != nil {
+			log.Printf("Failed to get expired messages: %v", err)
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+
+		ids := make([]uint, len(messages))
+		roomMessages := make(map[uint][]uint)
+		for i, message := range messages {
+			ids[i] = message.ID
+			roomMessages[message.ChatRoomID] = append(roomMessages[message.ChatRoomID], message.ID)
+		}
+
+		if err := s.repos.Message.HardDelete(ids); err != nil {
+			log.Printf("Failed to delete expired messages: %v", err)
+			return
+		}
+
+		for _, message := range messages {
+			if message.Media == nil {
+				continue
+			}
+			for _, url := range []string{message.Media.URL, message.Media.Thumbnail} {
+				if url == "" {
+					continue
+				}
+				// Forwards of the message share its files
+				referenced, err := s.repos.Message.IsMediaReferenced(url)
+				if err != nil {
+					log.Printf("Failed to check media of expired message %d: %v", message.ID, err)
+					continue
+				}
+				if referenced {
+					continue
+				}
+				if err := utils.DeleteUploadedFile(url); err != nil {
+					log.Printf("Failed to delete media of expired message %d: %v", message.ID, err)
+				}
+			}
+		}
+
+		for roomID, messageIDs := range roomMessages {
+			s.emitToRoom(roomID, 0, models.MessageTypeMessageExpired, models.MessageExpiredMessage{
+				RoomID:     roomID,
+				MessageIDs: messageIDs,
+			})
+		}
+
+		log.Printf("Purged %d expired messages", len(messages))
+
+		if len(messages) < expiredMessagesBatchSize {
+			return
+		}
+	}
+}
+
+// startExpiryJob starts background job to purge expired messages
+func (s *ChatService) startExpiryJob() {
+	ticker := time.NewTicker(MessageExpiryInterval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.PurgeExpiredMessages()
+			case <-s.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// formatTimer formats a timer in seconds as a human readable duration
+func formatTimer(seconds int) string {
+	units := []struct {
+		name    string
+		seconds int
+	}{
+		{"week", 7 * 24 * 60 * 60},
+		{"day", 24 * 60 * 60},
+		{"hour", 60 * 60},
+		{"minute", 60},
+	}
+
+	for _, unit := range units {
+		if seconds >= unit.seconds && seconds%unit.seconds == 0 {
+			count := seconds / unit.seconds
+			if count == 1 {
+				return "1 " + unit.name
+			}
+			return fmt.Sprintf("%d %ss", count, unit.name)
+		}
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
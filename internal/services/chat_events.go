@@ -0,0 +1,89 @@
+package services
+
+import (
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+)
+
+// ChatEvent is a realtime event produced by the chat service outside of a
+// websocket request (background jobs, REST endpoints, ...)
+type ChatEvent struct {
+	Type    models.MessageType
+	From    uint
+	RoomID  uint
+	UserIDs []uint // Recipients
+	Data    interface{}
+
+	// Set for channel events, the transport also delivers them to the
+	// subscribers of the channel, which are not listed in UserIDs
+	ChannelID uint
+
+	// Set for events of the whole room, the transport also delivers them to
+	// the connections that opened it. Events for some users only never are.
+	Broadcast bool
+
+	// Set when the users, everyone when empty, may have lost access to the
+	// room. Such events have no Type, the transport checks again what they
+	// follow of the room.
+	AccessChanged bool
+}
+
+// ChatEventCallback is called for every chat event that must be pushed to clients
+type ChatEventCallback func(event ChatEvent)
+
+// RegisterEventCallback registers a callback for chat events
+func (s *ChatService) RegisterEventCallback(callback ChatEventCallback) {
+	s.eventMutex.Lock()
+	defer s.eventMutex.Unlock()
+	s.eventCallbacks = append(s.eventCallbacks, callback)
+}
+
+// emit notifies all registered callbacks about a chat event
+func (s *ChatService) emit(event ChatEvent) {
+	s.eventMutex.RLock()
+	callbacks := s.eventCallbacks
+	s.eventMutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(event)
+	}
+}
+
+// emitAccessLost tells the transports the users may no longer follow the
+// room, all of its followers when none are given
+func (s *ChatService) emitAccessLost(roomID uint, userIDs ...uint) {
+	s.emit(ChatEvent{
+		RoomID:        roomID,
+		UserIDs:       userIDs,
+		AccessChanged: true,
+	})
+}
+
+// emitToRoom sends an event to every current participant of a room, and to
+// the subscribers of channels
+func (s *ChatService) emitToRoom(roomID uint, from uint, eventType models.MessageType, data interface{}) {
+	participants, err := s.repos.ChatRoom.GetParticipants(roomID)
+	if err != nil {
+		log.Printf("Failed to get participants of room %d for %s event: %v", roomID, eventType, err)
+		return
+	}
+
+	userIDs := make([]uint, len(participants))
+	for i, participant := range participants {
+		userIDs[i] = participant.UserID
+	}
+
+	event := ChatEvent{
+		Type:      eventType,
+		From:      from,
+		RoomID:    roomID,
+		UserIDs:   userIDs,
+		Data:      data,
+		Broadcast: true,
+	}
+	if s.roomType(roomID) == postgres.ChatRoomTypeChannel {
+		event.ChannelID = roomID
+	}
+	s.emit(event)
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"strings"
+	"time"
+)
+
+const (
+	ChatExportInterval      = 30 * time.Second
+	ChatExportTTL           = 7 * 24 * time.Hour
+	MaxActiveExportsPerUser = 3
+
+	chatExportDir        = "./exports"
+	chatExportUploadsDir = "./uploads"
+	chatExportBatchSize  = 500
+	chatExportStaleAfter = 10 * time.Minute
+)
+
+// RequestExport queues an export of a room for one of its participants.
+// Members only get the messages sent since they joined, unless the room
+// shares its history with new members.
+func (s *ChatService) RequestExport(roomID, userID uint, req requests.CreateChatExportRequest) (*postgres.ChatExport, error) {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("room not found")
+	}
+
+	active, err := s.repos.ChatExport.CountActive(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count exports: %w", err)
+	}
+	if active >= MaxActiveExportsPerUser {
+		return nil, fmt.Errorf("you already have %d exports in progress", active)
+	}
+
+	export := &postgres.ChatExport{
+		ChatRoomID:         roomID,
+		RequestedBy:        userID,
+		Format:             req.Format,
+		IncludeAttachments: req.IncludeAttachments,
+		Status:             postgres.ChatExportStatusPending,
+		Until:              time.Now(),
+	}
+	if !room.Settings.ShareHistoryWithNewMembers {
+		since := participant.JoinedAt
+		export.Since = &since
+	}
+
+	if err := s.repos.ChatExport.Create(export); err != nil {
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+	return export, nil
+}
+
+// GetExports lists the exports a user requested in a room
+func (s *ChatService) GetExports(roomID, userID uint) ([]postgres.ChatExport, error) {
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	exports, err := s.repos.ChatExport.GetUserRoomExports(roomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exports: %w", err)
+	}
+	return exports, nil
+}
+
+// GetExport returns an export of the user
+func (s *ChatService) GetExport(id, userID uint) (*postgres.ChatExport, error) {
+	export, err := s.repos.ChatExport.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("export not found")
+	}
+	if export.RequestedBy != userID {
+		return nil, fmt.Errorf("permission denied: only the requester can access an export")
+	}
+	return export, nil
+}
+
+// GetExportFile returns a completed export with the path of its file
+func (s *ChatService) GetExportFile(id, userID uint) (*postgres.ChatExport, error) {
+	export, err := s.GetExport(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if export.Status != postgres.ChatExportStatusCompleted {
+		return nil, fmt.Errorf("export is %s", export.Status)
+	}
+	if export.ExpiresAt != nil && export.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("export file not found, it has expired")
+	}
+	if _, err := os.Stat(export.FilePath); err != nil {
+		return nil, fmt.Errorf("export file not found")
+	}
+	return export, nil
+}
+
+// ProcessExports writes the pending exports and removes the expired files
+func (s *ChatService) ProcessExports() {
+	if err := s.repos.ChatExport.ReleaseStale(time.Now().Add(-chatExportStaleAfter)); err != nil {
+		log.Printf("Failed to release stale exports: %v", err)
+	}
+
+	pending, err := s.repos.ChatExport.GetPending(10)
+	if err != nil {
+		log.Printf("Failed to get pending exports: %v", err)
+		return
+	}
+	for _, export := range pending {
+		claimed, err := s.repos.ChatExport.Claim(export.ID)
+		if err != nil || !claimed {
+			continue
+		}
+		s.runExport(export)
+	}
+
+	s.removeExpiredExports()
+}
+
+func (s *ChatService) runExport(export postgres.ChatExport) {
+	updates, err := s.writeExport(&export)
+	if err != nil {
+		log.Printf("Failed to export room %d (export %d): %v", export.ChatRoomID, export.ID, err)
+		updates = map[string]interface{}{
+			"status": postgres.ChatExportStatusFailed,
+			"error":  err.Error(),
+		}
+	}
+
+	if err := s.repos.ChatExport.Update(export.ID, updates); err != nil {
+		log.Printf("Failed to update export %d: %v", export.ID, err)
+		return
+	}
+
+	if updated, err := s.repos.ChatExport.GetByID(export.ID); err == nil {
+		s.emit(ChatEvent{
+			Type:    models.MessageTypeChatExportUpdated,
+			RoomID:  export.ChatRoomID,
+			UserIDs: []uint{export.RequestedBy},
+			Data:    updated,
+		})
+	}
+}
+
+// writeExport writes the export file and returns the fields to save on success
+func (s *ChatService) writeExport(export *postgres.ChatExport) (map[string]interface{}, error) {
+	room, err := s.repos.ChatRoom.GetByID(export.ChatRoomID)
+	if err != nil {
+		return nil, fmt.Errorf("room not found")
+	}
+
+	if err := os.MkdirAll(chatExportDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	ext := chatExportExtension(export.Format)
+	fileName := fmt.Sprintf("chat-%d-%s.%s", room.ID, export.CreatedAt.Format("20060102-150405"), ext)
+	if export.IncludeAttachments {
+		fileName = strings.TrimSuffix(fileName, "."+ext) + ".zip"
+	}
+	filePath := filepath.Join(chatExportDir, fmt.Sprintf("%d-%s", export.ID, fileName))
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	stats, err := s.writeExportFile(file, export, room, ext)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write export file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export file: %w", err)
+	}
+
+	now := time.Now()
+	return map[string]interface{}{
+		"status":           postgres.ChatExportStatusCompleted,
+		"file_path":        filePath,
+		"file_name":        fileName,
+		"file_size":        info.Size(),
+		"message_count":    stats.messages,
+		"attachment_count": stats.attachments,
+		"skipped_count":    stats.skipped,
+		"error":            "",
+		"completed_at":     now,
+		"expires_at":       now.Add(ChatExportTTL),
+	}, nil
+}
+
+type chatExportStats struct {
+	messages    int
+	attachments int
+	skipped     int
+}
+
+// writeExportFile streams the messages to w, inside a ZIP next to their
+// attachments when the export includes them
+func (s *ChatService) writeExportFile(w io.Writer, export *postgres.ChatExport, room *postgres.ChatRoom, ext string) (*chatExportStats, error) {
+	if !export.IncludeAttachments {
+		return s.writeExportMessages(w, export, room, nil)
+	}
+
+	archive := zip.NewWriter(w)
+	entry, err := archive.Create("messages." + ext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	attachments := map[string]string{}
+	stats, err := s.writeExportMessages(entry, export, room, attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only one archive entry can be written at a time, attachments go last
+	for name, source := range attachments {
+		if err := addFileToZip(archive, name, source); err != nil {
+			return nil, err
+		}
+	}
+	stats.attachments = len(attachments)
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return stats, nil
+}
+
+// writeExportMessages writes the messages of an export in batches. When
+// attachments is not nil, the local files of the messages are collected in it
+// by their path in the archive.
+func (s *ChatService) writeExportMessages(w io.Writer, export *postgres.ChatExport, room *postgres.ChatRoom, attachments map[string]string) (*chatExportStats, error) {
+	writer := newChatExportWriter(export.Format, w)
+	if err := writer.Begin(newChatExportHeader(room, export)); err != nil {
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	names := newExportNameCache(s)
+	stats := &chatExportStats{}
+	afterID := uint(0)
+	for {
+		messages, err := s.repos.Message.GetForExport(room.ID, afterID, export.Since, export.Until, chatExportBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get messages: %w", err)
+		}
+
+		for _, message := range messages {
+			afterID = message.ID
+
+			// End-to-end encrypted content cannot be read by the server
+			if message.EncryptedContent != "" {
+				stats.skipped++
+				continue
+			}
+
+			entry := newChatExportMessage(message, names, export.Since)
+			if entry.Attachment != nil && attachments != nil {
+				if source, ok := localUploadPath(message.Media.URL); ok {
+					name := path.Join("attachments", fmt.Sprintf("%d-%s", message.ID, exportFileName(message.Media)))
+					attachments[name] = source
+					entry.Attachment.File = name
+				}
+			}
+
+			if err := writer.WriteMessage(entry); err != nil {
+				return nil, fmt.Errorf("failed to write export: %w", err)
+			}
+			stats.messages++
+		}
+
+		if len(messages) < chatExportBatchSize {
+			break
+		}
+
+		// Keep the export claimed while a large room is written
+		if err := s.repos.ChatExport.Update(export.ID, map[string]interface{}{}); err != nil {
+			log.Printf("Failed to refresh export %d: %v", export.ID, err)
+		}
+	}
+
+	if err := writer.End(); err != nil {
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+	return stats, nil
+}
+
+// removeExpiredExports deletes the files of the expired exports
+func (s *ChatService) removeExpiredExports() {
+	expired, err := s.repos.ChatExport.GetExpired(time.Now(), 100)
+	if err != nil {
+		log.Printf("Failed to get expired exports: %v", err)
+		return
+	}
+
+	for _, export := range expired {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove export file %s: %v", export.FilePath, err)
+			continue
+		}
+		err := s.repos.ChatExport.Update(export.ID, map[string]interface{}{
+			"status":    postgres.ChatExportStatusExpired,
+			"file_path": "",
+		})
+		if err != nil {
+			log.Printf("Failed to expire export %d: %v", export.ID, err)
+		}
+	}
+}
+
+// startExportJob starts background job to write the requested exports
+func (s *ChatService) startExportJob() {
+	ticker := time.NewTicker(ChatExportInterval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.ProcessExports()
+			case <-s.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// localUploadPath maps the URL of an uploaded file to its path on disk.
+// Files hosted elsewhere are not bundled.
+func localUploadPath(url string) (string, bool) {
+	if !strings.HasPrefix(url, "/uploads/") {
+		return "", false
+	}
+
+	relative := path.Clean(strings.TrimPrefix(url, "/uploads/"))
+	if relative == "." || strings.HasPrefix(relative, "..") {
+		return "", false
+	}
+
+	source := filepath.Join(chatExportUploadsDir, filepath.FromSlash(relative))
+	if info, err := os.Stat(source); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return source, true
+}
+
+func addFileToZip(archive *zip.Writer, name, source string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		// The file may have been removed since the message was read
+		log.Printf("Failed to open attachment %s: %v", source, err)
+		return nil
+	}
+	defer file.Close()
+
+	entry, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add attachment: %w", err)
+	}
+	if _, err := io.Copy(entry, file); err != nil {
+		return fmt.Errorf("failed to add attachment: %w", err)
+	}
+	return nil
+}
+
+// exportFileName returns a safe file name for an attachment
+func exportFileName(media *postgres.MessageMedia) string {
+	name := filepath.Base(media.Filename)
+	if name == "." || name == "/" || name == "" {
+		name = path.Base(media.URL)
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func chatExportExtension(format postgres.ChatExportFormat) string {
+	switch format {
+	case postgres.ChatExportFormatHTML:
+		return "html"
+	case postgres.ChatExportFormatText:
+		return "txt"
+	default:
+		return "json"
+	}
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"social_server/internal/models/postgres"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const chatExportQuoteLength = 100
+
+// chatExportHeader describes the exported room at the top of an export
+type chatExportHeader struct {
+	RoomID     uint                  `json:"room_id"`
+	RoomName   string                `json:"room_name"`
+	RoomType   postgres.ChatRoomType `json:"room_type"`
+	ExportedAt time.Time             `json:"exported_at"`
+	Since      *time.Time            `json:"since,omitempty"`
+	Until      time.Time             `json:"until"`
+}
+
+// chatExportMessage is a message as written in an export
+type chatExportMessage struct {
+	ID          uint                      `json:"id"`
+	Type        postgres.MessageType      `json:"type"`
+	SenderID    uint                      `json:"sender_id"`
+	SenderName  string                    `json:"sender_name"`
+	Content     string                    `json:"content,omitempty"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	EditedAt    *time.Time                `json:"edited_at,omitempty"`
+	IsForwarded bool                      `json:"is_forwarded,omitempty"`
+	ReplyTo     *chatExportReply          `json:"reply_to,omitempty"`
+	Attachment  *chatExportAttachment     `json:"attachment,omitempty"`
+	Location    *postgres.MessageLocation `json:"location,omitempty"`
+	Poll        *chatExportPoll           `json:"poll,omitempty"`
+	Reactions   []chatExportReaction      `json:"reactions,omitempty"`
+}
+
+type chatExportReply struct {
+	ID         uint   `json:"id"`
+	SenderName string `json:"sender_name"`
+	Content    string `json:"content"` // Shortened
+}
+
+type chatExportAttachment struct {
+	Type     string `json:"type"`
+	Filename string `json:"filename"`
+	MimeType string `json:"mime_type,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	URL      string `json:"url"`
+	File     string `json:"file,omitempty"` // Path in the ZIP when attachments are bundled
+}
+
+type chatExportPoll struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+}
+
+type chatExportReaction struct {
+	Emoji    string `json:"emoji"`
+	UserID   uint   `json:"user_id"`
+	UserName string `json:"user_name"`
+}
+
+func newChatExportHeader(room *postgres.ChatRoom, export *postgres.ChatExport) chatExportHeader {
+	name := room.Name
+	if name == "" {
+		name = fmt.Sprintf("Chat #%d", room.ID)
+	}
+	return chatExportHeader{
+		RoomID:     room.ID,
+		RoomName:   name,
+		RoomType:   room.Type,
+		ExportedAt: time.Now().UTC(),
+		Since:      export.Since,
+		Until:      export.Until,
+	}
+}
+
+// newChatExportMessage converts a message for the writers. Replies to
+// messages sent before since are not quoted, like those messages.
+func newChatExportMessage(message postgres.Message, names *exportNameCache, since *time.Time) chatExportMessage {
+	entry := chatExportMessage{
+		ID:          message.ID,
+		Type:        message.Type,
+		SenderID:    message.SenderID,
+		SenderName:  names.get(message.SenderID),
+		Content:     message.Content,
+		CreatedAt:   message.CreatedAt,
+		EditedAt:    message.EditedAt,
+		IsForwarded: message.IsForwarded,
+		Location:    message.Location,
+	}
+
+	if message.ReplyTo != nil && (since == nil || !message.ReplyTo.CreatedAt.Before(*since)) {
+		content := message.ReplyTo.Content
+		if message.ReplyTo.EncryptedContent != "" {
+			content = "Encrypted message"
+		}
+		entry.ReplyTo = &chatExportReply{
+			ID:         message.ReplyTo.ID,
+			SenderName: names.get(message.ReplyTo.SenderID),
+			Content:    shortenExportText(content),
+		}
+	}
+
+	if message.Media != nil && message.Media.URL != "" {
+		entry.Attachment = &chatExportAttachment{
+			Type:     message.Media.Type,
+			Filename: message.Media.Filename,
+			MimeType: message.Media.MimeType,
+			Size:     message.Media.Size,
+			URL:      message.Media.URL,
+		}
+	}
+
+	if message.Poll != nil {
+		poll := &chatExportPoll{Question: message.Poll.Question}
+		for _, option := range message.Poll.Options {
+			poll.Options = append(poll.Options, option.Text)
+		}
+		entry.Poll = poll
+	}
+
+	for _, reaction := range message.Reactions {
+		entry.Reactions = append(entry.Reactions, chatExportReaction{
+			Emoji:    reaction.Emoji,
+			UserID:   reaction.UserID,
+			UserName: names.get(reaction.UserID),
+		})
+	}
+
+	return entry
+}
+
+func shortenExportText(text string) string {
+	if utf8.RuneCountInString(text) <= chatExportQuoteLength {
+		return text
+	}
+	return string([]rune(text)[:chatExportQuoteLength]) + "…"
+}
+
+// exportNameCache looks up each display name once per export
+type exportNameCache struct {
+	service *ChatService
+	names   map[uint]string
+}
+
+func newExportNameCache(service *ChatService) *exportNameCache {
+	return &exportNameCache{service: service, names: make(map[uint]string)}
+}
+
+func (c *exportNameCache) get(userID uint) string {
+	name, ok := c.names[userID]
+	if !ok {
+		name = c.service.displayName(userID)
+		c.names[userID] = name
+	}
+	return name
+}
+
+// chatExportWriter writes the messages of an export one by one, so a room
+// never has to be held in memory
+type chatExportWriter interface {
+	Begin(header chatExportHeader) error
+	WriteMessage(message chatExportMessage) error
+	End() error
+}
+
+func newChatExportWriter(format postgres.ChatExportFormat, w io.Writer) chatExportWriter {
+	buffered := bufio.NewWriter(w)
+	switch format {
+	case postgres.ChatExportFormatHTML:
+		return &htmlExportWriter{w: buffered}
+	case postgres.ChatExportFormatText:
+		return &textExportWriter{w: buffered}
+	default:
+		return &jsonExportWriter{w: buffered}
+	}
+}
+
+// jsonExportWriter writes {"export": header, "messages": [...]}
+type jsonExportWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (j *jsonExportWriter) Begin(header chatExportHeader) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	j.w.WriteString(`{"export":`)
+	j.w.Write(data)
+	_, err = j.w.WriteString(`,"messages":[`)
+	return err
+}
+
+func (j *jsonExportWriter) WriteMessage(message chatExportMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		j.w.WriteByte(',')
+	}
+	j.count++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonExportWriter) End() error {
+	if _, err := j.w.WriteString("]}\n"); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+// textExportWriter writes a plain text transcript
+type textExportWriter struct {
+	w *bufio.Writer
+}
+
+func (t *textExportWriter) Begin(header chatExportHeader) error {
+	fmt.Fprintf(t.w, "%s\n", header.RoomName)
+	fmt.Fprintf(t.w, "Exported on %s\n", header.ExportedAt.Format(time.RFC1123))
+	if header.Since != nil {
+		fmt.Fprintf(t.w, "Messages from %s\n", header.Since.UTC().Format(time.RFC1123))
+	}
+	_, err := t.w.WriteString("\n")
+	return err
+}
+
+func (t *textExportWriter) WriteMessage(message chatExportMessage) error {
+	timestamp := message.CreatedAt.UTC().Format("2006-01-02 15:04")
+
+	if message.Type == postgres.MessageTypeSystem {
+		_, err := fmt.Fprintf(t.w, "[%s] * %s %s\n", timestamp, message.SenderName, message.Content)
+		return err
+	}
+
+	if message.ReplyTo != nil {
+		fmt.Fprintf(t.w, "  > %s: %s\n", message.ReplyTo.SenderName, message.ReplyTo.Content)
+	}
+
+	fmt.Fprintf(t.w, "[%s] %s:", timestamp, message.SenderName)
+	if message.IsForwarded {
+		t.w.WriteString(" (forwarded)")
+	}
+	if message.Content != "" {
+		fmt.Fprintf(t.w, " %s", message.Content)
+	}
+	if message.EditedAt != nil {
+		t.w.WriteString(" (edited)")
+	}
+	t.w.WriteString("\n")
+
+	if a := message.Attachment; a != nil {
+		file := a.URL
+		if a.File != "" {
+			file = a.File
+		}
+		fmt.Fprintf(t.w, "    [%s: %s] %s\n", a.Type, a.Filename, file)
+	}
+	if l := message.Location; l != nil {
+		fmt.Fprintf(t.w, "    [Location: %.6f, %.6f] %s\n", l.Latitude, l.Longitude, l.Address)
+	}
+	if p := message.Poll; p != nil {
+		fmt.Fprintf(t.w, "    [Poll: %s]\n", p.Question)
+		for _, option := range p.Options {
+			fmt.Fprintf(t.w, "      - %s\n", option)
+		}
+	}
+	if len(message.Reactions) > 0 {
+		reactions := make([]string, 0, len(message.Reactions))
+		for _, reaction := range message.Reactions {
+			reactions = append(reactions, reaction.Emoji+" "+reaction.UserName)
+		}
+		fmt.Fprintf(t.w, "    Reactions: %s\n", strings.Join(reactions, ", "))
+	}
+	return nil
+}
+
+func (t *textExportWriter) End() error {
+	return t.w.Flush()
+}
+
+// htmlExportWriter writes a standalone HTML page, styles included
+type htmlExportWriter struct {
+	w *bufio.Writer
+}
+
+var htmlExportTemplates = template.Must(template.New("export").Funcs(template.FuncMap{
+	"datetime": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04")
+	},
+}).Parse(`
+{{define "begin"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.RoomName}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; max-width: 800px; margin: 0 auto; padding: 24px; color: #1c1e21; background: #f0f2f5; }
+header { margin-bottom: 24px; }
+header p { color: #65676b; margin: 4px 0; }
+.message { background: #fff; border-radius: 8px; padding: 10px 14px; margin-bottom: 8px; }
+.message.system { background: none; color: #65676b; text-align: center; font-style: italic; }
+.meta { font-size: 13px; color: #65676b; margin-bottom: 4px; }
+.sender { font-weight: 600; color: #1c1e21; }
+.reply { border-left: 3px solid #ccd0d5; padding-left: 8px; margin-bottom: 6px; color: #65676b; font-size: 14px; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+.attachment, .location, .poll, .reactions { margin-top: 6px; font-size: 14px; }
+.attachment img { max-width: 100%; border-radius: 6px; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.RoomName}}</h1>
+<p>Exported on {{datetime .ExportedAt}} UTC</p>
+{{if .Since}}<p>Messages from {{datetime .Since}} UTC</p>{{end}}
+</header>
+<main>
+{{end}}
+
+{{define "message"}}{{if eq .Type "system"}}<div class="message system" id="m{{.ID}}">{{.SenderName}} {{.Content}} · {{datetime .CreatedAt}}</div>
+{{else}}<div class="message" id="m{{.ID}}">
+<div class="meta"><span class="sender">{{.SenderName}}</span> · {{datetime .CreatedAt}}{{if .IsForwarded}} · forwarded{{end}}{{if .EditedAt}} · edited{{end}}</div>
+{{with .ReplyTo}}<div class="reply"><a href="#m{{.ID}}">{{.SenderName}}</a>: {{.Content}}</div>{{end}}
+{{if .Content}}<div class="content">{{.Content}}</div>{{end}}
+{{with .Attachment}}<div class="attachment">{{if .File}}{{if eq .Type "image"}}<img src="{{.File}}" alt="{{.Filename}}">{{else}}<a href="{{.File}}">{{.Filename}}</a>{{end}}{{else}}{{.Filename}}{{end}}</div>{{end}}
+{{with .Location}}<div class="location">📍 {{.Latitude}}, {{.Longitude}}{{if .Address}} · {{.Address}}{{end}}</div>{{end}}
+{{with .Poll}}<div class="poll"><strong>{{.Question}}</strong><ul>{{range .Options}}<li>{{.}}</li>{{end}}</ul></div>{{end}}
+{{if .Reactions}}<div class="reactions">{{range $i, $r := .Reactions}}{{if $i}}, {{end}}{{$r.Emoji}} {{$r.UserName}}{{end}}</div>{{end}}
+</div>
+{{end}}{{end}}
+
+{{define "end"}}</main>
+</body>
+</html>
+{{end}}`))
+
+func (h *htmlExportWriter) Begin(header chatExportHeader) error {
+	return htmlExportTemplates.ExecuteTemplate(h.w, "begin", header)
+}
+
+func (h *htmlExportWriter) WriteMessage(message chatExportMessage) error {
+	return htmlExportTemplates.ExecuteTemplate(h.w, "message", message)
+}
+
+func (h *htmlExportWriter) End() error {
+	if err := htmlExportTemplates.ExecuteTemplate(h.w, "end", nil); err != nil {
+		return err
+	}
+	return h.w.Flush()
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"time"
+)
+
+const (
+	MaxForwardMessages = 50
+	MaxForwardRooms    = 10
+)
+
+// ForwardMessages copies messages into rooms the user belongs to. The copies point
+// back to the original message and sender, unless the sender turned forward
+// attribution off. Target rooms are all checked before anything is sent.
+func (s *ChatService) ForwardMessages(userID uint, messageIDs, roomIDs []uint) ([]postgres.Message, error) {
+	messageIDs = uniqueIDs(messageIDs)
+	roomIDs = uniqueIDs(roomIDs)
+	if len(messageIDs) > MaxForwardMessages {
+		return nil, fmt.Errorf("cannot forward more than %d messages at once", MaxForwardMessages)
+	}
+	if len(roomIDs) > MaxForwardRooms {
+		return nil, fmt.Errorf("cannot forward to more than %d rooms at once", MaxForwardRooms)
+	}
+
+	sources, err := s.getForwardableMessages(userID, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]*postgres.ChatRoom, len(roomIDs))
+	for i, roomID := range roomIDs {
+		room, err := s.checkForwardTarget(roomID, userID, sources)
+		if err != nil {
+			return nil, err
+		}
+		rooms[i] = room
+	}
+
+	var forwarded []postgres.Message
+	for _, room := range rooms {
+		var lastCreatedAt time.Time
+		for _, source := range sources {
+			message := newForwardedMessage(&source, userID, room.ID)
+			applyDisappearingTimer(room, message)
+
+			if err := s.repos.Message.Create(message); err != nil {
+				log.Printf("Failed to forward message %d to room %d: %v", source.ID, room.ID, err)
+				continue
+			}
+			lastCreatedAt = message.CreatedAt
+
+			s.emitToRoom(room.ID, userID, models.MessageTypeChatReceiveMessage, message)
+			forwarded = append(forwarded, *message)
+		}
+
+		if !lastCreatedAt.IsZero() {
+			if err := s.repos.ChatRoom.UpdateLastActivity(room.ID, lastCreatedAt); err != nil {
+				log.Printf("Failed to update last activity of room %d: %v", room.ID, err)
+			}
+		}
+	}
+
+	if len(forwarded) == 0 {
+		return nil, fmt.Errorf("failed to forward messages")
+	}
+	return forwarded, nil
+}
+
+// getForwardableMessages loads the messages to forward, in the order they were sent
+func (s *ChatService) getForwardableMessages(userID uint, messageIDs []uint) ([]postgres.Message, error) {
+	messages, err := s.repos.Message.GetByIDs(messageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if len(messages) != len(messageIDs) {
+		return nil, fmt.Errorf("message not found")
+	}
+
+	now := time.Now()
+	checkedRooms := map[uint]bool{}
+	for _, message := range messages {
+		if !checkedRooms[message.ChatRoomID] {
+			if _, err := s.repos.Participant.GetByRoomAndUser(message.ChatRoomID, userID); err != nil {
+				return nil, fmt.Errorf("message not found")
+			}
+			checkedRooms[message.ChatRoomID] = true
+		}
+
+		if message.ExpiresAt != nil && !message.ExpiresAt.After(now) {
+			return nil, fmt.Errorf("message not found")
+		}
+
+		switch {
+		case message.Type == postgres.MessageTypeSystem:
+			return nil, fmt.Errorf("system messages cannot be forwarded")
+		case message.Type == postgres.MessageTypePoll || message.Poll != nil:
+			return nil, fmt.Errorf("polls cannot be forwarded")
+		case message.EncryptedContent != "":
+			return nil, fmt.Errorf("encrypted messages cannot be forwarded")
+		}
+	}
+	return messages, nil
+}
+
+// checkForwardTarget checks that the user can post every message in a room
+func (s *ChatService) checkForwardTarget(roomID, userID uint, messages []postgres.Message) (*postgres.ChatRoom, error) {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room %d", roomID)
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	// A forward counts as a single send for slow mode
+	if err := s.checkCanPost(room, participant); err != nil {
+		return nil, fmt.Errorf("cannot forward to room %d: %w", roomID, err)
+	}
+
+	for _, message := range messages {
+		if err := checkMediaAllowed(room, message.Type); err != nil {
+			return nil, fmt.Errorf("permission denied: %v in room %d", err, roomID)
+		}
+	}
+	return room, nil
+}
+
+// checkMediaAllowed applies the sharing settings of a room to a message type
+func checkMediaAllowed(room *postgres.ChatRoom, messageType postgres.MessageType) error {
+	switch messageType {
+	case postgres.MessageTypeImage:
+		if !room.Settings.AllowImageSharing {
+			return fmt.Errorf("image sharing is disabled")
+		}
+	case postgres.MessageTypeVideo:
+		if !room.Settings.AllowVideoSharing {
+			return fmt.Errorf("video sharing is disabled")
+		}
+	case postgres.MessageTypeFile, postgres.MessageTypeAudio:
+		if !room.Settings.AllowFileSharing {
+			return fmt.Errorf("file sharing is disabled")
+		}
+	}
+	return nil
+}
+
+// newForwardedMessage copies a message for another room. Forwarding a forward
+// keeps the attribution to the first sender.
+func newForwardedMessage(source *postgres.Message, userID, roomID uint) *postgres.Message {
+	message := &postgres.Message{
+		ChatRoomID:    roomID,
+		SenderID:      userID,
+		Type:          source.Type,
+		Content:       source.Content,
+		IsForwarded:   true,
+		LinkPreviewID: source.LinkPreviewID,
+	}
+
+	if source.Media != nil {
+		media := *source.Media
+		message.Media = &media
+	}
+	if source.Location != nil {
+		// Forwards of a live location are a snapshot of it
+		location := *source.Location
+		location.LiveUntil = nil
+		message.Location = &location
+	}
+
+	switch {
+	case source.IsForwarded:
+		message.ForwardedFromID = source.ForwardedFromID
+		message.OriginalSenderID = source.OriginalSenderID
+	case source.Sender != nil && source.Sender.Settings.ForwardAttribution():
+		message.ForwardedFromID = &source.ID
+		message.OriginalSenderID = &source.SenderID
+	}
+	return message
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
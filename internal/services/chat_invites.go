@@ -0,0 +1,524 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/models/responses"
+	"time"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+)
+
+const (
+	ChatInviteTTL        = 7 * 24 * time.Hour
+	InviteExpiryInterval = 10 * time.Minute
+	inviteLinkTokenBytes = 16
+)
+
+// InviteUser invites a user to a group room
+func (s *ChatService) InviteUser(roomID, inviterID uint, req requests.InviteUserRequest) (*postgres.ChatInvite, error) {
+	room, err := s.checkInvitePermission(roomID, inviterID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repos.User.GetByID(req.UserID); err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, req.UserID); err == nil {
+		return nil, fmt.Errorf("user is already in the room")
+	}
+	if err := s.checkNotBanned(roomID, req.UserID); err != nil {
+		return nil, err
+	}
+	if _, err := s.repos.ChatInvite.GetPendingInvite(roomID, req.UserID); err == nil {
+		return nil, fmt.Errorf("user is already invited")
+	}
+
+	invite := &postgres.ChatInvite{
+		ChatRoomID: roomID,
+		InviterID:  inviterID,
+		InviteeID:  req.UserID,
+		Status:     string(postgres.ChatInviteStatusPending),
+		Message:    req.Message,
+		ExpiresAt:  time.Now().Add(ChatInviteTTL),
+	}
+	if err := s.repos.ChatInvite.Create(invite); err != nil {
+		return nil, fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	invite.ChatRoom = *room
+	s.emit(ChatEvent{
+		Type:    models.MessageTypeChatInvite,
+		From:    inviterID,
+		RoomID:  roomID,
+		UserIDs: []uint{req.UserID},
+		Data:    invite,
+	})
+	return invite, nil
+}
+
+// GetUserInvites lists the invites received by a user
+func (s *ChatService) GetUserInvites(userID uint, req requests.GetChatInvitesRequest) (*responses.ChatInvitesResponse, error) {
+	cursor := paginator.Cursor{
+		Before: &req.Before,
+		After:  &req.After,
+	}
+
+	invites, next, err := s.repos.ChatInvite.GetUserInvites(userID, req.Status, cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invites: %w", err)
+	}
+
+	return &responses.ChatInvitesResponse{
+		Invites:    invites,
+		NextCursor: &next,
+	}, nil
+}
+
+// GetRoomInvites lists the invites sent for a room, for the members allowed to invite
+func (s *ChatService) GetRoomInvites(roomID, userID uint, req requests.GetChatInvitesRequest) (*responses.ChatInvitesResponse, error) {
+	if _, err := s.checkInvitePermission(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	cursor := paginator.Cursor{
+		Before: &req.Before,
+		After:  &req.After,
+	}
+
+	invites, next, err := s.repos.ChatInvite.GetRoomInvites(roomID, cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invites: %w", err)
+	}
+
+	return &responses.ChatInvitesResponse{
+		Invites:    invites,
+		NextCursor: &next,
+	}, nil
+}
+
+// AcceptInvite adds the invitee to the room
+func (s *ChatService) AcceptInvite(inviteID, userID uint) (*postgres.ChatInvite, error) {
+	invite, err := s.getPendingInvite(inviteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repos.Participant.GetByRoomAndUser(invite.ChatRoomID, userID); err == nil {
+		return nil, fmt.Errorf("user is already in the room")
+	}
+
+	// The inviter must still be allowed to add members
+	room, err := s.checkCanAddParticipant(invite.ChatRoomID, userID, invite.InviterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the accept that answers the invite adds the user
+	accepted, err := s.repos.ChatInvite.AcceptInvite(inviteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invite: %w", err)
+	}
+	if !accepted {
+		return nil, fmt.Errorf("invite was already answered")
+	}
+
+	if err := s.addParticipant(room, userID); err != nil {
+		// The invite can be accepted again
+		if err := s.repos.ChatInvite.Update(inviteID, map[string]interface{}{"status": string(postgres.ChatInviteStatusPending)}); err != nil {
+			log.Printf("Failed to reopen invite %d: %v", inviteID, err)
+		}
+		return nil, err
+	}
+	invite.Status = string(postgres.ChatInviteStatusAccepted)
+
+	s.announceJoin(invite.ChatRoomID, userID, "Joined the room")
+
+	s.emitInviteUpdate(invite)
+	return invite, nil
+}
+
+// DeclineInvite declines an invite
+func (s *ChatService) DeclineInvite(inviteID, userID uint) (*postgres.ChatInvite, error) {
+	invite, err := s.getPendingInvite(inviteID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	declined, err := s.repos.ChatInvite.DeclineInvite(inviteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decline invite: %w", err)
+	}
+	if !declined {
+		return nil, fmt.Errorf("invite was already answered")
+	}
+	invite.Status = string(postgres.ChatInviteStatusDeclined)
+
+	s.emitInviteUpdate(invite)
+	return invite, nil
+}
+
+func (s *ChatService) getPendingInvite(inviteID, userID uint) (*postgres.ChatInvite, error) {
+	invite, err := s.repos.ChatInvite.GetByID(inviteID)
+	if err != nil {
+		return nil, fmt.Errorf("invite not found")
+	}
+	if invite.InviteeID != userID {
+		return nil, fmt.Errorf("permission denied: invite is for another user")
+	}
+	if invite.Status != string(postgres.ChatInviteStatusPending) {
+		return nil, fmt.Errorf("invite is already %s", invite.Status)
+	}
+	if !invite.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("invite has expired")
+	}
+	return invite, nil
+}
+
+// emitInviteUpdate tells the inviter that an invite was answered
+func (s *ChatService) emitInviteUpdate(invite *postgres.ChatInvite) {
+	s.emit(ChatEvent{
+		Type:    models.MessageTypeChatInviteUpdated,
+		From:    invite.InviteeID,
+		RoomID:  invite.ChatRoomID,
+		UserIDs: []uint{invite.InviterID},
+		Data:    invite,
+	})
+}
+
+// CreateInviteLink creates a shareable link to join a group room
+func (s *ChatService) CreateInviteLink(roomID, userID uint, req requests.CreateInviteLinkRequest) (*postgres.ChatInviteLink, error) {
+	if _, err := s.checkInvitePermission(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expires_at must be in the future")
+	}
+
+	token, err := generateInviteLinkToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invite link: %w", err)
+	}
+
+	link := &postgres.ChatInviteLink{
+		ChatRoomID:       roomID,
+		CreatorID:        userID,
+		Token:            token,
+		Name:             req.Name,
+		ExpiresAt:        req.ExpiresAt,
+		MaxUses:          req.MaxUses,
+		RequiresApproval: req.RequiresApproval,
+	}
+	if err := s.repos.ChatInviteLink.Create(link); err != nil {
+		return nil, fmt.Errorf("failed to create invite link: %w", err)
+	}
+	return link, nil
+}
+
+// GetInviteLinks lists the invite links of a room
+func (s *ChatService) GetInviteLinks(roomID, userID uint) ([]postgres.ChatInviteLink, error) {
+	if _, err := s.checkInvitePermission(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	links, err := s.repos.ChatInviteLink.GetRoomLinks(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite links: %w", err)
+	}
+	return links, nil
+}
+
+// RevokeInviteLink stops a link from being used, only its creator or an admin can revoke it
+func (s *ChatService) RevokeInviteLink(linkID, userID uint) error {
+	link, err := s.repos.ChatInviteLink.GetByID(linkID)
+	if err != nil {
+		return fmt.Errorf("invite link not found")
+	}
+
+	if link.CreatorID != userID && !s.isRoomAdmin(link.ChatRoomID, userID) {
+		return fmt.Errorf("permission denied: only the creator or an admin can revoke this link")
+	}
+	if link.RevokedAt != nil {
+		return nil
+	}
+
+	if err := s.repos.ChatInviteLink.Update(linkID, map[string]interface{}{"revoked_at": time.Now()}); err != nil {
+		return fmt.Errorf("failed to revoke invite link: %w", err)
+	}
+	return nil
+}
+
+// GetInviteLinkPreview returns the link with its room so a user can decide to join
+func (s *ChatService) GetInviteLinkPreview(token string) (*postgres.ChatInviteLink, error) {
+	link, err := s.repos.ChatInviteLink.GetByToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("invite link not found")
+	}
+	if err := checkInviteLinkUsable(link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// JoinByInviteLink adds the user to the room of a link, or queues a join request
+// when the link requires approval
+func (s *ChatService) JoinByInviteLink(token string, userID uint) (*responses.JoinByInviteLinkResponse, error) {
+	link, err := s.GetInviteLinkPreview(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repos.Participant.GetByRoomAndUser(link.ChatRoomID, userID); err == nil {
+		return nil, fmt.Errorf("user is already in the room")
+	}
+	channel := s.roomType(link.ChatRoomID) == postgres.ChatRoomTypeChannel
+	if channel {
+		subscribed, err := s.repos.Channel.IsSubscribed(link.ChatRoomID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check subscription: %w", err)
+		}
+		if subscribed {
+			return nil, fmt.Errorf("already subscribed to this channel")
+		}
+	}
+	if err := s.checkNotBanned(link.ChatRoomID, userID); err != nil {
+		return nil, err
+	}
+
+	// Everything is checked before a use of the link is counted, the use and
+	// the join then happen in one transaction
+	now := time.Now()
+	if link.RequiresApproval {
+		if pending, err := s.repos.ChatJoinRequest.GetPending(link.ChatRoomID, userID); err == nil {
+			return &responses.JoinByInviteLinkResponse{Request: pending}, nil
+		}
+
+		request := &postgres.ChatJoinRequest{
+			ChatRoomID:   link.ChatRoomID,
+			UserID:       userID,
+			InviteLinkID: link.ID,
+			Status:       postgres.ChatJoinRequestStatusPending,
+		}
+		used, err := s.repos.ChatInviteLink.UseToRequest(link.ID, now, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create join request: %w", err)
+		}
+		if !used {
+			return nil, fmt.Errorf("invite link is no longer valid")
+		}
+
+		s.emitToRoomAdmins(link.ChatRoomID, userID, models.MessageTypeChatJoinRequest, request)
+		return &responses.JoinByInviteLinkResponse{Request: request}, nil
+	}
+
+	// A valid link is the permission to join, whatever its creator can do now
+	var used bool
+	if channel {
+		used, err = s.repos.ChatInviteLink.UseToSubscribe(link.ID, now, link.ChatRoomID, userID)
+	} else {
+		used, err = s.repos.ChatInviteLink.UseToJoin(link.ID, now, &postgres.Participant{
+			ChatRoomID: link.ChatRoomID,
+			UserID:     userID,
+			Role:       postgres.ParticipantRoleMember,
+			JoinedAt:   now,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to join room: %w", err)
+	}
+	if !used {
+		// A join that raced this one is not counted as a use either
+		if _, err := s.repos.Participant.GetByRoomAndUser(link.ChatRoomID, userID); err == nil {
+			return nil, fmt.Errorf("user is already in the room")
+		}
+		return nil, fmt.Errorf("invite link is no longer valid")
+	}
+
+	if channel {
+		s.emitSubscriptionChange(link.ChatRoomID, userID, models.MessageTypeChannelSubscribed)
+	}
+	s.announceJoin(link.ChatRoomID, userID, "Joined via invite link")
+
+	return &responses.JoinByInviteLinkResponse{Joined: true, Room: link.ChatRoom}, nil
+}
+
+// GetJoinRequests lists the join requests of a room
+func (s *ChatService) GetJoinRequests(roomID, userID uint, status postgres.ChatJoinRequestStatus) ([]postgres.ChatJoinRequest, error) {
+	if !s.isRoomAdmin(roomID, userID) {
+		return nil, fmt.Errorf("permission denied: only admins can review join requests")
+	}
+
+	joinRequests, err := s.repos.ChatJoinRequest.GetRoomRequests(roomID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get join requests: %w", err)
+	}
+	return joinRequests, nil
+}
+
+// ReviewJoinRequest approves or rejects a pending join request
+func (s *ChatService) ReviewJoinRequest(requestID, userID uint, approve bool) (*postgres.ChatJoinRequest, error) {
+	request, err := s.repos.ChatJoinRequest.GetByID(requestID)
+	if err != nil {
+		return nil, fmt.Errorf("join request not found")
+	}
+
+	if !s.isRoomAdmin(request.ChatRoomID, userID) {
+		return nil, fmt.Errorf("permission denied: only admins can review join requests")
+	}
+	if request.Status != postgres.ChatJoinRequestStatusPending {
+		return nil, fmt.Errorf("join request is already %s", request.Status)
+	}
+
+	status := postgres.ChatJoinRequestStatusRejected
+	if approve {
+		status = postgres.ChatJoinRequestStatusApproved
+		if err := s.AddParticipant(request.ChatRoomID, request.UserID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	err = s.repos.ChatJoinRequest.Update(requestID, map[string]interface{}{
+		"status":      status,
+		"reviewed_by": userID,
+		"reviewed_at": now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update join request: %w", err)
+	}
+	request.Status = status
+	request.ReviewedBy = &userID
+	request.ReviewedAt = &now
+
+	if approve {
+		s.announceJoin(request.ChatRoomID, request.UserID, "Joined via invite link")
+	}
+
+	s.emit(ChatEvent{
+		Type:    models.MessageTypeChatJoinRequestUpdated,
+		From:    userID,
+		RoomID:  request.ChatRoomID,
+		UserIDs: []uint{request.UserID},
+		Data:    request,
+	})
+	return request, nil
+}
+
+// checkInvitePermission checks that a user can invite others to a group room
+func (s *ChatService) checkInvitePermission(roomID, userID uint) (*postgres.ChatRoom, error) {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.Type == postgres.ChatRoomTypePrivate {
+		return nil, fmt.Errorf("cannot invite users to a private room")
+	}
+
+	if room.Settings.OnlyAdminsCanInvite &&
+		participant.Role != postgres.ParticipantRoleAdmin && participant.Role != postgres.ParticipantRoleOwner {
+		return nil, fmt.Errorf("permission denied: only admins can invite users")
+	}
+	return room, nil
+}
+
+// announceJoin posts the system message of a new member, channel subscribers
+// join silently
+func (s *ChatService) announceJoin(roomID, userID uint, content string) {
+	if s.roomType(roomID) == postgres.ChatRoomTypeChannel {
+		return
+	}
+	if _, err := s.createSystemMessage(roomID, userID, content); err != nil {
+		log.Printf("Failed to post join of user %d in room %d: %v", userID, roomID, err)
+	}
+}
+
+func (s *ChatService) isRoomAdmin(roomID, userID uint) bool {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return false
+	}
+	return participant.Role == postgres.ParticipantRoleAdmin || participant.Role == postgres.ParticipantRoleOwner
+}
+
+// emitToRoomAdmins pushes an event to the admins and owner of a room
+func (s *ChatService) emitToRoomAdmins(roomID, from uint, eventType models.MessageType, data interface{}) {
+	participants, err := s.repos.ChatRoom.GetParticipants(roomID)
+	if err != nil {
+		log.Printf("Failed to get participants of room %d: %v", roomID, err)
+		return
+	}
+
+	var adminIDs []uint
+	for _, participant := range participants {
+		if participant.Role == postgres.ParticipantRoleAdmin || participant.Role == postgres.ParticipantRoleOwner {
+			adminIDs = append(adminIDs, participant.UserID)
+		}
+	}
+
+	s.emit(ChatEvent{
+		Type:    eventType,
+		From:    from,
+		RoomID:  roomID,
+		UserIDs: adminIDs,
+		Data:    data,
+	})
+}
+
+func checkInviteLinkUsable(link *postgres.ChatInviteLink) error {
+	switch {
+	case link.RevokedAt != nil:
+		return fmt.Errorf("invite link has been revoked")
+	case link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()):
+		return fmt.Errorf("invite link has expired")
+	case link.MaxUses > 0 && link.UseCount >= link.MaxUses:
+		return fmt.Errorf("invite link has reached its maximum uses")
+	}
+	return nil
+}
+
+func generateInviteLinkToken() (string, error) {
+	b := make([]byte, inviteLinkTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ExpireInvites marks the pending invites past their expiry as expired
+func (s *ChatService) ExpireInvites() {
+	if err := s.repos.ChatInvite.ExpireOldInvites(context.Background()); err != nil {
+		log.Printf("Failed to expire old invites: %v", err)
+	}
+}
+
+// startInviteExpiryJob starts background job to expire old invites
+func (s *ChatService) startInviteExpiryJob() {
+	ticker := time.NewTicker(InviteExpiryInterval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.ExpireInvites()
+			case <-s.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
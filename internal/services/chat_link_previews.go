@@ -0,0 +1,46 @@
+package services
+
+import (
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/utils"
+	"strings"
+)
+
+// resolveLinkPreview unfurls the first URL of a text message in the background
+// and pushes the updated message to the room once the preview is ready,
+// unless the message was deleted or its link edited out meanwhile
+func (s *ChatService) resolveLinkPreview(message *postgres.Message) {
+	if s.linkPreviews == nil || message.Type != postgres.MessageTypeText || message.LinkPreviewID != nil {
+		return
+	}
+
+	// Copy what the goroutine needs, the message may still be serialized elsewhere
+	updated := *message
+	go func() {
+		preview := s.linkPreviews.PreviewText(updated.Content)
+		if preview == nil {
+			return
+		}
+
+		url := utils.ExtractFirstURL(updated.Content)
+		current, err := s.repos.Message.GetByID(updated.ID)
+		if err != nil || !strings.Contains(current.Content, url) {
+			return
+		}
+
+		err = s.repos.Message.Update(updated.ID, map[string]interface{}{
+			"link_preview_id": preview.ID,
+		})
+		if err != nil {
+			log.Printf("Failed to attach link preview to message %d: %v", updated.ID, err)
+			return
+		}
+
+		updated.Content = current.Content
+		updated.LinkPreviewID = &preview.ID
+		updated.LinkPreview = preview
+		s.emitToRoom(updated.ChatRoomID, updated.SenderID, models.MessageTypeMessageUpdated, &updated)
+	}()
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"sort"
+	"time"
+)
+
+const (
+	MinLiveLocationDuration = time.Minute
+	MaxLiveLocationDuration = 8 * time.Hour
+
+	// Positions are pushed to the room at most once per interval,
+	// the job flushes the latest position of throttled shares
+	LiveLocationUpdateInterval = 2 * time.Second
+)
+
+// liveLocationShare is an active live location, only kept in memory.
+// The position is persisted on the location message when sharing stops.
+type liveLocationShare struct {
+	location      models.LiveLocationMessage
+	lastBroadcast time.Time
+	pending       bool
+}
+
+// StartLiveLocation posts a location message and starts sharing the position
+// of the user with the room. A previous share of the user in the room is stopped.
+func (s *ChatService) StartLiveLocation(userID uint, req models.StartLiveLocationMessage) (*postgres.Message, error) {
+	duration := time.Duration(req.Duration) * time.Second
+	if duration < MinLiveLocationDuration || duration > MaxLiveLocationDuration {
+		return nil, fmt.Errorf("duration must be between %s and %s", formatTimer(int(MinLiveLocationDuration.Seconds())), formatTimer(int(MaxLiveLocationDuration.Seconds())))
+	}
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+		return nil, err
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(req.RoomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	participant, err := s.repos.Participant.GetByRoomAndUser(req.RoomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+	if err := s.checkCanPost(room, participant); err != nil {
+		return nil, err
+	}
+
+	s.stopUserLiveLocations(req.RoomID, userID)
+
+	now := time.Now()
+	expiresAt := now.Add(duration)
+	message := &postgres.Message{
+		LocalID:    req.LocalID,
+		Type:       postgres.MessageTypeLocation,
+		SenderID:   userID,
+		ChatRoomID: req.RoomID,
+		Location: &postgres.MessageLocation{
+			Latitude:  req.Latitude,
+			Longitude: req.Longitude,
+			Accuracy:  req.Accuracy,
+			LiveUntil: &expiresAt,
+		},
+	}
+	applyDisappearingTimer(room, message)
+
+	if err := s.repos.Message.Create(message); err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+
+	if err := s.repos.ChatRoom.UpdateLastActivity(req.RoomID, message.CreatedAt); err != nil {
+		log.Printf("Failed to update last activity of room %d: %v", req.RoomID, err)
+	}
+
+	s.liveLocationMutex.Lock()
+	s.liveLocations[message.ID] = &liveLocationShare{
+		location: models.LiveLocationMessage{
+			MessageID: message.ID,
+			RoomID:    req.RoomID,
+			UserID:    userID,
+			Latitude:  req.Latitude,
+			Longitude: req.Longitude,
+			Accuracy:  req.Accuracy,
+			UpdatedAt: now,
+			ExpiresAt: expiresAt,
+		},
+		lastBroadcast: now,
+	}
+	s.liveLocationMutex.Unlock()
+
+	s.emitToRoom(req.RoomID, userID, models.MessageTypeChatReceiveMessage, message)
+	return message, nil
+}
+
+// UpdateLiveLocation records the new position of a live location. The room
+// gets it right away unless the last position was pushed too recently.
+func (s *ChatService) UpdateLiveLocation(userID uint, req models.UpdateLiveLocationMessage) error {
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.liveLocationMutex.Lock()
+	share, exists := s.liveLocations[req.MessageID]
+	if !exists || share.location.UserID != userID {
+		s.liveLocationMutex.Unlock()
+		return fmt.Errorf("live location not found")
+	}
+
+	share.location.Latitude = req.Latitude
+	share.location.Longitude = req.Longitude
+	share.location.Accuracy = req.Accuracy
+	share.location.Heading = req.Heading
+	share.location.UpdatedAt = now
+
+	if now.Sub(share.lastBroadcast) < LiveLocationUpdateInterval {
+		share.pending = true
+		s.liveLocationMutex.Unlock()
+		return nil
+	}
+
+	share.lastBroadcast = now
+	share.pending = false
+	location := share.location
+	s.liveLocationMutex.Unlock()
+
+	s.broadcastLiveLocation(location)
+	return nil
+}
+
+// StopLiveLocation ends a live location before it expires
+func (s *ChatService) StopLiveLocation(userID, messageID uint) error {
+	s.liveLocationMutex.Lock()
+	share, exists := s.liveLocations[messageID]
+	if !exists || share.location.UserID != userID {
+		s.liveLocationMutex.Unlock()
+		return fmt.Errorf("live location not found")
+	}
+	delete(s.liveLocations, messageID)
+	s.liveLocationMutex.Unlock()
+
+	s.finishLiveLocation(share.location, time.Now())
+	return nil
+}
+
+// GetLiveLocations returns the live locations currently shared in a room
+func (s *ChatService) GetLiveLocations(roomID, userID uint) ([]models.LiveLocationMessage, error) {
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	s.liveLocationMutex.Lock()
+	locations := []models.LiveLocationMessage{}
+	for _, share := range s.liveLocations {
+		if share.location.RoomID == roomID {
+			locations = append(locations, share.location)
+		}
+	}
+	s.liveLocationMutex.Unlock()
+
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].MessageID < locations[j].MessageID
+	})
+	return locations, nil
+}
+
+// stopUserLiveLocations ends the live locations of a user in a room, e.g. when
+// they leave it or start a new one
+func (s *ChatService) stopUserLiveLocations(roomID, userID uint) {
+	var stopped []models.LiveLocationMessage
+
+	s.liveLocationMutex.Lock()
+	for messageID, share := range s.liveLocations {
+		if share.location.RoomID == roomID && share.location.UserID == userID {
+			stopped = append(stopped, share.location)
+			delete(s.liveLocations, messageID)
+		}
+	}
+	s.liveLocationMutex.Unlock()
+
+	now := time.Now()
+	for _, location := range stopped {
+		s.finishLiveLocation(location, now)
+	}
+}
+
+// dropLiveLocation forgets a live location whose message was deleted
+func (s *ChatService) dropLiveLocation(messageID uint) {
+	s.liveLocationMutex.Lock()
+	delete(s.liveLocations, messageID)
+	s.liveLocationMutex.Unlock()
+}
+
+// broadcastLiveLocation pushes a position to the room, the share is stopped
+// when the user is no longer a participant
+func (s *ChatService) broadcastLiveLocation(location models.LiveLocationMessage) {
+	if _, err := s.repos.Participant.GetByRoomAndUser(location.RoomID, location.UserID); err != nil {
+		s.stopUserLiveLocations(location.RoomID, location.UserID)
+		return
+	}
+
+	s.emitToRoom(location.RoomID, location.UserID, models.MessageTypeLiveLocationUpdated, location)
+}
+
+// finishLiveLocation persists the last position on the location message
+// and tells the room that sharing stopped
+func (s *ChatService) finishLiveLocation(location models.LiveLocationMessage, stoppedAt time.Time) {
+	err := s.repos.Message.Update(location.MessageID, map[string]interface{}{
+		"location_latitude":   location.Latitude,
+		"location_longitude":  location.Longitude,
+		"location_accuracy":   location.Accuracy,
+		"location_live_until": stoppedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to save live location of message %d: %v", location.MessageID, err)
+	}
+
+	location.ExpiresAt = stoppedAt
+	s.emitToRoom(location.RoomID, location.UserID, models.MessageTypeLiveLocationStopped, location)
+}
+
+// ProcessLiveLocations pushes throttled positions and stops expired shares
+func (s *ChatService) ProcessLiveLocations() {
+	now := time.Now()
+	var pending, expired []models.LiveLocationMessage
+
+	s.liveLocationMutex.Lock()
+	for messageID, share := range s.liveLocations {
+		switch {
+		case !now.Before(share.location.ExpiresAt):
+			expired = append(expired, share.location)
+			delete(s.liveLocations, messageID)
+		case share.pending && now.Sub(share.lastBroadcast) >= LiveLocationUpdateInterval:
+			share.pending = false
+			share.lastBroadcast = now
+			pending = append(pending, share.location)
+		}
+	}
+	s.liveLocationMutex.Unlock()
+
+	for _, location := range pending {
+		s.broadcastLiveLocation(location)
+	}
+	for _, location := range expired {
+		s.finishLiveLocation(location, location.ExpiresAt)
+	}
+}
+
+// stopAllLiveLocations persists every active share, used on shutdown
+func (s *ChatService) stopAllLiveLocations() {
+	s.liveLocationMutex.Lock()
+	shares := s.liveLocations
+	s.liveLocations = make(map[uint]*liveLocationShare)
+	s.liveLocationMutex.Unlock()
+
+	now := time.Now()
+	for _, share := range shares {
+		s.finishLiveLocation(share.location, now)
+	}
+}
+
+// startLiveLocationJob starts background job to flush and expire live locations
+func (s *ChatService) startLiveLocationJob() {
+	ticker := time.NewTicker(LiveLocationUpdateInterval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.ProcessLiveLocations()
+			case <-s.stopChan:
+				ticker.Stop()
+				s.stopAllLiveLocations()
+				return
+			}
+		}
+	}()
+}
+
+func validateCoordinates(latitude, longitude float64) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("invalid coordinates")
+	}
+	return nil
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/models/responses"
+	"time"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+)
+
+const (
+	MaxMuteDuration    = 30 * 24 * 60 * 60 // 30 days
+	MaxSlowModeSeconds = 6 * 60 * 60       // 6 hours
+)
+
+// KickMember removes a member from a group room, they can come back with a new invite
+func (s *ChatService) KickMember(roomID, actorID, targetID uint, reason string) error {
+	if _, err := s.checkModerator(roomID, actorID); err != nil {
+		return err
+	}
+
+	target, err := s.checkModerationTarget(roomID, actorID, targetID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("user not found in room")
+	}
+
+	if err := s.repos.ChatRoom.RemoveParticipant(roomID, targetID); err != nil {
+		return fmt.Errorf("failed to remove participant: %w", err)
+	}
+	s.stopUserLiveLocations(roomID, targetID)
+	s.emitAccessLost(roomID, targetID)
+
+	s.logModeration(&postgres.ChatModerationLog{
+		ChatRoomID:   roomID,
+		ActorID:      actorID,
+		TargetUserID: &targetID,
+		Action:       postgres.ChatModerationActionKick,
+		Reason:       reason,
+	})
+	return nil
+}
+
+// BanMember removes a user from a group room and keeps them from joining again,
+// users who are not members can be banned too
+func (s *ChatService) BanMember(roomID, actorID, targetID uint, reason string) (*postgres.ChatRoomBan, error) {
+	if _, err := s.checkModerator(roomID, actorID); err != nil {
+		return nil, err
+	}
+
+	target, err := s.checkModerationTarget(roomID, actorID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		if _, err := s.repos.User.GetByID(targetID); err != nil {
+			return nil, fmt.Errorf("user not found")
+		}
+	}
+
+	ban := &postgres.ChatRoomBan{
+		ChatRoomID: roomID,
+		UserID:     targetID,
+		BannedBy:   actorID,
+		Reason:     reason,
+	}
+	if err := s.repos.ChatRoomBan.Create(ban); err != nil {
+		return nil, fmt.Errorf("failed to ban user: %w", err)
+	}
+
+	if target != nil {
+		if err := s.repos.ChatRoom.RemoveParticipant(roomID, targetID); err != nil {
+			return nil, fmt.Errorf("failed to remove participant: %w", err)
+		}
+		s.stopUserLiveLocations(roomID, targetID)
+	}
+	if s.roomType(roomID) == postgres.ChatRoomTypeChannel {
+		s.removeChannelSubscriber(roomID, targetID)
+	}
+	s.emitAccessLost(roomID, targetID)
+
+	s.logModeration(&postgres.ChatModerationLog{
+		ChatRoomID:   roomID,
+		ActorID:      actorID,
+		TargetUserID: &targetID,
+		Action:       postgres.ChatModerationActionBan,
+		Reason:       reason,
+	})
+	return ban, nil
+}
+
+// UnbanMember lifts a ban, the user still needs an invite to come back
+func (s *ChatService) UnbanMember(roomID, actorID, targetID uint) error {
+	if _, err := s.checkModerator(roomID, actorID); err != nil {
+		return err
+	}
+
+	banned, err := s.repos.ChatRoomBan.IsBanned(roomID, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to get ban: %w", err)
+	}
+	if !banned {
+		return fmt.Errorf("ban not found")
+	}
+
+	if err := s.repos.ChatRoomBan.Delete(roomID, targetID); err != nil {
+		return fmt.Errorf("failed to unban user: %w", err)
+	}
+
+	s.logModeration(&postgres.ChatModerationLog{
+		ChatRoomID:   roomID,
+		ActorID:      actorID,
+		TargetUserID: &targetID,
+		Action:       postgres.ChatModerationActionUnban,
+	})
+	return nil
+}
+
+// GetRoomBans lists the users banned from a room, admins only
+func (s *ChatService) GetRoomBans(roomID, userID uint) ([]postgres.ChatRoomBan, error) {
+	if _, err := s.checkModerator(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	bans, err := s.repos.ChatRoomBan.GetRoomBans(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bans: %w", err)
+	}
+	return bans, nil
+}
+
+// MuteMember keeps a member from posting for duration seconds, 0 mutes until unmuted
+func (s *ChatService) MuteMember(roomID, actorID, targetID uint, duration int, reason string) error {
+	if _, err := s.checkModerator(roomID, actorID); err != nil {
+		return err
+	}
+
+	target, err := s.checkModerationTarget(roomID, actorID, targetID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("user not found in room")
+	}
+
+	if duration < 0 || duration > MaxMuteDuration {
+		return fmt.Errorf("duration must be between 0 and %d seconds", MaxMuteDuration)
+	}
+
+	var mutedUntil *time.Time
+	if duration > 0 {
+		until := time.Now().Add(time.Duration(duration) * time.Second)
+		mutedUntil = &until
+	}
+
+	if err := s.repos.Participant.MuteParticipant(roomID, targetID, true, mutedUntil); err != nil {
+		return fmt.Errorf("failed to mute participant: %w", err)
+	}
+
+	s.logModeration(&postgres.ChatModerationLog{
+		ChatRoomID:   roomID,
+		ActorID:      actorID,
+		TargetUserID: &targetID,
+		Action:       postgres.ChatModerationActionMute,
+		Reason:       reason,
+		Duration:     duration,
+	})
+	return nil
+}
+
+// UnmuteMember lets a muted member post again
+func (s *ChatService) UnmuteMember(roomID, actorID, targetID uint) error {
+	if _, err := s.checkModerator(roomID, actorID); err != nil {
+		return err
+	}
+
+	target, err := s.checkModerationTarget(roomID, actorID, targetID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("user not found in room")
+	}
+	if !isMuted(target, time.Now()) {
+		return fmt.Errorf("user is not muted")
+	}
+
+	if err := s.repos.Participant.MuteParticipant(roomID, targetID, false, nil); err != nil {
+		return fmt.Errorf("failed to unmute participant: %w", err)
+	}
+
+	s.logModeration(&postgres.ChatModerationLog{
+		ChatRoomID:   roomID,
+		ActorID:      actorID,
+		TargetUserID: &targetID,
+		Action:       postgres.ChatModerationActionUnmute,
+	})
+	return nil
+}
+
+// SetSlowMode sets the minimum interval between two messages of a member,
+// 0 turns slow mode off. Admins are not limited.
+func (s *ChatService) SetSlowMode(roomID, userID uint, seconds int) (*postgres.ChatRoom, error) {
+	room, err := s.checkModerator(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if seconds < 0 || seconds > MaxSlowModeSeconds {
+		return nil, fmt.Errorf("slow mode must be between 0 and %d seconds", MaxSlowModeSeconds)
+	}
+
+	err = s.repos.ChatRoom.Update(roomID, map[string]interface{}{
+		"settings_slow_mode_seconds": seconds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update slow mode: %w", err)
+	}
+	room.Settings.SlowModeSeconds = seconds
+
+	content := "Slow mode turned off"
+	if seconds > 0 {
+		content = fmt.Sprintf("Slow mode set to %s", formatTimer(seconds))
+	}
+	if _, err := s.createSystemMessage(roomID, userID, content); err != nil {
+		log.Printf("Failed to post slow mode change in room %d: %v", roomID, err)
+	}
+
+	s.logModeration(&postgres.ChatModerationLog{
+		ChatRoomID: roomID,
+		ActorID:    userID,
+		Action:     postgres.ChatModerationActionSlowMode,
+		Duration:   seconds,
+	})
+	return room, nil
+}
+
+// GetModerationLog lists the moderation actions of a room, admins only
+func (s *ChatService) GetModerationLog(roomID, userID uint, req requests.GetModerationLogRequest) (*responses.ChatModerationLogResponse, error) {
+	if _, err := s.checkModerator(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	cursor := paginator.Cursor{
+		Before: &req.Before,
+		After:  &req.After,
+	}
+
+	entries, next, err := s.repos.ChatModeration.GetRoomLogs(roomID, cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get moderation log: %w", err)
+	}
+
+	return &responses.ChatModerationLogResponse{
+		Entries:    entries,
+		NextCursor: &next,
+	}, nil
+}
+
+// checkCanPost enforces the posting rules of a room: admin only posting, mutes and
+// slow mode. Admins and owners are exempt, and the only ones posting in channels.
+func (s *ChatService) checkCanPost(room *postgres.ChatRoom, participant *postgres.Participant) error {
+	if participant.Role == postgres.ParticipantRoleAdmin || participant.Role == postgres.ParticipantRoleOwner {
+		return nil
+	}
+
+	if room.Type == postgres.ChatRoomTypeChannel {
+		return fmt.Errorf("permission denied: only admins can post in this channel")
+	}
+
+	if room.Settings.OnlyAdminsCanPost {
+		return fmt.Errorf("permission denied: only admins can post in this room")
+	}
+
+	now := time.Now()
+	if isMuted(participant, now) {
+		if participant.MutedUntil != nil {
+			return fmt.Errorf("permission denied: muted until %s", participant.MutedUntil.Format(time.RFC3339))
+		}
+		return fmt.Errorf("permission denied: muted in this room")
+	}
+
+	if room.Settings.SlowModeSeconds > 0 {
+		lastSentAt, err := s.repos.Message.GetLastSentAt(room.ID, participant.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to check slow mode: %w", err)
+		}
+		if lastSentAt != nil {
+			wait := lastSentAt.Add(time.Duration(room.Settings.SlowModeSeconds) * time.Second).Sub(now)
+			if wait > 0 {
+				return fmt.Errorf("slow mode is on, wait %d seconds before sending another message", int(math.Ceil(wait.Seconds())))
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkNotBanned refuses users banned from a room
+func (s *ChatService) checkNotBanned(roomID, userID uint) error {
+	banned, err := s.repos.ChatRoomBan.IsBanned(roomID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check ban: %w", err)
+	}
+	if banned {
+		return fmt.Errorf("permission denied: user is banned from this room")
+	}
+	return nil
+}
+
+// checkModerator checks that a user can moderate a group room
+func (s *ChatService) checkModerator(roomID, userID uint) (*postgres.ChatRoom, error) {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	if participant.Role != postgres.ParticipantRoleAdmin && participant.Role != postgres.ParticipantRoleOwner {
+		return nil, fmt.Errorf("permission denied: only admins can moderate the room")
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	if room.Type == postgres.ChatRoomTypePrivate {
+		return nil, fmt.Errorf("private rooms cannot be moderated")
+	}
+	return room, nil
+}
+
+// checkModerationTarget checks that the actor outranks the target. Returns the
+// participant of the target, nil if the target is not in the room.
+func (s *ChatService) checkModerationTarget(roomID, actorID, targetID uint) (*postgres.Participant, error) {
+	if actorID == targetID {
+		return nil, fmt.Errorf("cannot moderate yourself")
+	}
+
+	target, err := s.repos.Participant.GetByRoomAndUser(roomID, targetID)
+	if err != nil {
+		return nil, nil
+	}
+
+	switch target.Role {
+	case postgres.ParticipantRoleOwner:
+		return nil, fmt.Errorf("permission denied: cannot moderate the owner")
+	case postgres.ParticipantRoleAdmin:
+		actor, err := s.repos.Participant.GetByRoomAndUser(roomID, actorID)
+		if err != nil || actor.Role != postgres.ParticipantRoleOwner {
+			return nil, fmt.Errorf("permission denied: only the owner can moderate admins")
+		}
+	}
+	return target, nil
+}
+
+// logModeration records a moderation action and pushes it to the room and
+// the moderated user, who may no longer be a participant
+func (s *ChatService) logModeration(entry *postgres.ChatModerationLog) {
+	if err := s.repos.ChatModeration.Create(entry); err != nil {
+		log.Printf("Failed to log %s in room %d: %v", entry.Action, entry.ChatRoomID, err)
+	}
+
+	participants, err := s.repos.ChatRoom.GetParticipants(entry.ChatRoomID)
+	if err != nil {
+		log.Printf("Failed to get participants of room %d: %v", entry.ChatRoomID, err)
+		return
+	}
+
+	userIDs := make([]uint, 0, len(participants)+1)
+	targetInRoom := false
+	for _, participant := range participants {
+		userIDs = append(userIDs, participant.UserID)
+		if entry.TargetUserID != nil && participant.UserID == *entry.TargetUserID {
+			targetInRoom = true
+		}
+	}
+	if entry.TargetUserID != nil && !targetInRoom {
+		userIDs = append(userIDs, *entry.TargetUserID)
+	}
+
+	s.emit(ChatEvent{
+		Type:    models.MessageTypeChatModeration,
+		From:    entry.ActorID,
+		RoomID:  entry.ChatRoomID,
+		UserIDs: userIDs,
+		Data:    entry,
+	})
+}
+
+func isMuted(participant *postgres.Participant, now time.Time) bool {
+	return participant.IsMuted && (participant.MutedUntil == nil || participant.MutedUntil.After(now))
+}
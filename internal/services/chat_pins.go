@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"time"
+)
+
+const MaxPinnedMessages = 10
+
+// PinMessage pins a message in its room
+func (s *ChatService) PinMessage(messageID, userID uint) (*postgres.PinnedMessage, error) {
+	message, err := s.repos.Message.GetByID(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("message not found")
+	}
+
+	if err := s.checkPinPermission(message.ChatRoomID, userID); err != nil {
+		return nil, err
+	}
+
+	if message.Type == postgres.MessageTypeSystem {
+		return nil, fmt.Errorf("system messages cannot be pinned")
+	}
+
+	if _, err := s.repos.PinnedMessage.GetByMessageID(messageID); err == nil {
+		return nil, fmt.Errorf("message is already pinned")
+	}
+
+	pin := &postgres.PinnedMessage{
+		ChatRoomID: message.ChatRoomID,
+		MessageID:  messageID,
+		PinnedBy:   userID,
+		PinnedAt:   time.Now(),
+	}
+	created, err := s.repos.PinnedMessage.CreateWithinLimit(pin, MaxPinnedMessages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin message: %w", err)
+	}
+	if !created {
+		return nil, fmt.Errorf("a room can have at most %d pinned messages", MaxPinnedMessages)
+	}
+	pin.Message = message
+
+	if _, err := s.createSystemMessage(message.ChatRoomID, userID, "Pinned a message"); err != nil {
+		log.Printf("Failed to post pin change in room %d: %v", message.ChatRoomID, err)
+	}
+	s.emitPinChange(models.MessageTypeMessagePinned, message.ChatRoomID, messageID, userID)
+
+	return pin, nil
+}
+
+// UnpinMessage removes a message from the pins of its room
+func (s *ChatService) UnpinMessage(messageID, userID uint) error {
+	pin, err := s.repos.PinnedMessage.GetByMessageID(messageID)
+	if err != nil {
+		return fmt.Errorf("pinned message not found")
+	}
+
+	if err := s.checkPinPermission(pin.ChatRoomID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repos.PinnedMessage.Delete(messageID); err != nil {
+		return fmt.Errorf("failed to unpin message: %w", err)
+	}
+
+	if _, err := s.createSystemMessage(pin.ChatRoomID, userID, "Unpinned a message"); err != nil {
+		log.Printf("Failed to post pin change in room %d: %v", pin.ChatRoomID, err)
+	}
+	s.emitPinChange(models.MessageTypeMessageUnpinned, pin.ChatRoomID, messageID, userID)
+
+	return nil
+}
+
+// GetPinnedMessages returns the pinned messages of a room
+func (s *ChatService) GetPinnedMessages(roomID, userID uint) ([]postgres.PinnedMessage, error) {
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	pins, err := s.repos.PinnedMessage.GetRoomPins(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned messages: %w", err)
+	}
+
+	messages := make([]*postgres.Message, 0, len(pins))
+	for i := range pins {
+		if pins[i].Message != nil {
+			messages = append(messages, pins[i].Message)
+		}
+	}
+	s.loadReactionSummaries(messages, userID)
+	return pins, nil
+}
+
+// unpinDeletedMessage drops the pin of a deleted message, if any
+func (s *ChatService) unpinDeletedMessage(message *postgres.Message, userID uint) {
+	if _, err := s.repos.PinnedMessage.GetByMessageID(message.ID); err != nil {
+		return
+	}
+
+	if err := s.repos.PinnedMessage.Delete(message.ID); err != nil {
+		log.Printf("Failed to unpin deleted message %d: %v", message.ID, err)
+		return
+	}
+	s.emitPinChange(models.MessageTypeMessageUnpinned, message.ChatRoomID, message.ID, userID)
+}
+
+func (s *ChatService) checkPinPermission(roomID, userID uint) error {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return fmt.Errorf("permission denied: user not in room")
+	}
+
+	if participant.Role == postgres.ParticipantRoleAdmin || participant.Role == postgres.ParticipantRoleOwner {
+		return nil
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get room: %w", err)
+	}
+	if room.Type == postgres.ChatRoomTypePrivate || room.Settings.AllowMembersToPin {
+		return nil
+	}
+
+	return fmt.Errorf("permission denied: only admins can pin messages")
+}
+
+func (s *ChatService) emitPinChange(eventType models.MessageType, roomID, messageID, userID uint) {
+	pins, err := s.repos.PinnedMessage.GetRoomPins(roomID)
+	if err != nil {
+		log.Printf("Failed to get pinned messages of room %d: %v", roomID, err)
+	}
+
+	s.emitToRoom(roomID, userID, eventType, models.MessagePinMessage{
+		RoomID:         roomID,
+		MessageID:      messageID,
+		UserID:         userID,
+		PinnedMessages: pins,
+	})
+}
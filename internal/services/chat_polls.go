@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+)
+
+// CreatePoll posts a poll message to a room
+func (s *ChatService) CreatePoll(roomID, userID uint, req requests.CreatePollRequest) (*postgres.Message, error) {
+	if err := s.CheckUserPermission(roomID, userID, "send_message"); err != nil {
+		return nil, err
+	}
+
+	poll, err := newPoll(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	message := &postgres.Message{
+		Content:    poll.Question,
+		LocalID:    req.LocalID,
+		Type:       postgres.MessageTypePoll,
+		SenderID:   userID,
+		ChatRoomID: roomID,
+	}
+	applyDisappearingTimer(room, message)
+
+	if err := s.repos.Poll.CreateWithMessage(poll, message); err != nil {
+		return nil, fmt.Errorf("failed to create poll: %w", err)
+	}
+	message.Poll = poll
+
+	if err := s.repos.ChatRoom.UpdateLastActivity(roomID, message.CreatedAt); err != nil {
+		log.Printf("Failed to update last activity of room %d: %v", roomID, err)
+	}
+
+	s.emitToRoom(roomID, userID, models.MessageTypeChatReceiveMessage, message)
+	return message, nil
+}
+
+// attachMessagePolls loads the poll results of the poll messages
+func (s *ChatService) attachMessagePolls(messages []postgres.Message, userID uint) {
+	var polls []*postgres.Poll
+	for i := range messages {
+		if messages[i].Poll != nil {
+			polls = append(polls, messages[i].Poll)
+		}
+	}
+	attachPollResults(s.repos.Poll, polls, userID)
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/models/responses"
+	"strings"
+	"unicode"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+)
+
+const (
+	MaxChatRoomEmojis   = 100
+	MaxAllowedReactions = 100
+	maxReactionLength   = 50
+)
+
+var customEmojiShortcodePattern = regexp.MustCompile(`^[a-z0-9_]{2,32}$`)
+
+// AddReaction reacts to a message and pushes the new summary to the room.
+// Returns the summary of the message.
+func (s *ChatService) AddReaction(messageID, userID uint, emoji string) ([]postgres.ReactionCount, error) {
+	message, err := s.getReactableMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emoji = strings.TrimSpace(emoji)
+	if err := s.checkReactionAllowed(message.ChatRoomID, emoji); err != nil {
+		return nil, err
+	}
+
+	added, err := s.repos.Message.AddReaction(messageID, userID, emoji)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add reaction: %w", err)
+	}
+	return s.reactionChanged(message, userID, emoji, added, models.MessageTypeReactionAdd)
+}
+
+// RemoveReaction removes a reaction of the user and pushes the new summary to the room
+func (s *ChatService) RemoveReaction(messageID, userID uint, emoji string) ([]postgres.ReactionCount, error) {
+	message, err := s.getReactableMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emoji = strings.TrimSpace(emoji)
+	removed, err := s.repos.Message.RemoveReaction(messageID, userID, emoji)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove reaction: %w", err)
+	}
+	return s.reactionChanged(message, userID, emoji, removed, models.MessageTypeReactionRemove)
+}
+
+// GetReactionUsers lists the users who reacted to a message with an emoji.
+// Subscribers of a channel do not see each other, only its staff can.
+func (s *ChatService) GetReactionUsers(messageID, userID uint, req requests.GetReactionUsersRequest) (*responses.ReactionUsersResponse, error) {
+	message, err := s.getReactableMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if s.roomType(message.ChatRoomID) == postgres.ChatRoomTypeChannel && !s.isRoomAdmin(message.ChatRoomID, userID) {
+		return nil, fmt.Errorf("permission denied: only the staff of a channel can see who reacted")
+	}
+
+	cursor := paginator.Cursor{
+		Before: &req.Before,
+		After:  &req.After,
+	}
+	reactions, next, err := s.repos.Message.GetReactionUsers(messageID, strings.TrimSpace(req.Emoji), cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reactions: %w", err)
+	}
+
+	return &responses.ReactionUsersResponse{
+		Reactions:  reactions,
+		NextCursor: &next,
+	}, nil
+}
+
+// GetRoomEmojis lists the custom emoji of a room
+func (s *ChatService) GetRoomEmojis(roomID, userID uint) ([]postgres.ChatRoomEmoji, error) {
+	if err := s.checkCanReact(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	emojis, err := s.repos.ChatRoomEmoji.GetRoomEmojis(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get emojis: %w", err)
+	}
+	return emojis, nil
+}
+
+// CreateRoomEmoji adds a custom emoji to a room, admins only. The image is
+// uploaded by the handler.
+func (s *ChatService) CreateRoomEmoji(roomID, userID uint, shortcode, url string) (*postgres.ChatRoomEmoji, error) {
+	if !s.isRoomAdmin(roomID, userID) {
+		return nil, fmt.Errorf("permission denied: only admins can add emojis")
+	}
+
+	shortcode = strings.ToLower(strings.Trim(strings.TrimSpace(shortcode), ":"))
+	if !customEmojiShortcodePattern.MatchString(shortcode) {
+		return nil, fmt.Errorf("invalid shortcode, use 2 to 32 letters, digits or underscores")
+	}
+
+	count, err := s.repos.ChatRoomEmoji.CountRoomEmojis(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count emojis: %w", err)
+	}
+	if count >= MaxChatRoomEmojis {
+		return nil, fmt.Errorf("a room can have at most %d custom emojis", MaxChatRoomEmojis)
+	}
+
+	emojis, err := s.customEmojiURLs(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := emojis[shortcode]; exists {
+		return nil, fmt.Errorf("emoji :%s: already exists", shortcode)
+	}
+
+	emoji := &postgres.ChatRoomEmoji{
+		ChatRoomID: roomID,
+		Shortcode:  shortcode,
+		URL:        url,
+		CreatedBy:  userID,
+	}
+	if err := s.repos.ChatRoomEmoji.Create(emoji); err != nil {
+		return nil, fmt.Errorf("failed to create emoji: %w", err)
+	}
+	return emoji, nil
+}
+
+// DeleteRoomEmoji removes a custom emoji of a room, admins only. Reactions
+// already made with it stay, without an image. Returns the emoji so the
+// handler can delete its file.
+func (s *ChatService) DeleteRoomEmoji(roomID, emojiID, userID uint) (*postgres.ChatRoomEmoji, error) {
+	emoji, err := s.repos.ChatRoomEmoji.GetByID(emojiID)
+	if err != nil || emoji.ChatRoomID != roomID {
+		return nil, fmt.Errorf("emoji not found")
+	}
+	if !s.isRoomAdmin(roomID, userID) {
+		return nil, fmt.Errorf("permission denied: only admins can delete emojis")
+	}
+
+	if err := s.repos.ChatRoomEmoji.Delete(emojiID); err != nil {
+		return nil, fmt.Errorf("failed to delete emoji: %w", err)
+	}
+	return emoji, nil
+}
+
+// reactionChanged pushes the new counts of a message to the room when a
+// reaction was added or removed, and returns the summary for the user.
+// The pushed summary is the same for everyone, so it has no reacted_by_me,
+// and in channels it does not say who reacted.
+func (s *ChatService) reactionChanged(message *postgres.Message, userID uint, emoji string, changed bool, eventType models.MessageType) ([]postgres.ReactionCount, error) {
+	if changed {
+		counts := *message
+		if err := s.loadReactionSummaryList([]*postgres.Message{&counts}, 0); err != nil {
+			return nil, fmt.Errorf("failed to get reactions: %w", err)
+		}
+
+		event := models.ReactionMessage{
+			MessageID: message.ID,
+			Emoji:     emoji,
+			RoomID:    message.ChatRoomID,
+			UserID:    userID,
+			Summary:   counts.ReactionSummary,
+		}
+		from := userID
+		if s.roomType(message.ChatRoomID) == postgres.ChatRoomTypeChannel {
+			event.UserID, from = 0, 0
+		}
+		s.emitToRoom(message.ChatRoomID, from, eventType, event)
+	}
+
+	messages := []*postgres.Message{message}
+	if err := s.loadReactionSummaryList(messages, userID); err != nil {
+		return nil, fmt.Errorf("failed to get reactions: %w", err)
+	}
+	return message.ReactionSummary, nil
+}
+
+// getReactableMessage loads a message the user can see and react to
+func (s *ChatService) getReactableMessage(messageID, userID uint) (*postgres.Message, error) {
+	message, err := s.repos.Message.GetByID(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("message not found")
+	}
+	if err := s.checkCanReact(message.ChatRoomID, userID); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// checkCanReact allows the participants of a room and the subscribers of a channel
+func (s *ChatService) checkCanReact(roomID, userID uint) error {
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err == nil {
+		return nil
+	}
+
+	if s.roomType(roomID) == postgres.ChatRoomTypeChannel {
+		subscribed, err := s.repos.Channel.IsSubscribed(roomID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to check subscription: %w", err)
+		}
+		if subscribed {
+			return nil
+		}
+	}
+	return fmt.Errorf("permission denied: user not in room")
+}
+
+// checkReactionAllowed accepts an emoji, or a custom emoji of the room as
+// :shortcode:, that is on the allow-list of the room if it has one
+func (s *ChatService) checkReactionAllowed(roomID uint, emoji string) error {
+	if shortcode, ok := customEmojiShortcode(emoji); ok {
+		emojis, err := s.customEmojiURLs(roomID)
+		if err != nil {
+			return err
+		}
+		if _, exists := emojis[shortcode]; !exists {
+			return fmt.Errorf("unknown emoji %s", emoji)
+		}
+	} else if !isEmoji(emoji) {
+		return fmt.Errorf("invalid reaction, use an emoji")
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get room: %w", err)
+	}
+	if len(room.Settings.AllowedReactions) == 0 {
+		return nil
+	}
+	for _, allowed := range room.Settings.AllowedReactions {
+		if allowed == emoji {
+			return nil
+		}
+	}
+	return fmt.Errorf("permission denied: %s is not allowed in this room", emoji)
+}
+
+// allowedReactionsUpdate validates a new allow-list of a room and returns the
+// value of its column
+func (s *ChatService) allowedReactionsUpdate(roomID uint, reactions []string) (string, error) {
+	if len(reactions) > MaxAllowedReactions {
+		return "", fmt.Errorf("at most %d reactions can be allowed", MaxAllowedReactions)
+	}
+
+	var emojis map[string]string
+	seen := make(map[string]bool, len(reactions))
+	allowed := make([]string, 0, len(reactions))
+	for _, reaction := range reactions {
+		reaction = strings.TrimSpace(reaction)
+		if seen[reaction] {
+			continue
+		}
+
+		if shortcode, ok := customEmojiShortcode(reaction); ok {
+			if emojis == nil {
+				var err error
+				if emojis, err = s.customEmojiURLs(roomID); err != nil {
+					return "", err
+				}
+			}
+			if _, exists := emojis[shortcode]; !exists {
+				return "", fmt.Errorf("unknown emoji %s", reaction)
+			}
+		} else if !isEmoji(reaction) {
+			return "", fmt.Errorf("invalid reaction %q, use an emoji", reaction)
+		}
+
+		seen[reaction] = true
+		allowed = append(allowed, reaction)
+	}
+
+	// Written as JSON since map updates skip the serializer of the column
+	value, err := json.Marshal(allowed)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode allowed reactions: %w", err)
+	}
+	return string(value), nil
+}
+
+// attachMessageReactions loads the reaction summaries of messages for userID
+func (s *ChatService) attachMessageReactions(messages []postgres.Message, userID uint) {
+	list := make([]*postgres.Message, len(messages))
+	for i := range messages {
+		list[i] = &messages[i]
+	}
+	s.loadReactionSummaries(list, userID)
+}
+
+func (s *ChatService) loadReactionSummaries(messages []*postgres.Message, userID uint) {
+	if err := s.loadReactionSummaryList(messages, userID); err != nil {
+		log.Printf("Failed to load reaction summaries: %v", err)
+	}
+}
+
+func (s *ChatService) loadReactionSummaryList(messages []*postgres.Message, userID uint) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	summaries, err := s.repos.Message.GetReactionSummaries(ids, userID)
+	if err != nil {
+		return err
+	}
+
+	// Custom emoji are loaded once per room
+	roomEmojis := make(map[uint]map[string]string)
+	for _, message := range messages {
+		summary := summaries[message.ID]
+		if summary == nil {
+			summary = []postgres.ReactionCount{}
+		}
+
+		for i := range summary {
+			shortcode, ok := customEmojiShortcode(summary[i].Emoji)
+			if !ok {
+				continue
+			}
+			emojis, loaded := roomEmojis[message.ChatRoomID]
+			if !loaded {
+				if emojis, err = s.customEmojiURLs(message.ChatRoomID); err != nil {
+					return err
+				}
+				roomEmojis[message.ChatRoomID] = emojis
+			}
+			summary[i].URL = emojis[shortcode]
+		}
+		message.ReactionSummary = summary
+	}
+	return nil
+}
+
+// customEmojiURLs maps the shortcodes of the custom emoji of a room to their image
+func (s *ChatService) customEmojiURLs(roomID uint) (map[string]string, error) {
+	emojis, err := s.repos.ChatRoomEmoji.GetRoomEmojis(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get emojis: %w", err)
+	}
+
+	urls := make(map[string]string, len(emojis))
+	for _, emoji := range emojis {
+		urls[emoji.Shortcode] = emoji.URL
+	}
+	return urls, nil
+}
+
+// customEmojiShortcode returns the shortcode of a :shortcode: reaction
+func customEmojiShortcode(reaction string) (string, bool) {
+	if len(reaction) < 3 || !strings.HasPrefix(reaction, ":") || !strings.HasSuffix(reaction, ":") {
+		return "", false
+	}
+	shortcode := reaction[1 : len(reaction)-1]
+	return shortcode, customEmojiShortcodePattern.MatchString(shortcode)
+}
+
+// isEmoji accepts a single emoji sequence: no letters or spaces, digits and
+// # or * only as keycaps
+func isEmoji(reaction string) bool {
+	if reaction == "" || len(reaction) > maxReactionLength {
+		return false
+	}
+
+	keycap := strings.ContainsRune(reaction, '⃣')
+	for _, r := range reaction {
+		switch {
+		case r < 0x80:
+			if !keycap || !(r >= '0' && r <= '9' || r == '#' || r == '*') {
+				return false
+			}
+		case unicode.IsLetter(r), unicode.IsSpace(r):
+			return false
+		}
+	}
+	return true
+}
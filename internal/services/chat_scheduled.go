@@ -0,0 +1,269 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"time"
+)
+
+const (
+	MaxScheduleAhead          = 365 * 24 * time.Hour
+	ScheduledMessageInterval  = 5 * time.Second
+	scheduledMessageBatchSize = 100
+	// Messages stuck in sending longer than this are retried
+	scheduledMessageStaleAfter = 5 * time.Minute
+)
+
+// ScheduleMessage stores a message to be delivered at req.SendAt.
+// It stays invisible to the other participants until delivery.
+func (s *ChatService) ScheduleMessage(senderID uint, req models.SendChatMessageMessage) (*postgres.ScheduledMessage, error) {
+	if req.SendAt == nil {
+		return nil, fmt.Errorf("send_at is required")
+	}
+
+	if err := s.CheckUserPermission(req.RoomID, senderID, "send_message"); err != nil {
+		return nil, err
+	}
+
+	if err := validateSendAt(*req.SendAt); err != nil {
+		return nil, err
+	}
+
+	if req.Content == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+
+	scheduled := &postgres.ScheduledMessage{
+		LocalID:    req.LocalID,
+		ChatRoomID: req.RoomID,
+		SenderID:   senderID,
+		Content:    req.Content,
+		SendAt:     req.SendAt.UTC(),
+		Status:     postgres.ScheduledMessageStatusPending,
+	}
+
+	if err := s.repos.ScheduledMessage.Create(scheduled); err != nil {
+		return nil, fmt.Errorf("failed to schedule message: %w", err)
+	}
+	return scheduled, nil
+}
+
+// GetScheduledMessages returns the pending scheduled messages of a user in a room
+func (s *ChatService) GetScheduledMessages(roomID, userID uint) ([]postgres.ScheduledMessage, error) {
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
+	messages, err := s.repos.ScheduledMessage.GetUserRoomMessages(userID, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled messages: %w", err)
+	}
+	return messages, nil
+}
+
+// UpdateScheduledMessage edits the content or delivery time of a pending message
+func (s *ChatService) UpdateScheduledMessage(id, userID uint, req requests.UpdateScheduledMessageRequest) (*postgres.ScheduledMessage, error) {
+	scheduled, err := s.getPendingScheduledMessage(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Content != nil {
+		if *req.Content == "" {
+			return nil, fmt.Errorf("content is required")
+		}
+		updates["content"] = *req.Content
+		scheduled.Content = *req.Content
+	}
+	if req.SendAt != nil {
+		if err := validateSendAt(*req.SendAt); err != nil {
+			return nil, err
+		}
+		updates["send_at"] = req.SendAt.UTC()
+		scheduled.SendAt = req.SendAt.UTC()
+	}
+	if len(updates) == 0 {
+		return scheduled, nil
+	}
+
+	// The scheduler may have claimed it since it was read
+	updated, err := s.repos.ScheduledMessage.UpdateIfStatus(id, postgres.ScheduledMessageStatusPending, updates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update scheduled message: %w", err)
+	}
+	if !updated {
+		return nil, fmt.Errorf("scheduled message is already being sent")
+	}
+	return scheduled, nil
+}
+
+// CancelScheduledMessage cancels a pending message
+func (s *ChatService) CancelScheduledMessage(id, userID uint) error {
+	if _, err := s.getPendingScheduledMessage(id, userID); err != nil {
+		return err
+	}
+
+	cancelled, err := s.repos.ScheduledMessage.UpdateIfStatus(id, postgres.ScheduledMessageStatusPending, map[string]interface{}{
+		"status": postgres.ScheduledMessageStatusCancelled,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled message: %w", err)
+	}
+	if !cancelled {
+		return fmt.Errorf("scheduled message is already being sent")
+	}
+	return nil
+}
+
+func (s *ChatService) getPendingScheduledMessage(id, userID uint) (*postgres.ScheduledMessage, error) {
+	scheduled, err := s.repos.ScheduledMessage.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("scheduled message not found")
+	}
+	if scheduled.SenderID != userID {
+		return nil, fmt.Errorf("permission denied: only the sender can change a scheduled message")
+	}
+	if scheduled.Status != postgres.ScheduledMessageStatusPending {
+		return nil, fmt.Errorf("scheduled message is already %s", scheduled.Status)
+	}
+	return scheduled, nil
+}
+
+// DeliverDueMessages sends every scheduled message whose time has come
+func (s *ChatService) DeliverDueMessages() {
+	if err := s.repos.ScheduledMessage.ReleaseStale(time.Now().Add(-scheduledMessageStaleAfter)); err != nil {
+		log.Printf("Failed to release stale scheduled messages: %v", err)
+	}
+
+	for {
+		due, err := s.repos.ScheduledMessage.GetDueMessages(time.Now(), scheduledMessageBatchSize)
+		if err != nil {
+			log.Printf("Failed to get due scheduled messages: %v", err)
+			return
+		}
+
+		for _, scheduled := range due {
+			s.deliverScheduledMessage(scheduled)
+		}
+
+		if len(due) < scheduledMessageBatchSize {
+			return
+		}
+	}
+}
+
+func (s *ChatService) deliverScheduledMessage(scheduled postgres.ScheduledMessage) {
+	// Claimed before sending, another node or a cancel may have got it first
+	claimed, err := s.repos.ScheduledMessage.Claim(scheduled.ID)
+	if err != nil {
+		log.Printf("Failed to claim scheduled message %d: %v", scheduled.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	if scheduled.IsReminder {
+		s.deliverReminder(scheduled)
+		return
+	}
+
+	// Scheduled content is posted as typed, slash commands are not run
+	message, err := s.sendMessage(scheduled.SenderID, models.SendChatMessageMessage{
+		RoomID:  scheduled.ChatRoomID,
+		LocalID: scheduled.LocalID,
+		Content: scheduled.Content,
+	})
+	if err != nil {
+		log.Printf("Failed to deliver scheduled message %d: %v", scheduled.ID, err)
+
+		_, updateErr := s.repos.ScheduledMessage.UpdateIfStatus(scheduled.ID, postgres.ScheduledMessageStatusSending, map[string]interface{}{
+			"status": postgres.ScheduledMessageStatusFailed,
+			"error":  err.Error(),
+		})
+		if updateErr != nil {
+			// Left sending, it is retried once stale
+			log.Printf("Failed to mark scheduled message %d as failed: %v", scheduled.ID, updateErr)
+			return
+		}
+
+		scheduled.Status = postgres.ScheduledMessageStatusFailed
+		scheduled.Error = err.Error()
+		s.emit(ChatEvent{
+			Type:    models.MessageTypeScheduledMessageFailed,
+			RoomID:  scheduled.ChatRoomID,
+			UserIDs: []uint{scheduled.SenderID},
+			Data:    scheduled,
+		})
+		return
+	}
+
+	sent, err := s.repos.ScheduledMessage.UpdateIfStatus(scheduled.ID, postgres.ScheduledMessageStatusSending, map[string]interface{}{
+		"status":     postgres.ScheduledMessageStatusSent,
+		"message_id": message.ID,
+		"sent_at":    message.CreatedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to mark scheduled message %d as sent, it may be sent again: %v", scheduled.ID, err)
+	} else if !sent {
+		log.Printf("Scheduled message %d was released while it was sent, it may be sent again", scheduled.ID)
+	}
+}
+
+// deliverReminder pushes a /remind reminder back to the user who set it
+func (s *ChatService) deliverReminder(scheduled postgres.ScheduledMessage) {
+	now := time.Now()
+	sent, err := s.repos.ScheduledMessage.UpdateIfStatus(scheduled.ID, postgres.ScheduledMessageStatusSending, map[string]interface{}{
+		"status":  postgres.ScheduledMessageStatusSent,
+		"sent_at": now,
+	})
+	if err != nil {
+		// Left sending, it is delivered once released
+		log.Printf("Failed to mark reminder %d as sent: %v", scheduled.ID, err)
+		return
+	}
+	if !sent {
+		return
+	}
+
+	scheduled.Status = postgres.ScheduledMessageStatusSent
+	scheduled.SentAt = &now
+	s.emit(ChatEvent{
+		Type:    models.MessageTypeChatReminder,
+		RoomID:  scheduled.ChatRoomID,
+		UserIDs: []uint{scheduled.SenderID},
+		Data:    scheduled,
+	})
+}
+
+// startSchedulerJob starts background job to deliver scheduled messages
+func (s *ChatService) startSchedulerJob() {
+	ticker := time.NewTicker(ScheduledMessageInterval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.DeliverDueMessages()
+			case <-s.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func validateSendAt(sendAt time.Time) error {
+	now := time.Now()
+	if !sendAt.After(now) {
+		return fmt.Errorf("send_at must be in the future")
+	}
+	if sendAt.After(now.Add(MaxScheduleAhead)) {
+		return fmt.Errorf("send_at cannot be more than %d days ahead", int(MaxScheduleAhead.Hours()/24))
+	}
+	return nil
+}
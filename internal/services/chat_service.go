@@ -2,24 +2,61 @@ package services
 
 import (
 	"fmt"
+	"log"
 	"social_server/internal/models"
 	"social_server/internal/models/postgres"
 	"social_server/internal/models/requests"
 	"social_server/internal/models/responses"
 	"social_server/internal/repositories"
+	"social_server/internal/utils"
+	"sync"
 	"time"
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 )
 
 type ChatService struct {
-	repos *repositories.Repositories
+	repos          *repositories.Repositories
+	linkPreviews   *LinkPreviewService
+	webhooks       *utils.WebhookSender
+	commands       *SlashCommandRegistry
+	eventCallbacks []ChatEventCallback
+	eventMutex     sync.RWMutex
+	stopChan       chan bool
+
+	liveLocations     map[uint]*liveLocationShare // By message ID
+	liveLocationMutex sync.Mutex
+
+	roomTypes sync.Map // Room ID to ChatRoomType, used to route channel events
 }
 
-func NewChatService(repos *repositories.Repositories) *ChatService {
-	return &ChatService{
-		repos: repos,
+func NewChatService(repos *repositories.Repositories, linkPreviews *LinkPreviewService, webhooks *utils.WebhookSender) *ChatService {
+	service := &ChatService{
+		repos:        repos,
+		linkPreviews: linkPreviews,
+		webhooks:     webhooks,
+		commands:     NewSlashCommandRegistry(),
+		stopChan:     make(chan bool),
+
+		liveLocations: make(map[uint]*liveLocationShare),
 	}
+
+	service.registerBuiltinCommands()
+
+	// Start background jobs
+	service.startExpiryJob()
+	service.startSchedulerJob()
+	service.startInviteExpiryJob()
+	service.startLiveLocationJob()
+	service.startWebhookDeliveryJob()
+	service.startExportJob()
+
+	return service
+}
+
+// Stop stops the chat background jobs
+func (s *ChatService) Stop() {
+	close(s.stopChan)
 }
 
 func (s *ChatService) SyncRooms(userID uint, req requests.SyncChatRoomsRequest) (*responses.SyncChatRoomsResponse, error) {
@@ -28,6 +65,15 @@ func (s *ChatService) SyncRooms(userID uint, req requests.SyncChatRoomsRequest)
 		return nil, fmt.Errorf("failed to sync chat rooms: %w", err)
 	}
 
+	roomIDs := make([]uint, len(rooms))
+	for i, room := range rooms {
+		roomIDs[i] = room.ID
+	}
+	pinnedMessageMap, err := s.repos.PinnedMessage.GetRoomsPins(roomIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned messages: %w", err)
+	}
+
 	roomSummaries := make([]responses.ChatRoomSummary, len(rooms))
 	for i, room := range rooms {
 		name := room.Name
@@ -83,6 +129,7 @@ func (s *ChatService) SyncRooms(userID uint, req requests.SyncChatRoomsRequest)
 			UpdatedAt:        room.UpdatedAt,
 			ParticipantCount: len(room.Participants),
 			UnreadCount:      unreadCount,
+			PinnedMessages:   pinnedMessageMap[room.ID],
 		}
 	}
 
@@ -120,6 +167,11 @@ func (s *ChatService) GetRoomByUserId(userID uint, roomID uint) (*responses.Chat
 		unreadCount = 0
 	}
 
+	pinnedMessages, err := s.repos.PinnedMessage.GetRoomPins(room.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned messages: %w", err)
+	}
+
 	return &responses.ChatRoomSummary{
 		ID:      room.ID,
 		LocalID: room.LocalID,
@@ -133,11 +185,15 @@ func (s *ChatService) GetRoomByUserId(userID uint, roomID uint) (*responses.Chat
 		UpdatedAt:        room.UpdatedAt,
 		ParticipantCount: len(room.Participants),
 		UnreadCount:      unreadCount,
+		PinnedMessages:   pinnedMessages,
 	}, nil
 }
 
 // Room operations
 func (s *ChatService) CreateRoom(creatorID uint, req *requests.CreateChatRoomRequest) (*responses.ChatRoomSummary, error) {
+	if req.Type == postgres.ChatRoomTypeChannel {
+		return nil, fmt.Errorf("channels are created with the channel endpoint")
+	}
 
 	room, err := s.repos.ChatRoom.Create(req.Name, creatorID, req.Type, req.Participants, nil)
 	if err != nil {
@@ -182,6 +238,9 @@ func (s *ChatService) CreateRoom(creatorID uint, req *requests.CreateChatRoomReq
 	}, nil
 }
 func (s *ChatService) CreateRoomFromWs(creatorID uint, req *models.CreateChatRoomMessage) (*postgres.ChatRoom, error) {
+	if req.Type == postgres.ChatRoomTypeChannel {
+		return nil, fmt.Errorf("channels are created with the channel endpoint")
+	}
 
 	room, err := s.repos.ChatRoom.Create(req.Name, creatorID, req.Type, req.ParticipantIDs, &req.CreatedAt)
 	if err != nil {
@@ -197,6 +256,7 @@ func (s *ChatService) DeleteRoom(userID uint, roomID uint) error {
 		return fmt.Errorf("failed to delete chat room: %w", err)
 	}
 
+	s.emitAccessLost(roomID)
 	return nil
 }
 
@@ -288,6 +348,8 @@ func (s *ChatService) GetRoomMessages(roomID, userID uint, limit int, before str
 	if err != nil {
 		return nil, fmt.Errorf("failed to get room messages: %w", err)
 	}
+	s.attachMessagePolls(messages, userID)
+	s.attachMessageReactions(messages, userID)
 
 	return &responses.MessageResponse{
 		Messages:   messages,
@@ -300,6 +362,8 @@ func (s *ChatService) MarkMessageAsRead(messageID, userID uint) error {
 	if err != nil {
 		return fmt.Errorf("failed to mark message as read: %w", err)
 	}
+
+	s.startReadCountdown(messageID, userID)
 	return nil
 }
 
@@ -330,46 +394,56 @@ func (s *ChatService) DeleteMessage(messageID, userID uint) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete message: %w", err)
 	}
-	return nil
-}
 
-func (s *ChatService) AddReaction(messageID, userID uint, emoji string) error {
-	err := s.repos.Message.AddReaction(messageID, userID, emoji)
-	if err != nil {
-		return fmt.Errorf("failed to add reaction: %w", err)
-	}
+	s.unpinDeletedMessage(message, userID)
+	s.dropLiveLocation(messageID)
 	return nil
 }
 
-func (s *ChatService) RemoveReaction(messageID, userID uint, emoji string) error {
-	err := s.repos.Message.RemoveReaction(messageID, userID, emoji)
+// Participant operations
+func (s *ChatService) AddParticipant(roomID, userID, addedBy uint) error {
+	room, err := s.checkCanAddParticipant(roomID, userID, addedBy)
 	if err != nil {
-		return fmt.Errorf("failed to remove reaction: %w", err)
+		return err
 	}
-	return nil
+	return s.addParticipant(room, userID)
 }
 
-// Participant operations
-func (s *ChatService) AddParticipant(roomID, userID, addedBy uint) error {
+// checkCanAddParticipant checks that addedBy may add userID to the room
+// and returns the room
+func (s *ChatService) checkCanAddParticipant(roomID, userID, addedBy uint) (*postgres.ChatRoom, error) {
 	// Check if user adding has permission
 	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, addedBy)
 	if err != nil {
-		return fmt.Errorf("permission denied: user not in room")
+		return nil, fmt.Errorf("permission denied: user not in room")
 	}
 
 	// Check room settings
 	room, err := s.repos.ChatRoom.GetByID(roomID)
 	if err != nil {
-		return fmt.Errorf("failed to get room: %w", err)
+		return nil, fmt.Errorf("failed to get room: %w", err)
 	}
 
 	if room.Settings.OnlyAdminsCanInvite && participant.Role != postgres.ParticipantRoleAdmin && participant.Role != postgres.ParticipantRoleOwner {
-		return fmt.Errorf("permission denied: only admins can invite users")
+		return nil, fmt.Errorf("permission denied: only admins can invite users")
+	}
+
+	if err := s.checkNotBanned(roomID, userID); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// addParticipant adds a user checked by checkCanAddParticipant to the room
+func (s *ChatService) addParticipant(room *postgres.ChatRoom, userID uint) error {
+	// Channel readers are subscribers, only the staff are participants
+	if room.Type == postgres.ChatRoomTypeChannel {
+		return s.subscribeChannel(room.ID, userID)
 	}
 
 	// Add participant
 	newParticipant := &postgres.Participant{
-		ChatRoomID: roomID,
+		ChatRoomID: room.ID,
 		UserID:     userID,
 		Role:       postgres.ParticipantRoleMember,
 		JoinedAt:   time.Now(),
@@ -377,10 +451,13 @@ func (s *ChatService) AddParticipant(roomID, userID, addedBy uint) error {
 		UpdatedAt:  time.Now(),
 	}
 
-	err = s.repos.ChatRoom.AddParticipant(newParticipant)
+	added, err := s.repos.ChatRoom.AddParticipant(newParticipant)
 	if err != nil {
 		return fmt.Errorf("failed to add participant: %w", err)
 	}
+	if !added {
+		return fmt.Errorf("user is already in the room")
+	}
 
 	return nil
 }
@@ -415,14 +492,24 @@ func (s *ChatService) RemoveParticipant(roomID, userID, removedBy uint) error {
 		return fmt.Errorf("failed to remove participant: %w", err)
 	}
 
+	s.stopUserLiveLocations(roomID, userID)
+	s.emitAccessLost(roomID, userID)
 	return nil
 }
 
-func (s *ChatService) GetParticipants(roomID uint) ([]postgres.Participant, error) {
+func (s *ChatService) GetParticipants(roomID, userID uint) ([]postgres.Participant, error) {
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+
 	participants, err := s.repos.ChatRoom.GetParticipants(roomID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get participants: %w", err)
 	}
+
+	for i := range participants {
+		participants[i].IsBot = participants[i].User.IsBot
+	}
 	return participants, nil
 }
 
@@ -484,6 +571,23 @@ func (s *ChatService) GetUserStatus(userID uint) (*postgres.OnlineStatus, error)
 	return status, nil
 }
 
+// CheckRoomAccess allows the participants of a room, and the readers of a
+// channel
+func (s *ChatService) CheckRoomAccess(roomID, userID uint) error {
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return fmt.Errorf("room not found")
+	}
+	if room.Type == postgres.ChatRoomTypeChannel {
+		return s.checkCanReadChannel(room, userID)
+	}
+
+	if _, err := s.repos.Participant.GetByRoomAndUser(roomID, userID); err != nil {
+		return fmt.Errorf("user not in room")
+	}
+	return nil
+}
+
 func (s *ChatService) CheckUserPermission(roomID, userID uint, action string) error {
 	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
 	if err != nil {
@@ -492,14 +596,16 @@ func (s *ChatService) CheckUserPermission(roomID, userID uint, action string) er
 
 	switch action {
 	case "send_message":
-		// Check room settings
-		// This would need room data to check settings
-		return nil
+		room, err := s.repos.ChatRoom.GetByID(roomID)
+		if err != nil {
+			return fmt.Errorf("failed to get room: %w", err)
+		}
+		return s.checkCanPost(room, participant)
 	case "add_participant":
 		if participant.Role != postgres.ParticipantRoleAdmin && participant.Role != postgres.ParticipantRoleOwner {
 			return fmt.Errorf("permission denied")
 		}
-	case "remove_participant":
+	case "remove_participant", "moderate_members", "update_room":
 		if participant.Role != postgres.ParticipantRoleAdmin && participant.Role != postgres.ParticipantRoleOwner {
 			return fmt.Errorf("permission denied")
 		}
@@ -574,10 +680,138 @@ func (s *ChatService) UpdateRoom(roomID, userID uint, updates map[string]interfa
 	return nil
 }
 
+// UpdateRoomSettings changes the settings of a room, only the provided fields are updated
+func (s *ChatService) UpdateRoomSettings(roomID, userID uint, req requests.ChatRoomSettingsRequest) (*postgres.ChatRoom, error) {
+	updates := map[string]interface{}{}
+	if req.AllowFileSharing != nil {
+		updates["settings_allow_file_sharing"] = *req.AllowFileSharing
+	}
+	if req.AllowImageSharing != nil {
+		updates["settings_allow_image_sharing"] = *req.AllowImageSharing
+	}
+	if req.AllowVideoSharing != nil {
+		updates["settings_allow_video_sharing"] = *req.AllowVideoSharing
+	}
+	if req.OnlyAdminsCanPost != nil {
+		updates["settings_only_admins_can_post"] = *req.OnlyAdminsCanPost
+	}
+	if req.OnlyAdminsCanInvite != nil {
+		updates["settings_only_admins_can_invite"] = *req.OnlyAdminsCanInvite
+	}
+	if req.MessageEncryption != nil {
+		updates["settings_message_encryption"] = *req.MessageEncryption
+	}
+	if req.AllowMembersToPin != nil {
+		updates["settings_allow_members_to_pin"] = *req.AllowMembersToPin
+	}
+	if req.ShareHistoryWithNewMembers != nil {
+		updates["settings_share_history_with_new_members"] = *req.ShareHistoryWithNewMembers
+	}
+	if req.AllowedReactions != nil {
+		allowed, err := s.allowedReactionsUpdate(roomID, *req.AllowedReactions)
+		if err != nil {
+			return nil, err
+		}
+		updates["settings_allowed_reactions"] = allowed
+	}
+
+	if len(updates) > 0 {
+		if err := s.UpdateRoom(roomID, userID, updates); err != nil {
+			return nil, err
+		}
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	return room, nil
+}
+
 func (s *ChatService) CreateMessage(senderID uint, req models.SendChatMessageMessage) (*postgres.Message, error) {
-	message, err := s.repos.Message.Create(req.Content, req.LocalID, senderID, req.RoomID, req.CreatedAt)
+	room, err := s.repos.ChatRoom.GetByID(req.RoomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	participant, err := s.repos.Participant.GetByRoomAndUser(req.RoomID, senderID)
 	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+	if err := s.checkCanPost(room, participant); err != nil {
+		return nil, err
+	}
+
+	message := &postgres.Message{
+		Content:    req.Content,
+		LocalID:    req.LocalID,
+		Type:       postgres.MessageTypeText,
+		SenderID:   senderID,
+		ChatRoomID: req.RoomID,
+		CreatedAt:  req.CreatedAt,
+	}
+	applyDisappearingTimer(room, message)
+
+	if err := s.repos.Message.Create(message); err != nil {
 		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
 	return message, nil
 }
+
+// SendMessage creates a message and pushes it to the room participants. Messages
+// starting with a registered slash command run the command instead, commands that
+// do not post a message return ErrSlashCommandHandled.
+func (s *ChatService) SendMessage(senderID uint, req models.SendChatMessageMessage) (*postgres.Message, error) {
+	if command, text, ok := s.findSlashCommand(req.Content); ok {
+		return s.runSlashCommand(senderID, req, command, text)
+	}
+	return s.sendMessage(senderID, req)
+}
+
+func (s *ChatService) sendMessage(senderID uint, req models.SendChatMessageMessage) (*postgres.Message, error) {
+	message, err := s.CreateMessage(senderID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.deliverMessage(message)
+	s.dispatchOutgoingWebhooks(message)
+	return message, nil
+}
+
+// deliverMessage pushes a new message to the room participants
+func (s *ChatService) deliverMessage(message *postgres.Message) {
+	if err := s.repos.ChatRoom.UpdateLastActivity(message.ChatRoomID, message.CreatedAt); err != nil {
+		log.Printf("Failed to update last activity of room %d: %v", message.ChatRoomID, err)
+	}
+
+	s.emitToRoom(message.ChatRoomID, message.SenderID, models.MessageTypeChatReceiveMessage, message)
+	s.resolveLinkPreview(message)
+}
+
+// createSystemMessage posts a system message to a room and pushes it to the participants
+func (s *ChatService) createSystemMessage(roomID, actorID uint, content string) (*postgres.Message, error) {
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	message := &postgres.Message{
+		Content:    content,
+		Type:       postgres.MessageTypeSystem,
+		SenderID:   actorID,
+		ChatRoomID: roomID,
+	}
+	applyDisappearingTimer(room, message)
+
+	if err := s.repos.Message.Create(message); err != nil {
+		return nil, fmt.Errorf("failed to create system message: %w", err)
+	}
+
+	if err := s.repos.ChatRoom.UpdateLastActivity(roomID, message.CreatedAt); err != nil {
+		log.Printf("Failed to update last activity of room %d: %v", roomID, err)
+	}
+
+	s.emitToRoom(roomID, actorID, models.MessageTypeChatReceiveMessage, message)
+	return message, nil
+}
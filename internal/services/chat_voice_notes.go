@@ -0,0 +1,40 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+)
+
+// SendVoiceNote posts an uploaded voice note as an audio message
+func (s *ChatService) SendVoiceNote(senderID, roomID, localID uint, media postgres.MessageMedia) (*postgres.Message, error) {
+	if err := s.CheckUserPermission(roomID, senderID, "send_message"); err != nil {
+		return nil, err
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	message := &postgres.Message{
+		LocalID:    localID,
+		Type:       postgres.MessageTypeAudio,
+		SenderID:   senderID,
+		ChatRoomID: roomID,
+		Media:      &media,
+	}
+	applyDisappearingTimer(room, message)
+
+	if err := s.repos.Message.Create(message); err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+
+	if err := s.repos.ChatRoom.UpdateLastActivity(roomID, message.CreatedAt); err != nil {
+		log.Printf("Failed to update last activity of room %d: %v", roomID, err)
+	}
+
+	s.emitToRoom(roomID, senderID, models.MessageTypeChatReceiveMessage, message)
+	return message, nil
+}
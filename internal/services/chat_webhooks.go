@@ -0,0 +1,417 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/models/responses"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+)
+
+const (
+	MaxWebhooksPerRoom = 20
+	MaxWebhookAttempts = 5
+
+	// Retries wait 30s, 1m, 2m then 4m
+	WebhookRetryBaseDelay = 30 * time.Second
+	WebhookRetryInterval  = 15 * time.Second
+
+	// A delivery being attempted is not picked up by the retry job before the lease ends
+	webhookDeliveryLease    = time.Minute
+	webhookRetryBatchSize   = 50
+	maxWebhookErrorLength   = 1000
+	maxWebhookTriggerLength = 100
+	webhookTokenBytes       = 24
+	webhookSecretBytes      = 32
+	maxWebhookReplyLength   = 4000
+)
+
+// WebhookPayload is the JSON body posted to outgoing webhooks
+type WebhookPayload struct {
+	WebhookID uint                  `json:"webhook_id"`
+	RoomID    uint                  `json:"room_id"`
+	Trigger   string                `json:"trigger"`
+	Text      string                `json:"text"` // Message content after the trigger
+	Message   WebhookPayloadMessage `json:"message"`
+}
+
+type WebhookPayloadMessage struct {
+	ID        uint      `json:"id"`
+	SenderID  uint      `json:"sender_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// webhookReply is an optional answer of an outgoing webhook, posted to the room as the bot
+type webhookReply struct {
+	Content string `json:"content"`
+}
+
+// CreateWebhook adds an incoming or outgoing webhook to a group room. The bot
+// must be managed by the admin creating the webhook, it joins the room if needed.
+func (s *ChatService) CreateWebhook(roomID, userID uint, req requests.CreateChatWebhookRequest) (*postgres.ChatWebhook, error) {
+	if _, err := s.checkWebhookManager(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	bot, err := s.getOwnedBot(userID, req.BotID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repos.ChatWebhook.GetRoomWebhooks(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	if len(existing) >= MaxWebhooksPerRoom {
+		return nil, fmt.Errorf("a room can have at most %d webhooks", MaxWebhooksPerRoom)
+	}
+
+	webhook := &postgres.ChatWebhook{
+		ChatRoomID: roomID,
+		BotID:      bot.ID,
+		CreatedBy:  userID,
+		Type:       req.Type,
+		Name:       strings.TrimSpace(req.Name),
+		IsActive:   true,
+	}
+
+	switch req.Type {
+	case postgres.ChatWebhookTypeIncoming:
+		if webhook.Token, err = randomHex(webhookTokenBytes); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook token: %w", err)
+		}
+	case postgres.ChatWebhookTypeOutgoing:
+		if err := s.webhooks.CheckURL(req.URL); err != nil {
+			return nil, fmt.Errorf("invalid webhook url")
+		}
+		trigger := strings.TrimSpace(req.Trigger)
+		if trigger == "" || len(trigger) > maxWebhookTriggerLength || strings.IndexFunc(trigger, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("trigger must be a single word such as /deploy or !alert")
+		}
+		if webhook.Secret, err = randomHex(webhookSecretBytes); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		webhook.URL = req.URL
+		webhook.Trigger = trigger
+	default:
+		return nil, fmt.Errorf("invalid webhook type")
+	}
+
+	if err := s.addBotToRoom(roomID, bot.ID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repos.ChatWebhook.Create(webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	webhook.Bot = bot
+	return webhook, nil
+}
+
+// GetRoomWebhooks lists the webhooks of a room with their tokens and secrets, admins only
+func (s *ChatService) GetRoomWebhooks(roomID, userID uint) ([]postgres.ChatWebhook, error) {
+	if _, err := s.checkWebhookManager(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.repos.ChatWebhook.GetRoomWebhooks(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook, its bot stays in the room
+func (s *ChatService) DeleteWebhook(webhookID, userID uint) error {
+	webhook, err := s.repos.ChatWebhook.GetByID(webhookID)
+	if err != nil {
+		return fmt.Errorf("webhook not found")
+	}
+	if _, err := s.checkWebhookManager(webhook.ChatRoomID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repos.ChatWebhook.Delete(webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the delivery log of an outgoing webhook, newest first
+func (s *ChatService) GetWebhookDeliveries(webhookID, userID uint, req requests.GetWebhookDeliveriesRequest) (*responses.WebhookDeliveriesResponse, error) {
+	webhook, err := s.repos.ChatWebhook.GetByID(webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	if _, err := s.checkWebhookManager(webhook.ChatRoomID, userID); err != nil {
+		return nil, err
+	}
+
+	cursor := paginator.Cursor{
+		Before: &req.Before,
+		After:  &req.After,
+	}
+	deliveries, next, err := s.repos.WebhookDelivery.GetWebhookDeliveries(webhookID, cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+
+	return &responses.WebhookDeliveriesResponse{
+		Deliveries: deliveries,
+		NextCursor: &next,
+	}, nil
+}
+
+// ReceiveIncomingWebhook posts the content sent to an incoming webhook URL as its bot
+func (s *ChatService) ReceiveIncomingWebhook(token string, req requests.IncomingWebhookRequest) (*postgres.Message, error) {
+	webhook, err := s.repos.ChatWebhook.GetByToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	if !webhook.IsActive {
+		return nil, fmt.Errorf("permission denied: webhook is disabled")
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+
+	return s.postBotMessage(webhook.BotID, webhook.ChatRoomID, content)
+}
+
+// dispatchOutgoingWebhooks queues a delivery for each outgoing webhook of the
+// room triggered by a message, then attempts them in the background
+func (s *ChatService) dispatchOutgoingWebhooks(message *postgres.Message) {
+	if s.webhooks == nil || message.Type != postgres.MessageTypeText {
+		return
+	}
+
+	webhooks, err := s.repos.ChatWebhook.GetActiveOutgoing(message.ChatRoomID)
+	if err != nil {
+		log.Printf("Failed to get outgoing webhooks of room %d: %v", message.ChatRoomID, err)
+		return
+	}
+
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if webhook.BotID == message.SenderID || !matchesWebhookTrigger(message.Content, webhook.Trigger) {
+			continue
+		}
+
+		payload, err := json.Marshal(WebhookPayload{
+			WebhookID: webhook.ID,
+			RoomID:    message.ChatRoomID,
+			Trigger:   webhook.Trigger,
+			Text:      strings.TrimSpace(message.Content[len(webhook.Trigger):]),
+			Message: WebhookPayloadMessage{
+				ID:        message.ID,
+				SenderID:  message.SenderID,
+				Content:   message.Content,
+				CreatedAt: message.CreatedAt,
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to encode payload of webhook %d: %v", webhook.ID, err)
+			continue
+		}
+
+		leaseUntil := time.Now().Add(webhookDeliveryLease)
+		delivery := &postgres.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			MessageID:     message.ID,
+			Status:        postgres.WebhookDeliveryStatusPending,
+			Payload:       string(payload),
+			NextAttemptAt: &leaseUntil,
+		}
+		if err := s.repos.WebhookDelivery.Create(delivery); err != nil {
+			log.Printf("Failed to queue delivery of webhook %d: %v", webhook.ID, err)
+			continue
+		}
+
+		go s.attemptWebhookDelivery(webhook, delivery)
+	}
+}
+
+// attemptWebhookDelivery sends a delivery once and records the outcome, failed
+// attempts are retried with an exponential backoff
+func (s *ChatService) attemptWebhookDelivery(webhook *postgres.ChatWebhook, delivery *postgres.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookDeliveryLease)
+	defer cancel()
+
+	resp, err := s.webhooks.Send(ctx, webhook.URL, webhook.Secret, delivery.ID, []byte(delivery.Payload))
+
+	now := time.Now()
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts": attempts,
+	}
+
+	if err == nil && resp.OK() {
+		updates["status"] = postgres.WebhookDeliveryStatusSucceeded
+		updates["response_status"] = resp.StatusCode
+		updates["error"] = ""
+		updates["next_attempt_at"] = nil
+		updates["delivered_at"] = now
+	} else {
+		if err != nil {
+			updates["error"] = truncateWebhookError(err.Error())
+		} else {
+			updates["response_status"] = resp.StatusCode
+			updates["error"] = truncateWebhookError(fmt.Sprintf("unexpected status %d", resp.StatusCode))
+		}
+
+		if attempts >= MaxWebhookAttempts {
+			updates["status"] = postgres.WebhookDeliveryStatusFailed
+			updates["next_attempt_at"] = nil
+		} else {
+			updates["next_attempt_at"] = now.Add(WebhookRetryBaseDelay << (attempts - 1))
+		}
+	}
+
+	if err := s.repos.WebhookDelivery.Update(delivery.ID, updates); err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+	}
+
+	if err == nil && resp.OK() {
+		s.postWebhookReply(webhook, resp.Body)
+	}
+}
+
+// postWebhookReply posts the content returned by an outgoing webhook as its bot
+func (s *ChatService) postWebhookReply(webhook *postgres.ChatWebhook, body []byte) {
+	var reply webhookReply
+	if len(body) == 0 || json.Unmarshal(body, &reply) != nil {
+		return
+	}
+
+	content := strings.TrimSpace(reply.Content)
+	if content == "" {
+		return
+	}
+	if utf8.RuneCountInString(content) > maxWebhookReplyLength {
+		content = string([]rune(content)[:maxWebhookReplyLength])
+	}
+
+	if _, err := s.postBotMessage(webhook.BotID, webhook.ChatRoomID, content); err != nil {
+		log.Printf("Failed to post reply of webhook %d: %v", webhook.ID, err)
+	}
+}
+
+// RetryWebhookDeliveries attempts the pending deliveries whose retry is due
+func (s *ChatService) RetryWebhookDeliveries() {
+	now := time.Now()
+	deliveries, err := s.repos.WebhookDelivery.GetDueDeliveries(now, webhookRetryBatchSize)
+	if err != nil {
+		log.Printf("Failed to get due webhook deliveries: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		webhook, err := s.repos.ChatWebhook.GetByID(delivery.WebhookID)
+		if err != nil || !webhook.IsActive {
+			err := s.repos.WebhookDelivery.Update(delivery.ID, map[string]interface{}{
+				"status":          postgres.WebhookDeliveryStatusFailed,
+				"error":           "webhook was deleted or disabled",
+				"next_attempt_at": nil,
+			})
+			if err != nil {
+				log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		// Take a lease so the next run does not attempt it again meanwhile
+		leaseUntil := now.Add(webhookDeliveryLease)
+		if err := s.repos.WebhookDelivery.Update(delivery.ID, map[string]interface{}{"next_attempt_at": leaseUntil}); err != nil {
+			log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+			continue
+		}
+
+		go s.attemptWebhookDelivery(webhook, delivery)
+	}
+}
+
+// startWebhookDeliveryJob starts background job to retry failed webhook deliveries
+func (s *ChatService) startWebhookDeliveryJob() {
+	ticker := time.NewTicker(WebhookRetryInterval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.RetryWebhookDeliveries()
+			case <-s.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// checkWebhookManager checks that the user administrates a group room
+func (s *ChatService) checkWebhookManager(roomID, userID uint) (*postgres.ChatRoom, error) {
+	participant, err := s.repos.Participant.GetByRoomAndUser(roomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("permission denied: user not in room")
+	}
+	if participant.Role != postgres.ParticipantRoleAdmin && participant.Role != postgres.ParticipantRoleOwner {
+		return nil, fmt.Errorf("permission denied: only admins can manage webhooks")
+	}
+
+	room, err := s.repos.ChatRoom.GetByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	if room.Type != postgres.ChatRoomTypeGroup {
+		return nil, fmt.Errorf("webhooks are only available in group rooms")
+	}
+	return room, nil
+}
+
+// matchesWebhookTrigger reports whether a message starts with a trigger. Slash
+// commands must be followed by a space or end the message, so /deploy does not
+// match /deployment.
+func matchesWebhookTrigger(content, trigger string) bool {
+	if trigger == "" || !strings.HasPrefix(content, trigger) {
+		return false
+	}
+	if !strings.HasPrefix(trigger, "/") {
+		return true
+	}
+
+	rest := content[len(trigger):]
+	if rest == "" {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return unicode.IsSpace(r)
+}
+
+func truncateWebhookError(text string) string {
+	if len(text) <= maxWebhookErrorLength {
+		return text
+	}
+	return text[:maxWebhookErrorLength]
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
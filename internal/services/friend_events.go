@@ -0,0 +1,23 @@
+package services
+
+// RelationCallback is called when two users stop being friends or one blocks
+// the other, what one could see of the other may be hidden now
+type RelationCallback func(userID, otherID uint)
+
+// RegisterRelationCallback registers a callback for lost relations
+func (s *FriendService) RegisterRelationCallback(callback RelationCallback) {
+	s.relationMutex.Lock()
+	defer s.relationMutex.Unlock()
+	s.relationCallbacks = append(s.relationCallbacks, callback)
+}
+
+// emitRelationLost notifies all registered callbacks about a lost relation
+func (s *FriendService) emitRelationLost(userID, otherID uint) {
+	s.relationMutex.RLock()
+	callbacks := s.relationCallbacks
+	s.relationMutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(userID, otherID)
+	}
+}
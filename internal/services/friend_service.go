@@ -5,6 +5,7 @@ import (
 	"social_server/internal/models/requests"
 	"social_server/internal/models/responses"
 	"social_server/internal/repositories"
+	"sync"
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 )
@@ -12,6 +13,9 @@ import (
 type FriendService struct {
 	userRepo   repositories.UserRepository
 	friendRepo repositories.FriendRepository
+
+	relationCallbacks []RelationCallback
+	relationMutex     sync.RWMutex
 }
 
 func NewFriendService(userRepo repositories.UserRepository, friendRepo repositories.FriendRepository) *FriendService {
@@ -266,6 +270,7 @@ func (s *FriendService) RemoveFriend(userID, friendID uint) error {
 		return fmt.Errorf("failed to remove friend")
 	}
 
+	s.emitRelationLost(userID, friendID)
 	return nil
 }
 
@@ -305,6 +310,7 @@ func (s *FriendService) BlockUser(userID, targetID uint) error {
 		return fmt.Errorf("failed to block user")
 	}
 
+	s.emitRelationLost(userID, targetID)
 	return nil
 }
 
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"social_server/internal/models/postgres"
+	"social_server/internal/repositories"
+	"social_server/internal/utils"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	LinkPreviewTTL       = 24 * time.Hour
+	LinkPreviewFailedTTL = time.Hour
+)
+
+var ErrNoLinkPreview = errors.New("no preview available for this link")
+
+// LinkPreviewService unfurls the URLs found in posts and messages, caching
+// the previews by URL
+type LinkPreviewService struct {
+	repo     repositories.LinkPreviewRepository
+	fetcher  *utils.LinkPreviewFetcher
+	inflight singleflight.Group
+}
+
+func NewLinkPreviewService(repo repositories.LinkPreviewRepository, fetcher *utils.LinkPreviewFetcher) *LinkPreviewService {
+	return &LinkPreviewService{
+		repo:    repo,
+		fetcher: fetcher,
+	}
+}
+
+// GetPreview returns the preview of a URL, from the cache when it is fresh.
+// Concurrent requests for the same URL share a single fetch.
+func (s *LinkPreviewService) GetPreview(rawURL string) (*postgres.LinkPreview, error) {
+	key := utils.NormalizePreviewURL(rawURL)
+	if key == "" {
+		return nil, ErrNoLinkPreview
+	}
+
+	if cached, err := s.repo.GetByURL(key); err == nil && isPreviewFresh(cached, time.Now()) {
+		if cached.Failed {
+			return nil, ErrNoLinkPreview
+		}
+		return cached, nil
+	}
+
+	result, err, _ := s.inflight.Do(key, func() (interface{}, error) {
+		return s.fetch(key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*postgres.LinkPreview), nil
+}
+
+// PreviewText returns the preview of the first URL of a text, nil if there is
+// none or it could not be cached, posts and messages link it by ID
+func (s *LinkPreviewService) PreviewText(text string) *postgres.LinkPreview {
+	rawURL := utils.ExtractFirstURL(text)
+	if rawURL == "" {
+		return nil
+	}
+
+	preview, err := s.GetPreview(rawURL)
+	if err != nil || preview.ID == 0 {
+		return nil
+	}
+	return preview
+}
+
+func (s *LinkPreviewService) fetch(key string) (*postgres.LinkPreview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	preview := &postgres.LinkPreview{
+		URL:       key,
+		FetchedAt: time.Now(),
+	}
+
+	data, fetchErr := s.fetcher.Fetch(ctx, key)
+	if fetchErr == nil && data.Title == "" && data.Description == "" && data.ImageURL == "" {
+		fetchErr = ErrNoLinkPreview
+	}
+
+	if fetchErr != nil {
+		// Remember the failure so the page is not fetched again for every message
+		preview.Failed = true
+	} else {
+		preview.CanonicalURL = data.CanonicalURL
+		preview.Title = data.Title
+		preview.Description = data.Description
+		preview.SiteName = data.SiteName
+		preview.ImageURL = data.ImageURL
+		preview.Type = data.Type
+	}
+
+	if err := s.repo.Save(preview); err != nil {
+		log.Printf("Failed to cache link preview of %s: %v", key, err)
+	}
+
+	if fetchErr != nil {
+		log.Printf("Failed to fetch link preview of %s: %v", key, fetchErr)
+		return nil, ErrNoLinkPreview
+	}
+	return preview, nil
+}
+
+func isPreviewFresh(preview *postgres.LinkPreview, now time.Time) bool {
+	ttl := LinkPreviewTTL
+	if preview.Failed {
+		ttl = LinkPreviewFailedTTL
+	}
+	return now.Sub(preview.FetchedAt) < ttl
+}
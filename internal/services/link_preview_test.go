@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"social_server/internal/models/postgres"
+	"social_server/internal/utils"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryLinkPreviews is a LinkPreviewRepository whose saves can fail
+type memoryLinkPreviews struct {
+	mutex    sync.Mutex
+	previews map[string]*postgres.LinkPreview
+	nextID   uint
+	saveErr  error
+}
+
+func (r *memoryLinkPreviews) GetByURL(url string) (*postgres.LinkPreview, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	preview, exists := r.previews[url]
+	if !exists {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *preview
+	return &copied, nil
+}
+
+func (r *memoryLinkPreviews) Save(preview *postgres.LinkPreview) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+	r.nextID++
+	preview.ID = r.nextID
+	copied := *preview
+	r.previews[preview.URL] = &copied
+	return nil
+}
+
+// newTestLinkPreviewService returns a service fetching from a test server,
+// with the URL of the server and the count of pages fetched from it
+func newTestLinkPreviewService(t *testing.T, repo *memoryLinkPreviews) (*LinkPreviewService, string, *atomic.Int32) {
+	t.Helper()
+
+	fetches := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<head><meta property="og:title" content="Title"></head>`)
+	}))
+	t.Cleanup(server.Close)
+
+	config := utils.DefaultLinkPreviewConfig()
+	config.AllowPrivateNetworks = true
+	return NewLinkPreviewService(repo, utils.NewLinkPreviewFetcher(config)), server.URL, fetches
+}
+
+func TestPreviewTextCachesPreviews(t *testing.T) {
+	repo := &memoryLinkPreviews{previews: map[string]*postgres.LinkPreview{}}
+	service, serverURL, fetches := newTestLinkPreviewService(t, repo)
+	text := "read " + serverURL + "/post"
+
+	first := service.PreviewText(text)
+	if first == nil || first.ID == 0 || first.Title != "Title" {
+		t.Fatalf("PreviewText() = %+v, want a saved preview", first)
+	}
+
+	second := service.PreviewText(text)
+	if second == nil || second.ID != first.ID {
+		t.Fatalf("PreviewText() = %+v, want the cached preview %d", second, first.ID)
+	}
+	if fetched := fetches.Load(); fetched != 1 {
+		t.Errorf("page fetched %d times, want 1", fetched)
+	}
+}
+
+func TestPreviewTextSkipsUnsavedPreviews(t *testing.T) {
+	repo := &memoryLinkPreviews{
+		previews: map[string]*postgres.LinkPreview{},
+		saveErr:  errors.New("database is down"),
+	}
+	service, text, _ := newTestLinkPreviewService(t, repo)
+
+	// A preview that was not saved has no ID to link
+	if preview := service.PreviewText(text); preview != nil {
+		t.Errorf("PreviewText() = %+v, want nil when the preview could not be saved", preview)
+	}
+
+	// It is still shown when asked for directly
+	preview, err := service.GetPreview(text)
+	if err != nil || preview.Title != "Title" {
+		t.Errorf("GetPreview() = %+v, %v, want the fetched preview", preview, err)
+	}
+}
+
+func TestGetPreviewRemembersFailures(t *testing.T) {
+	repo := &memoryLinkPreviews{previews: map[string]*postgres.LinkPreview{
+		"https://example.com/": {URL: "https://example.com/", Failed: true, FetchedAt: time.Now()},
+	}}
+	service, _, fetches := newTestLinkPreviewService(t, repo)
+
+	if _, err := service.GetPreview("https://EXAMPLE.com"); !errors.Is(err, ErrNoLinkPreview) {
+		t.Errorf("GetPreview() error = %v, want %v", err, ErrNoLinkPreview)
+	}
+	if fetched := fetches.Load(); fetched != 0 {
+		t.Errorf("page fetched %d times, want the cached failure", fetched)
+	}
+}
@@ -15,6 +15,12 @@ type OnlineStatusService struct {
 	mutex       sync.RWMutex
 	cleanupTicker *time.Ticker
 	stopChan    chan bool
+
+	// Devices of the users connected to this node, and the users whose last
+	// device left, reported offline unless one comes back in time
+	devices        map[uint]map[string]bool
+	pendingOffline map[uint]*time.Timer
+	onlineElsewhere func(userID uint) bool
 }
 
 type OnlineUserInfo struct {
@@ -41,6 +47,7 @@ var onlineStatusCallbacks []OnlineStatusCallback
 const (
 	HeartbeatTimeout = 90 * time.Second  // User considered offline after 90s without heartbeat
 	CleanupInterval  = 30 * time.Second  // Cleanup check every 30s
+	OfflineDebounce  = 10 * time.Second  // A device reconnecting within this is no status change
 )
 
 func NewOnlineStatusService(db *gorm.DB) *OnlineStatusService {
@@ -48,6 +55,9 @@ func NewOnlineStatusService(db *gorm.DB) *OnlineStatusService {
 		db:          db,
 		onlineUsers: make(map[uint]*OnlineUserInfo),
 		stopChan:    make(chan bool),
+		devices:        make(map[uint]map[string]bool),
+		pendingOffline: make(map[uint]*time.Timer),
+		onlineElsewhere: func(userID uint) bool { return false },
 	}
 
 	// Start background cleanup job
@@ -122,6 +132,103 @@ func (s *OnlineStatusService) SetUserOffline(userID uint) error {
 	return nil
 }
 
+// SetPresenceLookup tells the service how to know if a user is connected to
+// another node, whose status changes are that node's to report
+func (s *OnlineStatusService) SetPresenceLookup(onlineElsewhere func(userID uint) bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.onlineElsewhere = onlineElsewhere
+}
+
+// ConnectDevice counts a device of the user connected to this node. The user
+// goes online with their first device, unless they were already online here
+// or on another node.
+func (s *OnlineStatusService) ConnectDevice(userID uint, connectionID string) error {
+	s.mutex.Lock()
+	if s.devices[userID] == nil {
+		s.devices[userID] = make(map[string]bool)
+	}
+	first := len(s.devices[userID]) == 0
+	s.devices[userID][connectionID] = true
+
+	// A device came back before the user was reported offline
+	timer, pending := s.pendingOffline[userID]
+	if pending {
+		timer.Stop()
+		delete(s.pendingOffline, userID)
+	}
+	onlineElsewhere := s.onlineElsewhere
+	s.mutex.Unlock()
+
+	if !first || pending {
+		return nil
+	}
+	if onlineElsewhere(userID) {
+		s.cacheOnline(userID, connectionID)
+		return nil
+	}
+	return s.SetUserOnline(userID, connectionID)
+}
+
+// DisconnectDevice uncounts a device. The user is reported offline once
+// their last device has been gone for OfflineDebounce.
+func (s *OnlineStatusService) DisconnectDevice(userID uint, connectionID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.devices[userID][connectionID] {
+		return
+	}
+	delete(s.devices[userID], connectionID)
+	if len(s.devices[userID]) > 0 {
+		return
+	}
+	delete(s.devices, userID)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(OfflineDebounce, func() {
+		s.mutex.Lock()
+		current := s.pendingOffline[userID] == timer
+		if current {
+			delete(s.pendingOffline, userID)
+		}
+		onlineElsewhere := s.onlineElsewhere
+		s.mutex.Unlock()
+
+		if !current {
+			return
+		}
+		if onlineElsewhere(userID) {
+			s.mutex.Lock()
+			delete(s.onlineUsers, userID)
+			s.mutex.Unlock()
+			return
+		}
+		if err := s.SetUserOffline(userID); err != nil {
+			log.Printf("Failed to set user %d offline: %v", userID, err)
+		}
+	})
+	s.pendingOffline[userID] = timer
+}
+
+// cacheOnline records a user online without reporting it
+func (s *OnlineStatusService) cacheOnline(userID uint, connectionID string) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.onlineUsers[userID] = &OnlineUserInfo{
+		UserID:        userID,
+		LastSeen:      now,
+		LastHeartbeat: now,
+		ConnectedAt:   now,
+		ConnectionID:  connectionID,
+		IsActive:      true,
+	}
+}
+
 // UpdateHeartbeat updates user's last heartbeat
 func (s *OnlineStatusService) UpdateHeartbeat(userID uint) error {
 	now := time.Now()
@@ -218,14 +325,14 @@ func (s *OnlineStatusService) GetOnlineUserCount() int {
 	return count
 }
 
-// GetOnlineFriends returns online friends of a user
+// GetOnlineFriends returns online friends of a user, those hiding their
+// status or blocked either way are left out
 func (s *OnlineStatusService) GetOnlineFriends(userID uint) ([]uint, error) {
-	// Get user's friends from database
 	var friendIDs []uint
-	err := s.db.Table("user_friends").
-		Select("friend_id").
-		Where("user_id = ? AND status = ?", userID, "accepted").
-		Pluck("friend_id", &friendIDs).Error
+	err := s.visibleFriends(userID).
+		Joins("JOIN users ON users.id = user_friends.friend_id").
+		Where("users.settings_privacy_show_online_status = ?", true).
+		Pluck("user_friends.friend_id", &friendIDs).Error
 
 	if err != nil {
 		return nil, err
@@ -242,6 +349,52 @@ func (s *OnlineStatusService) GetOnlineFriends(userID uint) ([]uint, error) {
 	return onlineFriends, nil
 }
 
+// GetStatusAudience returns the friends who may see the status of a user:
+// none when the user hides it, and no one blocked either way
+func (s *OnlineStatusService) GetStatusAudience(userID uint) ([]uint, error) {
+	var user postgres.User
+	err := s.db.Select("id", "settings_privacy_show_online_status").First(&user, userID).Error
+	if err != nil {
+		return nil, err
+	}
+	if !user.Settings.PrivacyShowOnlineStatus {
+		return nil, nil
+	}
+
+	var friendIDs []uint
+	err = s.visibleFriends(userID).Pluck("user_friends.friend_id", &friendIDs).Error
+	return friendIDs, err
+}
+
+// CanSeeStatus tells if a viewer may follow the status of a user
+func (s *OnlineStatusService) CanSeeStatus(viewerID, userID uint) (bool, error) {
+	if viewerID == userID {
+		return true, nil
+	}
+
+	audience, err := s.GetStatusAudience(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, friendID := range audience {
+		if friendID == viewerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// visibleFriends selects the active friendships of a user with no block
+// between them
+func (s *OnlineStatusService) visibleFriends(userID uint) *gorm.DB {
+	return s.db.Model(&postgres.UserFriend{}).
+		Where("user_friends.user_id = ? AND user_friends.status = ?", userID, postgres.FriendStatusActive).
+		Where("user_friends.friend_id NOT IN (?)",
+			s.db.Table("user_blocks").Select("blocked_user_id").Where("user_id = ?", userID)).
+		Where("user_friends.friend_id NOT IN (?)",
+			s.db.Table("user_blocks").Select("user_id").Where("blocked_user_id = ?", userID))
+}
+
 // CleanupOfflineUsers removes users who haven't sent heartbeat for a while
 func (s *OnlineStatusService) CleanupOfflineUsers() {
 	s.mutex.Lock()
@@ -251,6 +404,10 @@ func (s *OnlineStatusService) CleanupOfflineUsers() {
 	var usersToRemove []uint
 
 	for userID, userInfo := range s.onlineUsers {
+		// Connected devices are online whether they send heartbeats or not
+		if len(s.devices[userID]) > 0 {
+			continue
+		}
 		if now.Sub(userInfo.LastHeartbeat) >= HeartbeatTimeout {
 			usersToRemove = append(usersToRemove, userID)
 		}
@@ -258,6 +415,7 @@ func (s *OnlineStatusService) CleanupOfflineUsers() {
 
 	// Remove offline users
 	for _, userID := range usersToRemove {
+		lastSeen := s.onlineUsers[userID].LastSeen
 		delete(s.onlineUsers, userID)
 		
 		// Update database
@@ -265,14 +423,14 @@ func (s *OnlineStatusService) CleanupOfflineUsers() {
 			Where("id = ?", userID).
 			Updates(map[string]interface{}{
 				"is_online": false,
-				"last_seen": s.onlineUsers[userID].LastSeen,
+				"last_seen": lastSeen,
 			}).Error
 
 		if err != nil {
 			log.Printf("Error updating offline status for user %d: %v", userID, err)
 		} else {
 			// Broadcast status change
-			s.broadcastStatusChange(userID, false, now)
+			s.broadcastStatusChange(userID, false, lastSeen)
 		}
 	}
 
@@ -359,9 +517,18 @@ func (s *OnlineStatusService) GetConnectionStats() map[string]interface{} {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	onlineCount := 0
+	now := time.Now()
+	for _, userInfo := range s.onlineUsers {
+		if now.Sub(userInfo.LastHeartbeat) < HeartbeatTimeout {
+			onlineCount++
+		}
+	}
+
 	stats := map[string]interface{}{
 		"total_cached_users": len(s.onlineUsers),
-		"online_users_count": s.GetOnlineUserCount(),
+		"online_users_count": onlineCount,
+		"users_with_devices": len(s.devices),
 		"cleanup_interval":   CleanupInterval.String(),
 		"heartbeat_timeout":  HeartbeatTimeout.String(),
 	}
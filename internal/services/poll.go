@@ -0,0 +1,301 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"social_server/internal/models"
+	"social_server/internal/models/postgres"
+	"social_server/internal/models/requests"
+	"social_server/internal/repositories"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MinPollOptions = 2
+	MaxPollOptions = 12
+)
+
+type PollService struct {
+	repos       *repositories.Repositories
+	chatService *ChatService
+	postService *PostService
+}
+
+func NewPollService(repos *repositories.Repositories, chatService *ChatService, postService *PostService) *PollService {
+	return &PollService{
+		repos:       repos,
+		chatService: chatService,
+		postService: postService,
+	}
+}
+
+// GetPoll returns a poll with its results
+func (s *PollService) GetPoll(pollID, userID uint) (*postgres.Poll, error) {
+	poll, err := s.getAccessiblePoll(pollID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadPollResults(s.repos.Poll, []*postgres.Poll{poll}, userID); err != nil {
+		return nil, fmt.Errorf("failed to get poll results: %w", err)
+	}
+	return poll, nil
+}
+
+// Vote replaces the votes of a user, so it is also used to change a vote
+func (s *PollService) Vote(pollID, userID uint, optionIDs []uint) (*postgres.Poll, error) {
+	poll, err := s.getAccessiblePoll(pollID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	validOptions := make(map[uint]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		validOptions[option.ID] = true
+	}
+
+	seen := make(map[uint]bool, len(optionIDs))
+	var choices []uint
+	for _, optionID := range optionIDs {
+		if !validOptions[optionID] {
+			return nil, fmt.Errorf("option %d does not belong to this poll", optionID)
+		}
+		if !seen[optionID] {
+			seen[optionID] = true
+			choices = append(choices, optionID)
+		}
+	}
+
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("at least one option is required")
+	}
+	if !poll.MultipleChoice && len(choices) > 1 {
+		return nil, fmt.Errorf("this poll only allows a single choice")
+	}
+
+	return s.replaceVotes(poll, userID, choices)
+}
+
+// RetractVote removes the votes of a user
+func (s *PollService) RetractVote(pollID, userID uint) (*postgres.Poll, error) {
+	poll, err := s.getAccessiblePoll(pollID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.replaceVotes(poll, userID, nil)
+}
+
+// ClosePoll stops a poll from accepting votes, freezing the results
+func (s *PollService) ClosePoll(pollID, userID uint) (*postgres.Poll, error) {
+	poll, err := s.getAccessiblePoll(pollID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if poll.CreatorID != userID && !s.isChatPollAdmin(poll, userID) {
+		return nil, fmt.Errorf("permission denied: only the creator can close this poll")
+	}
+
+	now := time.Now()
+	if poll.Closed(now) {
+		return nil, fmt.Errorf("poll is already closed")
+	}
+
+	if err := s.repos.Poll.Update(pollID, map[string]interface{}{"closed_at": now}); err != nil {
+		return nil, fmt.Errorf("failed to close poll: %w", err)
+	}
+	poll.ClosedAt = &now
+
+	if err := loadPollResults(s.repos.Poll, []*postgres.Poll{poll}, userID); err != nil {
+		return nil, fmt.Errorf("failed to get poll results: %w", err)
+	}
+
+	s.publishPollUpdate(poll, userID)
+	return poll, nil
+}
+
+func (s *PollService) replaceVotes(poll *postgres.Poll, userID uint, optionIDs []uint) (*postgres.Poll, error) {
+	accepted, err := s.repos.Poll.ReplaceVotes(poll.ID, userID, optionIDs, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save vote: %w", err)
+	}
+	if !accepted {
+		return nil, fmt.Errorf("poll is closed")
+	}
+
+	if err := loadPollResults(s.repos.Poll, []*postgres.Poll{poll}, userID); err != nil {
+		return nil, fmt.Errorf("failed to get poll results: %w", err)
+	}
+
+	s.publishPollUpdate(poll, userID)
+	return poll, nil
+}
+
+// getAccessiblePoll loads a poll the user can see, through its chat room or its post
+func (s *PollService) getAccessiblePoll(pollID, userID uint) (*postgres.Poll, error) {
+	poll, err := s.repos.Poll.GetByID(pollID)
+	if err != nil {
+		return nil, fmt.Errorf("poll not found")
+	}
+
+	switch {
+	case poll.ChatRoomID != nil:
+		if _, err := s.repos.Participant.GetByRoomAndUser(*poll.ChatRoomID, userID); err != nil {
+			return nil, fmt.Errorf("permission denied: user not in room")
+		}
+	case poll.PostID != nil:
+		canView, err := s.postService.CheckPostVisibility(*poll.PostID, &userID)
+		if err != nil || !canView {
+			return nil, fmt.Errorf("poll not found")
+		}
+	}
+
+	return poll, nil
+}
+
+func (s *PollService) isChatPollAdmin(poll *postgres.Poll, userID uint) bool {
+	if poll.ChatRoomID == nil {
+		return false
+	}
+	participant, err := s.repos.Participant.GetByRoomAndUser(*poll.ChatRoomID, userID)
+	if err != nil {
+		return false
+	}
+	return participant.Role == postgres.ParticipantRoleAdmin || participant.Role == postgres.ParticipantRoleOwner
+}
+
+// publishPollUpdate pushes the new tallies to the chat room of a poll.
+// Post polls are not pushed, their results come with the post.
+func (s *PollService) publishPollUpdate(poll *postgres.Poll, userID uint) {
+	if poll.ChatRoomID == nil || poll.MessageID == nil {
+		return
+	}
+
+	// The votes of the current user are not shared with the room, and the
+	// subscribers of a channel do not see who voted
+	shared := *poll
+	shared.MyVotes = nil
+	if s.chatService.roomType(*poll.ChatRoomID) == postgres.ChatRoomTypeChannel {
+		shared.Options = append([]postgres.PollOption(nil), poll.Options...)
+		hidePollVoters(&shared)
+	}
+
+	s.chatService.emitToRoom(*poll.ChatRoomID, userID, models.MessageTypePollUpdated, models.PollUpdatedMessage{
+		RoomID:    *poll.ChatRoomID,
+		MessageID: *poll.MessageID,
+		Poll:      &shared,
+	})
+}
+
+// newPoll validates a poll request and builds the poll with its options
+func newPoll(creatorID uint, req requests.CreatePollRequest) (*postgres.Poll, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, fmt.Errorf("poll question is required")
+	}
+
+	if len(req.Options) < MinPollOptions || len(req.Options) > MaxPollOptions {
+		return nil, fmt.Errorf("a poll must have between %d and %d options", MinPollOptions, MaxPollOptions)
+	}
+
+	if req.ClosesAt != nil && !req.ClosesAt.After(time.Now()) {
+		return nil, fmt.Errorf("closes_at must be in the future")
+	}
+
+	seen := make(map[string]bool, len(req.Options))
+	options := make([]postgres.PollOption, len(req.Options))
+	for i, text := range req.Options {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, fmt.Errorf("poll options cannot be empty")
+		}
+		key := strings.ToLower(text)
+		if seen[key] {
+			return nil, fmt.Errorf("poll options must be unique")
+		}
+		seen[key] = true
+		options[i] = postgres.PollOption{Text: text, Position: i}
+	}
+
+	return &postgres.Poll{
+		CreatorID:      creatorID,
+		Question:       question,
+		MultipleChoice: req.MultipleChoice,
+		Anonymous:      req.Anonymous,
+		ClosesAt:       req.ClosesAt,
+		Options:        options,
+	}, nil
+}
+
+// loadPollResults fills in the tallies of the polls, and the votes of userID when not 0
+func loadPollResults(pollRepo repositories.PollRepository, polls []*postgres.Poll, userID uint) error {
+	if len(polls) == 0 {
+		return nil
+	}
+
+	pollIDs := make([]uint, len(polls))
+	var publicPollIDs []uint
+	for i, poll := range polls {
+		pollIDs[i] = poll.ID
+		if !poll.Anonymous {
+			publicPollIDs = append(publicPollIDs, poll.ID)
+		}
+	}
+
+	optionCounts, err := pollRepo.GetOptionVoteCounts(pollIDs)
+	if err != nil {
+		return err
+	}
+
+	voterCounts, err := pollRepo.GetVoterCounts(pollIDs)
+	if err != nil {
+		return err
+	}
+
+	optionVoters := map[uint][]uint{}
+	if len(publicPollIDs) > 0 {
+		optionVoters, err = pollRepo.GetOptionVoters(publicPollIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	userVotes := map[uint][]uint{}
+	if userID != 0 {
+		userVotes, err = pollRepo.GetUserVotes(pollIDs, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, poll := range polls {
+		sort.Slice(poll.Options, func(i, j int) bool {
+			return poll.Options[i].Position < poll.Options[j].Position
+		})
+		for i := range poll.Options {
+			poll.Options[i].VoteCount = optionCounts[poll.Options[i].ID]
+			poll.Options[i].VoterIDs = optionVoters[poll.Options[i].ID]
+		}
+		poll.TotalVoters = voterCounts[poll.ID]
+		poll.MyVotes = userVotes[poll.ID]
+		poll.IsClosed = poll.Closed(now)
+	}
+	return nil
+}
+
+// hidePollVoters keeps the tallies of a poll but not who voted
+func hidePollVoters(poll *postgres.Poll) {
+	for i := range poll.Options {
+		poll.Options[i].VoterIDs = nil
+	}
+}
+
+// attachPollResults loads the results of the polls found in a list of posts or messages
+func attachPollResults(pollRepo repositories.PollRepository, polls []*postgres.Poll, userID uint) {
+	if err := loadPollResults(pollRepo, polls, userID); err != nil {
+		log.Printf("Failed to load poll results: %v", err)
+	}
+}
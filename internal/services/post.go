@@ -7,6 +7,7 @@ import (
 	"social_server/internal/models/requests"
 	"social_server/internal/models/responses"
 	"social_server/internal/repositories"
+	"sync"
 	"time"
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
@@ -18,6 +19,12 @@ type PostService struct {
 	commentRepo repositories.CommentRepository
 	likeRepo    repositories.LikeRepository
 	shareRepo   repositories.ShareRepository
+	pollRepo    repositories.PollRepository
+
+	linkPreviews *LinkPreviewService
+
+	eventCallbacks []PostEventCallback
+	eventMutex     sync.RWMutex
 }
 
 func NewPostService(
@@ -26,13 +33,17 @@ func NewPostService(
 	commentRepo repositories.CommentRepository,
 	likeRepo repositories.LikeRepository,
 	shareRepo repositories.ShareRepository,
+	pollRepo repositories.PollRepository,
+	linkPreviews *LinkPreviewService,
 ) *PostService {
 	return &PostService{
-		postRepo:    postRepo,
-		userRepo:    userRepo,
-		commentRepo: commentRepo,
-		likeRepo:    likeRepo,
-		shareRepo:   shareRepo,
+		postRepo:     postRepo,
+		userRepo:     userRepo,
+		commentRepo:  commentRepo,
+		likeRepo:     likeRepo,
+		shareRepo:    shareRepo,
+		pollRepo:     pollRepo,
+		linkPreviews: linkPreviews,
 	}
 }
 
@@ -48,10 +59,24 @@ func (s *PostService) CreatePost(userID uint, req *requests.CreatePostRequest) (
 	}
 
 	// Validate content
-	if req.Content == "" && len(req.Media) == 0 {
+	if req.Content == "" && len(req.Media) == 0 && req.Type != constants.PostTypePoll {
 		return nil, fmt.Errorf("post must have content or media")
 	}
 
+	var poll *postgres.Poll
+	if req.Type == constants.PostTypePoll {
+		poll, err = newPoll(userID, requests.CreatePollRequest{
+			Question:       req.PollQuestion,
+			Options:        req.PollOptions,
+			MultipleChoice: req.PollMultipleChoice,
+			Anonymous:      req.PollAnonymous,
+			ClosesAt:       req.PollClosesAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Create post
 	post := &postgres.Post{
 		AuthorID:  userID,
@@ -79,6 +104,19 @@ func (s *PostService) CreatePost(userID uint, req *requests.CreatePostRequest) (
 		return nil, fmt.Errorf("failed to create post: %w", err)
 	}
 
+	// Link posts carry the preview of their first URL once it is fetched
+	if post.Type == constants.PostTypeLink {
+		s.resolveLinkPreview(post.ID, post.Content)
+	}
+
+	// Create poll if provided
+	if poll != nil {
+		poll.PostID = &post.ID
+		if err := s.pollRepo.Create(poll); err != nil {
+			return nil, fmt.Errorf("failed to create post poll: %w", err)
+		}
+	}
+
 	// Create media if provided
 	for i, mediaReq := range req.Media {
 		media := &postgres.PostMedia{
@@ -106,7 +144,12 @@ func (s *PostService) CreatePost(userID uint, req *requests.CreatePostRequest) (
 	}
 
 	// Return created post with relations
-	return s.postRepo.GetByID(post.ID)
+	created, err := s.postRepo.GetByID(post.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.attachPostPolls([]*postgres.Post{created}, userID)
+	return created, nil
 }
 
 func (s *PostService) GetPost(postID uint, userID *uint) (*postgres.Post, error) {
@@ -125,6 +168,12 @@ func (s *PostService) GetPost(postID uint, userID *uint) (*postgres.Post, error)
 		return nil, fmt.Errorf("post not found")
 	}
 
+	var viewerID uint
+	if userID != nil {
+		viewerID = *userID
+	}
+	s.attachPostPolls([]*postgres.Post{post}, viewerID)
+
 	return post, nil
 }
 
@@ -146,6 +195,10 @@ func (s *PostService) UpdatePost(postID, userID uint, req *requests.UpdatePostRe
 		updates["content"] = req.Content
 		updates["is_edited"] = true
 		updates["edited_at"] = time.Now()
+
+		if post.Type == constants.PostTypeLink {
+			updates["link_preview_id"] = nil
+		}
 	}
 	if req.Privacy != "" {
 		updates["privacy"] = req.Privacy
@@ -159,6 +212,12 @@ func (s *PostService) UpdatePost(postID, userID uint, req *requests.UpdatePostRe
 	if err != nil {
 		return nil, fmt.Errorf("failed to update post: %w", err)
 	}
+	if req.Privacy != "" && req.Privacy != post.Privacy {
+		s.emit(PostEvent{PostID: postID, AccessChanged: true})
+	}
+	if req.Content != "" && post.Type == constants.PostTypeLink {
+		s.resolveLinkPreview(postID, req.Content)
+	}
 
 	return s.postRepo.GetByID(postID)
 }
@@ -180,6 +239,7 @@ func (s *PostService) DeletePost(postID, userID uint) error {
 		return fmt.Errorf("failed to delete post: %w", err)
 	}
 
+	s.emit(PostEvent{PostID: postID, AccessChanged: true})
 	return nil
 }
 
@@ -196,6 +256,7 @@ func (s *PostService) GetUserPosts(
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user posts: %w", err)
 	}
+	s.attachPostPolls(postPointers(posts), currentUserID)
 
 	return &responses.PostResponse{
 		Posts:      posts,
@@ -213,6 +274,7 @@ func (s *PostService) GetFeed(userID uint, limit int, cursor string) (*responses
 	if err != nil {
 		return nil, fmt.Errorf("failed to get feed: %w", err)
 	}
+	s.attachPostPolls(postPointers(posts), userID)
 
 	return &responses.PostResponse{
 		Posts:      posts,
@@ -230,6 +292,7 @@ func (s *PostService) GetPublicFeed(limit int, cursor string) (*responses.PostRe
 	if err != nil {
 		return nil, fmt.Errorf("failed to get public feed: %w", err)
 	}
+	s.attachPostPolls(postPointers(result), 0)
 
 	return &responses.PostResponse{
 		Posts:      result,
@@ -248,6 +311,12 @@ func (s *PostService) SearchPosts(query string, userID *uint, limit int, cursor
 		return nil, fmt.Errorf("failed to search posts: %w", err)
 	}
 
+	var viewerID uint
+	if userID != nil {
+		viewerID = *userID
+	}
+	s.attachPostPolls(postPointers(result), viewerID)
+
 	return &responses.PostResponse{
 		Posts:      result,
 		NextCursor: &next,
@@ -280,6 +349,7 @@ func (s *PostService) ToggleLike(postID, userID uint, likeType string) (bool, er
 			return false, fmt.Errorf("failed to decrement like count: %w", err)
 		}
 
+		s.emitCounts(postID)
 		return false, nil
 	} else {
 		// Like
@@ -306,6 +376,7 @@ func (s *PostService) ToggleLike(postID, userID uint, likeType string) (bool, er
 			return false, fmt.Errorf("failed to increment like count: %w", err)
 		}
 
+		s.emitCounts(postID)
 		return true, nil
 	}
 }
@@ -337,6 +408,7 @@ func (s *PostService) CreateComment(postID, userID uint, req *requests.CreateCom
 	if err != nil {
 		return nil, fmt.Errorf("failed to increment comment count: %w", err)
 	}
+	s.emitCounts(postID)
 
 	return s.commentRepo.GetByID(comment.ID)
 }
@@ -396,6 +468,7 @@ func (s *PostService) SharePost(postID, userID uint, req *requests.SharePostRequ
 	if err != nil {
 		return nil, fmt.Errorf("failed to increment share count: %w", err)
 	}
+	s.emitCounts(postID)
 
 	return s.shareRepo.GetByID(share.ID)
 }
@@ -495,6 +568,7 @@ func (s *PostService) GetSavedPosts(userID uint) ([]postgres.Post, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get saved posts: %w", err)
 	}
+	s.attachPostPolls(postPointers(posts), userID)
 	return posts, nil
 }
 
@@ -600,3 +674,53 @@ func (s *PostService) CheckPostVisibility(postID uint, currentUserID *uint) (boo
 
 	return false, nil
 }
+
+// CheckPostAccess allows a user to watch a post they can see, unless they
+// and its author blocked each other
+func (s *PostService) CheckPostAccess(postID, userID uint) error {
+	visible, err := s.CheckPostVisibility(postID, &userID)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return fmt.Errorf("access denied")
+	}
+
+	post, err := s.postRepo.GetByID(postID)
+	if err != nil {
+		return fmt.Errorf("post not found: %w", err)
+	}
+	if post.AuthorID == userID {
+		return nil
+	}
+
+	for _, pair := range [][2]uint{{post.AuthorID, userID}, {userID, post.AuthorID}} {
+		isBlocked, err := s.userRepo.IsBlocked(pair[0], pair[1])
+		if err != nil {
+			return fmt.Errorf("failed to check block: %w", err)
+		}
+		if isBlocked {
+			return fmt.Errorf("access denied")
+		}
+	}
+	return nil
+}
+
+// attachPostPolls loads the poll results of the poll posts, with the votes of userID when not 0
+func (s *PostService) attachPostPolls(posts []*postgres.Post, userID uint) {
+	var polls []*postgres.Poll
+	for _, post := range posts {
+		if post.Poll != nil {
+			polls = append(polls, post.Poll)
+		}
+	}
+	attachPollResults(s.pollRepo, polls, userID)
+}
+
+func postPointers(posts []postgres.Post) []*postgres.Post {
+	pointers := make([]*postgres.Post, len(posts))
+	for i := range posts {
+		pointers[i] = &posts[i]
+	}
+	return pointers
+}
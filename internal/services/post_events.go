@@ -0,0 +1,58 @@
+package services
+
+import (
+	"log"
+	"social_server/internal/models"
+)
+
+// PostEvent is a realtime change of a post, pushed to the clients watching it
+type PostEvent struct {
+	Type   models.MessageType
+	PostID uint
+	Data   interface{}
+
+	// Set when who may see the post changed. Such events have no Type, the
+	// transport checks its watchers again.
+	AccessChanged bool
+}
+
+// PostEventCallback is called for every post event that must be pushed to clients
+type PostEventCallback func(event PostEvent)
+
+// RegisterEventCallback registers a callback for post events
+func (s *PostService) RegisterEventCallback(callback PostEventCallback) {
+	s.eventMutex.Lock()
+	defer s.eventMutex.Unlock()
+	s.eventCallbacks = append(s.eventCallbacks, callback)
+}
+
+// emit notifies all registered callbacks about a post event
+func (s *PostService) emit(event PostEvent) {
+	s.eventMutex.RLock()
+	callbacks := s.eventCallbacks
+	s.eventMutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(event)
+	}
+}
+
+// emitCounts pushes the current like, comment and share counts of a post
+func (s *PostService) emitCounts(postID uint) {
+	post, err := s.postRepo.GetByID(postID)
+	if err != nil {
+		log.Printf("Failed to get post %d for counts event: %v", postID, err)
+		return
+	}
+
+	s.emit(PostEvent{
+		Type:   models.MessageTypePostCountsUpdated,
+		PostID: postID,
+		Data: models.PostCountsMessage{
+			PostID:       postID,
+			LikeCount:    post.LikeCount,
+			CommentCount: post.CommentCount,
+			ShareCount:   post.ShareCount,
+		},
+	})
+}
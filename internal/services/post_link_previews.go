@@ -0,0 +1,31 @@
+package services
+
+import "log"
+
+// resolveLinkPreview unfurls the first URL of a link post in the background
+// and attaches the preview once it is ready, unless the post was edited
+// meanwhile
+func (s *PostService) resolveLinkPreview(postID uint, content string) {
+	if s.linkPreviews == nil {
+		return
+	}
+
+	go func() {
+		preview := s.linkPreviews.PreviewText(content)
+		if preview == nil {
+			return
+		}
+
+		post, err := s.postRepo.GetByID(postID)
+		if err != nil || post.Content != content {
+			return
+		}
+
+		err = s.postRepo.Update(postID, map[string]interface{}{
+			"link_preview_id": preview.ID,
+		})
+		if err != nil {
+			log.Printf("Failed to attach link preview to post %d: %v", postID, err)
+		}
+	}()
+}
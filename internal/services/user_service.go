@@ -178,6 +178,9 @@ func (s *UserService) UpdateSettings(userID uint, settings *postgres.UserSetting
 	}
 	updates["settings_privacy_show_online_status"] = settings.PrivacyShowOnlineStatus
 	updates["settings_privacy_allow_friend_requests"] = settings.PrivacyAllowFriendRequests
+	if settings.PrivacyForwardAttribution != nil {
+		updates["settings_privacy_forward_attribution"] = *settings.PrivacyForwardAttribution
+	}
 
 	// Update notification settings
 	updates["settings_notifications_email"] = settings.NotificationsEmail
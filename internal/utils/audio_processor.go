@@ -0,0 +1,558 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Audio container formats accepted for voice notes
+const (
+	AudioFormatOgg  = "ogg"
+	AudioFormatWebM = "webm"
+	AudioFormatMP4  = "m4a"
+	AudioFormatAAC  = "aac"
+)
+
+// DefaultWaveformSamples is the number of bars in a computed waveform
+const DefaultWaveformSamples = 64
+
+// AudioProcessResult contains information extracted from an audio file
+type AudioProcessResult struct {
+	Format   string  `json:"format"`
+	MimeType string  `json:"mime_type"`
+	Duration float64 `json:"duration"` // in seconds
+	Waveform []int   `json:"waveform"` // 0-100 per bar
+}
+
+// audioFrame is an encoded frame (or page/packet group) at a point in time
+type audioFrame struct {
+	time float64 // seconds from start
+	size int     // encoded bytes
+}
+
+// ProcessAudio detects the container of an audio file, validates it and extracts
+// the duration and a waveform.
+//
+// Nothing is decoded: the waveform is built from the encoded frame sizes over time,
+// which follow the loudness closely for the variable bitrate codecs used by voice
+// recorders (Opus, Vorbis, AAC).
+func ProcessAudio(data []byte, waveformSamples int) (*AudioProcessResult, error) {
+	var (
+		format   string
+		mimeType string
+		duration float64
+		frames   []audioFrame
+		err      error
+	)
+
+	switch {
+	case len(data) >= 4 && bytes.Equal(data[:4], []byte("OggS")):
+		format, mimeType = AudioFormatOgg, "audio/ogg"
+		duration, frames, err = parseOgg(data)
+	case len(data) >= 4 && bytes.Equal(data[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		format, mimeType = AudioFormatWebM, "audio/webm"
+		duration, frames, err = parseWebM(data)
+	case len(data) >= 8 && bytes.Equal(data[4:8], []byte("ftyp")):
+		format, mimeType = AudioFormatMP4, "audio/mp4"
+		duration, frames, err = parseMP4(data)
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xF6 == 0xF0:
+		format, mimeType = AudioFormatAAC, "audio/aac"
+		duration, frames, err = parseADTS(data)
+	default:
+		return nil, fmt.Errorf("unsupported audio format")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file: %v", format, err)
+	}
+
+	if duration <= 0 || math.IsNaN(duration) || math.IsInf(duration, 0) {
+		return nil, fmt.Errorf("could not determine audio duration")
+	}
+
+	return &AudioProcessResult{
+		Format:   format,
+		MimeType: mimeType,
+		Duration: duration,
+		Waveform: buildWaveform(frames, duration, waveformSamples),
+	}, nil
+}
+
+// buildWaveform buckets the frame sizes over time and normalizes them to 0-100
+func buildWaveform(frames []audioFrame, duration float64, samples int) []int {
+	if samples <= 0 {
+		samples = DefaultWaveformSamples
+	}
+
+	sums := make([]float64, samples)
+	counts := make([]int, samples)
+	for _, frame := range frames {
+		i := int(frame.time / duration * float64(samples))
+		if i < 0 {
+			i = 0
+		}
+		if i >= samples {
+			i = samples - 1
+		}
+		sums[i] += float64(frame.size)
+		counts[i]++
+	}
+
+	// Average per bucket, carrying the previous value over empty buckets
+	// (short files have fewer frames than bars)
+	levels := make([]float64, samples)
+	maxLevel := 0.0
+	for i := range levels {
+		if counts[i] > 0 {
+			levels[i] = sums[i] / float64(counts[i])
+		} else if i > 0 {
+			levels[i] = levels[i-1]
+		}
+		maxLevel = math.Max(maxLevel, levels[i])
+	}
+
+	// Stretch between the quietest and loudest bucket so silence reads as flat
+	minLevel := maxLevel
+	for _, level := range levels {
+		if level > 0 {
+			minLevel = math.Min(minLevel, level)
+		}
+	}
+
+	waveform := make([]int, samples)
+	for i, level := range levels {
+		if maxLevel <= minLevel {
+			if level > 0 {
+				waveform[i] = 100
+			}
+			continue
+		}
+		waveform[i] = int(math.Round(math.Max(level-minLevel, 0) / (maxLevel - minLevel) * 100))
+	}
+	return waveform
+}
+
+// parseOgg reads the pages of the first logical stream of an Ogg Opus or Vorbis file
+func parseOgg(data []byte) (float64, []audioFrame, error) {
+	var (
+		serial     uint32
+		sampleRate float64
+		preSkip    int64
+		lastGran   int64 = -1
+		frames     []audioFrame
+		pages      int
+	)
+
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 27 || !bytes.Equal(data[offset:offset+4], []byte("OggS")) {
+			return 0, nil, fmt.Errorf("bad page at offset %d", offset)
+		}
+		header := data[offset:]
+		granule := int64(binary.LittleEndian.Uint64(header[6:14]))
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		segments := int(header[26])
+		if len(header) < 27+segments {
+			return 0, nil, fmt.Errorf("truncated page at offset %d", offset)
+		}
+		bodySize := 0
+		for _, lacing := range header[27 : 27+segments] {
+			bodySize += int(lacing)
+		}
+		bodyStart := 27 + segments
+		if len(header) < bodyStart+bodySize {
+			return 0, nil, fmt.Errorf("truncated page at offset %d", offset)
+		}
+		body := header[bodyStart : bodyStart+bodySize]
+		offset += bodyStart + bodySize
+
+		if pages == 0 {
+			serial = pageSerial
+			switch {
+			case len(body) >= 19 && bytes.Equal(body[:8], []byte("OpusHead")):
+				sampleRate = 48000 // Opus granule positions are always at 48kHz
+				preSkip = int64(binary.LittleEndian.Uint16(body[10:12]))
+			case len(body) >= 30 && bytes.Equal(body[:7], []byte("\x01vorbis")):
+				sampleRate = float64(binary.LittleEndian.Uint32(body[12:16]))
+			default:
+				return 0, nil, fmt.Errorf("not an Opus or Vorbis stream")
+			}
+			if sampleRate <= 0 {
+				return 0, nil, fmt.Errorf("invalid sample rate")
+			}
+		}
+		pages++
+
+		// Granule -1 marks pages where no packet ends, header pages have granule 0
+		if pageSerial != serial || granule <= 0 {
+			continue
+		}
+
+		lastGran = granule
+		frames = append(frames, audioFrame{
+			time: float64(granule-preSkip) / sampleRate,
+			size: bodySize,
+		})
+	}
+
+	if lastGran < 0 {
+		return 0, nil, fmt.Errorf("no audio data")
+	}
+	return float64(lastGran-preSkip) / sampleRate, frames, nil
+}
+
+// WebM (Matroska) element IDs used when walking a file
+const (
+	ebmlIDSegment       = 0x18538067
+	ebmlIDInfo          = 0x1549A966
+	ebmlIDTimecodeScale = 0x2AD7B1
+	ebmlIDDuration      = 0x4489
+	ebmlIDTracks        = 0x1654AE6B
+	ebmlIDTrackEntry    = 0xAE
+	ebmlIDTrackType     = 0x83
+	ebmlIDCluster       = 0x1F43B675
+	ebmlIDTimecode      = 0xE7
+	ebmlIDBlockGroup    = 0xA0
+	ebmlIDBlock         = 0xA1
+	ebmlIDSimpleBlock   = 0xA3
+
+	matroskaTrackTypeVideo = 1
+	matroskaTrackTypeAudio = 2
+)
+
+// parseWebM walks the elements of a WebM file. Browser recorders write live
+// streams with unknown sizes and no duration, so containers are entered
+// instead of skipped and the duration falls back to the last block time.
+func parseWebM(data []byte) (float64, []audioFrame, error) {
+	var (
+		timecodeScale   = 1000000.0 // nanoseconds per tick
+		duration        float64
+		clusterTimecode int64
+		lastBlock       float64
+		hasAudio        bool
+		frames          []audioFrame
+	)
+
+	for offset := 0; offset < len(data); {
+		id, idLen, err := readEBMLID(data[offset:])
+		if err != nil {
+			return 0, nil, err
+		}
+		size, sizeLen, unknown, err := readEBMLSize(data[offset+idLen:])
+		if err != nil {
+			return 0, nil, err
+		}
+		start := offset + idLen + sizeLen
+
+		switch id {
+		case ebmlIDSegment, ebmlIDInfo, ebmlIDTracks, ebmlIDTrackEntry, ebmlIDCluster, ebmlIDBlockGroup:
+			offset = start
+			continue
+		}
+
+		if unknown || size > uint64(len(data)-start) {
+			return 0, nil, fmt.Errorf("element %x overflows the file", id)
+		}
+		payload := data[start : start+int(size)]
+		offset = start + int(size)
+
+		switch id {
+		case ebmlIDTimecodeScale:
+			timecodeScale = float64(readEBMLUint(payload))
+		case ebmlIDDuration:
+			switch len(payload) {
+			case 4:
+				duration = float64(math.Float32frombits(binary.BigEndian.Uint32(payload)))
+			case 8:
+				duration = math.Float64frombits(binary.BigEndian.Uint64(payload))
+			}
+		case ebmlIDTrackType:
+			switch readEBMLUint(payload) {
+			case matroskaTrackTypeVideo:
+				return 0, nil, fmt.Errorf("video tracks are not allowed")
+			case matroskaTrackTypeAudio:
+				hasAudio = true
+			}
+		case ebmlIDTimecode:
+			clusterTimecode = int64(readEBMLUint(payload))
+		case ebmlIDSimpleBlock, ebmlIDBlock:
+			_, trackLen, _, err := readEBMLSize(payload)
+			if err != nil || len(payload) < trackLen+3 {
+				return 0, nil, fmt.Errorf("bad block")
+			}
+			relative := int64(int16(binary.BigEndian.Uint16(payload[trackLen : trackLen+2])))
+			lastBlock = float64(clusterTimecode+relative) * timecodeScale / 1e9
+			frames = append(frames, audioFrame{time: lastBlock, size: len(payload)})
+		}
+	}
+
+	if !hasAudio {
+		return 0, nil, fmt.Errorf("no audio track")
+	}
+	if duration > 0 {
+		return duration * timecodeScale / 1e9, frames, nil
+	}
+	return lastBlock, frames, nil
+}
+
+// readEBMLID reads an element ID, keeping its length marker bits
+func readEBMLID(data []byte) (uint32, int, error) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, fmt.Errorf("bad element id")
+	}
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 4 || len(data) < length {
+		return 0, 0, fmt.Errorf("bad element id")
+	}
+	var id uint32
+	for _, b := range data[:length] {
+		id = id<<8 | uint32(b)
+	}
+	return id, length, nil
+}
+
+// readEBMLSize reads a variable size integer, reporting the reserved unknown size
+func readEBMLSize(data []byte) (uint64, int, bool, error) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, false, fmt.Errorf("bad element size")
+	}
+	length := 1
+	mask := byte(0x80)
+	for data[0]&mask == 0 {
+		length++
+		mask >>= 1
+	}
+	if len(data) < length {
+		return 0, 0, false, fmt.Errorf("bad element size")
+	}
+	value := uint64(data[0] & (mask - 1))
+	allOnes := data[0]&(mask-1) == mask-1
+	for _, b := range data[1:length] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	return value, length, allOnes, nil
+}
+
+func readEBMLUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// mp4Track holds the boxes of a track needed for timing
+type mp4Track struct {
+	handler    string
+	timescale  uint32
+	duration   uint64
+	sizes      []uint32
+	deltas     []uint32 // per sample, expanded from stts
+	sampleSize uint32   // instead of sizes when every sample has this size
+}
+
+// parseMP4 reads the audio track of an MP4/M4A file from its moov box
+func parseMP4(data []byte) (float64, []audioFrame, error) {
+	var tracks []*mp4Track
+	if err := walkMP4Boxes(data, nil, &tracks); err != nil {
+		return 0, nil, err
+	}
+
+	var audio *mp4Track
+	for _, track := range tracks {
+		switch track.handler {
+		case "vide":
+			return 0, nil, fmt.Errorf("video tracks are not allowed")
+		case "soun":
+			if audio == nil {
+				audio = track
+			}
+		}
+	}
+	if audio == nil {
+		return 0, nil, fmt.Errorf("no audio track")
+	}
+	if audio.timescale == 0 {
+		return 0, nil, fmt.Errorf("invalid timescale")
+	}
+
+	frames := make([]audioFrame, 0, len(audio.sizes))
+	var ticks uint64
+	for i, size := range audio.sizes {
+		frames = append(frames, audioFrame{
+			time: float64(ticks) / float64(audio.timescale),
+			size: int(size),
+		})
+		if i < len(audio.deltas) {
+			ticks += uint64(audio.deltas[i])
+		}
+	}
+	if audio.sampleSize != 0 {
+		// Samples of the same size make a flat waveform, one frame is enough
+		frames = append(frames, audioFrame{size: int(audio.sampleSize)})
+		for _, delta := range audio.deltas {
+			ticks += uint64(delta)
+		}
+	}
+
+	duration := audio.duration
+	if duration == 0 {
+		duration = ticks
+	}
+	return float64(duration) / float64(audio.timescale), frames, nil
+}
+
+func walkMP4Boxes(data []byte, track *mp4Track, tracks *[]*mp4Track) error {
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 8 {
+			return fmt.Errorf("truncated box at offset %d", offset)
+		}
+		size := uint64(binary.BigEndian.Uint32(data[offset : offset+4]))
+		boxType := string(data[offset+4 : offset+8])
+		headerLen := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data) - offset)
+		case 1:
+			if len(data)-offset < 16 {
+				return fmt.Errorf("truncated box at offset %d", offset)
+			}
+			size = binary.BigEndian.Uint64(data[offset+8 : offset+16])
+			headerLen = 16
+		}
+		if size < headerLen || size > uint64(len(data)-offset) {
+			return fmt.Errorf("box %q overflows its parent", boxType)
+		}
+		payload := data[offset+int(headerLen) : offset+int(size)]
+		offset += int(size)
+
+		switch boxType {
+		case "moov", "mdia", "minf", "stbl":
+			if err := walkMP4Boxes(payload, track, tracks); err != nil {
+				return err
+			}
+		case "trak":
+			newTrack := &mp4Track{}
+			*tracks = append(*tracks, newTrack)
+			if err := walkMP4Boxes(payload, newTrack, tracks); err != nil {
+				return err
+			}
+		case "hdlr":
+			if track != nil && len(payload) >= 12 {
+				track.handler = string(payload[8:12])
+			}
+		case "mdhd":
+			if track == nil || len(payload) < 4 {
+				continue
+			}
+			if payload[0] == 1 {
+				if len(payload) < 32 {
+					return fmt.Errorf("truncated mdhd")
+				}
+				track.timescale = binary.BigEndian.Uint32(payload[20:24])
+				track.duration = binary.BigEndian.Uint64(payload[24:32])
+			} else {
+				if len(payload) < 20 {
+					return fmt.Errorf("truncated mdhd")
+				}
+				track.timescale = binary.BigEndian.Uint32(payload[12:16])
+				track.duration = uint64(binary.BigEndian.Uint32(payload[16:20]))
+			}
+		case "stsz":
+			if track == nil {
+				continue
+			}
+			if len(payload) < 12 {
+				return fmt.Errorf("truncated stsz")
+			}
+			sampleSize := binary.BigEndian.Uint32(payload[4:8])
+			count := uint64(binary.BigEndian.Uint32(payload[8:12]))
+			if sampleSize != 0 {
+				track.sampleSize = sampleSize
+				continue
+			}
+			// Sizes are only allocated for the entries the box holds
+			if count > uint64(len(payload)-12)/4 {
+				return fmt.Errorf("truncated stsz")
+			}
+			track.sizes = make([]uint32, count)
+			for i := range track.sizes {
+				track.sizes[i] = binary.BigEndian.Uint32(payload[12+i*4:])
+			}
+		case "stts":
+			if track == nil {
+				continue
+			}
+			if len(payload) < 8 {
+				return fmt.Errorf("truncated stts")
+			}
+			entries := uint64(binary.BigEndian.Uint32(payload[4:8]))
+			if entries*8 > uint64(len(payload)-8) {
+				return fmt.Errorf("truncated stts")
+			}
+			for i := 0; i < int(entries); i++ {
+				count := binary.BigEndian.Uint32(payload[8+i*8:])
+				delta := binary.BigEndian.Uint32(payload[12+i*8:])
+				// Bounded by the file size so a forged count cannot exhaust memory
+				if uint64(len(track.deltas))+uint64(count) > uint64(len(data)) {
+					return fmt.Errorf("invalid stts sample count")
+				}
+				for j := uint32(0); j < count; j++ {
+					track.deltas = append(track.deltas, delta)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// adtsSampleRates maps the ADTS sampling frequency index to Hz
+var adtsSampleRates = []float64{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// parseADTS reads the frame headers of a raw AAC (ADTS) stream
+func parseADTS(data []byte) (float64, []audioFrame, error) {
+	var (
+		samples    float64
+		sampleRate float64
+		frames     []audioFrame
+	)
+
+	for offset := 0; offset < len(data); {
+		header := data[offset:]
+		if len(header) < 7 || header[0] != 0xFF || header[1]&0xF6 != 0xF0 {
+			// Trailing ID3 tags and padding are ignored once frames were found
+			if len(frames) > 0 {
+				break
+			}
+			return 0, nil, fmt.Errorf("bad frame at offset %d", offset)
+		}
+
+		rateIndex := int(header[2]>>2) & 0x0F
+		if rateIndex >= len(adtsSampleRates) {
+			return 0, nil, fmt.Errorf("invalid sample rate")
+		}
+		rate := adtsSampleRates[rateIndex]
+		if sampleRate == 0 {
+			sampleRate = rate
+		}
+
+		frameLen := int(header[3]&0x03)<<11 | int(header[4])<<3 | int(header[5]>>5)
+		if frameLen < 7 || frameLen > len(header) {
+			return 0, nil, fmt.Errorf("bad frame length at offset %d", offset)
+		}
+		blocks := int(header[6]&0x03) + 1
+
+		frames = append(frames, audioFrame{time: samples / sampleRate, size: frameLen})
+		samples += float64(blocks * 1024)
+		offset += frameLen
+	}
+
+	if sampleRate == 0 {
+		return 0, nil, fmt.Errorf("no audio data")
+	}
+	return samples / sampleRate, frames, nil
+}
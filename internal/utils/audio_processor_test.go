@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessAudio(t *testing.T) {
+	tests := []struct {
+		file     string
+		format   string
+		duration float64
+		wantErr  bool
+	}{
+		{file: "voice.ogg", format: AudioFormatOgg, duration: 2},
+		{file: "voice.webm", format: AudioFormatWebM, duration: 1.5},
+		{file: "voice.m4a", format: AudioFormatMP4, duration: 1},
+		{file: "voice.aac", format: AudioFormatAAC, duration: 25 * 1024 / 16000.0},
+		// Samples of one size are not listed, their count is not allocated
+		{file: "constant_stsz.m4a", format: AudioFormatMP4, duration: 1},
+		{file: "truncated_stsz.m4a", wantErr: true},
+		{file: "truncated.ogg", wantErr: true},
+		{file: "video.webm", wantErr: true},
+		{file: "video.m4a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "audio", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := ProcessAudio(data, 16)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ProcessAudio() = %+v, want an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessAudio() error = %v", err)
+			}
+
+			if result.Format != tt.format {
+				t.Errorf("Format = %q, want %q", result.Format, tt.format)
+			}
+			if math.Abs(result.Duration-tt.duration) > 0.001 {
+				t.Errorf("Duration = %v, want %v", result.Duration, tt.duration)
+			}
+			if len(result.Waveform) != 16 {
+				t.Fatalf("waveform has %d bars, want 16", len(result.Waveform))
+			}
+			peak := 0
+			for _, level := range result.Waveform {
+				if level < 0 || level > 100 {
+					t.Errorf("waveform level %d out of 0-100", level)
+				}
+				peak = max(peak, level)
+			}
+			if peak != 100 {
+				t.Errorf("waveform peaks at %d, want 100", peak)
+			}
+		})
+	}
+}
+
+func TestProcessAudioRejectsUnknownFormats(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("RIFF....WAVE"), []byte("ftyp")} {
+		if _, err := ProcessAudio(data, 0); err == nil {
+			t.Errorf("ProcessAudio(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestBuildWaveform(t *testing.T) {
+	frames := []audioFrame{
+		{time: 0, size: 10},
+		{time: 1, size: 30},
+		{time: 2, size: 20},
+	}
+
+	// Empty bars carry the previous one, the quietest bar is 0
+	got := buildWaveform(frames, 3, 6)
+	want := []int{0, 0, 100, 100, 50, 50}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("buildWaveform() = %v, want %v", got, want)
+		}
+	}
+
+	if got := buildWaveform(nil, 1, 0); len(got) != DefaultWaveformSamples {
+		t.Errorf("buildWaveform() has %d bars, want %d by default", len(got), DefaultWaveformSamples)
+	}
+}
@@ -22,6 +22,8 @@ type FileUploadResult struct {
 	URL          string `json:"url"`
 	// Image processing info (optional)
 	ImageInfo    *ImageProcessInfo `json:"image_info,omitempty"`
+	// Audio processing info (optional)
+	AudioInfo    *AudioProcessResult `json:"audio_info,omitempty"`
 }
 
 // ImageProcessInfo contains information about processed image
@@ -39,6 +41,7 @@ type FileUploadConfig struct {
 	MaxFileSize  int64
 	AllowedTypes []string
 	BaseURL      string
+	MaxDuration  time.Duration // Audio and video only
 }
 
 // DefaultAvatarConfig returns default configuration for avatar uploads
@@ -51,6 +54,27 @@ func DefaultAvatarConfig() *FileUploadConfig {
 	}
 }
 
+// DefaultVoiceNoteConfig returns default configuration for voice note uploads
+func DefaultVoiceNoteConfig() *FileUploadConfig {
+	return &FileUploadConfig{
+		UploadDir:    "./uploads/voice",
+		MaxFileSize:  10 * 1024 * 1024, // 10MB
+		AllowedTypes: []string{"audio/ogg", "audio/webm", "audio/mp4", "audio/aac"},
+		BaseURL:      "/uploads/voice",
+		MaxDuration:  15 * time.Minute,
+	}
+}
+
+// DefaultCustomEmojiConfig returns default configuration for custom emoji uploads
+func DefaultCustomEmojiConfig() *FileUploadConfig {
+	return &FileUploadConfig{
+		UploadDir:    "./uploads/emojis",
+		MaxFileSize:  256 * 1024, // 256KB
+		AllowedTypes: []string{"image/png", "image/gif", "image/webp", "image/jpeg"},
+		BaseURL:      "/uploads/emojis",
+	}
+}
+
 // UploadFile uploads a file with given configuration
 func UploadFile(fileHeader *multipart.FileHeader, config *FileUploadConfig) (*FileUploadResult, error) {
 	if fileHeader == nil {
@@ -153,6 +177,69 @@ func UploadImageAsPNG(fileHeader *multipart.FileHeader, config *FileUploadConfig
 	return uploadResult, nil
 }
 
+// UploadVoiceNote validates a voice note, extracts its duration and waveform and stores it.
+// The format is detected from the content, the declared content type is not trusted.
+func UploadVoiceNote(fileHeader *multipart.FileHeader, config *FileUploadConfig) (*FileUploadResult, error) {
+	if fileHeader == nil {
+		return nil, fmt.Errorf("no file provided")
+	}
+
+	// Check file size
+	if fileHeader.Size > config.MaxFileSize {
+		return nil, fmt.Errorf("file size exceeds limit of %d bytes", config.MaxFileSize)
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %v", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, config.MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %v", err)
+	}
+	if int64(len(data)) > config.MaxFileSize {
+		return nil, fmt.Errorf("file size exceeds limit of %d bytes", config.MaxFileSize)
+	}
+
+	audioInfo, err := ProcessAudio(data, DefaultWaveformSamples)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.AllowedTypes) > 0 && !isAllowedType(audioInfo.MimeType, config.AllowedTypes) {
+		return nil, fmt.Errorf("file type %s is not allowed", audioInfo.MimeType)
+	}
+
+	if config.MaxDuration > 0 && audioInfo.Duration > config.MaxDuration.Seconds() {
+		return nil, fmt.Errorf("voice note exceeds the limit of %d seconds", int(config.MaxDuration.Seconds()))
+	}
+
+	// Create upload directory if it doesn't exist
+	if err := os.MkdirAll(config.UploadDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %v", err)
+	}
+
+	// Extension comes from the detected format
+	fileName := fmt.Sprintf("%s_%d.%s", uuid.New().String(), time.Now().Unix(), audioInfo.Format)
+	filePath := filepath.Join(config.UploadDir, fileName)
+
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file: %v", err)
+	}
+
+	return &FileUploadResult{
+		FileName:     fileName,
+		OriginalName: fileHeader.Filename,
+		FilePath:     filePath,
+		FileSize:     int64(len(data)),
+		ContentType:  audioInfo.MimeType,
+		URL:          filepath.Join(config.BaseURL, fileName),
+		AudioInfo:    audioInfo,
+	}, nil
+}
+
 // DeleteFile deletes a file from the upload directory
 func DeleteFile(fileName string, uploadDir string) error {
 	if fileName == "" {
@@ -202,3 +289,17 @@ func ExtractFileNameFromURL(url string) string {
 	}
 	return filepath.Base(url)
 }
+
+// DeleteUploadedFile deletes a file previously served under /uploads by its public URL
+func DeleteUploadedFile(url string) error {
+	if !strings.HasPrefix(url, "/uploads/") {
+		return nil // Not a local upload
+	}
+
+	relPath := filepath.Clean(strings.TrimPrefix(url, "/"))
+	if !strings.HasPrefix(relPath, "uploads"+string(filepath.Separator)) {
+		return fmt.Errorf("invalid upload path: %s", url)
+	}
+
+	return DeleteFile(filepath.Base(relPath), filepath.Join(".", filepath.Dir(relPath)))
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// JSONSchemaBuilder turns Go types into JSON Schema. Named structs go to
+// Defs and are referenced, so recursive models stay finite. Field names come
+// from json tags and constraints from binding tags. Enums lists the values
+// of named types that have a fixed set.
+type JSONSchemaBuilder struct {
+	Defs  map[string]interface{}
+	Enums map[reflect.Type][]string
+}
+
+func NewJSONSchemaBuilder() *JSONSchemaBuilder {
+	return &JSONSchemaBuilder{
+		Defs:  make(map[string]interface{}),
+		Enums: make(map[reflect.Type][]string),
+	}
+}
+
+// Schema returns the schema of a value of the type
+func (b *JSONSchemaBuilder) Schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// Custom JSON, e.g. json.RawMessage or gorm.DeletedAt
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		if values, exists := b.Enums[t]; exists {
+			return map[string]interface{}{"type": "string", "enum": values}
+		}
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.Schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := t.String()
+		if _, exists := b.Defs[name]; !exists {
+			// Placeholder first, the struct may refer to itself
+			b.Defs[name] = map[string]interface{}{}
+			b.Defs[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (b *JSONSchemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	b.addFields(t, properties, &required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (b *JSONSchemaBuilder) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a name are flattened like encoding/json does
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			b.addFields(fieldType, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := b.Schema(field.Type)
+		if isRequired := applyBindingRules(schema, field.Tag.Get("binding")); isRequired {
+			*required = append(*required, name)
+		}
+		if strings.Contains(options, "string") {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		properties[name] = schema
+	}
+}
+
+// applyBindingRules adds the validation rules of a binding tag the schema can
+// express, and tells if the field is required
+func applyBindingRules(schema map[string]interface{}, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	if _, isRef := schema["$ref"]; isRef {
+		return strings.Contains(tag, "required")
+	}
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			schema["enum"] = strings.Fields(value)
+		case "email":
+			schema["format"] = "email"
+		case "url":
+			schema["format"] = "uri"
+		case "min", "max", "gte", "lte", "len":
+			limit, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			applyLimit(schema, key, limit)
+		}
+	}
+	return required
+}
+
+func applyLimit(schema map[string]interface{}, rule string, limit float64) {
+	lower := rule == "min" || rule == "gte" || rule == "len"
+	upper := rule == "max" || rule == "lte" || rule == "len"
+
+	var minKey, maxKey string
+	switch schema["type"] {
+	case "string":
+		minKey, maxKey = "minLength", "maxLength"
+	case "array":
+		minKey, maxKey = "minItems", "maxItems"
+	case "object":
+		minKey, maxKey = "minProperties", "maxProperties"
+	default:
+		minKey, maxKey = "minimum", "maximum"
+	}
+
+	if lower {
+		schema[minKey] = limit
+	}
+	if upper {
+		schema[maxKey] = limit
+	}
+}
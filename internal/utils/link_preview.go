@@ -0,0 +1,442 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	maxPreviewTitleLength       = 300
+	maxPreviewDescriptionLength = 1000
+	MaxPreviewURLLength         = 2048
+)
+
+var (
+	ErrLinkPreviewBlocked     = errors.New("link preview target is not allowed")
+	ErrLinkPreviewUnsupported = errors.New("link preview content type is not supported")
+
+	urlPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+	// Ranges the fetcher must never connect to, on top of the loopback,
+	// private, link-local and multicast checks of net/netip
+	blockedPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		netip.MustParsePrefix("64:ff9b::/96"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+)
+
+// LinkPreviewConfig limits what the link preview fetcher downloads
+type LinkPreviewConfig struct {
+	Timeout      time.Duration
+	MaxBodySize  int64
+	MaxRedirects int
+	UserAgent    string
+
+	// Disables the SSRF protection so previews can be fetched from a local
+	// server, never enable it in production
+	AllowPrivateNetworks bool
+}
+
+// DefaultLinkPreviewConfig returns the default configuration for link previews
+func DefaultLinkPreviewConfig() *LinkPreviewConfig {
+	return &LinkPreviewConfig{
+		Timeout:      5 * time.Second,
+		MaxBodySize:  1 << 20, // 1MB
+		MaxRedirects: 5,
+		UserAgent:    "Mozilla/5.0 (compatible; SocialServerBot/1.0; +link-preview)",
+	}
+}
+
+// LinkPreviewData is the metadata found on a page
+type LinkPreviewData struct {
+	URL          string `json:"url"`
+	CanonicalURL string `json:"canonical_url"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	SiteName     string `json:"site_name"`
+	ImageURL     string `json:"image_url"`
+	Type         string `json:"type"`
+}
+
+// LinkPreviewFetcher fetches pages and reads their Open Graph, Twitter card
+// and oEmbed metadata
+type LinkPreviewFetcher struct {
+	config *LinkPreviewConfig
+	client *http.Client
+}
+
+// NewLinkPreviewFetcher creates a fetcher that refuses to connect to private networks
+func NewLinkPreviewFetcher(config *LinkPreviewConfig) *LinkPreviewFetcher {
+	if config == nil {
+		config = DefaultLinkPreviewConfig()
+	}
+
+	dialer := &net.Dialer{
+		Timeout: config.Timeout,
+	}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = blockPrivateAddresses(ErrLinkPreviewBlocked)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          20,
+		IdleConnTimeout:       30 * time.Second,
+		TLSHandshakeTimeout:   config.Timeout,
+		ResponseHeaderTimeout: config.Timeout,
+	}
+
+	fetcher := &LinkPreviewFetcher{config: config}
+	fetcher.client = &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= config.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", config.MaxRedirects)
+			}
+			return fetcher.checkURL(req.URL)
+		},
+	}
+	return fetcher
+}
+
+// Fetch downloads a page and returns its preview metadata
+func (f *LinkPreviewFetcher) Fetch(ctx context.Context, rawURL string) (*LinkPreviewData, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := f.checkURL(target); err != nil {
+		return nil, err
+	}
+
+	resp, err := f.get(ctx, target.String(), "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	finalURL := resp.Request.URL
+	preview := &LinkPreviewData{
+		URL:          rawURL,
+		CanonicalURL: finalURL.String(),
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		// Direct links to images preview as the image itself
+		preview.Title = path.Base(finalURL.Path)
+		preview.ImageURL = finalURL.String()
+		preview.Type = "image"
+		preview.SiteName = finalURL.Hostname()
+		return preview, nil
+	case mediaType != "text/html" && mediaType != "application/xhtml+xml":
+		return nil, ErrLinkPreviewUnsupported
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.config.MaxBodySize), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+
+	meta := parsePreviewMeta(body)
+
+	// oEmbed only fills in what the page itself does not provide
+	oembed := &oembedData{}
+	if meta.oembedURL != "" && (meta.title() == "" || meta.image() == "") {
+		if oembedURL, err := finalURL.Parse(meta.oembedURL); err == nil && f.checkURL(oembedURL) == nil {
+			if data, err := f.fetchOEmbed(ctx, oembedURL.String()); err == nil {
+				oembed = data
+			}
+		}
+	}
+
+	preview.Title = firstNonEmpty(meta.title(), oembed.Title, meta.documentTitle)
+	preview.Description = firstNonEmpty(meta.values["og:description"], meta.values["twitter:description"], meta.values["description"])
+	preview.SiteName = firstNonEmpty(meta.values["og:site_name"], oembed.ProviderName, meta.values["application-name"], finalURL.Hostname())
+	preview.Type = firstNonEmpty(meta.values["og:type"], oembed.Type)
+
+	if image := firstNonEmpty(meta.image(), oembed.ThumbnailURL); image != "" {
+		preview.ImageURL = resolvePreviewURL(finalURL, image)
+	}
+	if canonical := firstNonEmpty(meta.values["og:url"], meta.canonicalURL); canonical != "" {
+		if resolved := resolvePreviewURL(finalURL, canonical); resolved != "" {
+			preview.CanonicalURL = resolved
+		}
+	}
+
+	preview.Title = truncateText(collapseSpaces(preview.Title), maxPreviewTitleLength)
+	preview.Description = truncateText(collapseSpaces(preview.Description), maxPreviewDescriptionLength)
+	preview.SiteName = truncateText(collapseSpaces(preview.SiteName), maxPreviewTitleLength)
+	return preview, nil
+}
+
+// ExtractFirstURL returns the first http(s) URL found in a text, or an empty string
+func ExtractFirstURL(text string) string {
+	match := urlPattern.FindString(text)
+	if match == "" {
+		return ""
+	}
+
+	// Drop punctuation ending the sentence rather than the URL
+	match = strings.TrimRight(match, ".,;:!?'\"")
+	for strings.HasSuffix(match, ")") && strings.Count(match, ")") > strings.Count(match, "(") {
+		match = strings.TrimSuffix(match, ")")
+	}
+	return match
+}
+
+// NormalizePreviewURL returns the cache key of a URL: lowercase scheme and
+// host, no fragment. Returns an empty string for URLs that cannot be previewed.
+func NormalizePreviewURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ""
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	normalized := u.String()
+	if len(normalized) > MaxPreviewURLLength {
+		return ""
+	}
+	return normalized
+}
+
+func (f *LinkPreviewFetcher) get(ctx context.Context, target, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrLinkPreviewBlocked) {
+			return nil, ErrLinkPreviewBlocked
+		}
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: status %d", target, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// checkURL refuses non http(s) URLs, credentials and unusual ports. Addresses
+// are checked when connecting.
+func (f *LinkPreviewFetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrLinkPreviewBlocked
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return ErrLinkPreviewBlocked
+	}
+	if f.config.AllowPrivateNetworks {
+		return nil
+	}
+
+	switch u.Port() {
+	case "", "80", "443", "8080", "8443":
+	default:
+		return ErrLinkPreviewBlocked
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(u.Hostname(), "[]")); err == nil && isBlockedAddr(addr) {
+		return ErrLinkPreviewBlocked
+	}
+	return nil
+}
+
+type oembedData struct {
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+func (f *LinkPreviewFetcher) fetchOEmbed(ctx context.Context, target string) (*oembedData, error) {
+	resp, err := f.get(ctx, target, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data oembedData
+	if err := json.NewDecoder(io.LimitReader(resp.Body, f.config.MaxBodySize)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid oembed response: %w", err)
+	}
+	return &data, nil
+}
+
+type previewMeta struct {
+	values        map[string]string // First value of each meta property or name, lowercased keys
+	documentTitle string
+	canonicalURL  string
+	oembedURL     string
+}
+
+func (m *previewMeta) title() string {
+	return firstNonEmpty(m.values["og:title"], m.values["twitter:title"])
+}
+
+func (m *previewMeta) image() string {
+	return firstNonEmpty(m.values["og:image:secure_url"], m.values["og:image"], m.values["og:image:url"],
+		m.values["twitter:image"], m.values["twitter:image:src"])
+}
+
+// parsePreviewMeta reads the metadata of the head of a page, it stops at the body
+func parsePreviewMeta(r io.Reader) *previewMeta {
+	meta := &previewMeta{values: map[string]string{}}
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return meta
+		case html.TextToken:
+			if inTitle && meta.documentTitle == "" {
+				meta.documentTitle = string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "title" {
+				inTitle = false
+			}
+			if string(name) == "head" {
+				return meta
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				attrs[strings.ToLower(string(key))] = string(value)
+			}
+
+			switch string(name) {
+			case "body":
+				return meta
+			case "title":
+				inTitle = true
+			case "meta":
+				key := strings.ToLower(firstNonEmpty(attrs["property"], attrs["name"]))
+				if key != "" && attrs["content"] != "" {
+					if _, exists := meta.values[key]; !exists {
+						meta.values[key] = attrs["content"]
+					}
+				}
+			case "link":
+				rel := strings.ToLower(attrs["rel"])
+				switch {
+				case rel == "canonical" && meta.canonicalURL == "":
+					meta.canonicalURL = attrs["href"]
+				case rel == "alternate" && strings.EqualFold(attrs["type"], "application/json+oembed") && meta.oembedURL == "":
+					meta.oembedURL = attrs["href"]
+				}
+			}
+		}
+	}
+}
+
+// blockPrivateAddresses returns a dialer Control refusing private and reserved
+// addresses. Checking the resolved address at connect time also covers
+// redirects and DNS names pointing at internal hosts.
+func blockPrivateAddresses(blockedErr error) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil || isBlockedAddr(addr) {
+			return blockedErr
+		}
+		return nil
+	}
+}
+
+func isBlockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePreviewURL resolves a possibly relative http(s) URL found on a page
+func resolvePreviewURL(base *url.URL, ref string) string {
+	resolved, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return ""
+	}
+	value := resolved.String()
+	if len(value) > MaxPreviewURLLength {
+		return ""
+	}
+	return value
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func collapseSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
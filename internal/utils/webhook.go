@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var ErrWebhookURLBlocked = errors.New("webhook url is not allowed")
+
+// WebhookConfig limits the requests sent to outgoing webhooks
+type WebhookConfig struct {
+	Timeout         time.Duration
+	MaxResponseSize int64
+	UserAgent       string
+
+	// Lets webhooks reach hosts on private networks, e.g. internal tools
+	// deployed next to the server
+	AllowPrivateNetworks bool
+}
+
+// DefaultWebhookConfig returns the default configuration for outgoing webhooks
+func DefaultWebhookConfig() *WebhookConfig {
+	return &WebhookConfig{
+		Timeout:         10 * time.Second,
+		MaxResponseSize: 64 << 10, // 64KB
+		UserAgent:       "SocialServer-Webhook/1.0",
+	}
+}
+
+// WebhookResponse is what an outgoing webhook answered
+type WebhookResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// OK reports whether the webhook accepted the delivery
+func (r *WebhookResponse) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// WebhookSender posts signed JSON payloads to outgoing webhooks
+type WebhookSender struct {
+	config *WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookSender creates a sender, it does not follow redirects
+func NewWebhookSender(config *WebhookConfig) *WebhookSender {
+	if config == nil {
+		config = DefaultWebhookConfig()
+	}
+
+	dialer := &net.Dialer{
+		Timeout: config.Timeout,
+	}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = blockPrivateAddresses(ErrWebhookURLBlocked)
+	}
+
+	return &WebhookSender{
+		config: config,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 nil,
+				DialContext:           dialer.DialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          20,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   config.Timeout,
+				ResponseHeaderTimeout: config.Timeout,
+			},
+			Timeout: config.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// CheckURL validates the URL of an outgoing webhook
+func (s *WebhookSender) CheckURL(rawURL string) error {
+	if len(rawURL) > MaxPreviewURLLength {
+		return ErrWebhookURLBlocked
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrWebhookURLBlocked
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrWebhookURLBlocked
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return ErrWebhookURLBlocked
+	}
+	if s.config.AllowPrivateNetworks {
+		return nil
+	}
+
+	if strings.EqualFold(u.Hostname(), "localhost") {
+		return ErrWebhookURLBlocked
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && isBlockedAddr(addr) {
+		return ErrWebhookURLBlocked
+	}
+	return nil
+}
+
+// Send posts a payload to a webhook. The body is signed with the secret of the
+// webhook, see SignWebhookPayload.
+func (s *WebhookSender) Send(ctx context.Context, target, secret string, deliveryID uint, payload []byte) (*WebhookResponse, error) {
+	if err := s.CheckURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.config.UserAgent)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(deliveryID), 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, timestamp, payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, s.config.MaxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &WebhookResponse{StatusCode: resp.StatusCode, Body: body}, nil
+}
+
+// SignWebhookPayload returns the signature header of a payload: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<payload>" keyed with the webhook secret.
+// Receivers should recompute it and reject old timestamps.
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}